@@ -51,6 +51,9 @@ func run() (err error) {
 	addr := fmt.Sprintf("%s:%d", args.HostName, args.Port)
 	httpSrv := &http.Server{Addr: addr, Handler: apiSrv}
 
+	apiSrv.StartJobs()
+	defer apiSrv.StopJobs()
+
 	go func() {
 		fmt.Printf("%s Starting server ...\n", emoji.EmojiTagToUnicode(`:rocket:`))
 		fmt.Printf("%s Version: '%s-%s'\n", emoji.EmojiTagToUnicode(`:bookmark:`), Version, Build)
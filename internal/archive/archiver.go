@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"fmt"
+
+	"github.com/bihe/bookmarks/internal"
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/bihe/bookmarks/internal/store"
+)
+
+// DefaultMaxVersions is the number of archived versions kept for a bookmark
+const DefaultMaxVersions = 10
+
+// Archiver creates snapshots of bookmarked pages and keeps a limited number of versions
+type Archiver struct {
+	Fetcher    fetch.Fetcher
+	Repository store.Repository
+	Store      *Store
+	// MaxVersions per bookmark, the oldest versions are removed. DefaultMaxVersions is used if not set
+	MaxVersions int
+	Limits      Limits
+}
+
+// Archive creates a snapshot of the page of the bookmark. If the page did not change since the most
+// recent version, no new version is created and the existing archive is returned
+func (a *Archiver) Archive(bm store.Bookmark) (archive store.Archive, created bool, err error) {
+	if bm.Type != store.Node || bm.URL == "" {
+		return store.Archive{}, false, fmt.Errorf("only bookmarks with an URL can be archived")
+	}
+	content, err := Snapshot(a.Fetcher, bm.URL, a.Limits)
+	if err != nil {
+		return store.Archive{}, false, fmt.Errorf("could not create snapshot of '%s': %v", bm.URL, err)
+	}
+	name, err := a.Store.Save(content)
+	if err != nil {
+		return store.Archive{}, false, err
+	}
+
+	maxVersions := a.MaxVersions
+	if maxVersions <= 0 {
+		maxVersions = DefaultMaxVersions
+	}
+
+	var pruned []string
+	err = a.Repository.InUnitOfWork(func(repo store.Repository) error {
+		if latest, err := repo.GetArchive(bm.ID, 0); err == nil && latest.FileName == name && latest.URL == bm.URL {
+			archive = latest
+			return nil
+		}
+		archive, err = repo.CreateArchive(store.Archive{
+			BookmarkID: bm.ID,
+			UserName:   bm.UserName,
+			URL:        bm.URL,
+			FileName:   name,
+			Size:       int64(len(content)),
+		})
+		if err != nil {
+			return err
+		}
+		created = true
+
+		archives, err := repo.GetArchives(bm.ID)
+		if err != nil {
+			return err
+		}
+		for i := maxVersions; i < len(archives); i++ {
+			if err := repo.DeleteArchive(archives[i].ID); err != nil {
+				return err
+			}
+			pruned = append(pruned, archives[i].FileName)
+		}
+		return nil
+	})
+	if err != nil {
+		return store.Archive{}, false, err
+	}
+
+	// the files of removed versions are released after the transaction, left-overs are removed by the garbage collection
+	for _, name := range pruned {
+		if err := a.Store.Release(name, a.Repository); err != nil {
+			internal.LogFunction("archive.Archive").Warnf("could not release snapshot: %v", err)
+		}
+	}
+	return archive, created, nil
+}
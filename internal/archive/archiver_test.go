@@ -0,0 +1,135 @@
+package archive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// archiveRepository keeps the archives in memory
+type archiveRepository struct {
+	store.Repository
+	archives []store.Archive
+}
+
+func (r *archiveRepository) InUnitOfWork(fn func(repo store.Repository) error) error {
+	return fn(r)
+}
+
+func (r *archiveRepository) CreateArchive(a store.Archive) (store.Archive, error) {
+	a.ID = uint(len(r.archives) + 1)
+	a.Version = 1
+	for _, e := range r.archives {
+		if e.BookmarkID == a.BookmarkID && e.Version >= a.Version {
+			a.Version = e.Version + 1
+		}
+	}
+	r.archives = append(r.archives, a)
+	return a, nil
+}
+
+func (r *archiveRepository) GetArchives(bookmarkID string) ([]store.Archive, error) {
+	var archives []store.Archive
+	for _, a := range r.archives {
+		if a.BookmarkID == bookmarkID {
+			archives = append(archives, a)
+		}
+	}
+	sort.Slice(archives, func(i, j int) bool { return archives[i].Version > archives[j].Version })
+	return archives, nil
+}
+
+func (r *archiveRepository) GetArchive(bookmarkID string, version int) (store.Archive, error) {
+	archives, _ := r.GetArchives(bookmarkID)
+	for _, a := range archives {
+		if version <= 0 || a.Version == version {
+			return a, nil
+		}
+	}
+	return store.Archive{}, os.ErrNotExist
+}
+
+func (r *archiveRepository) DeleteArchive(id uint) error {
+	for i, a := range r.archives {
+		if a.ID == id {
+			r.archives = append(r.archives[:i], r.archives[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (r *archiveRepository) GetArchiveReferences(fileName string) (int, error) {
+	count := 0
+	for _, a := range r.archives {
+		if a.FileName == fileName {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func TestArchive(t *testing.T) {
+	content := "version 1"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>" + content + "</body></html>"))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client, err := fetch.New(fetch.Options{AllowPrivateNetworks: true})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	s, cleanup := tempStore(t)
+	defer cleanup()
+	repo := &archiveRepository{}
+	archiver := &Archiver{Fetcher: client, Repository: repo, Store: s, MaxVersions: 2}
+
+	bm := store.Bookmark{ID: "id", Type: store.Node, URL: ts.URL + "/page", UserName: "username"}
+
+	_, _, err = archiver.Archive(store.Bookmark{ID: "folder", Type: store.Folder})
+	assert.Error(t, err)
+	_, _, err = archiver.Archive(store.Bookmark{ID: "missing", Type: store.Node, URL: ts.URL + "/missing"})
+	assert.Error(t, err)
+
+	first, created, err := archiver.Archive(bm)
+	if err != nil {
+		t.Fatalf("could not archive bookmark: %v", err)
+	}
+	assert.True(t, created)
+	assert.Equal(t, 1, first.Version)
+	assert.Equal(t, bm.URL, first.URL)
+	assert.Equal(t, "username", first.UserName)
+	file, _ := s.File(first.FileName)
+	_, err = os.Stat(file)
+	assert.NoError(t, err)
+
+	// an unchanged page does not create a new version
+	same, created, _ := archiver.Archive(bm)
+	assert.False(t, created)
+	assert.Equal(t, first.Version, same.Version)
+
+	content = "version 2"
+	second, created, _ := archiver.Archive(bm)
+	assert.True(t, created)
+	assert.Equal(t, 2, second.Version)
+	content = "version 3"
+	third, _, _ := archiver.Archive(bm)
+	assert.Equal(t, 3, third.Version)
+
+	// the oldest version is removed
+	archives, _ := repo.GetArchives(bm.ID)
+	assert.Equal(t, 2, len(archives))
+	assert.Equal(t, 2, archives[1].Version)
+	_, err = os.Stat(file)
+	assert.True(t, os.IsNotExist(err))
+}
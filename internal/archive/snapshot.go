@@ -0,0 +1,349 @@
+// Package archive creates self-contained snapshots of bookmarked pages and keeps their versions
+package archive
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/bihe/bookmarks/internal/metadata"
+)
+
+const (
+	// DefaultMaxResources limits the number of stylesheets and images embedded into a snapshot
+	DefaultMaxResources = 100
+	// DefaultMaxSize limits the total size in bytes of the resources embedded into a snapshot
+	DefaultMaxSize = 20 * 1024 * 1024
+	// maxImportDepth limits the nesting of stylesheets imported by @import
+	maxImportDepth = 3
+)
+
+// Limits restricts the resources which are embedded into a snapshot
+type Limits struct {
+	MaxResources int
+	MaxSize      int64
+}
+
+// elements which execute scripts, embed other documents or navigate automatically
+var removedElements = "script, noscript, iframe, frame, frameset, object, embed, applet, portal, template, base, " +
+	"meta[http-equiv], meta[charset], link[rel~=preload], link[rel~=prefetch], link[rel~=modulepreload], " +
+	"link[rel~=manifest], link[rel~=import], link[rel~=dns-prefetch], link[rel~=preconnect], picture source[srcset]"
+
+var (
+	cssURL    = regexp.MustCompile(`(?i)url\(\s*(?:'([^']*)'|"([^"]*)"|([^'")\s]*))\s*\)`)
+	cssImport = regexp.MustCompile(`(?i)@import\s+(?:url\(\s*)?(?:'([^']*)'|"([^"]*)"|([^'")\s;]*))\s*\)?[^;]*;`)
+)
+
+// Snapshot fetches the page and creates a self-contained HTML document. Stylesheets and images of the
+// same origin are embedded as data URIs, scripts and embedded documents are removed. Links are
+// rewritten to absolute URLs
+func Snapshot(client fetch.Fetcher, uri string, limits Limits) ([]byte, error) {
+	if limits.MaxResources <= 0 {
+		limits.MaxResources = DefaultMaxResources
+	}
+	if limits.MaxSize <= 0 {
+		limits.MaxSize = DefaultMaxSize
+	}
+
+	pageURL, err := url.Parse(uri)
+	if err != nil || (pageURL.Scheme != "http" && pageURL.Scheme != "https") {
+		return nil, fmt.Errorf("the URL '%s' is not supported", uri)
+	}
+	resp, err := client.Get(pageURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch page: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got status %d", resp.StatusCode)
+	}
+	if !resp.IsType(metadata.PageTypes...) {
+		return nil, fmt.Errorf("the content-type '%s' is not supported", resp.ContentType)
+	}
+	if final, err := url.Parse(resp.URL); err == nil && final.Host != "" {
+		pageURL = final
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse page: %v", err)
+	}
+
+	s := &snapshot{
+		client:    client,
+		origin:    pageURL,
+		base:      pageURL,
+		limits:    limits,
+		resources: make(map[string]resource),
+	}
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if u, err := pageURL.Parse(strings.TrimSpace(href)); err == nil {
+			s.base = u
+		}
+	}
+	s.process(doc)
+
+	content, err := doc.Html()
+	if err != nil {
+		return nil, fmt.Errorf("could not render snapshot: %v", err)
+	}
+	return []byte(content), nil
+}
+
+type snapshot struct {
+	client fetch.Fetcher
+	origin *url.URL
+	base   *url.URL
+	limits Limits
+
+	count int
+	size  int64
+	// resources caches the fetched resources by URL
+	resources map[string]resource
+}
+
+func (s *snapshot) process(doc *goquery.Document) {
+	doc.Find(removedElements).Remove()
+
+	doc.Find("*").Each(func(i int, sel *goquery.Selection) {
+		n := sel.Get(0)
+		attrs := n.Attr[:0]
+		for _, a := range n.Attr {
+			key := strings.ToLower(a.Key)
+			if strings.HasPrefix(key, "on") || unsafeURL(a.Val) {
+				continue
+			}
+			attrs = append(attrs, a)
+		}
+		n.Attr = attrs
+	})
+
+	doc.Find("link[href]").Each(func(i int, sel *goquery.Selection) {
+		rel := strings.ToLower(sel.AttrOr("rel", ""))
+		href := sel.AttrOr("href", "")
+		switch {
+		case containsToken(rel, "stylesheet"):
+			u, err := s.base.Parse(strings.TrimSpace(href))
+			if err != nil || !s.sameOrigin(u) {
+				sel.Remove()
+				return
+			}
+			css, err := s.stylesheet(u, 0)
+			if err != nil {
+				sel.Remove()
+				return
+			}
+			style := "<style"
+			if media, ok := sel.Attr("media"); ok {
+				style += ` media="` + escapeAttr(media) + `"`
+			}
+			sel.ReplaceWithHtml(style + ">" + escapeStyle(css) + "</style>")
+		case containsToken(rel, "icon"):
+			sel.SetAttr("href", s.embed(s.base, href, false))
+		default:
+			sel.SetAttr("href", s.absolute(s.base, href))
+		}
+	})
+
+	doc.Find("style").Each(func(i int, sel *goquery.Selection) {
+		sel.SetText(s.rewriteCSS(sel.Text(), s.base, 0))
+	})
+	doc.Find("[style]").Each(func(i int, sel *goquery.Selection) {
+		sel.SetAttr("style", s.rewriteCSS(sel.AttrOr("style", ""), s.base, 0))
+	})
+
+	doc.Find("img[src]").Each(func(i int, sel *goquery.Selection) {
+		sel.SetAttr("src", s.embed(s.base, sel.AttrOr("src", ""), false))
+		sel.RemoveAttr("srcset")
+		sel.RemoveAttr("sizes")
+		sel.RemoveAttr("loading")
+	})
+	doc.Find("a[href], area[href]").Each(func(i int, sel *goquery.Selection) {
+		sel.SetAttr("href", s.absolute(s.base, sel.AttrOr("href", "")))
+	})
+	doc.Find("form[action]").Each(func(i int, sel *goquery.Selection) {
+		sel.SetAttr("action", s.absolute(s.base, sel.AttrOr("action", "")))
+	})
+	for _, attr := range []string{"src", "poster"} {
+		doc.Find("video[" + attr + "], audio[" + attr + "], source[" + attr + "], track[" + attr + "]").Each(func(i int, sel *goquery.Selection) {
+			sel.SetAttr(attr, s.absolute(s.base, sel.AttrOr(attr, "")))
+		})
+	}
+
+	// the content is serialized as UTF-8, the original encoding declarations were removed
+	head := doc.Find("head").First()
+	head.PrependHtml(`<meta charset="utf-8">`)
+}
+
+// stylesheet fetches the CSS and embeds the referenced resources
+func (s *snapshot) stylesheet(u *url.URL, depth int) (string, error) {
+	payload, _, err := s.fetch(u, "text/css")
+	if err != nil {
+		return "", err
+	}
+	return s.rewriteCSS(string(payload), u, depth), nil
+}
+
+// rewriteCSS inlines imported stylesheets of the same origin and embeds the referenced resources
+func (s *snapshot) rewriteCSS(css string, base *url.URL, depth int) string {
+	css = cssImport.ReplaceAllStringFunc(css, func(m string) string {
+		ref := firstGroup(cssImport.FindStringSubmatch(m))
+		u, err := base.Parse(strings.TrimSpace(ref))
+		if err != nil || !s.sameOrigin(u) || depth >= maxImportDepth {
+			return ""
+		}
+		imported, err := s.stylesheet(u, depth+1)
+		if err != nil {
+			return ""
+		}
+		return imported
+	})
+	return cssURL.ReplaceAllStringFunc(css, func(m string) string {
+		ref := firstGroup(cssURL.FindStringSubmatch(m))
+		if strings.HasPrefix(strings.TrimSpace(ref), "#") {
+			return m
+		}
+		return `url("` + s.embed(base, ref, true) + `")`
+	})
+}
+
+// embed returns the resource as data URI if it is available from the same origin, otherwise the
+// absolute URL is returned
+func (s *snapshot) embed(base *url.URL, ref string, fonts bool) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(strings.ToLower(ref), "data:") {
+		return ref
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if !s.sameOrigin(u) {
+		return s.absolute(base, ref)
+	}
+	types := []string{"image/"}
+	if fonts {
+		types = append(types, "font/", "application/font-", "application/x-font-", "application/vnd.ms-fontobject", "application/octet-stream")
+	}
+	payload, contentType, err := s.fetch(u, types...)
+	if err != nil {
+		return u.String()
+	}
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(payload)
+}
+
+// fetch retrieves a resource within the limits of the snapshot, resources referenced more than once
+// are only requested once
+func (s *snapshot) fetch(u *url.URL, types ...string) ([]byte, string, error) {
+	key := u.String()
+	r, ok := s.resources[key]
+	if !ok {
+		r = s.request(key)
+		s.resources[key] = r
+	}
+	if r.err != nil {
+		return nil, "", r.err
+	}
+	if !r.isType(types...) {
+		return nil, "", fmt.Errorf("the content-type '%s' is not supported", r.contentType)
+	}
+	return r.payload, r.contentType, nil
+}
+
+func (s *snapshot) request(uri string) resource {
+	if s.count >= s.limits.MaxResources {
+		return resource{err: fmt.Errorf("the maximum number of %d resources is reached", s.limits.MaxResources)}
+	}
+	s.count++
+
+	resp, err := s.client.Get(uri)
+	if err != nil {
+		return resource{err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resource{err: fmt.Errorf("got status %d", resp.StatusCode)}
+	}
+	if s.size+int64(len(resp.Body)) > s.limits.MaxSize {
+		return resource{err: fmt.Errorf("the maximum size of %d bytes is reached", s.limits.MaxSize)}
+	}
+	s.size += int64(len(resp.Body))
+
+	contentType, _, err := mime.ParseMediaType(resp.ContentType)
+	if err != nil {
+		contentType, _, _ = mime.ParseMediaType(http.DetectContentType(resp.Body))
+	}
+	return resource{payload: resp.Body, contentType: strings.ToLower(contentType)}
+}
+
+// resource is a fetched stylesheet, image or font
+type resource struct {
+	payload     []byte
+	contentType string
+	err         error
+}
+
+func (r resource) isType(types ...string) bool {
+	for _, t := range types {
+		if strings.HasPrefix(r.contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *snapshot) sameOrigin(u *url.URL) bool {
+	return strings.EqualFold(u.Scheme, s.origin.Scheme) && strings.EqualFold(u.Host, s.origin.Host)
+}
+
+// absolute resolves the reference against the base URL, fragments of the document are kept
+func (s *snapshot) absolute(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return ref
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
+
+// unsafeURL detects attribute values which execute scripts
+func unsafeURL(value string) bool {
+	v := strings.ToLower(strings.Join(strings.Fields(value), ""))
+	return strings.HasPrefix(v, "javascript:") || strings.HasPrefix(v, "vbscript:") || strings.HasPrefix(v, "data:text/html")
+}
+
+func firstGroup(groups []string) string {
+	for _, g := range groups[1:] {
+		if g != "" {
+			return g
+		}
+	}
+	return ""
+}
+
+func containsToken(value, token string) bool {
+	for _, t := range strings.Fields(value) {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeStyle prevents the content of a stylesheet from closing the style element
+func escapeStyle(css string) string {
+	return strings.Replace(css, "</", `<\/`, -1)
+}
+
+func escapeAttr(value string) string {
+	r := strings.NewReplacer(`&`, "&amp;", `"`, "&quot;", `<`, "&lt;", `>`, "&gt;")
+	return r.Replace(value)
+}
@@ -0,0 +1,147 @@
+package archive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/stretchr/testify/assert"
+)
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="iso-8859-1">
+<meta http-equiv="refresh" content="0; url=http://other.example/">
+<base href="/site/">
+<title>Page</title>
+<link rel="stylesheet" href="style.css" media="screen">
+<link rel="stylesheet" href="http://other.example/remote.css">
+<link rel="icon" href="/favicon.png">
+<script src="app.js"></script>
+<script>alert("x")</script>
+<style>body { background: url('/bg.png'); }</style>
+</head>
+<body onload="init()">
+<a href="other.html" onclick="track()">Other</a>
+<a href="javascript:alert(1)">Script</a>
+<a href="#top">Top</a>
+<img src="/logo.png" srcset="/logo-2x.png 2x">
+<img src="http://other.example/remote.png">
+<img src="/missing.png">
+<div style="background-image: url(/bg.png)">Content</div>
+<iframe src="http://other.example/frame"></iframe>
+<object data="/plugin.swf"></object>
+</body>
+</html>`
+
+func snapshotServer(requests map[string]int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/site/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	})
+	mux.HandleFunc("/site/style.css", func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		w.Header().Set("Content-Type", "text/css")
+		w.Write([]byte(`@import "base.css"; h1 { background: url(img/h1.png) } </style><img src=x>`))
+	})
+	mux.HandleFunc("/site/base.css", func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		w.Header().Set("Content-Type", "text/css")
+		w.Write([]byte(`@font-face { src: url("/font.woff2") } p { color: red }`))
+	})
+	for _, p := range []string{"/logo.png", "/bg.png", "/favicon.png", "/site/img/h1.png"} {
+		mux.HandleFunc(p, func(w http.ResponseWriter, r *http.Request) {
+			requests[r.URL.Path]++
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png"))
+		})
+	}
+	mux.HandleFunc("/font.woff2", func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		w.Header().Set("Content-Type", "font/woff2")
+		w.Write([]byte("font"))
+	})
+	mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("text"))
+	})
+	mux.HandleFunc("/missing.png", func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		w.WriteHeader(http.StatusNotFound)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestSnapshot(t *testing.T) {
+	requests := make(map[string]int)
+	ts := snapshotServer(requests)
+	defer ts.Close()
+
+	client, err := fetch.New(fetch.Options{AllowPrivateNetworks: true})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
+	content, err := Snapshot(client, ts.URL+"/site/page", Limits{})
+	if err != nil {
+		t.Fatalf("could not create snapshot: %v", err)
+	}
+	html := string(content)
+
+	// active content is removed
+	assert.NotContains(t, html, "<script")
+	assert.NotContains(t, html, "alert")
+	assert.NotContains(t, html, "onload")
+	assert.NotContains(t, html, "onclick")
+	assert.NotContains(t, html, "<iframe")
+	assert.NotContains(t, html, "<object")
+	assert.NotContains(t, html, "refresh")
+	assert.NotContains(t, html, "<base")
+	assert.NotContains(t, html, "iso-8859-1")
+	assert.Contains(t, html, `<meta charset="utf-8"/>`)
+
+	// same-origin resources are embedded
+	png := "data:image/png;base64,cG5n"
+	assert.Contains(t, html, `<img src="`+png+`"/>`)
+	assert.Contains(t, html, `<link rel="icon" href="`+png+`"/>`)
+	assert.Contains(t, html, `body { background: url("`+png+`"); }`)
+	assert.Contains(t, html, `style="background-image: url(&#34;`+png+`&#34;)"`)
+	assert.Contains(t, html, `<style media="screen">`)
+	assert.Contains(t, html, `url("data:font/woff2;base64,Zm9udA==")`)
+	assert.Contains(t, html, "p { color: red }")
+	assert.Contains(t, html, `h1 { background: url("`+png+`") }`)
+	assert.Contains(t, html, `<\/style><img src=x>`)
+	assert.NotContains(t, html, "srcset")
+	assert.NotContains(t, html, "remote.css")
+
+	// other resources and links use absolute URLs
+	assert.Contains(t, html, `<img src="http://other.example/remote.png"/>`)
+	assert.Contains(t, html, `<img src="`+ts.URL+`/missing.png"/>`)
+	assert.Contains(t, html, `<a href="`+ts.URL+`/site/other.html">Other</a>`)
+	assert.Contains(t, html, `<a>Script</a>`)
+	assert.Contains(t, html, `<a href="#top">Top</a>`)
+
+	// resources referenced more than once are requested once
+	assert.Equal(t, 1, requests["/bg.png"])
+	assert.Equal(t, 1, requests["/site/base.css"])
+
+	// the number of resources is limited
+	content, err = Snapshot(client, ts.URL+"/site/page", Limits{MaxResources: 1})
+	if err != nil {
+		t.Fatalf("could not create snapshot: %v", err)
+	}
+	html = string(content)
+	assert.NotContains(t, html, "data:")
+	assert.NotContains(t, html, "p { color: red }")
+	assert.Contains(t, html, `h1 { background: url("`+ts.URL+`/site/img/h1.png") }`)
+
+	_, err = Snapshot(client, ts.URL+"/text", Limits{})
+	assert.Error(t, err)
+	_, err = Snapshot(client, ts.URL+"/missing.png", Limits{})
+	assert.Error(t, err)
+	_, err = Snapshot(client, "ftp://example.com", Limits{})
+	assert.Error(t, err)
+}
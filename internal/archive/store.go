@@ -0,0 +1,92 @@
+package archive
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bihe/bookmarks/internal"
+	"github.com/bihe/bookmarks/internal/filestore"
+	"github.com/bihe/bookmarks/internal/store"
+)
+
+// DefaultPath is the directory of the snapshots relative to the base-path if no path is configured
+const DefaultPath = "archive"
+
+// DefaultGracePeriod protects recently written files from removal. A snapshot is written before the
+// archive referencing it is saved, therefore young files might not be referenced yet
+const DefaultGracePeriod = 1 * time.Hour
+
+// archive files are named by the hash of the content, only those files are touched by the garbage collection
+var archiveName = regexp.MustCompile(`^[0-9a-f]{40}\.html$`)
+
+// Store persists snapshots within a directory. The files are named by the hash of the content,
+// therefore identical snapshots are stored only once
+type Store struct {
+	filestore.Store
+}
+
+// NewStore creates a Store for the given directory
+func NewStore(path string) *Store {
+	return &Store{filestore.Store{
+		Path:        path,
+		GracePeriod: DefaultGracePeriod,
+		Kind:        "snapshot",
+		ValidName:   ValidName,
+		TempPrefix:  ".archive-",
+	}}
+}
+
+// Save writes the snapshot to the store, the name of the file is returned
+func (s *Store) Save(content []byte) (string, error) {
+	if len(content) == 0 {
+		return "", fmt.Errorf("the snapshot is empty")
+	}
+	name := fmt.Sprintf("%x.html", sha1.Sum(content))
+	if err := s.Write(name, content); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// ValidName checks if the name is a content-addressed snapshot file of the store
+func ValidName(name string) bool {
+	return archiveName.MatchString(name)
+}
+
+// Hash returns the content-hash of the snapshot name without the file extension
+func Hash(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// Release removes the snapshot if it is no longer referenced by any archive
+func (s *Store) Release(name string, repo store.Repository) error {
+	return s.Store.Release(name, repo.GetArchiveReferences)
+}
+
+// GarbageCollector is a job which removes the snapshots no longer referenced by any archive
+type GarbageCollector struct {
+	Store      *Store
+	Repository store.Repository
+}
+
+// Name of the job
+func (g *GarbageCollector) Name() string {
+	return "archive-gc"
+}
+
+// Run performs the garbage collection
+func (g *GarbageCollector) Run() error {
+	referenced, err := g.Repository.GetAllArchiveFiles()
+	if err != nil {
+		return fmt.Errorf("could not get the referenced snapshots: %v", err)
+	}
+	removed, err := g.Store.Collect(referenced)
+	if len(removed) > 0 {
+		internal.LogFunction("archive.GarbageCollector").Infof("removed %d unreferenced snapshots", len(removed))
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package archive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// referenceRepository provides the snapshot references for the store
+type referenceRepository struct {
+	store.Repository
+	references map[string]int
+}
+
+func (r referenceRepository) GetArchiveReferences(fileName string) (int, error) {
+	return r.references[fileName], nil
+}
+
+func (r referenceRepository) GetAllArchiveFiles() ([]string, error) {
+	var files []string
+	for f, n := range r.references {
+		if n > 0 {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+func tempStore(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	s := NewStore(filepath.Join(dir, "snapshots"))
+	s.GracePeriod = 0
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func TestStoreSave(t *testing.T) {
+	s, cleanup := tempStore(t)
+	defer cleanup()
+
+	_, err := s.Save(nil)
+	assert.Error(t, err)
+
+	name, err := s.Save([]byte("<html></html>"))
+	if err != nil {
+		t.Fatalf("could not save snapshot: %v", err)
+	}
+	assert.True(t, ValidName(name))
+	assert.Equal(t, 40, len(Hash(name)))
+
+	// identical snapshots are stored only once
+	other, _ := s.Save([]byte("<html></html>"))
+	assert.Equal(t, name, other)
+
+	file, err := s.File(name)
+	if err != nil {
+		t.Fatalf("could not get snapshot file: %v", err)
+	}
+	content, _ := ioutil.ReadFile(file)
+	assert.Equal(t, "<html></html>", string(content))
+
+	_, err = s.File("../secret.html")
+	assert.Error(t, err)
+}
+
+func TestStoreCollect(t *testing.T) {
+	s, cleanup := tempStore(t)
+	defer cleanup()
+
+	// nothing was archived yet
+	removed, err := s.Collect(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(removed))
+
+	used, _ := s.Save([]byte("used"))
+	unused, _ := s.Save([]byte("unused"))
+	released, _ := s.Save([]byte("released"))
+	ioutil.WriteFile(filepath.Join(s.Path, "other.txt"), []byte("other"), 0644)
+
+	repo := referenceRepository{references: map[string]int{used: 1, released: 1}}
+	gc := &GarbageCollector{Store: s, Repository: repo}
+	assert.Equal(t, "archive-gc", gc.Name())
+	assert.NoError(t, gc.Run())
+
+	for name, exists := range map[string]bool{used: true, unused: false, released: true, "other.txt": true} {
+		_, err := os.Stat(filepath.Join(s.Path, name))
+		assert.Equal(t, exists, err == nil, name)
+	}
+
+	assert.NoError(t, s.Release(released, repo))
+	_, err = os.Stat(filepath.Join(s.Path, released))
+	assert.NoError(t, err)
+	repo.references[released] = 0
+	assert.NoError(t, s.Release(released, repo))
+	_, err = os.Stat(filepath.Join(s.Path, released))
+	assert.True(t, os.IsNotExist(err))
+
+	// young files are protected
+	s.GracePeriod = DefaultGracePeriod
+	removed, _ = s.Collect(nil)
+	assert.Equal(t, 0, len(removed))
+}
@@ -19,6 +19,13 @@ type AppConfig struct {
 	Environment    string             `yaml:"environment"`
 	FaviconPath    string             `yaml:"faviconUploadPath"`
 	DefaultFavicon string             `yaml:"defaultFavicon"`
+	Fetcher        FetcherSettings    `yaml:"fetcher"`
+	Jobs           JobSettings        `yaml:"jobs"`
+	LinkCheck      LinkCheckSettings  `yaml:"linkCheck"`
+	Archive        ArchiveSettings    `yaml:"archive"`
+	Visits         VisitSettings      `yaml:"visits"`
+	Events         EventSettings      `yaml:"events"`
+	Webhooks       WebhookSettings    `yaml:"webhooks"`
 }
 
 // Security settings for the application
@@ -68,6 +75,67 @@ type CorsSettings struct {
 	MaxAge           int      `yaml:"maxAge"`
 }
 
+// FetcherSettings configures the HTTP client used to fetch external pages and favicons
+type FetcherSettings struct {
+	Timeout              string   `yaml:"timeout"`
+	MaxBodySize          int64    `yaml:"maxBodySize"`
+	UserAgent            string   `yaml:"userAgent"`
+	MaxRedirects         int      `yaml:"maxRedirects"`
+	Proxy                string   `yaml:"proxy"`
+	AllowPrivateNetworks bool     `yaml:"allowPrivateNetworks"`
+	DenyNetworks         []string `yaml:"denyNetworks"`
+}
+
+// JobSettings defines the intervals of the background jobs, an empty interval disables the job
+type JobSettings struct {
+	FaviconGC       string `yaml:"faviconGC"`
+	LinkHealth      string `yaml:"linkHealth"`
+	ArchiveGC       string `yaml:"archiveGC"`
+	ContentIndex    string `yaml:"contentIndex"`
+	VisitRetention  string `yaml:"visitRetention"`
+	ReadLaterExpiry string `yaml:"readLaterExpiry"`
+	Webhooks        string `yaml:"webhooks"`
+}
+
+// LinkCheckSettings configures the checks of the bookmark URLs
+type LinkCheckSettings struct {
+	RecheckAfter     string `yaml:"recheckAfter"`
+	HostDelay        string `yaml:"hostDelay"`
+	BatchSize        int    `yaml:"batchSize"`
+	Workers          int    `yaml:"workers"`
+	HistoryRetention string `yaml:"historyRetention"`
+}
+
+// ArchiveSettings configures the offline snapshots of bookmarked pages
+type ArchiveSettings struct {
+	Path         string `yaml:"path"`
+	OnCreate     bool   `yaml:"onCreate"`
+	MaxVersions  int    `yaml:"maxVersions"`
+	MaxResources int    `yaml:"maxResources"`
+	MaxSize      int64  `yaml:"maxSize"`
+}
+
+// VisitSettings configures the history of bookmark visits
+type VisitSettings struct {
+	// Retention is the period the visits are kept, an empty value keeps the visits forever
+	Retention string `yaml:"retention"`
+}
+
+// EventSettings configures the stream of bookmark changes
+type EventSettings struct {
+	// BufferSize is the number of events kept for clients resuming the stream
+	BufferSize int `yaml:"bufferSize"`
+}
+
+// WebhookSettings configures the delivery of the webhooks
+type WebhookSettings struct {
+	// MaxAttempts is the number of attempts before a delivery fails
+	MaxAttempts int `yaml:"maxAttempts"`
+	BatchSize   int `yaml:"batchSize"`
+	// Retention is the period the completed deliveries are kept in the delivery log
+	Retention string `yaml:"retention"`
+}
+
 // GetSettings returns application configuration values
 func GetSettings(r io.Reader) (*AppConfig, error) {
 	var (
@@ -52,6 +52,50 @@ errorPath: error
 environment: Development
 faviconUploadPath: "./faviconpath"
 defaultFavicon: "./favicon.ico"
+
+fetcher:
+  timeout: 5s
+  maxBodySize: 1048576
+  userAgent: bookmarks
+  maxRedirects: 3
+  proxy: http://proxy:3128
+  allowPrivateNetworks: false
+  denyNetworks:
+  - "203.0.113.0/24"
+
+jobs:
+  faviconGC: 24h
+  linkHealth: 1h
+  archiveGC: 24h
+  contentIndex: 30m
+  visitRetention: 12h
+  readLaterExpiry: 2h
+  webhooks: 1m
+
+linkCheck:
+  recheckAfter: 168h
+  hostDelay: 2s
+  batchSize: 500
+  workers: 4
+  historyRetention: 2160h
+
+archive:
+  path: ./archive
+  onCreate: true
+  maxVersions: 5
+  maxResources: 50
+  maxSize: 10485760
+
+visits:
+  retention: 720h
+
+events:
+  bufferSize: 500
+
+webhooks:
+  maxAttempts: 5
+  batchSize: 50
+  retention: 240h
 `
 
 // TestConfigReader reads config settings from json
@@ -88,4 +132,38 @@ func TestConfigReader(t *testing.T) {
 	assert.Equal(t, []string{"Accept", "Authorization"}, config.Cors.AllowedHeaders)
 	assert.Equal(t, []string{"GET", "POST"}, config.Cors.AllowedMethods)
 	assert.Equal(t, []string{"*"}, config.Cors.AllowedOrigins)
+
+	assert.Equal(t, "5s", config.Fetcher.Timeout)
+	assert.Equal(t, int64(1048576), config.Fetcher.MaxBodySize)
+	assert.Equal(t, "bookmarks", config.Fetcher.UserAgent)
+	assert.Equal(t, 3, config.Fetcher.MaxRedirects)
+	assert.Equal(t, "http://proxy:3128", config.Fetcher.Proxy)
+	assert.Equal(t, false, config.Fetcher.AllowPrivateNetworks)
+	assert.Equal(t, []string{"203.0.113.0/24"}, config.Fetcher.DenyNetworks)
+
+	assert.Equal(t, "24h", config.Jobs.FaviconGC)
+	assert.Equal(t, "1h", config.Jobs.LinkHealth)
+	assert.Equal(t, "24h", config.Jobs.ArchiveGC)
+	assert.Equal(t, "30m", config.Jobs.ContentIndex)
+	assert.Equal(t, "12h", config.Jobs.VisitRetention)
+	assert.Equal(t, "2h", config.Jobs.ReadLaterExpiry)
+
+	assert.Equal(t, "168h", config.LinkCheck.RecheckAfter)
+	assert.Equal(t, "2s", config.LinkCheck.HostDelay)
+	assert.Equal(t, 500, config.LinkCheck.BatchSize)
+	assert.Equal(t, 4, config.LinkCheck.Workers)
+	assert.Equal(t, "2160h", config.LinkCheck.HistoryRetention)
+
+	assert.Equal(t, "./archive", config.Archive.Path)
+	assert.Equal(t, true, config.Archive.OnCreate)
+	assert.Equal(t, 5, config.Archive.MaxVersions)
+	assert.Equal(t, 50, config.Archive.MaxResources)
+	assert.Equal(t, int64(10485760), config.Archive.MaxSize)
+
+	assert.Equal(t, "720h", config.Visits.Retention)
+	assert.Equal(t, 500, config.Events.BufferSize)
+	assert.Equal(t, "1m", config.Jobs.Webhooks)
+	assert.Equal(t, 5, config.Webhooks.MaxAttempts)
+	assert.Equal(t, 50, config.Webhooks.BatchSize)
+	assert.Equal(t, "240h", config.Webhooks.Retention)
 }
@@ -0,0 +1,154 @@
+// Package events distributes the changes of bookmarks to the subscribers of a user
+package events
+
+import (
+	"sync"
+)
+
+// DefaultBufferSize is the number of events kept for the resumption of subscriptions
+const DefaultBufferSize = 1000
+
+// the number of events a subscriber can fall behind, before the subscription is closed
+const subscriberQueue = 64
+
+// Type is the kind of change of a bookmark
+type Type string
+
+const (
+	// Created is a new bookmark
+	Created Type = "created"
+	// Updated is a change of the bookmark
+	Updated Type = "updated"
+	// Moved is a bookmark with a changed path
+	Moved Type = "moved"
+	// Deleted is a removed bookmark
+	Deleted Type = "deleted"
+	// FaviconUpdated is a bookmark with a new favicon
+	FaviconUpdated Type = "favicon-updated"
+)
+
+// Event is the change of a bookmark of a user. The ID is increasing with every event of the bus
+type Event struct {
+	ID       uint64
+	Type     Type
+	UserName string
+	Data     interface{}
+}
+
+// Bus publishes events to the subscriptions of the user. The most recent events are buffered, subscriptions
+// can be resumed after the ID of the last received event
+type Bus struct {
+	mu          sync.Mutex
+	lastID      uint64
+	buffer      []Event
+	start       int
+	count       int
+	subscribers map[*Subscription]bool
+}
+
+// NewBus creates a bus which keeps the given number of events, without a positive size the DefaultBufferSize is used
+func NewBus(size int) *Bus {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &Bus{
+		buffer:      make([]Event, size),
+		subscribers: make(map[*Subscription]bool),
+	}
+}
+
+// Publish assigns the next ID to the event and sends it to the subscriptions of the user. Subscriptions which
+// cannot keep up are closed, the subscriber has to resume the subscription
+func (b *Bus) Publish(userName string, t Type, data interface{}) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e := Event{ID: b.lastID, Type: t, UserName: userName, Data: data}
+	end := (b.start + b.count) % len(b.buffer)
+	b.buffer[end] = e
+	if b.count < len(b.buffer) {
+		b.count++
+	} else {
+		b.start = (b.start + 1) % len(b.buffer)
+	}
+
+	for s := range b.subscribers {
+		if s.userName != userName {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			b.remove(s)
+		}
+	}
+	return e
+}
+
+// Subscribe registers a subscription for the events of the user. The buffered events after the lastID are
+// returned, complete is false if some of these events are no longer buffered. A lastID of 0 starts with the
+// next published event
+func (b *Bus) Subscribe(userName string, lastID uint64) (s *Subscription, missed []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s = &Subscription{
+		Start:    b.lastID,
+		bus:      b,
+		userName: userName,
+		events:   make(chan Event, subscriberQueue),
+	}
+	b.subscribers[s] = true
+
+	if lastID == 0 || lastID == b.lastID {
+		return s, nil, true
+	}
+	// the IDs start again after a restart, a later ID belongs to the previous bus
+	oldest := b.lastID - uint64(b.count) + 1
+	complete = lastID < b.lastID && lastID+1 >= oldest
+	for i := 0; i < b.count; i++ {
+		e := b.buffer[(b.start+i)%len(b.buffer)]
+		if e.ID > lastID && e.UserName == userName {
+			missed = append(missed, e)
+		}
+	}
+	if !complete {
+		missed = nil
+	}
+	return s, missed, complete
+}
+
+// Subscribers returns the number of open subscriptions
+func (b *Bus) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+func (b *Bus) remove(s *Subscription) {
+	if b.subscribers[s] {
+		delete(b.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Subscription receives the events of a user. Start is the ID of the last event before the subscription
+type Subscription struct {
+	Start    uint64
+	bus      *Bus
+	userName string
+	events   chan Event
+}
+
+// Events returns the channel of the published events, the channel is closed with the subscription
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.remove(s)
+}
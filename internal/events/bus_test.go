@@ -0,0 +1,82 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishToSubscribers(t *testing.T) {
+	bus := NewBus(10)
+	sub, missed, complete := bus.Subscribe("user", 0)
+	other, _, _ := bus.Subscribe("other", 0)
+	assert.Nil(t, missed)
+	assert.True(t, complete)
+	assert.Equal(t, 2, bus.Subscribers())
+
+	e := bus.Publish("user", Created, "a")
+	assert.Equal(t, uint64(1), e.ID)
+	bus.Publish("other", Deleted, "b")
+	bus.Publish("user", Moved, "c")
+
+	assert.Equal(t, Event{ID: 1, Type: Created, UserName: "user", Data: "a"}, <-sub.Events())
+	assert.Equal(t, Event{ID: 3, Type: Moved, UserName: "user", Data: "c"}, <-sub.Events())
+	assert.Equal(t, uint64(2), (<-other.Events()).ID)
+	assert.Equal(t, 0, len(sub.Events()))
+
+	sub.Close()
+	_, open := <-sub.Events()
+	assert.False(t, open)
+	assert.Equal(t, 1, bus.Subscribers())
+	// a second close is ignored
+	sub.Close()
+}
+
+func TestResumeSubscription(t *testing.T) {
+	bus := NewBus(3)
+	for i := 0; i < 4; i++ {
+		bus.Publish("user", Updated, i)
+	}
+	bus.Publish("other", Updated, 4)
+
+	// the events after the last ID are returned, the buffer holds the events 3 to 5
+	sub, missed, complete := bus.Subscribe("user", 2)
+	assert.True(t, complete)
+	assert.Equal(t, 2, len(missed))
+	assert.Equal(t, uint64(3), missed[0].ID)
+	assert.Equal(t, uint64(4), missed[1].ID)
+	assert.Equal(t, uint64(5), sub.Start)
+
+	_, missed, complete = bus.Subscribe("user", 5)
+	assert.True(t, complete)
+	assert.Nil(t, missed)
+
+	// the event 2 is no longer available
+	_, missed, complete = bus.Subscribe("user", 1)
+	assert.False(t, complete)
+	assert.Nil(t, missed)
+
+	// the ID belongs to a previous bus
+	_, _, complete = bus.Subscribe("user", 10)
+	assert.False(t, complete)
+}
+
+func TestSlowSubscriberIsClosed(t *testing.T) {
+	bus := NewBus(0)
+	sub, _, _ := bus.Subscribe("user", 0)
+	for i := 0; i < subscriberQueue+1; i++ {
+		bus.Publish("user", Updated, i)
+	}
+	assert.Equal(t, 0, bus.Subscribers())
+
+	var received int
+	for range sub.Events() {
+		received++
+	}
+	assert.Equal(t, subscriberQueue, received)
+
+	// the subscriber resumes with the buffered events
+	_, missed, complete := bus.Subscribe("user", uint64(received))
+	assert.True(t, complete)
+	assert.Equal(t, 1, len(missed))
+}
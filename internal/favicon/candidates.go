@@ -0,0 +1,275 @@
+package favicon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bihe/bookmarks/internal/metadata"
+)
+
+// PreferredSize is the icon size in pixels which is used to rank the available favicon candidates
+const PreferredSize = 32
+
+// Candidate is a possible favicon definition found for a page
+type Candidate struct {
+	// URL is the absolute URL of the icon, or a data: URI for inline icons
+	URL string
+	// Rel is the link relation the icon was defined with (icon, apple-touch-icon, mask-icon, manifest)
+	Rel string
+	// Type is the mime-type either given by the definition or derived from the URL
+	Type string
+	// Sizes holds the dimensions of the icon, a value of 0 indicates a scalable icon ("any")
+	Sizes []int
+
+	position int
+}
+
+// FileName returns the name of the icon file derived from the URL
+func (c Candidate) FileName() string {
+	if isDataURI(c.URL) {
+		return "favicon" + extensionForType(c.Type)
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || path.Base(u.Path) == "/" || path.Base(u.Path) == "." {
+		return DefaultFaviconName
+	}
+	return path.Base(u.Path)
+}
+
+// the link relations which define icons of a page
+var iconRelations = map[string]bool{
+	"icon":                         true,
+	"shortcut":                     true,
+	"apple-touch-icon":             true,
+	"apple-touch-icon-precomposed": true,
+	"mask-icon":                    true,
+}
+
+// metadataCandidates converts the icons found in the metadata of a page to candidates. the location of a web
+// manifest is returned separately, because the manifest needs to be fetched to retrieve the icons within
+func metadataCandidates(meta *metadata.Metadata) (candidates []Candidate, manifest *url.URL) {
+	if meta.Manifest != "" {
+		if u, err := url.Parse(meta.Manifest); err == nil {
+			manifest = u
+		}
+	}
+	for _, icon := range meta.Icons {
+		relation := iconRelation(icon.Rel)
+		if relation == "" || relation == "manifest" {
+			continue
+		}
+		candidates = append(candidates, newCandidate(icon.URL, relation, icon.Type, icon.Sizes, len(candidates)))
+	}
+	return candidates, manifest
+}
+
+// iconRelation maps the whitespace separated tokens of a rel attribute to a single relation
+func iconRelation(rel string) string {
+	var relation string
+	for _, token := range strings.Fields(strings.ToLower(rel)) {
+		switch {
+		case token == "manifest":
+			return token
+		case iconRelations[token]:
+			if token == "shortcut" {
+				token = "icon"
+			}
+			if relation == "" || relation == "icon" {
+				relation = token
+			}
+		}
+	}
+	return relation
+}
+
+type webManifest struct {
+	Icons []struct {
+		Src     string `json:"src"`
+		Sizes   string `json:"sizes"`
+		Type    string `json:"type"`
+		Purpose string `json:"purpose"`
+	} `json:"icons"`
+}
+
+// parseManifest returns the icons defined by a web manifest, the icon locations are relative to the manifest
+func parseManifest(payload []byte, manifestURL *url.URL, offset int) ([]Candidate, error) {
+	var (
+		m          webManifest
+		candidates []Candidate
+	)
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return nil, fmt.Errorf("could not parse manifest: %v", err)
+	}
+	for _, icon := range m.Icons {
+		if icon.Src == "" {
+			continue
+		}
+		// maskable icons have a safe-zone and are padded, only use icons intended for any usage
+		if icon.Purpose != "" && !containsToken(icon.Purpose, "any") {
+			continue
+		}
+		u, err := manifestURL.Parse(icon.Src)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, newCandidate(u.String(), "manifest", icon.Type, icon.Sizes, offset+len(candidates)))
+	}
+	return candidates, nil
+}
+
+func newCandidate(iconURL, rel, mimeType, sizes string, position int) Candidate {
+	c := Candidate{
+		URL:      iconURL,
+		Rel:      rel,
+		Type:     strings.ToLower(strings.TrimSpace(mimeType)),
+		Sizes:    parseSizes(sizes),
+		position: position,
+	}
+	if c.Type == "" {
+		c.Type = typeForURL(iconURL)
+	}
+	return c
+}
+
+// parseSizes parses the sizes attribute of the form "16x16 32x32" or "any"
+func parseSizes(sizes string) []int {
+	var result []int
+	for _, s := range strings.Fields(strings.ToLower(sizes)) {
+		if s == "any" {
+			result = append(result, 0)
+			continue
+		}
+		parts := strings.SplitN(s, "x", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		w, err := strconv.Atoi(parts[0])
+		if err != nil || w <= 0 {
+			continue
+		}
+		result = append(result, w)
+	}
+	return result
+}
+
+// rankCandidates sorts the candidates, the best matching icon is the first element
+func rankCandidates(candidates []Candidate, preferred int) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		si, sj := sizeRank(candidates[i], preferred), sizeRank(candidates[j], preferred)
+		if si != sj {
+			return si < sj
+		}
+		fi, fj := formatRank(candidates[i]), formatRank(candidates[j])
+		if fi != fj {
+			return fi < fj
+		}
+		return candidates[i].position < candidates[j].position
+	})
+}
+
+// sizeRank returns a lower value the better the icon size fits the preferred size.
+// exact or scalable icons are best, followed by larger icons (which can be scaled down)
+// and icons without size information. smaller icons come last.
+func sizeRank(c Candidate, preferred int) int {
+	if c.Type == "image/svg+xml" && c.Rel != "mask-icon" {
+		return 0
+	}
+	best := -1
+	for _, s := range c.Sizes {
+		switch {
+		case s == 0 || s == preferred:
+			return 0
+		case s > preferred:
+			if best == -1 || best < preferred || s < best {
+				best = s
+			}
+		case best == -1 || (best < preferred && s > best):
+			best = s
+		}
+	}
+	switch {
+	case best == -1:
+		return 10000
+	case best > preferred:
+		return best - preferred
+	default:
+		return 20000 + preferred - best
+	}
+}
+
+// formatRank prefers raster formats which are supported everywhere. mask-icons are
+// monochrome templates and are only used if nothing else is available
+func formatRank(c Candidate) int {
+	if c.Rel == "mask-icon" {
+		return 10
+	}
+	switch c.Type {
+	case "image/png":
+		return 0
+	case "image/svg+xml":
+		return 1
+	case "image/x-icon", "image/vnd.microsoft.icon", "image/ico":
+		return 2
+	case "image/gif", "image/jpeg", "image/webp":
+		return 3
+	}
+	return 4
+}
+
+func typeForURL(iconURL string) string {
+	if isDataURI(iconURL) {
+		if mediaType, _, err := decodeDataURI(iconURL); err == nil {
+			return mediaType
+		}
+		return ""
+	}
+	u, err := url.Parse(iconURL)
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".png":
+		return "image/png"
+	case ".svg":
+		return "image/svg+xml"
+	case ".ico":
+		return "image/x-icon"
+	case ".gif":
+		return "image/gif"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".webp":
+		return "image/webp"
+	}
+	return ""
+}
+
+func extensionForType(mimeType string) string {
+	switch mimeType {
+	case "image/png":
+		return ".png"
+	case "image/svg+xml":
+		return ".svg"
+	case "image/gif":
+		return ".gif"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/webp":
+		return ".webp"
+	}
+	return ".ico"
+}
+
+func containsToken(value, token string) bool {
+	for _, t := range strings.Fields(strings.ToLower(value)) {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package favicon
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/metadata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataCandidates(t *testing.T) {
+	page := `<html>
+        <head>
+            <base href="https://cdn.example.com/assets/">
+            <link rel="icon" href="favicon.ico">
+            <link rel="Shortcut Icon" type="image/png" href="/img/favicon-32.png" sizes="32x32">
+            <link rel="apple-touch-icon" href="../touch.png" sizes="180x180">
+            <link rel="mask-icon" href="mask.svg">
+            <link rel="manifest" href="/site.webmanifest">
+            <link rel="stylesheet" href="site.css">
+            <link rel="icon" href="">
+        </head>
+        <body>html</body>
+    </html>`
+	pageURL, _ := url.Parse("https://www.example.com/a/b/index.html")
+
+	// relative definitions are resolved against the <base href> of the page by the metadata
+	meta, err := metadata.Parse([]byte(page), pageURL)
+	if err != nil {
+		t.Fatalf("could not parse metadata: %v", err)
+	}
+	candidates, manifest := metadataCandidates(meta)
+
+	assert.Equal(t, 4, len(candidates))
+	assert.Equal(t, "https://cdn.example.com/site.webmanifest", manifest.String())
+
+	assert.Equal(t, "https://cdn.example.com/assets/favicon.ico", candidates[0].URL)
+	assert.Equal(t, "icon", candidates[0].Rel)
+	assert.Equal(t, "image/x-icon", candidates[0].Type)
+
+	assert.Equal(t, "https://cdn.example.com/img/favicon-32.png", candidates[1].URL)
+	assert.Equal(t, "icon", candidates[1].Rel)
+	assert.Equal(t, []int{32}, candidates[1].Sizes)
+
+	assert.Equal(t, "https://cdn.example.com/touch.png", candidates[2].URL)
+	assert.Equal(t, "apple-touch-icon", candidates[2].Rel)
+
+	assert.Equal(t, "https://cdn.example.com/assets/mask.svg", candidates[3].URL)
+	assert.Equal(t, "mask-icon", candidates[3].Rel)
+
+	rankCandidates(candidates, PreferredSize)
+	assert.Equal(t, "https://cdn.example.com/img/favicon-32.png", candidates[0].URL)
+	assert.Equal(t, "https://cdn.example.com/touch.png", candidates[1].URL)
+	assert.Equal(t, "https://cdn.example.com/assets/favicon.ico", candidates[2].URL)
+	assert.Equal(t, "https://cdn.example.com/assets/mask.svg", candidates[3].URL)
+}
+
+func TestParseManifest(t *testing.T) {
+	manifest := `{"icons": [
+        {"src": "icon-192.png", "sizes": "192x192", "type": "image/png"},
+        {"src": "/maskable.png", "sizes": "48x48", "type": "image/png", "purpose": "maskable"},
+        {"src": "icon.svg", "sizes": "any", "type": "image/svg+xml", "purpose": "any maskable"}
+    ]}`
+	manifestURL, _ := url.Parse("https://example.com/static/manifest.json")
+
+	candidates, err := parseManifest([]byte(manifest), manifestURL, 0)
+	if err != nil {
+		t.Fatalf("could not parse manifest: %v", err)
+	}
+	assert.Equal(t, 2, len(candidates))
+	assert.Equal(t, "https://example.com/static/icon-192.png", candidates[0].URL)
+	assert.Equal(t, "https://example.com/static/icon.svg", candidates[1].URL)
+
+	rankCandidates(candidates, PreferredSize)
+	assert.Equal(t, "https://example.com/static/icon.svg", candidates[0].URL)
+
+	if _, err = parseManifest([]byte("{"), manifestURL, 0); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestRankBySize(t *testing.T) {
+	candidates := []Candidate{
+		newCandidate("https://example.com/16.png", "icon", "", "16x16", 0),
+		newCandidate("https://example.com/unknown.ico", "icon", "", "", 1),
+		newCandidate("https://example.com/256.png", "icon", "", "256x256", 2),
+		newCandidate("https://example.com/multi.ico", "icon", "", "16x16 48x48", 3),
+		newCandidate("https://example.com/24.png", "icon", "", "24x24", 4),
+	}
+	rankCandidates(candidates, PreferredSize)
+
+	var urls []string
+	for _, c := range candidates {
+		urls = append(urls, c.URL)
+	}
+	assert.Equal(t, []string{
+		"https://example.com/multi.ico",
+		"https://example.com/256.png",
+		"https://example.com/unknown.ico",
+		"https://example.com/24.png",
+		"https://example.com/16.png",
+	}, urls)
+}
+
+func TestIconRelation(t *testing.T) {
+	assert.Equal(t, "icon", iconRelation("shortcut icon"))
+	assert.Equal(t, "icon", iconRelation("ICON"))
+	assert.Equal(t, "apple-touch-icon", iconRelation("apple-touch-icon"))
+	assert.Equal(t, "apple-touch-icon-precomposed", iconRelation("apple-touch-icon-precomposed"))
+	assert.Equal(t, "mask-icon", iconRelation("mask-icon"))
+	assert.Equal(t, "manifest", iconRelation("manifest"))
+	assert.Equal(t, "", iconRelation("stylesheet"))
+}
+
+func TestDecodeDataURI(t *testing.T) {
+	mediaType, payload, err := decodeDataURI("data:image/png;base64,aWNvbg==")
+	if err != nil {
+		t.Fatalf("could not decode: %v", err)
+	}
+	assert.Equal(t, "image/png", mediaType)
+	assert.Equal(t, []byte("icon"), payload)
+
+	mediaType, payload, err = decodeDataURI("data:image/svg+xml,%3Csvg%3E%3C/svg%3E")
+	if err != nil {
+		t.Fatalf("could not decode: %v", err)
+	}
+	assert.Equal(t, "image/svg+xml", mediaType)
+	assert.Equal(t, []byte("<svg></svg>"), payload)
+
+	_, _, err = decodeDataURI("data:image/png;base64")
+	assert.Error(t, err)
+	_, _, err = decodeDataURI("data:image/png;base64,")
+	assert.Error(t, err)
+
+	c := newCandidate("data:image/svg+xml,%3Csvg%3E%3C/svg%3E", "icon", "", "", 0)
+	assert.Equal(t, "favicon.svg", c.FileName())
+}
@@ -2,147 +2,170 @@
 package favicon
 
 import (
-	"bytes"
+	"encoding/base64"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/bihe/bookmarks/internal/metadata"
 )
 
 const DefaultFaviconName = "favicon.ico"
 
+// the media-types accepted for icons, servers often deliver icons as binary or xml data
+var iconTypes = []string{"image/", "application/octet-stream", "text/xml", "application/xml"}
+
 // GetFaviconFromURL tries to find and fetch the favicon from the given URL
-func GetFaviconFromURL(url string) (fileName string, payload []byte, err error) {
+// the supplied client is used for all outbound requests
+func GetFaviconFromURL(client fetch.Fetcher, uri string) (fileName string, payload []byte, err error) {
+	var pageURL *url.URL
+
+	if pageURL, err = parseURL(uri); err != nil {
+		return
+	}
+	meta, err := metadata.Fetch(client, pageURL.String())
+	if err != nil {
+		// the page is not available, the favicon might still be available
+		meta = &metadata.Metadata{URL: pageURL.String()}
+	}
+	return GetFaviconFromMetadata(client, meta)
+}
+
+// GetFaviconFromMetadata fetches the best favicon defined by the metadata of a page. If the page does
+// not define any icons, the favicon is requested from the base-path of the page
+func GetFaviconFromMetadata(client fetch.Fetcher, meta *metadata.Metadata) (fileName string, payload []byte, err error) {
 	var (
-		scheme  string
-		baseURL string
-		pageURL string
-		iconURL string
+		pageURL    *url.URL
+		candidates []Candidate
 	)
 
-	if scheme, baseURL, pageURL, err = parseURL(url); err != nil {
+	if pageURL, err = parseURL(meta.URL); err != nil {
 		return
 	}
 
-	if iconURL, fileName, err = parseHtmlPageForFavicon(url); err != nil {
+	if candidates = findCandidates(client, meta); len(candidates) == 0 {
 		// no favicon found on page
 		// fall back to the standard to get the favicon from the base-path
-		iconURL = fmt.Sprintf("%s/%s", baseURL, DefaultFaviconName)
-		if payload, err = fetchURL(iconURL); err != nil {
+		iconURL := fmt.Sprintf("%s://%s/%s", pageURL.Scheme, pageURL.Host, DefaultFaviconName)
+		if payload, err = fetchURL(client, iconURL, iconTypes...); err != nil {
 			err = fmt.Errorf("could not fetch favicon '%s': %v", iconURL, err)
 			return
 		}
 		return DefaultFaviconName, payload, nil
 	}
 
-	// we have parsed the favicon from the html
-	// now ensure that the parsed url is downloadable html pages use some kind of tricks:
-	// a) missing base-url href=/assets/abc/favicon.png
-	// b) missing scheme //cdn.com/abc/favicon.png
-
-	if strings.HasPrefix(iconURL, "//") {
-		iconURL = scheme + ":" + iconURL
-	} else if strings.HasPrefix(iconURL, "/") {
-		iconURL = baseURL + iconURL
-	} else if strings.HasPrefix(iconURL, "./") {
-		// local to the page-URL
-		iconURL = strings.ReplaceAll(iconURL, "./", "/")
-		iconURL = pageURL + iconURL
-	} else if !strings.HasPrefix(iconURL, "http") {
-		// if a file without anything is specified "favicon.png"
-		// then use the pageurl
-		iconURL = pageURL + iconURL
-	}
-
-	if payload, err = fetchURL(iconURL); err != nil {
-		err = fmt.Errorf("could not fetch favicon '%s': %v", iconURL, err)
-		return
+	// the candidates are ranked, use the first one which can be retrieved
+	// the error of the best candidate is reported if none of the candidates is available
+	for i, c := range candidates {
+		var fetchErr error
+		if payload, fetchErr = fetchIcon(client, c); fetchErr == nil {
+			return c.FileName(), payload, nil
+		}
+		if i == 0 {
+			err = fmt.Errorf("could not fetch favicon '%s': %v", c.URL, fetchErr)
+		}
 	}
-	return
+	return "", nil, err
 }
 
-func parseURL(uri string) (scheme, baseURL, pageURL string, err error) {
-	var u *url.URL
-	u, err = url.Parse(uri)
+func parseURL(uri string) (*url.URL, error) {
+	u, err := url.Parse(uri)
 	if err != nil {
-		return "", "", "", fmt.Errorf("could not parse the supplied uri: %v", err)
+		return nil, fmt.Errorf("could not parse the supplied uri: %v", err)
 	}
-	path := u.Path
-	if strings.HasSuffix(path, "index.html") || strings.HasSuffix(path, "index.htm") {
-		path = strings.ReplaceAll(path, "index.html", "")
-		path = strings.ReplaceAll(path, "index.htm", "")
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("the scheme '%s' of the supplied uri is not supported", u.Scheme)
 	}
-
-	return u.Scheme, fmt.Sprintf("%s://%s", u.Scheme, u.Host), fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, path), nil
+	return u, nil
 }
 
-func parseHtmlPageForFavicon(url string) (iconUrl, fileName string, err error) {
-	var (
-		page []byte
-	)
+// findCandidates collects the available favicon definitions of the page and the linked web manifest.
+// the candidates are ranked, the best matching icon is the first element
+func findCandidates(client fetch.Fetcher, meta *metadata.Metadata) []Candidate {
+	candidates, manifestURL := metadataCandidates(meta)
 
-	if page, err = fetchURL(url); err != nil {
-		return
-	}
-	if iconUrl, err = tryFaviconDefinitions(page); err != nil {
-		return
+	if manifestURL != nil {
+		if payload, err := fetchURL(client, manifestURL.String()); err == nil {
+			if icons, err := parseManifest(payload, manifestURL, len(candidates)); err == nil {
+				candidates = append(candidates, icons...)
+			}
+		}
 	}
 
-	parts := strings.Split(iconUrl, "/")
-	fileName = parts[len(parts)-1]
-	return iconUrl, fileName, nil
+	rankCandidates(candidates, PreferredSize)
+	return candidates
 }
 
-func fetchURL(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+func fetchIcon(client fetch.Fetcher, c Candidate) ([]byte, error) {
+	if isDataURI(c.URL) {
+		_, payload, err := decodeDataURI(c.URL)
+		return payload, err
+	}
+	return fetchURL(client, c.URL, iconTypes...)
+}
+
+// fetchURL retrieves the content of the given url, if media-types are supplied
+// the content-type of the response needs to match one of the types
+func fetchURL(client fetch.Fetcher, url string, types ...string) ([]byte, error) {
+	resp, err := client.Get(url)
 	if err != nil {
 		return nil, fmt.Errorf("could not fetch page: %v", err)
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
+	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("got status %d", resp.StatusCode)
 	}
-
-	content, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("could not read content body: %v", err)
+	if len(types) > 0 && !resp.IsType(types...) {
+		return nil, fmt.Errorf("the content-type '%s' is not supported", resp.ContentType)
+	}
+	if len(resp.Body) == 0 {
+		return nil, fmt.Errorf("got an empty response")
 	}
-	return content, nil
+	return resp.Body, nil
 }
 
-func tryFaviconDefinitions(page []byte) (string, error) {
-	var (
-		iconUrl string
-		err     error
-	)
-	iconUrl, err = parseFavicon(page, "icon")
-	if err != nil {
-		iconUrl, err = parseFavicon(page, "shortcut icon")
-	}
-	return iconUrl, err
+func isDataURI(uri string) bool {
+	return strings.HasPrefix(strings.ToLower(uri), "data:")
 }
 
-func parseFavicon(page []byte, faviconDef string) (string, error) {
-	var (
-		iconUrl string
-		err     error
-		doc     *goquery.Document
-		ok      bool
-	)
+// decodeDataURI returns the media-type and the payload of a data URI (RFC 2397)
+// data:[<mediatype>][;base64],<data>
+func decodeDataURI(uri string) (mediaType string, payload []byte, err error) {
+	i := strings.Index(uri, ",")
+	if !isDataURI(uri) || i == -1 {
+		return "", nil, fmt.Errorf("invalid data URI")
+	}
 
-	doc, err = goquery.NewDocumentFromReader(bytes.NewReader(page))
-	if err != nil {
-		return "", fmt.Errorf("could not parse page: %v", err)
+	header := uri[len("data:"):i]
+	data := uri[i+1:]
+
+	encoded := false
+	params := strings.Split(header, ";")
+	if len(params) > 1 && strings.EqualFold(params[len(params)-1], "base64") {
+		encoded = true
+		params = params[:len(params)-1]
+	}
+	mediaType = strings.ToLower(strings.TrimSpace(params[0]))
+
+	if encoded {
+		// some pages include whitespace or omit the padding within the data
+		data = strings.Join(strings.Fields(data), "")
+		if payload, err = base64.StdEncoding.DecodeString(data); err != nil {
+			if payload, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "=")); err != nil {
+				return "", nil, fmt.Errorf("could not decode data URI: %v", err)
+			}
+		}
+	} else {
+		var unescaped string
+		if unescaped, err = url.PathUnescape(data); err != nil {
+			return "", nil, fmt.Errorf("could not decode data URI: %v", err)
+		}
+		payload = []byte(unescaped)
 	}
 
-	doc.Find(fmt.Sprintf(`link[rel="%s"]`, faviconDef)).Each(func(i int, s *goquery.Selection) {
-		iconUrl, ok = s.Attr("href")
-	})
-	if iconUrl == "" || !ok {
-		return "", fmt.Errorf("could not find a favicon definition on page")
+	if len(payload) == 0 {
+		return "", nil, fmt.Errorf("empty data URI")
 	}
-	return iconUrl, nil
+	return mediaType, payload, nil
 }
@@ -1,12 +1,14 @@
 package favicon
 
 import (
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/bihe/bookmarks/internal/fetch"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -71,7 +73,7 @@ func TestFetchFavicon(t *testing.T) {
 			t.Fatalf("%v", err)
 		}
 	})
-	mux.HandleFunc("/pageRel", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/pageRel/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("content-type", "text/html")
 		html := ` <html>
                 <head>
@@ -110,6 +112,81 @@ func TestFetchFavicon(t *testing.T) {
 			t.Fatalf("%v", err)
 		}
 	})
+	mux.HandleFunc("/apple-touch-icon.png", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write(favicon); err != nil {
+			t.Fatalf("%v", err)
+		}
+	})
+	mux.HandleFunc("/icons/manifest-64.png", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write(favicon); err != nil {
+			t.Fatalf("%v", err)
+		}
+	})
+	mux.HandleFunc("/icons/manifest.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("content-type", "application/manifest+json")
+		manifest := `{"icons": [
+			{"src": "manifest-512.png", "sizes": "512x512", "type": "image/png"},
+			{"src": "manifest-64.png", "sizes": "64x64", "type": "image/png"}
+		]}`
+		if _, err := w.Write([]byte(manifest)); err != nil {
+			t.Fatalf("%v", err)
+		}
+	})
+	mux.HandleFunc("/sizes", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("content-type", "text/html")
+		html := ` <html>
+                <head>
+                    <meta charset="utf-8">
+                    <link rel="icon" type="image/png" href="/img/favicon-16.png" sizes="16x16">
+                    <link rel="apple-touch-icon" sizes="180x180" href="/apple-touch-icon.png">
+                    <link rel="mask-icon" href="/img/mask.svg" color="#000000">
+                </head>
+                <body>html</body>
+            </html>`
+		if _, err := w.Write([]byte(html)); err != nil {
+			t.Fatalf("%v", err)
+		}
+	})
+	mux.HandleFunc("/manifest", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("content-type", "text/html")
+		html := ` <html>
+                <head>
+                    <meta charset="utf-8">
+                    <link rel="manifest" href="/icons/manifest.json">
+                </head>
+                <body>html</body>
+            </html>`
+		if _, err := w.Write([]byte(html)); err != nil {
+			t.Fatalf("%v", err)
+		}
+	})
+	mux.HandleFunc("/base/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("content-type", "text/html")
+		html := ` <html>
+                <head>
+                    <meta charset="utf-8">
+                    <base href="/pageRel/sub/">
+                    <link rel="shortcut icon" href="../img/favicon32x32.png">
+                </head>
+                <body>html</body>
+            </html>`
+		if _, err := w.Write([]byte(html)); err != nil {
+			t.Fatalf("%v", err)
+		}
+	})
+	mux.HandleFunc("/dataURI", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("content-type", "text/html")
+		html := fmt.Sprintf(` <html>
+                <head>
+                    <meta charset="utf-8">
+                    <link rel="icon" href="data:image/png;base64,%s">
+                </head>
+                <body>html</body>
+            </html>`, base64.StdEncoding.EncodeToString(favicon))
+		if _, err := w.Write([]byte(html)); err != nil {
+			t.Fatalf("%v", err)
+		}
+	})
 	mux.HandleFunc("/parseErr", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("content-type", "text/html")
 		html := `\\\\\\\
@@ -125,9 +202,15 @@ func TestFetchFavicon(t *testing.T) {
 	ts := httptest.NewServer(mux)
 	defer ts.Close()
 
+	// the test-server is available on the loopback interface
+	client, err := fetch.New(fetch.Options{AllowPrivateNetworks: true})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
 	// default, use basepath favicon.ico
 	// ------------------------------------------------------------------
-	fileName, payload, err := GetFaviconFromURL(ts.URL)
+	fileName, payload, err := GetFaviconFromURL(client, ts.URL)
 	if err != nil {
 		t.Errorf("could not get default favicon: %v", err)
 	}
@@ -136,7 +219,7 @@ func TestFetchFavicon(t *testing.T) {
 
 	// use html content1
 	// ------------------------------------------------------------------
-	fileName, payload, err = GetFaviconFromURL(ts.URL + "/pageAbs")
+	fileName, payload, err = GetFaviconFromURL(client, ts.URL+"/pageAbs")
 	if err != nil {
 		t.Errorf("could not get favicon: %v", err)
 	}
@@ -145,7 +228,7 @@ func TestFetchFavicon(t *testing.T) {
 
 	// use html content2
 	// ------------------------------------------------------------------
-	fileName, payload, err = GetFaviconFromURL(ts.URL + "/pageRel")
+	fileName, payload, err = GetFaviconFromURL(client, ts.URL+"/pageRel/")
 	if err != nil {
 		t.Errorf("could not get favicon: %v", err)
 	}
@@ -154,7 +237,7 @@ func TestFetchFavicon(t *testing.T) {
 
 	// use html content3
 	// ------------------------------------------------------------------
-	fileName, payload, err = GetFaviconFromURL(ts.URL + "/cdn")
+	fileName, payload, err = GetFaviconFromURL(client, ts.URL+"/cdn")
 	if err != nil {
 		t.Errorf("could not get favicon: %v", err)
 	}
@@ -163,16 +246,52 @@ func TestFetchFavicon(t *testing.T) {
 
 	// single file
 	// ------------------------------------------------------------------
-	fileName, payload, err = GetFaviconFromURL(ts.URL + "/singleFile/index.html")
+	fileName, payload, err = GetFaviconFromURL(client, ts.URL+"/singleFile/index.html")
 	if err != nil {
 		t.Errorf("could not get favicon: %v", err)
 	}
 	assert.Equal(t, "favicon.ico", fileName)
 	assert.Equal(t, len(favicon), len(payload))
 
+	// best size: the apple-touch-icon is preferred to the 16x16 icon and the mask-icon
+	// ------------------------------------------------------------------
+	fileName, payload, err = GetFaviconFromURL(client, ts.URL+"/sizes")
+	if err != nil {
+		t.Errorf("could not get favicon: %v", err)
+	}
+	assert.Equal(t, "apple-touch-icon.png", fileName)
+	assert.Equal(t, len(favicon), len(payload))
+
+	// web manifest, the icon closest to the preferred size is used
+	// ------------------------------------------------------------------
+	fileName, payload, err = GetFaviconFromURL(client, ts.URL+"/manifest")
+	if err != nil {
+		t.Errorf("could not get favicon: %v", err)
+	}
+	assert.Equal(t, "manifest-64.png", fileName)
+	assert.Equal(t, len(favicon), len(payload))
+
+	// base href and relative parent path
+	// ------------------------------------------------------------------
+	fileName, payload, err = GetFaviconFromURL(client, ts.URL+"/base/page.html")
+	if err != nil {
+		t.Errorf("could not get favicon: %v", err)
+	}
+	assert.Equal(t, "favicon32x32.png", fileName)
+	assert.Equal(t, len(favicon), len(payload))
+
+	// inline data URI
+	// ------------------------------------------------------------------
+	fileName, payload, err = GetFaviconFromURL(client, ts.URL+"/dataURI")
+	if err != nil {
+		t.Errorf("could not get favicon: %v", err)
+	}
+	assert.Equal(t, "favicon.png", fileName)
+	assert.Equal(t, favicon, payload)
+
 	// html parse error
 	// ------------------------------------------------------------------
-	fileName, payload, err = GetFaviconFromURL(ts.URL + "/parseErr")
+	fileName, payload, err = GetFaviconFromURL(client, ts.URL+"/parseErr")
 	if err != nil {
 		t.Errorf("could not get default favicon: %v", err)
 	}
@@ -181,16 +300,26 @@ func TestFetchFavicon(t *testing.T) {
 
 	// http error
 	// ------------------------------------------------------------------
-	_, _, err = GetFaviconFromURL(ts.URL + "/errorFavicon")
+	_, _, err = GetFaviconFromURL(client, ts.URL+"/errorFavicon")
 	if err == nil {
 		t.Errorf("expected error")
 	}
 
 	// invalid url
 	// ------------------------------------------------------------------
-	_, _, err = GetFaviconFromURL("udp://this should be an invalid URL /")
+	_, _, err = GetFaviconFromURL(client, "udp://this should be an invalid URL /")
 	if err == nil {
 		t.Errorf("expected error")
 	}
 
+	// loopback addresses are denied by default
+	// ------------------------------------------------------------------
+	denyClient, err := fetch.New(fetch.Options{})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	_, _, err = GetFaviconFromURL(denyClient, ts.URL+"/pageAbs")
+	if err == nil {
+		t.Errorf("expected error")
+	}
 }
@@ -0,0 +1,184 @@
+package favicon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+type icoEntry struct {
+	width    int
+	height   int
+	bitCount int
+	size     int
+	offset   int
+}
+
+// decodeICO returns the largest image of the given ICO file. the images within an ICO file
+// are either stored as PNG or as a device independent bitmap (DIB) without file-header
+func decodeICO(payload []byte) (image.Image, error) {
+	if len(payload) < 6 {
+		return nil, fmt.Errorf("invalid ICO header")
+	}
+	reserved := binary.LittleEndian.Uint16(payload[0:2])
+	kind := binary.LittleEndian.Uint16(payload[2:4])
+	count := int(binary.LittleEndian.Uint16(payload[4:6]))
+	if reserved != 0 || kind != 1 || count == 0 {
+		return nil, fmt.Errorf("invalid ICO header")
+	}
+	if len(payload) < 6+count*16 {
+		return nil, fmt.Errorf("invalid ICO directory")
+	}
+
+	var best *icoEntry
+	for i := 0; i < count; i++ {
+		d := payload[6+i*16 : 6+(i+1)*16]
+		e := icoEntry{
+			width:    int(d[0]),
+			height:   int(d[1]),
+			bitCount: int(binary.LittleEndian.Uint16(d[6:8])),
+			size:     int(binary.LittleEndian.Uint32(d[8:12])),
+			offset:   int(binary.LittleEndian.Uint32(d[12:16])),
+		}
+		// a value of 0 is used for 256 pixels
+		if e.width == 0 {
+			e.width = 256
+		}
+		if e.height == 0 {
+			e.height = 256
+		}
+		if e.offset < 0 || e.size <= 0 || e.offset+e.size > len(payload) {
+			continue
+		}
+		if best == nil || e.width > best.width || (e.width == best.width && e.bitCount > best.bitCount) {
+			entry := e
+			best = &entry
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no valid image found in ICO")
+	}
+
+	data := payload[best.offset : best.offset+best.size]
+	if bytes.HasPrefix(data, pngSignature) {
+		// the size of the embedded PNG is not bound by the directory entry, it is checked before decoding
+		cfg, err := png.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Width > 256 || cfg.Height > 256 {
+			return nil, fmt.Errorf("unsupported PNG dimensions %dx%d", cfg.Width, cfg.Height)
+		}
+		return png.Decode(bytes.NewReader(data))
+	}
+	return decodeDIB(data)
+}
+
+// decodeDIB decodes the bitmap of an ICO entry. The height of the bitmap is doubled
+// because the XOR (color) bitmap is followed by the 1-bit AND (transparency) mask
+func decodeDIB(data []byte) (image.Image, error) {
+	if len(data) < 40 {
+		return nil, fmt.Errorf("invalid bitmap header")
+	}
+	headerSize := int(binary.LittleEndian.Uint32(data[0:4]))
+	width := int(int32(binary.LittleEndian.Uint32(data[4:8])))
+	height := int(int32(binary.LittleEndian.Uint32(data[8:12]))) / 2
+	bitCount := int(binary.LittleEndian.Uint16(data[14:16]))
+	compression := binary.LittleEndian.Uint32(data[16:20])
+	colorsUsed := int(binary.LittleEndian.Uint32(data[32:36]))
+
+	if headerSize < 40 || width <= 0 || height <= 0 || width > 256 || height > 256 {
+		return nil, fmt.Errorf("unsupported bitmap dimensions %dx%d", width, height)
+	}
+	// only uncompressed bitmaps are used in ICO files, BI_BITFIELDS is used for 32bit images with the default masks
+	if compression != 0 && !(compression == 3 && bitCount == 32) {
+		return nil, fmt.Errorf("unsupported bitmap compression %d", compression)
+	}
+
+	var palette []color.NRGBA
+	offset := headerSize
+	if bitCount <= 8 {
+		if colorsUsed == 0 {
+			colorsUsed = 1 << uint(bitCount)
+		}
+		if offset+colorsUsed*4 > len(data) {
+			return nil, fmt.Errorf("invalid bitmap palette")
+		}
+		for i := 0; i < colorsUsed; i++ {
+			p := data[offset+i*4 : offset+i*4+4]
+			palette = append(palette, color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xff})
+		}
+		offset += colorsUsed * 4
+	} else if compression == 3 {
+		// skip the color masks following the header
+		offset += 12
+	}
+
+	switch bitCount {
+	case 1, 4, 8, 24, 32:
+	default:
+		return nil, fmt.Errorf("unsupported bit-count %d", bitCount)
+	}
+
+	stride := ((width*bitCount + 31) / 32) * 4
+	maskStride := ((width + 31) / 32) * 4
+	if offset+stride*height > len(data) {
+		return nil, fmt.Errorf("invalid bitmap data")
+	}
+	hasMask := offset+stride*height+maskStride*height <= len(data)
+	maskOffset := offset + stride*height
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		// the rows are stored bottom-up
+		row := data[offset+(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch bitCount {
+			case 32:
+				c = color.NRGBA{R: row[x*4+2], G: row[x*4+1], B: row[x*4], A: row[x*4+3]}
+				if c.A != 0 {
+					hasAlpha = true
+				}
+			case 24:
+				c = color.NRGBA{R: row[x*3+2], G: row[x*3+1], B: row[x*3], A: 0xff}
+			default:
+				perByte := 8 / bitCount
+				shift := uint(8 - bitCount*(x%perByte+1))
+				index := int(row[x/perByte]>>shift) & (1<<uint(bitCount) - 1)
+				if index < len(palette) {
+					c = palette[index]
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	// 32bit images carry the transparency in the alpha channel, if the alpha channel is not used
+	// the AND mask defines the transparent pixels
+	if (bitCount != 32 || !hasAlpha) && hasMask {
+		for y := 0; y < height; y++ {
+			row := data[maskOffset+(height-1-y)*maskStride:]
+			for x := 0; x < width; x++ {
+				c := img.NRGBAAt(x, y)
+				if row[x/8]&(0x80>>uint(x%8)) != 0 {
+					c.A = 0
+				} else {
+					c.A = 0xff
+				}
+				img.SetNRGBA(x, y, c)
+			}
+		}
+	} else if bitCount == 32 && !hasAlpha {
+		for i := 3; i < len(img.Pix); i += 4 {
+			img.Pix[i] = 0xff
+		}
+	}
+	return img, nil
+}
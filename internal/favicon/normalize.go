@@ -0,0 +1,183 @@
+package favicon
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+
+	_ "image/gif"  // register the decoder for image.Decode
+	_ "image/jpeg" // register the decoder for image.Decode
+)
+
+// NormalizedSizes defines the fixed dimensions of converted favicons. An image is scaled to the
+// largest size which does not exceed the original dimensions
+var NormalizedSizes = []int{16, 32, 64}
+
+const (
+	// MaxPayloadSize is the maximum size in bytes of a favicon which is accepted
+	MaxPayloadSize = 1024 * 1024
+	// maxDimension prevents decoding of huge images (decompression bombs)
+	maxDimension = 4096
+)
+
+// Image is a validated and normalized favicon
+type Image struct {
+	Payload  []byte
+	MimeType string
+}
+
+// Ext returns the file extension for the image
+func (i Image) Ext() string {
+	return extensionForType(i.MimeType)
+}
+
+// Normalize validates the payload of a favicon. ICO files and images which exceed the largest of the
+// NormalizedSizes are converted to PNG, SVG images are sanitized. Other images are used as they are.
+func Normalize(payload []byte) (Image, error) {
+	if len(payload) == 0 {
+		return Image{}, fmt.Errorf("empty favicon payload")
+	}
+	if len(payload) > MaxPayloadSize {
+		return Image{}, fmt.Errorf("the favicon exceeds the maximum size of %d bytes", MaxPayloadSize)
+	}
+
+	switch mimeType := detectType(payload); mimeType {
+	case "image/x-icon":
+		img, err := decodeICO(payload)
+		if err != nil {
+			return Image{}, fmt.Errorf("invalid ICO favicon: %v", err)
+		}
+		return encodePNG(img)
+
+	case "image/png", "image/gif", "image/jpeg":
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(payload))
+		if err != nil {
+			return Image{}, fmt.Errorf("invalid favicon: %v", err)
+		}
+		if cfg.Width > maxDimension || cfg.Height > maxDimension {
+			return Image{}, fmt.Errorf("the favicon dimensions %dx%d are not supported", cfg.Width, cfg.Height)
+		}
+		img, _, err := image.Decode(bytes.NewReader(payload))
+		if err != nil {
+			return Image{}, fmt.Errorf("invalid favicon: %v", err)
+		}
+		if cfg.Width > maxNormalizedSize() || cfg.Height > maxNormalizedSize() {
+			return encodePNG(img)
+		}
+		return Image{Payload: payload, MimeType: mimeType}, nil
+
+	case "image/webp":
+		return Image{Payload: payload, MimeType: mimeType}, nil
+
+	case "image/svg+xml":
+		svg, err := sanitizeSVG(payload)
+		if err != nil {
+			return Image{}, fmt.Errorf("invalid SVG favicon: %v", err)
+		}
+		return Image{Payload: svg, MimeType: mimeType}, nil
+	}
+
+	return Image{}, fmt.Errorf("the favicon is not a supported image")
+}
+
+// detectType determines the image type by the content of the payload
+func detectType(payload []byte) string {
+	switch {
+	case bytes.HasPrefix(payload, []byte{0, 0, 1, 0}):
+		return "image/x-icon"
+	case bytes.HasPrefix(payload, pngSignature):
+		return "image/png"
+	case bytes.HasPrefix(payload, []byte("GIF87a")), bytes.HasPrefix(payload, []byte("GIF89a")):
+		return "image/gif"
+	case bytes.HasPrefix(payload, []byte{0xff, 0xd8, 0xff}):
+		return "image/jpeg"
+	case len(payload) > 12 && bytes.HasPrefix(payload, []byte("RIFF")) && bytes.Equal(payload[8:12], []byte("WEBP")):
+		return "image/webp"
+	case isSVG(payload):
+		return "image/svg+xml"
+	}
+	return ""
+}
+
+// encodePNG scales the image to one of the NormalizedSizes and encodes it as PNG
+func encodePNG(img image.Image) (Image, error) {
+	b := img.Bounds()
+	size := targetSize(b.Dx(), b.Dy())
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scale(img, size)); err != nil {
+		return Image{}, fmt.Errorf("could not encode favicon: %v", err)
+	}
+	return Image{Payload: buf.Bytes(), MimeType: "image/png"}, nil
+}
+
+func maxNormalizedSize() int {
+	return NormalizedSizes[len(NormalizedSizes)-1]
+}
+
+func targetSize(width, height int) int {
+	dim := width
+	if height > dim {
+		dim = height
+	}
+	size := NormalizedSizes[0]
+	for _, s := range NormalizedSizes {
+		if s <= dim {
+			size = s
+		}
+	}
+	return size
+}
+
+// scale fits the image into a square of the given size. the aspect ratio is kept and the image is centered
+// on a transparent background. the pixels are averaged (box-filter) when downscaling.
+func scale(src image.Image, size int) *image.NRGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// work on premultiplied colors to average transparent pixels correctly
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	dw, dh := size, size
+	if w > h {
+		dh = maxInt(1, h*size/w)
+	} else if h > w {
+		dw = maxInt(1, w*size/h)
+	}
+	offX, offY := (size-dw)/2, (size-dh)/2
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < dh; y++ {
+		y0 := y * h / dh
+		y1 := maxInt(y0+1, (y+1)*h/dh)
+		for x := 0; x < dw; x++ {
+			x0 := x * w / dw
+			x1 := maxInt(x0+1, (x+1)*w/dw)
+
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := rgba.RGBAAt(sx, sy)
+					r += uint32(c.R)
+					g += uint32(c.G)
+					bl += uint32(c.B)
+					a += uint32(c.A)
+					n++
+				}
+			}
+			avg := color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: uint8(a / n)}
+			dst.Set(offX+x, offY+y, avg)
+		}
+	}
+	return dst
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,180 @@
+package favicon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pngPayload(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("could not encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// icoPayload creates an ICO file with a single 2x2 bitmap with 1 bit per pixel
+// the upper left pixel is transparent by the AND mask
+func icoPayload() []byte {
+	var buf bytes.Buffer
+	w := func(v interface{}) { binary.Write(&buf, binary.LittleEndian, v) }
+
+	bitmap := 40 + 2*4 + 2*4 + 2*4
+	// header and directory
+	w([]uint16{0, 1, 1})
+	w([]uint8{2, 2, 2, 0})
+	w([]uint16{1, 1})
+	w([]uint32{uint32(bitmap), 22})
+	// bitmap header, the height covers the color bitmap and the mask
+	w([]uint32{40, 2, 4})
+	w([]uint16{1, 1})
+	w([]uint32{0, 0, 0, 0, 2, 0})
+	// palette: black, red
+	w([]uint8{0, 0, 0, 0, 0, 0, 0xff, 0})
+	// color bitmap bottom-up: row1 = red, black; row0 = black, red
+	w([]uint8{0x80, 0, 0, 0})
+	w([]uint8{0x40, 0, 0, 0})
+	// mask bottom-up: row1 opaque; row0 first pixel transparent
+	w([]uint8{0, 0, 0, 0})
+	w([]uint8{0x80, 0, 0, 0})
+	return buf.Bytes()
+}
+
+// pngICOPayload creates an ICO file with the PNG as single image, the directory declares 16x16 pixels
+func pngICOPayload(payload []byte) []byte {
+	var buf bytes.Buffer
+	w := func(v interface{}) { binary.Write(&buf, binary.LittleEndian, v) }
+
+	w([]uint16{0, 1, 1})
+	w([]uint8{16, 16, 0, 0})
+	w([]uint16{1, 32})
+	w([]uint32{uint32(len(payload)), 22})
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+func TestNormalizeICO(t *testing.T) {
+	payload, err := ioutil.ReadFile("../../assets/favicon.ico")
+	if err != nil {
+		t.Fatalf("could not read favicon: %v", err)
+	}
+	img, err := Normalize(payload)
+	if err != nil {
+		t.Fatalf("could not normalize favicon: %v", err)
+	}
+	assert.Equal(t, "image/png", img.MimeType)
+	assert.Equal(t, ".png", img.Ext())
+
+	// the largest entry has 48x48 pixels, the next normalized size is 32
+	cfg, err := png.DecodeConfig(bytes.NewReader(img.Payload))
+	if err != nil {
+		t.Fatalf("could not decode normalized favicon: %v", err)
+	}
+	assert.Equal(t, 32, cfg.Width)
+	assert.Equal(t, 32, cfg.Height)
+}
+
+func TestDecodeDIB(t *testing.T) {
+	img, err := decodeICO(icoPayload())
+	if err != nil {
+		t.Fatalf("could not decode ICO: %v", err)
+	}
+	assert.Equal(t, 2, img.Bounds().Dx())
+	assert.Equal(t, uint8(0), color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA).A)
+	assert.Equal(t, color.NRGBA{R: 0xff, A: 0xff}, color.NRGBAModel.Convert(img.At(1, 0)))
+	assert.Equal(t, color.NRGBA{R: 0xff, A: 0xff}, color.NRGBAModel.Convert(img.At(0, 1)))
+	assert.Equal(t, color.NRGBA{A: 0xff}, color.NRGBAModel.Convert(img.At(1, 1)))
+
+	// the normalized favicon has the smallest size
+	norm, err := Normalize(icoPayload())
+	if err != nil {
+		t.Fatalf("could not normalize favicon: %v", err)
+	}
+	cfg, _ := png.DecodeConfig(bytes.NewReader(norm.Payload))
+	assert.Equal(t, 16, cfg.Width)
+
+	_, err = decodeICO([]byte{0, 0, 1, 0, 1, 0})
+	assert.Error(t, err)
+}
+
+func TestDecodeICOWithPNG(t *testing.T) {
+	img, err := decodeICO(pngICOPayload(pngPayload(t, 16, 16)))
+	if err != nil {
+		t.Fatalf("could not decode ICO: %v", err)
+	}
+	assert.Equal(t, 16, img.Bounds().Dx())
+
+	// the embedded PNG exceeds the maximum size of an ICO image
+	_, err = decodeICO(pngICOPayload(pngPayload(t, 257, 16)))
+	assert.Error(t, err)
+
+	// the header of the PNG declares a huge image, which would be allocated by the decoder
+	bomb := pngPayload(t, 1, 1)
+	binary.BigEndian.PutUint32(bomb[16:20], 50000)
+	binary.BigEndian.PutUint32(bomb[20:24], 50000)
+	binary.BigEndian.PutUint32(bomb[29:33], crc32.ChecksumIEEE(bomb[12:29]))
+	_, err = decodeICO(pngICOPayload(bomb))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "50000x50000")
+	}
+	_, err = Normalize(pngICOPayload(bomb))
+	assert.Error(t, err)
+}
+
+func TestNormalizeImages(t *testing.T) {
+	// small images are used as they are
+	small := pngPayload(t, 24, 24)
+	img, err := Normalize(small)
+	if err != nil {
+		t.Fatalf("could not normalize favicon: %v", err)
+	}
+	assert.Equal(t, small, img.Payload)
+	assert.Equal(t, "image/png", img.MimeType)
+
+	// oversized images are scaled and centered
+	img, err = Normalize(pngPayload(t, 200, 100))
+	if err != nil {
+		t.Fatalf("could not normalize favicon: %v", err)
+	}
+	scaled, err := png.Decode(bytes.NewReader(img.Payload))
+	if err != nil {
+		t.Fatalf("could not decode normalized favicon: %v", err)
+	}
+	assert.Equal(t, image.Rect(0, 0, 64, 64), scaled.Bounds())
+	assert.Equal(t, uint8(0), color.NRGBAModel.Convert(scaled.At(0, 0)).(color.NRGBA).A)
+	assert.Equal(t, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, color.NRGBAModel.Convert(scaled.At(32, 32)))
+
+	webp := append([]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), make([]byte, 16)...)
+	img, err = Normalize(webp)
+	if err != nil {
+		t.Fatalf("could not normalize favicon: %v", err)
+	}
+	assert.Equal(t, "image/webp", img.MimeType)
+}
+
+func TestNormalizeInvalid(t *testing.T) {
+	_, err := Normalize(nil)
+	assert.Error(t, err)
+
+	_, err = Normalize([]byte("<html><body>not found</body></html>"))
+	assert.Error(t, err)
+
+	// a PNG signature without a valid image
+	_, err = Normalize(append(pngSignature, []byte("broken")...))
+	assert.Error(t, err)
+
+	_, err = Normalize(make([]byte, MaxPayloadSize+1))
+	assert.Error(t, err)
+}
@@ -0,0 +1,122 @@
+package favicon
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bihe/bookmarks/internal"
+	"github.com/bihe/bookmarks/internal/filestore"
+	"github.com/bihe/bookmarks/internal/store"
+)
+
+// DefaultGracePeriod protects recently written files from removal. A favicon is written before the
+// bookmark referencing it is saved, therefore young files might not be referenced yet
+const DefaultGracePeriod = 1 * time.Hour
+
+// favicon files are named by the hash of the content, older versions used the hash of the filename
+// and the hash of the content. only those files are touched by the garbage collection
+var faviconName = regexp.MustCompile(`^[0-9a-f]{40}(_[0-9a-f]{40})?(\.[^/\\]*)?$`)
+
+// the content-hash of a favicon is the name without the file extension
+var faviconHash = regexp.MustCompile(`^[0-9a-f]{40}(_[0-9a-f]{40})?$`)
+
+// Store persists favicons within a directory. The files are named by the hash of the normalized
+// content, therefore identical favicons are stored only once and shared between bookmarks
+type Store struct {
+	filestore.Store
+}
+
+// NewStore creates a Store for the given directory
+func NewStore(path string) *Store {
+	return &Store{filestore.Store{
+		Path:        path,
+		GracePeriod: DefaultGracePeriod,
+		Kind:        "favicon",
+		ValidName:   ValidName,
+		TempPrefix:  ".favicon-",
+	}}
+}
+
+// Save validates and normalizes the payload and writes it to the store, the name of the file is returned
+func (s *Store) Save(payload []byte) (string, error) {
+	img, err := Normalize(payload)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha1.New()
+	if _, err := hash.Write(img.Payload); err != nil {
+		return "", fmt.Errorf("could not hash favicon: %v", err)
+	}
+	name := fmt.Sprintf("%x%s", hash.Sum(nil), img.Ext())
+	if err := s.Write(name, img.Payload); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// FileByHash returns the name of the favicon with the given content-hash, an empty name is returned if the
+// favicon is not available
+func (s *Store) FileByHash(hash string) (string, error) {
+	if !faviconHash.MatchString(hash) {
+		return "", fmt.Errorf("invalid favicon hash '%s'", hash)
+	}
+	// the hash only consists of hex characters, it cannot be interpreted as a pattern
+	matches, err := filepath.Glob(filepath.Join(s.Path, hash+".*"))
+	if err != nil {
+		return "", fmt.Errorf("could not find favicon '%s': %v", hash, err)
+	}
+	if _, err := os.Stat(filepath.Join(s.Path, hash)); err == nil {
+		matches = append(matches, filepath.Join(s.Path, hash))
+	}
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && !info.IsDir() && Hash(filepath.Base(m)) == hash {
+			return filepath.Base(m), nil
+		}
+	}
+	return "", nil
+}
+
+// ValidName checks if the name is a content-addressed favicon file of the store
+func ValidName(name string) bool {
+	return faviconName.MatchString(name)
+}
+
+// Hash returns the content-hash of the favicon name without the file extension
+func Hash(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// Release removes the favicon if it is no longer referenced by any bookmark
+func (s *Store) Release(name string, repo store.Repository) error {
+	return s.Store.Release(name, repo.GetFaviconReferences)
+}
+
+// GarbageCollector is a job which removes the favicons no longer referenced by any bookmark
+type GarbageCollector struct {
+	Store      *Store
+	Repository store.Repository
+}
+
+// Name of the job
+func (g *GarbageCollector) Name() string {
+	return "favicon-gc"
+}
+
+// Run performs the garbage collection
+func (g *GarbageCollector) Run() error {
+	referenced, err := g.Repository.GetAllFavicons()
+	if err != nil {
+		return fmt.Errorf("could not get the referenced favicons: %v", err)
+	}
+	removed, err := g.Store.Collect(referenced)
+	if len(removed) > 0 {
+		internal.LogFunction("favicon.GarbageCollector").Infof("removed %d unreferenced favicons", len(removed))
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+package favicon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// referenceRepository provides the favicon references for the store
+type referenceRepository struct {
+	store.Repository
+	references map[string]int
+}
+
+func (r referenceRepository) GetFaviconReferences(favicon string) (int, error) {
+	return r.references[favicon], nil
+}
+
+func (r referenceRepository) GetAllFavicons() ([]string, error) {
+	var favicons []string
+	for f, n := range r.references {
+		if n > 0 {
+			favicons = append(favicons, f)
+		}
+	}
+	return favicons, nil
+}
+
+func tempStore(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "favicons")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	s := NewStore(dir)
+	s.GracePeriod = 0
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func TestStoreSave(t *testing.T) {
+	s, cleanup := tempStore(t)
+	defer cleanup()
+
+	payload, _ := ioutil.ReadFile("../../assets/favicon.ico")
+	name, err := s.Save(payload)
+	if err != nil {
+		t.Fatalf("could not save favicon: %v", err)
+	}
+	assert.Regexp(t, `^[0-9a-f]{40}\.png$`, name)
+
+	// identical favicons are stored only once
+	other, err := s.Save(payload)
+	if err != nil {
+		t.Fatalf("could not save favicon: %v", err)
+	}
+	assert.Equal(t, name, other)
+
+	files, _ := ioutil.ReadDir(s.Path)
+	assert.Equal(t, 1, len(files))
+
+	_, err = s.Save([]byte("<html></html>"))
+	assert.Error(t, err)
+	files, _ = ioutil.ReadDir(s.Path)
+	assert.Equal(t, 1, len(files))
+}
+
+func TestStoreRelease(t *testing.T) {
+	s, cleanup := tempStore(t)
+	defer cleanup()
+
+	name, err := s.Save(pngPayload(t, 16, 16))
+	if err != nil {
+		t.Fatalf("could not save favicon: %v", err)
+	}
+
+	repo := referenceRepository{references: map[string]int{name: 1}}
+	assert.NoError(t, s.Release(name, repo))
+	assert.FileExists(t, filepath.Join(s.Path, name))
+
+	repo.references[name] = 0
+	assert.NoError(t, s.Release(name, repo))
+	_, err = os.Stat(filepath.Join(s.Path, name))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, s.Release("", repo))
+	assert.Error(t, s.Release("../"+name, repo))
+}
+
+func TestGarbageCollector(t *testing.T) {
+	s, cleanup := tempStore(t)
+	defer cleanup()
+
+	used, _ := s.Save(pngPayload(t, 16, 16))
+	unused, _ := s.Save(pngPayload(t, 32, 32))
+	legacy := "0123456789abcdef0123456789abcdef01234567_0123456789abcdef0123456789abcdef01234567.ico"
+	recent := "89abcdef0123456789abcdef0123456789abcdef.png"
+	for _, f := range []string{legacy, recent, "readme.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(s.Path, f), []byte("x"), 0644); err != nil {
+			t.Fatalf("could not write file: %v", err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	for _, f := range []string{used, unused, legacy, "readme.txt"} {
+		if err := os.Chtimes(filepath.Join(s.Path, f), old, old); err != nil {
+			t.Fatalf("could not change file time: %v", err)
+		}
+	}
+	s.GracePeriod = time.Hour
+
+	gc := &GarbageCollector{
+		Store:      s,
+		Repository: referenceRepository{references: map[string]int{used: 2}},
+	}
+	assert.Equal(t, "favicon-gc", gc.Name())
+	assert.NoError(t, gc.Run())
+
+	var remaining []string
+	files, _ := ioutil.ReadDir(s.Path)
+	for _, f := range files {
+		remaining = append(remaining, f.Name())
+	}
+	// unknown files and files within the grace-period are kept
+	assert.ElementsMatch(t, []string{used, recent, "readme.txt"}, remaining)
+
+	_, err := (&Store{}).Collect(nil)
+	assert.Error(t, err)
+}
+
+func TestStoreFile(t *testing.T) {
+	s := NewStore("/favicons")
+	hash := "0123456789abcdef0123456789abcdef01234567"
+
+	file, err := s.File(hash + ".png")
+	assert.NoError(t, err)
+	assert.Equal(t, "/favicons/"+hash+".png", file)
+	assert.Equal(t, hash, Hash(hash+".png"))
+
+	for _, name := range []string{"", "favicon.ico", "../" + hash + ".png", hash + "/x.png"} {
+		_, err = s.File(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestStoreFileByHash(t *testing.T) {
+	s, cleanup := tempStore(t)
+	defer cleanup()
+
+	name, err := s.Save(pngPayload(t, 16, 16))
+	if err != nil {
+		t.Fatalf("could not save favicon: %v", err)
+	}
+	found, err := s.FileByHash(Hash(name))
+	assert.NoError(t, err)
+	assert.Equal(t, name, found)
+
+	found, err = s.FileByHash("0123456789abcdef0123456789abcdef01234567")
+	assert.NoError(t, err)
+	assert.Equal(t, "", found)
+
+	for _, hash := range []string{"", name, "*", "../" + Hash(name)} {
+		_, err = s.FileByHash(hash)
+		assert.Error(t, err, hash)
+	}
+}
@@ -0,0 +1,138 @@
+package favicon
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const svgNamespace = "http://www.w3.org/2000/svg"
+
+// elements which are able to execute scripts, embed external content or change attributes at runtime
+var forbiddenSVGElements = map[string]bool{
+	"script":           true,
+	"foreignobject":    true,
+	"iframe":           true,
+	"embed":            true,
+	"object":           true,
+	"audio":            true,
+	"video":            true,
+	"handler":          true,
+	"listener":         true,
+	"set":              true,
+	"animate":          true,
+	"animatemotion":    true,
+	"animatetransform": true,
+	"discard":          true,
+}
+
+// isSVG checks if the payload is an SVG document
+func isSVG(payload []byte) bool {
+	head := payload
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	head = bytes.TrimSpace(head)
+	return bytes.HasPrefix(head, []byte("<")) && bytes.Contains(bytes.ToLower(head), []byte("<svg"))
+}
+
+// sanitizeSVG removes scripts, event-handlers and references to external resources from the SVG.
+// only the svg element is used, processing instructions and document type definitions are dropped
+func sanitizeSVG(payload []byte) ([]byte, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse SVG: %v", err)
+	}
+	svg := doc.Find("svg").First()
+	if svg.Length() == 0 {
+		return nil, fmt.Errorf("no svg element found")
+	}
+
+	sanitize := func(s *goquery.Selection) {
+		n := s.Get(0)
+		if forbiddenSVGElements[strings.ToLower(n.Data)] {
+			s.Remove()
+			return
+		}
+		if strings.ToLower(n.Data) == "style" && !safeStyle(s.Text()) {
+			s.Remove()
+			return
+		}
+		attrs := n.Attr[:0]
+		for _, a := range n.Attr {
+			if safeAttribute(a.Namespace, a.Key, a.Val) {
+				attrs = append(attrs, a)
+			}
+		}
+		n.Attr = attrs
+	}
+	sanitize(svg)
+	svg.Find("*").Each(func(i int, s *goquery.Selection) {
+		sanitize(s)
+	})
+
+	if _, ok := svg.Attr("xmlns"); !ok {
+		svg.SetAttr("xmlns", svgNamespace)
+	}
+
+	content, err := goquery.OuterHtml(svg)
+	if err != nil {
+		return nil, fmt.Errorf("could not render SVG: %v", err)
+	}
+	return []byte(content), nil
+}
+
+func safeAttribute(namespace, key, value string) bool {
+	key = strings.ToLower(key)
+	v := strings.ToLower(strings.Join(strings.Fields(value), ""))
+
+	if strings.HasPrefix(key, "on") {
+		return false
+	}
+	if strings.Contains(v, "javascript:") || strings.Contains(v, "vbscript:") {
+		return false
+	}
+	switch {
+	case key == "href" || key == "xlink:href" || (namespace == "xlink" && key == "href"):
+		// only references within the document or embedded raster images are allowed
+		return strings.HasPrefix(v, "#") || isRasterDataURI(v)
+	case key == "style":
+		return safeStyle(value)
+	}
+	return !externalReference(v)
+}
+
+// safeStyle denies styles which import or reference external resources
+func safeStyle(style string) bool {
+	s := strings.ToLower(strings.Join(strings.Fields(style), ""))
+	if strings.Contains(s, "@import") || strings.Contains(s, "expression(") || strings.Contains(s, "javascript:") {
+		return false
+	}
+	return !externalReference(s)
+}
+
+// externalReference checks for url(...) references which do not point to a fragment of the document
+func externalReference(value string) bool {
+	for {
+		i := strings.Index(value, "url(")
+		if i == -1 {
+			return false
+		}
+		value = strings.TrimLeft(value[i+len("url("):], `'"`)
+		if !strings.HasPrefix(value, "#") && !isRasterDataURI(value) {
+			return true
+		}
+	}
+}
+
+func isRasterDataURI(value string) bool {
+	for _, t := range []string{"data:image/png", "data:image/gif", "data:image/jpeg", "data:image/webp"} {
+		if strings.HasPrefix(value, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package favicon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const unsafeSVG = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE svg>
+<svg viewBox="0 0 16 16" onload="alert(1)">
+  <script>alert(1)</script>
+  <style>@import url(https://evil.example.com/x.css);</style>
+  <style>.a { fill: red; }</style>
+  <defs><linearGradient id="g"/></defs>
+  <a href="javascript:alert(1)"><circle cx="8" cy="8" r="8" fill="url(#g)"/></a>
+  <image href="https://tracker.example.com/pixel.png" width="1" height="1"/>
+  <use xlink:href="#g"/>
+  <rect style="fill: url(https://evil.example.com/x)" onclick="alert(1)" width="4" height="4"/>
+  <foreignObject><div>html</div></foreignObject>
+  <set attributeName="href" to="javascript:alert(1)"/>
+</svg>`
+
+func TestSanitizeSVG(t *testing.T) {
+	img, err := Normalize([]byte(unsafeSVG))
+	if err != nil {
+		t.Fatalf("could not normalize SVG: %v", err)
+	}
+	assert.Equal(t, "image/svg+xml", img.MimeType)
+	assert.Equal(t, ".svg", img.Ext())
+
+	svg := string(img.Payload)
+	assert.Contains(t, svg, `xmlns="http://www.w3.org/2000/svg"`)
+	assert.Contains(t, svg, `fill="url(#g)"`)
+	assert.Contains(t, svg, `.a { fill: red; }`)
+	assert.Contains(t, svg, `<use xlink:href="#g">`)
+	assert.Contains(t, svg, `width="4"`)
+
+	for _, s := range []string{"<?xml", "DOCTYPE", "onload", "onclick", "<script", "@import", "javascript:", "tracker.example.com", "evil.example.com", "foreignobject", "<set"} {
+		assert.NotContains(t, svg, s)
+	}
+}
+
+func TestSanitizeSVGInvalid(t *testing.T) {
+	assert.False(t, isSVG([]byte("<html><body></body></html>")))
+	assert.True(t, isSVG([]byte("\xef\xbb\xbf  <svg></svg>")))
+
+	_, err := sanitizeSVG([]byte("<html><body></body></html>"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,381 @@
+// Package fetch provides a hardened HTTP client to retrieve external resources like pages and favicons
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultTimeout limits the overall duration of a request including redirects and reading the body
+	DefaultTimeout = 10 * time.Second
+	// DefaultMaxBodySize is the maximum number of bytes read from a response body
+	DefaultMaxBodySize = 5 * 1024 * 1024
+	// DefaultMaxRedirects is the maximum number of redirects followed for a request
+	DefaultMaxRedirects = 5
+	// DefaultUserAgent is sent with each request if no specific agent is configured
+	DefaultUserAgent = "bookmarks/1.0 (+https://github.com/bihe/bookmarks)"
+)
+
+// the address ranges which are denied if private networks are not explicitly allowed
+var privateNetworks = []string{
+	"0.0.0.0/8",      // "this" network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // carrier-grade NAT
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local
+	"172.16.0.0/12",  // private
+	"192.0.0.0/24",   // IETF protocol assignments
+	"192.168.0.0/16", // private
+	"198.18.0.0/15",  // benchmarking
+	"224.0.0.0/4",    // multicast
+	"240.0.0.0/4",    // reserved
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"64:ff9b::/96",   // NAT64, maps to IPv4 addresses
+	"fc00::/7",       // unique local
+	"fe80::/10",      // link-local
+	"ff00::/8",       // multicast
+}
+
+// Options configures the behavior of the Client
+type Options struct {
+	// Timeout of a request, DefaultTimeout is used if not set
+	Timeout time.Duration
+	// MaxBodySize is the maximum number of bytes read, DefaultMaxBodySize is used if not set
+	MaxBodySize int64
+	// UserAgent is sent with every request, DefaultUserAgent is used if not set
+	UserAgent string
+	// MaxRedirects defines how many redirects are followed, DefaultMaxRedirects is used if not set.
+	// a negative value disables redirects
+	MaxRedirects int
+	// Proxy is the URL of a HTTP proxy used for all requests
+	Proxy string
+	// AllowPrivateNetworks disables the deny-list for private, loopback and link-local addresses
+	AllowPrivateNetworks bool
+	// DenyNetworks defines additional address ranges in CIDR notation which are not accessible
+	DenyNetworks []string
+}
+
+// Fetcher retrieves external resources identified by URLs
+type Fetcher interface {
+	Get(url string) (*Response, error)
+	Head(url string) (*Response, error)
+	Probe(url string) (*Response, error)
+}
+
+// Response is the result of a fetch operation
+type Response struct {
+	// URL is the final URL after redirects
+	URL         string
+	StatusCode  int
+	ContentType string
+	Header      http.Header
+	Body        []byte
+	// Redirects lists the redirects which were followed in the order of the requests
+	Redirects []Redirect
+}
+
+// Redirect is a response which redirected the request to another location
+type Redirect struct {
+	// URL is the requested URL which responded with the redirect
+	URL        string
+	StatusCode int
+	// Location is the URL the request was redirected to
+	Location string
+}
+
+// IsPermanent checks if the redirect is permanent (301 or 308)
+func (r Redirect) IsPermanent() bool {
+	return r.StatusCode == http.StatusMovedPermanently || r.StatusCode == http.StatusPermanentRedirect
+}
+
+// PermanentURL follows the chain of permanent redirects from the requested URL and returns the
+// location of the last permanent redirect. If the first redirect is not permanent, an empty string is returned
+func (r *Response) PermanentURL() string {
+	var location string
+	for _, rd := range r.Redirects {
+		if !rd.IsPermanent() {
+			break
+		}
+		location = rd.Location
+	}
+	return location
+}
+
+// IsType checks if the media-type of the response starts with one of the given types
+// e.g. "image/" matches "image/png"
+func (r *Response) IsType(types ...string) bool {
+	mediaType, _, err := mime.ParseMediaType(r.ContentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(r.ContentType))
+	}
+	for _, t := range types {
+		if strings.HasPrefix(mediaType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Client implements the Fetcher with the configured restrictions
+type Client struct {
+	client *http.Client
+	// post does not follow redirects, the body and the method are not repeated for another location
+	post   *http.Client
+	opts   Options
+	denied []*net.IPNet
+	proxy  bool
+}
+
+// New creates a Client using the supplied options
+func New(opts Options) (*Client, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = DefaultMaxBodySize
+	}
+	if opts.MaxRedirects == 0 {
+		opts.MaxRedirects = DefaultMaxRedirects
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+
+	c := &Client{opts: opts}
+
+	networks := opts.DenyNetworks
+	if !opts.AllowPrivateNetworks {
+		networks = append(networks, privateNetworks...)
+	}
+	for _, n := range networks {
+		_, ipNet, err := net.ParseCIDR(n)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network '%s' in deny-list: %v", n, err)
+		}
+		c.denied = append(c.denied, ipNet)
+	}
+
+	var proxy func(*http.Request) (*url.URL, error)
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL '%s'", opts.Proxy)
+		}
+		proxy = http.ProxyURL(proxyURL)
+		c.proxy = true
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   opts.Timeout,
+		KeepAlive: 30 * time.Second,
+		// the check is performed on the resolved address right before the connection is established
+		// this also covers redirects and DNS entries pointing to internal addresses
+		Control: c.checkConnection,
+	}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          20,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   opts.Timeout,
+		ResponseHeaderTimeout: opts.Timeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	c.client = &http.Client{
+		Transport:     transport,
+		Timeout:       opts.Timeout,
+		CheckRedirect: c.checkRedirect,
+	}
+	c.post = &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return c, nil
+}
+
+// Get retrieves the resource identified by the URL
+func (c *Client) Get(url string) (*Response, error) {
+	return c.do(http.MethodGet, url, true)
+}
+
+// Head retrieves the meta-data of the resource identified by the URL, the body is not read
+func (c *Client) Head(url string) (*Response, error) {
+	return c.do(http.MethodHead, url, false)
+}
+
+// Probe requests the resource identified by the URL with GET but does not read the body.
+// It is used for servers which do not answer HEAD requests properly
+func (c *Client) Probe(url string) (*Response, error) {
+	return c.do(http.MethodGet, url, false)
+}
+
+// Post sends the body with the given header to the URL, redirects are not followed. The body of the response is read
+func (c *Client) Post(url string, header http.Header, body []byte) (*Response, error) {
+	req, err := c.request(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return c.send(c.post, req, url, true)
+}
+
+func (c *Client) do(method, uri string, readBody bool) (*Response, error) {
+	req, err := c.request(method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(c.client, req, uri, readBody)
+}
+
+func (c *Client) request(method, uri string, body io.Reader) (*http.Request, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse url '%s': %v", uri, err)
+	}
+	if err := c.checkURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %v", err)
+	}
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+	return req, nil
+}
+
+func (c *Client) send(client *http.Client, req *http.Request, uri string, readBody bool) (*Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch '%s': %v", uri, err)
+	}
+	defer resp.Body.Close()
+
+	result := &Response{
+		URL:         resp.Request.URL.String(),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Header:      resp.Header,
+		Redirects:   redirects(resp),
+	}
+	if !readBody {
+		return result, nil
+	}
+
+	if resp.ContentLength > c.opts.MaxBodySize {
+		return nil, fmt.Errorf("the content-length %d of '%s' exceeds the maximum size of %d bytes", resp.ContentLength, uri, c.opts.MaxBodySize)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, c.opts.MaxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not read content body: %v", err)
+	}
+	if int64(len(body)) > c.opts.MaxBodySize {
+		return nil, fmt.Errorf("the content of '%s' exceeds the maximum size of %d bytes", uri, c.opts.MaxBodySize)
+	}
+	result.Body = body
+	if result.ContentType == "" {
+		result.ContentType = http.DetectContentType(body)
+	}
+	return result, nil
+}
+
+// redirects collects the redirect responses which lead to the given response. Every request created
+// because of a redirect references the response which caused the redirect
+func redirects(resp *http.Response) []Redirect {
+	var chain []Redirect
+	req := resp.Request
+	for req != nil && req.Response != nil {
+		prev := req.Response
+		chain = append([]Redirect{{
+			URL:        prev.Request.URL.String(),
+			StatusCode: prev.StatusCode,
+			Location:   req.URL.String(),
+		}}, chain...)
+		req = prev.Request
+	}
+	return chain
+}
+
+// checkURL validates the scheme. if a proxy is used the connection is established to the proxy,
+// therefore the host is resolved upfront and validated against the deny-list
+func (c *Client) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("the scheme '%s' is not supported", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("no host supplied in url '%s'", u.String())
+	}
+	if !c.proxy {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.Timeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("could not resolve host '%s': %v", u.Hostname(), err)
+	}
+	for _, a := range addrs {
+		if c.isDenied(a.IP) {
+			return fmt.Errorf("access to address '%s' of host '%s' is denied", a.IP, u.Hostname())
+		}
+	}
+	return nil
+}
+
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if c.opts.MaxRedirects < 0 || len(via) > c.opts.MaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", len(via)-1)
+	}
+	return c.checkURL(req.URL)
+}
+
+// checkConnection is called by the dialer with the resolved ip-address. if a proxy is used,
+// all connections are established to the proxy and the target was already validated by checkURL
+func (c *Client) checkConnection(network, address string, conn syscall.RawConn) error {
+	if c.proxy {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid address '%s': %v", address, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("invalid ip-address '%s'", host)
+	}
+	if c.isDenied(ip) {
+		return fmt.Errorf("access to address '%s' is denied", ip)
+	}
+	return nil
+}
+
+func (c *Client) isDenied(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	for _, n := range c.denied {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,283 @@
+package fetch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("content-type", "text/html; charset=utf-8")
+		if _, err := w.Write([]byte("<html><body>" + r.UserAgent() + "</body></html>")); err != nil {
+			t.Fatalf("%v", err)
+		}
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write([]byte(strings.Repeat("a", 2048))); err != nil {
+			t.Fatalf("%v", err)
+		}
+	})
+	mux.HandleFunc("/redirect/", func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/redirect/"), "%d", &n)
+		if n <= 0 {
+			http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/redirect/%d", n-1), http.StatusFound)
+	})
+	mux.HandleFunc("/moved/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/moved/a":
+			http.Redirect(w, r, "/moved/b", http.StatusMovedPermanently)
+		case "/moved/b":
+			http.Redirect(w, r, "/moved/c", http.StatusPermanentRedirect)
+		case "/moved/c":
+			http.Redirect(w, r, "/page", http.StatusFound)
+		}
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Add("content-type", "text/plain")
+		fmt.Fprintf(w, "%s %s %s %s", r.Method, r.Header.Get("X-Test"), r.UserAgent(), body)
+	})
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://10.255.255.1/secret", http.StatusFound)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestFetchDenyPrivateNetworks(t *testing.T) {
+	ts := testServer(t)
+	defer ts.Close()
+
+	client, err := New(Options{})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
+	// the test-server listens on the loopback interface
+	_, err = client.Get(ts.URL + "/page")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "denied")
+
+	_, err = client.Get("http://[::1]:1/page")
+	assert.Error(t, err)
+
+	_, err = client.Get("http://169.254.169.254/latest/meta-data")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "denied")
+
+	// the NAT64 address of 10.0.0.1
+	_, err = client.Get("http://[64:ff9b::a00:1]/page")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "denied")
+
+	// additional networks are denied even if private networks are allowed
+	client, err = New(Options{AllowPrivateNetworks: true, DenyNetworks: []string{"127.0.0.0/8"}})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	_, err = client.Get(ts.URL + "/page")
+	assert.Error(t, err)
+
+	// a redirect to a denied address is not followed
+	client, err = New(Options{AllowPrivateNetworks: true, DenyNetworks: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	_, err = client.Get(ts.URL + "/internal")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "denied")
+}
+
+func TestFetch(t *testing.T) {
+	ts := testServer(t)
+	defer ts.Close()
+
+	client, err := New(Options{AllowPrivateNetworks: true, UserAgent: "test-agent"})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
+	resp, err := client.Get(ts.URL + "/page")
+	if err != nil {
+		t.Fatalf("could not fetch page: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "<html><body>test-agent</body></html>", string(resp.Body))
+	assert.True(t, resp.IsType("text/html"))
+	assert.False(t, resp.IsType("image/"))
+
+	resp, err = client.Head(ts.URL + "/page")
+	if err != nil {
+		t.Fatalf("could not fetch page: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 0, len(resp.Body))
+
+	resp, err = client.Probe(ts.URL + "/large")
+	if err != nil {
+		t.Fatalf("could not fetch page: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 0, len(resp.Body))
+
+	resp, err = client.Get(ts.URL + "/unknown")
+	if err != nil {
+		t.Fatalf("could not fetch page: %v", err)
+	}
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	_, err = client.Get("ftp://example.com/file")
+	assert.Error(t, err)
+	_, err = client.Get("http:///path")
+	assert.Error(t, err)
+}
+
+func TestFetchPost(t *testing.T) {
+	ts := testServer(t)
+	defer ts.Close()
+
+	client, err := New(Options{AllowPrivateNetworks: true, UserAgent: "test-agent"})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
+	resp, err := client.Post(ts.URL+"/echo", http.Header{"X-Test": []string{"value"}}, []byte("payload"))
+	if err != nil {
+		t.Fatalf("could not post: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "POST value test-agent payload", string(resp.Body))
+
+	// redirects are not followed
+	resp, err = client.Post(ts.URL+"/redirect/0", nil, nil)
+	if err != nil {
+		t.Fatalf("could not post: %v", err)
+	}
+	assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal(t, 0, len(resp.Redirects))
+
+	_, err = client.Post("ftp://example.com/file", nil, nil)
+	assert.Error(t, err)
+
+	// the deny-list applies to the posts as well
+	client, _ = New(Options{})
+	_, err = client.Post(ts.URL+"/echo", nil, nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "denied")
+}
+
+func TestFetchMaxBodySize(t *testing.T) {
+	ts := testServer(t)
+	defer ts.Close()
+
+	client, err := New(Options{AllowPrivateNetworks: true, MaxBodySize: 1024})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	_, err = client.Get(ts.URL + "/large")
+	assert.Error(t, err)
+
+	_, err = client.Get(ts.URL + "/page")
+	assert.NoError(t, err)
+}
+
+func TestFetchRedirects(t *testing.T) {
+	ts := testServer(t)
+	defer ts.Close()
+
+	client, err := New(Options{AllowPrivateNetworks: true, MaxRedirects: 3})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
+	resp, err := client.Get(ts.URL + "/redirect/2")
+	if err != nil {
+		t.Fatalf("could not fetch page: %v", err)
+	}
+	assert.Equal(t, ts.URL+"/page", resp.URL)
+
+	assert.Equal(t, 3, len(resp.Redirects))
+	assert.Equal(t, ts.URL+"/redirect/2", resp.Redirects[0].URL)
+	assert.Equal(t, ts.URL+"/redirect/1", resp.Redirects[0].Location)
+	assert.Equal(t, http.StatusMovedPermanently, resp.Redirects[2].StatusCode)
+	// the first redirect is temporary
+	assert.Equal(t, "", resp.PermanentURL())
+
+	_, err = client.Get(ts.URL + "/redirect/3")
+	assert.Error(t, err)
+
+	// only the leading permanent redirects are followed
+	resp, err = client.Head(ts.URL + "/moved/a")
+	if err != nil {
+		t.Fatalf("could not fetch page: %v", err)
+	}
+	assert.Equal(t, ts.URL+"/page", resp.URL)
+	assert.Equal(t, ts.URL+"/moved/c", resp.PermanentURL())
+
+	resp, err = client.Get(ts.URL + "/page")
+	if err != nil {
+		t.Fatalf("could not fetch page: %v", err)
+	}
+	assert.Equal(t, 0, len(resp.Redirects))
+	assert.Equal(t, "", resp.PermanentURL())
+
+	client, err = New(Options{AllowPrivateNetworks: true, MaxRedirects: -1})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	_, err = client.Get(ts.URL + "/redirect/0")
+	assert.Error(t, err)
+}
+
+func TestFetchProxy(t *testing.T) {
+	ts := testServer(t)
+	defer ts.Close()
+
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		if _, err := w.Write([]byte("proxied")); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}))
+	defer proxy.Close()
+
+	client, err := New(Options{AllowPrivateNetworks: true, Proxy: proxy.URL})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	resp, err := client.Get(ts.URL + "/page")
+	if err != nil {
+		t.Fatalf("could not fetch page: %v", err)
+	}
+	assert.Equal(t, "proxied", string(resp.Body))
+	assert.Equal(t, ts.URL+"/page", proxied)
+
+	// the target is validated before the request is sent to the proxy
+	proxied = ""
+	client, err = New(Options{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	_, err = client.Get(ts.URL + "/page")
+	assert.Error(t, err)
+	assert.Equal(t, "", proxied)
+}
+
+func TestInvalidOptions(t *testing.T) {
+	_, err := New(Options{DenyNetworks: []string{"10.0.0.0"}})
+	assert.Error(t, err)
+
+	_, err = New(Options{Proxy: "::invalid"})
+	assert.Error(t, err)
+}
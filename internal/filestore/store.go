@@ -0,0 +1,154 @@
+// Package filestore keeps content-addressed files within a directory. The files are named by the hash of the
+// content, therefore identical content is stored only once and can be shared between references
+package filestore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Store persists the files within a directory. Only the files with a valid name and the temporary files are
+// touched by the removal, other files of the directory are kept
+type Store struct {
+	Path string
+	// GracePeriod protects recently written files from removal. A file is written before the reference is
+	// saved, therefore young files might not be referenced yet
+	GracePeriod time.Duration
+	// Kind describes the files in the errors of the store
+	Kind string
+	// ValidName checks if the name is a content-addressed file of the store
+	ValidName func(name string) bool
+	// TempPrefix is used for the temporary files, which are created while files are written
+	TempPrefix string
+}
+
+// Write stores the content with the given name. The timestamp of an existing file is renewed to protect it
+// from removal
+func (s *Store) Write(name string, content []byte) error {
+	if !s.ValidName(name) {
+		return fmt.Errorf("invalid %s name '%s'", s.Kind, name)
+	}
+	if err := os.MkdirAll(s.Path, 0755); err != nil {
+		return fmt.Errorf("could not create %s path '%s': %v", s.Kind, s.Path, err)
+	}
+	fullPath := filepath.Join(s.Path, name)
+
+	if _, err := os.Stat(fullPath); err == nil {
+		now := time.Now()
+		if err := os.Chtimes(fullPath, now, now); err != nil {
+			return fmt.Errorf("could not update %s '%s': %v", s.Kind, name, err)
+		}
+		return nil
+	}
+
+	// write to a temporary file first to prevent partially written files
+	tmp, err := ioutil.TempFile(s.Path, s.TempPrefix)
+	if err != nil {
+		return fmt.Errorf("could not create %s file: %v", s.Kind, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write %s file: %v", s.Kind, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write %s file: %v", s.Kind, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("could not write %s file: %v", s.Kind, err)
+	}
+	if err := os.Rename(tmp.Name(), fullPath); err != nil {
+		return fmt.Errorf("could not write %s file '%s': %v", s.Kind, name, err)
+	}
+	return nil
+}
+
+// File returns the full path of the file with the given name
+func (s *Store) File(name string) (string, error) {
+	if !s.ValidName(name) {
+		return "", fmt.Errorf("invalid %s name '%s'", s.Kind, name)
+	}
+	return filepath.Join(s.Path, name), nil
+}
+
+// Release removes the file if it is no longer referenced, the references are counted by the given function
+func (s *Store) Release(name string, references func(name string) (int, error)) error {
+	if name == "" {
+		return nil
+	}
+	count, err := references(name)
+	if err != nil {
+		return fmt.Errorf("could not get references of %s '%s': %v", s.Kind, name, err)
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err = s.remove(name)
+	return err
+}
+
+// Collect removes all files of the store which are not part of the referenced files
+func (s *Store) Collect(referenced []string) ([]string, error) {
+	var removed []string
+
+	if s.Path == "" {
+		return nil, fmt.Errorf("no %s path defined", s.Kind)
+	}
+
+	files, err := ioutil.ReadDir(s.Path)
+	if os.IsNotExist(err) {
+		// nothing was stored yet
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s path '%s': %v", s.Kind, s.Path, err)
+	}
+
+	refs := make(map[string]bool)
+	for _, r := range referenced {
+		refs[r] = true
+	}
+
+	for _, f := range files {
+		if f.IsDir() || refs[f.Name()] {
+			continue
+		}
+		ok, err := s.remove(f.Name())
+		if err != nil {
+			return removed, err
+		}
+		if ok {
+			removed = append(removed, f.Name())
+		}
+	}
+	return removed, nil
+}
+
+// remove deletes the given file if it is older than the grace-period
+func (s *Store) remove(name string) (bool, error) {
+	if filepath.Base(name) != name {
+		return false, fmt.Errorf("invalid %s name '%s'", s.Kind, name)
+	}
+	if !s.ValidName(name) && !strings.HasPrefix(name, s.TempPrefix) {
+		return false, nil
+	}
+	fullPath := filepath.Join(s.Path, name)
+	info, err := os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not access %s '%s': %v", s.Kind, name, err)
+	}
+	if time.Since(info.ModTime()) < s.GracePeriod {
+		return false, nil
+	}
+	if err := os.Remove(fullPath); err != nil {
+		return false, fmt.Errorf("could not remove %s '%s': %v", s.Kind, name, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,91 @@
+package filestore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testName = regexp.MustCompile(`^[0-9a-f]{4}\.txt$`)
+
+func tempStore(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "filestore")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	s := &Store{
+		Path:       filepath.Join(dir, "files"),
+		Kind:       "file",
+		ValidName:  testName.MatchString,
+		TempPrefix: ".file-",
+	}
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func TestStoreWrite(t *testing.T) {
+	s, cleanup := tempStore(t)
+	defer cleanup()
+
+	assert.NoError(t, s.Write("abcd.txt", []byte("content")))
+	// the existing file is kept
+	assert.NoError(t, s.Write("abcd.txt", []byte("other")))
+	file, err := s.File("abcd.txt")
+	if err != nil {
+		t.Fatalf("could not get file: %v", err)
+	}
+	content, _ := ioutil.ReadFile(file)
+	assert.Equal(t, "content", string(content))
+
+	assert.Error(t, s.Write("../abcd.txt", []byte("content")))
+	_, err = s.File("readme.txt")
+	assert.Error(t, err)
+	files, _ := ioutil.ReadDir(s.Path)
+	assert.Equal(t, 1, len(files))
+}
+
+func TestStoreReleaseAndCollect(t *testing.T) {
+	s, cleanup := tempStore(t)
+	defer cleanup()
+
+	removed, err := s.Collect(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(removed))
+
+	for _, name := range []string{"aaaa.txt", "bbbb.txt", "cccc.txt"} {
+		if err := s.Write(name, []byte(name)); err != nil {
+			t.Fatalf("could not write file: %v", err)
+		}
+	}
+	for _, name := range []string{".file-123", "readme.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(s.Path, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("could not write file: %v", err)
+		}
+	}
+
+	references := func(name string) (int, error) {
+		if name == "aaaa.txt" {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	assert.NoError(t, s.Release("aaaa.txt", references))
+	assert.FileExists(t, filepath.Join(s.Path, "aaaa.txt"))
+	assert.NoError(t, s.Release("bbbb.txt", references))
+	_, err = os.Stat(filepath.Join(s.Path, "bbbb.txt"))
+	assert.True(t, os.IsNotExist(err))
+	assert.NoError(t, s.Release("", references))
+	assert.Error(t, s.Release("../aaaa.txt", references))
+
+	// unknown files are kept
+	removed, err = s.Collect([]string{"aaaa.txt"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"cccc.txt", ".file-123"}, removed)
+	assert.FileExists(t, filepath.Join(s.Path, "readme.txt"))
+
+	_, err = (&Store{}).Collect(nil)
+	assert.Error(t, err)
+}
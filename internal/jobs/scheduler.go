@@ -0,0 +1,92 @@
+// Package jobs executes recurring background tasks of the application
+package jobs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bihe/bookmarks/internal"
+)
+
+// Job is a task which is executed periodically
+type Job interface {
+	Name() string
+	Run() error
+}
+
+type entry struct {
+	job      Job
+	interval time.Duration
+}
+
+// Scheduler runs the registered jobs in their defined intervals
+type Scheduler struct {
+	entries []entry
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewScheduler creates a new Scheduler without any jobs
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// Add registers a job with the given interval, jobs without a positive interval are ignored
+func (s *Scheduler) Add(job Job, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	s.entries = append(s.entries, entry{job: job, interval: interval})
+}
+
+// Start executes every job immediately and afterwards in the defined interval
+func (s *Scheduler) Start() {
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	for _, e := range s.entries {
+		s.wg.Add(1)
+		go s.schedule(e, s.stop)
+	}
+}
+
+// Stop ends the scheduling and waits for running jobs to finish
+func (s *Scheduler) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	s.wg.Wait()
+	s.stop = nil
+}
+
+func (s *Scheduler) schedule(e entry, stop chan struct{}) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		run(e.job)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func run(job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			internal.LogFunction("jobs.run").Errorf("job '%s' panicked: %v", job.Name(), r)
+		}
+	}()
+	start := time.Now()
+	if err := job.Run(); err != nil {
+		internal.LogFunction("jobs.run").Errorf("job '%s' failed: %v", job.Name(), err)
+		return
+	}
+	internal.LogFunction("jobs.run").Debugf("job '%s' finished in %s", job.Name(), time.Since(start))
+}
@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingJob struct {
+	runs int32
+	fail bool
+}
+
+func (j *countingJob) Name() string {
+	return "counting"
+}
+
+func (j *countingJob) Run() error {
+	atomic.AddInt32(&j.runs, 1)
+	if j.fail {
+		return fmt.Errorf("failed")
+	}
+	return nil
+}
+
+type panicJob struct{}
+
+func (j panicJob) Name() string {
+	return "panic"
+}
+
+func (j panicJob) Run() error {
+	panic("error")
+}
+
+func TestScheduler(t *testing.T) {
+	job := &countingJob{}
+	failing := &countingJob{fail: true}
+	disabled := &countingJob{}
+
+	s := NewScheduler()
+	s.Add(job, 10*time.Millisecond)
+	s.Add(failing, 10*time.Millisecond)
+	s.Add(panicJob{}, 10*time.Millisecond)
+	s.Add(disabled, 0)
+
+	s.Start()
+	time.Sleep(55 * time.Millisecond)
+	s.Stop()
+
+	runs := atomic.LoadInt32(&job.runs)
+	assert.True(t, runs >= 2, "expected the job to run several times, got %d", runs)
+	assert.True(t, atomic.LoadInt32(&failing.runs) >= 2)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&disabled.runs))
+
+	// no runs after the scheduler was stopped
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, runs, atomic.LoadInt32(&job.runs))
+
+	// stopping twice is fine
+	s.Stop()
+}
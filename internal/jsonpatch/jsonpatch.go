@@ -0,0 +1,313 @@
+// Package jsonpatch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902) documents to JSON documents
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Operation is a single operation of a JSON Patch document
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// MergePatch applies the merge patch to the document. Members of the patch with a null value are removed,
+// objects are merged recursively and all other values replace the values of the document
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target, p interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, fmt.Errorf("invalid document: %v", err)
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %v", err)
+	}
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = merge(t[k], v)
+	}
+	return t
+}
+
+// Apply applies the operations of the JSON Patch to the document. The operations are applied in order, the
+// patched document is only returned if all operations succeed
+func Apply(doc, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, fmt.Errorf("invalid document: %v", err)
+	}
+	var ops []Operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("invalid patch: %v", err)
+	}
+
+	for i, op := range ops {
+		var err error
+		if target, err = op.apply(target); err != nil {
+			return nil, fmt.Errorf("operation %d '%s' of '%s' failed: %v", i, op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+func (o Operation) apply(doc interface{}) (interface{}, error) {
+	switch o.Op {
+	case "add", "replace", "test":
+		if len(o.Value) == 0 {
+			return nil, fmt.Errorf("the value is missing")
+		}
+		var value interface{}
+		if err := json.Unmarshal(o.Value, &value); err != nil {
+			return nil, fmt.Errorf("invalid value: %v", err)
+		}
+		switch o.Op {
+		case "add":
+			return add(doc, o.Path, value)
+		case "replace":
+			if o.Path == "" {
+				return value, nil
+			}
+			if _, err := get(doc, o.Path); err != nil {
+				return nil, err
+			}
+			doc, err := remove(doc, o.Path)
+			if err != nil {
+				return nil, err
+			}
+			return add(doc, o.Path, value)
+		default:
+			current, err := get(doc, o.Path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, fmt.Errorf("the value does not match")
+			}
+			return doc, nil
+		}
+	case "remove":
+		return remove(doc, o.Path)
+	case "move", "copy":
+		value, err := get(doc, o.From)
+		if err != nil {
+			return nil, err
+		}
+		if o.Op == "move" {
+			if strings.HasPrefix(o.Path, o.From+"/") {
+				return nil, fmt.Errorf("cannot move '%s' into one of its children", o.From)
+			}
+			if doc, err = remove(doc, o.From); err != nil {
+				return nil, err
+			}
+		} else {
+			// the copy must not share the maps or slices of the original value
+			value = deepCopy(value)
+		}
+		return add(doc, o.Path, value)
+	default:
+		return nil, fmt.Errorf("unknown operation")
+	}
+}
+
+// parsePointer splits the JSON Pointer (RFC 6901) into the unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid pointer '%s'", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// arrayIndex returns the index of the reference token, the token '-' refers to the end of the array for additions
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if token == "-" && end {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index '%s'", token)
+	}
+	max := length - 1
+	if end {
+		max = length
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %d out of bounds", i)
+	}
+	return i, nil
+}
+
+func get(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	current := doc
+	for _, t := range tokens {
+		switch c := current.(type) {
+		case map[string]interface{}:
+			v, ok := c[t]
+			if !ok {
+				return nil, fmt.Errorf("the path '%s' does not exist", pointer)
+			}
+			current = v
+		case []interface{}:
+			i, err := arrayIndex(t, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			current = c[i]
+		default:
+			return nil, fmt.Errorf("the path '%s' does not exist", pointer)
+		}
+	}
+	return current, nil
+}
+
+// update replaces the container of the last reference token with the result of the change function
+func update(doc interface{}, pointer string, change func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return change(nil, "")
+	}
+	parent := "/" + strings.Join(escape(tokens[:len(tokens)-1]), "/")
+	if len(tokens) == 1 {
+		parent = ""
+	}
+	container, err := get(doc, parent)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := change(container, tokens[len(tokens)-1])
+	if err != nil {
+		return nil, err
+	}
+	if parent == "" {
+		return changed, nil
+	}
+	// arrays might have been reallocated, the changed container is assigned to its parent again
+	return update(doc, parent, func(c interface{}, token string) (interface{}, error) {
+		return set(c, token, changed)
+	})
+}
+
+func set(container interface{}, token string, value interface{}) (interface{}, error) {
+	switch c := container.(type) {
+	case map[string]interface{}:
+		c[token] = value
+		return c, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(c), false)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = value
+		return c, nil
+	default:
+		return nil, fmt.Errorf("the parent of '%s' is not a container", token)
+	}
+}
+
+func add(doc interface{}, pointer string, value interface{}) (interface{}, error) {
+	return update(doc, pointer, func(container interface{}, token string) (interface{}, error) {
+		if pointer == "" {
+			return value, nil
+		}
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[token] = value
+			return c, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		default:
+			return nil, fmt.Errorf("the parent of '%s' is not a container", pointer)
+		}
+	})
+}
+
+func remove(doc interface{}, pointer string) (interface{}, error) {
+	if pointer == "" {
+		return nil, fmt.Errorf("the document cannot be removed")
+	}
+	return update(doc, pointer, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if _, ok := c[token]; !ok {
+				return nil, fmt.Errorf("the path '%s' does not exist", pointer)
+			}
+			delete(c, token)
+			return c, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(c[:i], c[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("the parent of '%s' is not a container", pointer)
+		}
+	})
+}
+
+func escape(tokens []string) []string {
+	escaped := make([]string, len(tokens))
+	for i, t := range tokens {
+		escaped[i] = strings.Replace(strings.Replace(t, "~", "~0", -1), "/", "~1", -1)
+	}
+	return escaped
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			c[k] = deepCopy(e)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, e := range v {
+			c[i] = deepCopy(e)
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,99 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch(t *testing.T) {
+	for _, tc := range []struct {
+		doc      string
+		patch    string
+		expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	} {
+		t.Run(tc.patch, func(t *testing.T) {
+			result, err := MergePatch([]byte(tc.doc), []byte(tc.patch))
+			if err != nil {
+				t.Fatalf("could not apply the merge patch: %v", err)
+			}
+			assert.JSONEq(t, tc.expected, string(result))
+		})
+	}
+
+	_, err := MergePatch([]byte(`{}`), []byte(`{`))
+	assert.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		doc      string
+		patch    string
+		expected string
+	}{
+		{"add member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"add element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"add at the end", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{"remove member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"remove element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"replace element", `{"foo":["a","b","c"]}`, `[{"op":"replace","path":"/foo/1","value":"x"}]`, `{"foo":["a","x","c"]}`},
+		{"replace document", `{"foo":"bar"}`, `[{"op":"replace","path":"","value":{"baz":1}}]`, `{"baz":1}`},
+		{"move", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"move element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{"copy", `{"foo":{"bar":1}}`, `[{"op":"copy","from":"/foo","path":"/baz"},{"op":"replace","path":"/baz/bar","value":2}]`, `{"foo":{"bar":1},"baz":{"bar":2}}`},
+		{"test", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{"escaped pointer", `{"a/b":1,"m~n":2}`, `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`, `{"a/b":3}`},
+		{"null value", `{"foo":"bar"}`, `[{"op":"add","path":"/foo","value":null}]`, `{"foo":null}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := Apply([]byte(tc.doc), []byte(tc.patch))
+			if err != nil {
+				t.Fatalf("could not apply the patch: %v", err)
+			}
+			assert.JSONEq(t, tc.expected, string(result))
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		patch string
+	}{
+		{"invalid patch", `{"op":"add"}`},
+		{"unknown operation", `[{"op":"rename","path":"/foo"}]`},
+		{"missing value", `[{"op":"add","path":"/baz"}]`},
+		{"missing parent", `[{"op":"add","path":"/baz/bat","value":"qux"}]`},
+		{"remove missing", `[{"op":"remove","path":"/baz"}]`},
+		{"replace missing", `[{"op":"replace","path":"/baz","value":1}]`},
+		{"index out of bounds", `[{"op":"add","path":"/list/3","value":1}]`},
+		{"invalid index", `[{"op":"remove","path":"/list/01"}]`},
+		{"invalid pointer", `[{"op":"remove","path":"foo"}]`},
+		{"failed test", `[{"op":"test","path":"/foo","value":"baz"}]`},
+		{"move into child", `[{"op":"move","from":"/obj","path":"/obj/child"}]`},
+		{"failed later operation", `[{"op":"replace","path":"/foo","value":"x"},{"op":"test","path":"/foo","value":"bar"}]`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Apply([]byte(`{"foo":"bar","list":[1,2],"obj":{"a":1}}`), []byte(tc.patch))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,289 @@
+// Package linkhealth checks the URLs of bookmarks and records their availability. Permanent redirects
+// are resolved during the check and offered as URL updates of the bookmarks
+package linkhealth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bihe/bookmarks/internal"
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/bihe/bookmarks/internal/store"
+)
+
+const (
+	// DefaultRecheckAfter defines the age of a check after which the bookmark is checked again
+	DefaultRecheckAfter = 7 * 24 * time.Hour
+	// DefaultHostDelay is the minimum duration between two requests to the same host
+	DefaultHostDelay = 2 * time.Second
+	// DefaultBatchSize limits the number of bookmarks checked in one run
+	DefaultBatchSize = 500
+	// DefaultWorkers is the number of concurrent checks
+	DefaultWorkers = 4
+	// DefaultHistoryRetention defines how long the history of checks is kept
+	DefaultHistoryRetention = 90 * 24 * time.Hour
+)
+
+// Options configures the Checker
+type Options struct {
+	RecheckAfter     time.Duration
+	HostDelay        time.Duration
+	BatchSize        int
+	Workers          int
+	HistoryRetention time.Duration
+}
+
+// Result of checking a URL
+type Result struct {
+	store.LinkCheck
+	// PermanentURL is the location the URL permanently redirects to, empty if there is no permanent redirect
+	PermanentURL string
+	// PermanentStatus is the status code of the permanent redirect (301 or 308)
+	PermanentStatus int
+}
+
+// Checker requests the URLs of bookmarks and stores the result as link health
+type Checker struct {
+	fetcher    fetch.Fetcher
+	repository store.Repository
+	opts       Options
+	limiter    *hostLimiter
+}
+
+// New creates a Checker, default values are used for options which are not set
+func New(fetcher fetch.Fetcher, repository store.Repository, opts Options) *Checker {
+	if opts.RecheckAfter <= 0 {
+		opts.RecheckAfter = DefaultRecheckAfter
+	}
+	if opts.HostDelay < 0 {
+		opts.HostDelay = 0
+	} else if opts.HostDelay == 0 {
+		opts.HostDelay = DefaultHostDelay
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.HistoryRetention <= 0 {
+		opts.HistoryRetention = DefaultHistoryRetention
+	}
+	return &Checker{
+		fetcher:    fetcher,
+		repository: repository,
+		opts:       opts,
+		limiter:    newHostLimiter(opts.HostDelay),
+	}
+}
+
+// Name of the job
+func (c *Checker) Name() string {
+	return "link-health"
+}
+
+// Run checks the bookmarks which are due and removes the outdated history
+func (c *Checker) Run() error {
+	now := time.Now().UTC()
+	bookmarks, err := c.repository.GetBookmarksToCheck(now.Add(-c.opts.RecheckAfter), c.opts.BatchSize)
+	if err != nil {
+		return fmt.Errorf("could not get the bookmarks to check: %v", err)
+	}
+	c.CheckBookmarks(bookmarks)
+
+	if err := c.repository.DeleteLinkChecksBefore(now.Add(-c.opts.HistoryRetention)); err != nil {
+		return fmt.Errorf("could not remove the history of link checks: %v", err)
+	}
+	return nil
+}
+
+// CheckBookmarks checks the given bookmarks concurrently and saves the results
+func (c *Checker) CheckBookmarks(bookmarks []store.Bookmark) {
+	queue := make(chan store.Bookmark)
+	var wg sync.WaitGroup
+	for i := 0; i < c.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for bm := range queue {
+				c.checkBookmark(bm)
+			}
+		}()
+	}
+	for _, bm := range bookmarks {
+		if bm.Type != store.Node {
+			continue
+		}
+		queue <- bm
+	}
+	close(queue)
+	wg.Wait()
+}
+
+func (c *Checker) checkBookmark(bm store.Bookmark) {
+	result := c.Check(bm.URL)
+	result.BookmarkID = bm.ID
+	if err := c.repository.InUnitOfWork(func(repo store.Repository) error {
+		if _, err := repo.SaveLinkCheck(result.LinkCheck, bm.UserName); err != nil {
+			return err
+		}
+		return resolveRedirect(repo, bm, result)
+	}); err != nil {
+		internal.LogFunction("linkhealth.checkBookmark").Errorf("could not save the link check of bookmark '%s': %v", bm.ID, err)
+	}
+}
+
+// resolveRedirect stores the location of a permanent redirect as suggested URL of the bookmark. If the user
+// opted in, the URL of the bookmark is updated directly. Failed checks do not change an existing suggestion
+func resolveRedirect(repo store.Repository, bm store.Bookmark, result Result) error {
+	if result.Status == store.LinkBroken || result.Status == store.LinkError {
+		return nil
+	}
+	if result.PermanentURL == "" || sameURL(bm.URL, result.PermanentURL) {
+		return repo.DeleteURLSuggestion(bm.ID)
+	}
+
+	settings, err := repo.GetUserSettings(bm.UserName)
+	if err != nil {
+		return err
+	}
+	if settings.AutoApplyRedirects {
+		internal.LogFunction("linkhealth.resolveRedirect").Infof("update URL of bookmark '%s' to '%s'", bm.ID, result.PermanentURL)
+		before, err := repo.GetBookmarkById(bm.ID, bm.UserName)
+		if err != nil {
+			return err
+		}
+		updated, err := repo.UpdateURL(bm.ID, bm.UserName, result.PermanentURL, store.URLChangeAutoRedirect)
+		if err != nil {
+			return err
+		}
+		return store.LogChange(repo, "", store.ChangeUpdate, &before, &updated)
+	}
+	return repo.SaveURLSuggestion(store.URLSuggestion{
+		BookmarkID:   bm.ID,
+		UserName:     bm.UserName,
+		URL:          bm.URL,
+		SuggestedURL: result.PermanentURL,
+		StatusCode:   result.PermanentStatus,
+		Detected:     result.Checked,
+	})
+}
+
+// Check requests the URL and classifies the result. A HEAD request is used first, if the server
+// does not answer the HEAD request successfully, the URL is requested with GET
+func (c *Checker) Check(uri string) Result {
+	check := Result{LinkCheck: store.LinkCheck{FinalURL: uri}}
+
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" {
+		check.Status = store.LinkBroken
+		check.Error = fmt.Sprintf("invalid URL '%s'", uri)
+		check.Checked = time.Now().UTC()
+		return check
+	}
+
+	c.limiter.Wait(u.Host)
+	start := time.Now()
+	resp, err := c.fetcher.Head(uri)
+	if err != nil || resp.StatusCode >= 400 {
+		c.limiter.Wait(u.Host)
+		start = time.Now()
+		resp, err = c.fetcher.Probe(uri)
+	}
+	check.Latency = time.Since(start).Nanoseconds() / int64(time.Millisecond)
+	check.Checked = time.Now().UTC()
+
+	if err != nil {
+		check.Status = classifyError(err)
+		check.Error = truncate(err.Error(), 512)
+		return check
+	}
+	check.StatusCode = resp.StatusCode
+	check.FinalURL = truncate(resp.URL, 512)
+	check.Status = classifyStatus(resp.StatusCode)
+	if check.Status == store.LinkOK && !sameURL(uri, resp.URL) {
+		check.Status = store.LinkRedirected
+	}
+	// a truncated URL is not a valid suggestion
+	if permanent := resp.PermanentURL(); permanent != "" && len(permanent) <= 512 {
+		check.PermanentURL = permanent
+		check.PermanentStatus = resp.Redirects[0].StatusCode
+	}
+	return check
+}
+
+// classifyStatus maps the HTTP status to a link status. Responses which require authentication or
+// indicate rate-limiting do not prove that the link is broken
+func classifyStatus(code int) store.LinkStatus {
+	switch {
+	case code < 400:
+		return store.LinkOK
+	case code == http.StatusUnauthorized, code == http.StatusForbidden, code == http.StatusTooManyRequests, code >= 500:
+		return store.LinkError
+	}
+	return store.LinkBroken
+}
+
+// classifyError treats unknown hosts and refused connections as broken links, other errors
+// like timeouts might be temporary
+func classifyError(err error) store.LinkStatus {
+	msg := err.Error()
+	for _, s := range []string{"no such host", "connection refused", "invalid url", "could not parse url"} {
+		if strings.Contains(strings.ToLower(msg), s) {
+			return store.LinkBroken
+		}
+	}
+	return store.LinkError
+}
+
+// sameURL compares the URLs and ignores a trailing slash of the path
+func sameURL(a, b string) bool {
+	return strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/")
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// hostLimiter spaces the requests to the same host
+type hostLimiter struct {
+	delay time.Duration
+	mu    sync.Mutex
+	next  map[string]time.Time
+}
+
+func newHostLimiter(delay time.Duration) *hostLimiter {
+	return &hostLimiter{
+		delay: delay,
+		next:  make(map[string]time.Time),
+	}
+}
+
+// Wait blocks until a request to the host is allowed
+func (l *hostLimiter) Wait(host string) {
+	host = strings.ToLower(host)
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next[host] = slot.Add(l.delay)
+	// forget hosts which are not limited anymore
+	for h, t := range l.next {
+		if t.Before(now) {
+			delete(l.next, h)
+		}
+	}
+	l.mu.Unlock()
+
+	time.Sleep(time.Until(slot))
+}
@@ -0,0 +1,252 @@
+package linkhealth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+type checkRepository struct {
+	store.Repository
+	bookmarks []store.Bookmark
+	mu        sync.Mutex
+	checks    map[string]store.LinkCheck
+	pruned    bool
+
+	autoApply   bool
+	suggestions map[string]store.URLSuggestion
+	updates     map[string]string
+	changes     []store.ChangeLog
+}
+
+func (r *checkRepository) InUnitOfWork(fn func(repo store.Repository) error) error {
+	return fn(r)
+}
+
+func (r *checkRepository) GetBookmarksToCheck(checkedBefore time.Time, limit int) ([]store.Bookmark, error) {
+	return r.bookmarks, nil
+}
+
+func (r *checkRepository) SaveLinkCheck(check store.LinkCheck, username string) (store.LinkHealth, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[check.BookmarkID] = check
+	return store.LinkHealth{}, nil
+}
+
+func (r *checkRepository) DeleteLinkChecksBefore(t time.Time) error {
+	r.pruned = true
+	return nil
+}
+
+func (r *checkRepository) SaveURLSuggestion(suggestion store.URLSuggestion) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.suggestions[suggestion.BookmarkID] = suggestion
+	return nil
+}
+
+func (r *checkRepository) DeleteURLSuggestion(bookmarkID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.suggestions, bookmarkID)
+	return nil
+}
+
+func (r *checkRepository) GetUserSettings(username string) (store.UserSettings, error) {
+	return store.UserSettings{UserName: username, AutoApplyRedirects: r.autoApply}, nil
+}
+
+func (r *checkRepository) GetBookmarkById(id, username string) (store.Bookmark, error) {
+	for _, bm := range r.bookmarks {
+		if bm.ID == id && bm.UserName == username {
+			return bm, nil
+		}
+	}
+	return store.Bookmark{}, fmt.Errorf("could not find bookmark '%s'", id)
+}
+
+func (r *checkRepository) UpdateURL(id, username, url string, reason store.URLChangeReason) (store.Bookmark, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updates[id] = url
+	return store.Bookmark{ID: id, UserName: username, URL: url}, nil
+}
+
+func (r *checkRepository) AddChange(entry store.ChangeLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, entry)
+	return nil
+}
+
+func newCheckRepository() *checkRepository {
+	return &checkRepository{
+		checks:      make(map[string]store.LinkCheck),
+		suggestions: make(map[string]store.URLSuggestion),
+		updates:     make(map[string]string),
+	}
+}
+
+func testServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/nohead", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/temporary", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/moved", http.StatusFound)
+	})
+	mux.HandleFunc("/moved-gone", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/gone", http.StatusPermanentRedirect)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	mux.HandleFunc("/private", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestCheck(t *testing.T) {
+	ts := testServer()
+	defer ts.Close()
+
+	client, err := fetch.New(fetch.Options{AllowPrivateNetworks: true})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	checker := New(client, nil, Options{HostDelay: -1})
+
+	check := checker.Check(ts.URL + "/ok")
+	assert.Equal(t, store.LinkOK, check.Status)
+	assert.Equal(t, http.StatusOK, check.StatusCode)
+	assert.False(t, check.Checked.IsZero())
+
+	check = checker.Check(ts.URL + "/nohead")
+	assert.Equal(t, store.LinkOK, check.Status)
+
+	check = checker.Check(ts.URL + "/moved")
+	assert.Equal(t, store.LinkRedirected, check.Status)
+	assert.Equal(t, ts.URL+"/ok", check.FinalURL)
+	assert.Equal(t, ts.URL+"/ok", check.PermanentURL)
+	assert.Equal(t, http.StatusMovedPermanently, check.PermanentStatus)
+
+	check = checker.Check(ts.URL + "/temporary")
+	assert.Equal(t, store.LinkRedirected, check.Status)
+	assert.Equal(t, "", check.PermanentURL)
+
+	check = checker.Check(ts.URL + "/gone")
+	assert.Equal(t, store.LinkBroken, check.Status)
+	assert.Equal(t, http.StatusGone, check.StatusCode)
+
+	check = checker.Check(ts.URL + "/private")
+	assert.Equal(t, store.LinkError, check.Status)
+
+	assert.Equal(t, store.LinkBroken, classifyError(fmt.Errorf("dial tcp: lookup unknown.host: no such host")))
+	assert.Equal(t, store.LinkError, classifyError(fmt.Errorf("Client.Timeout exceeded while awaiting headers")))
+
+	check = checker.Check("no-url")
+	assert.Equal(t, store.LinkBroken, check.Status)
+}
+
+func TestRun(t *testing.T) {
+	ts := testServer()
+	defer ts.Close()
+
+	client, err := fetch.New(fetch.Options{AllowPrivateNetworks: true})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	repo := newCheckRepository()
+	for i, p := range []string{"/ok", "/gone", "/moved"} {
+		repo.bookmarks = append(repo.bookmarks, store.Bookmark{ID: fmt.Sprintf("%d", i), URL: ts.URL + p, Type: store.Node})
+	}
+	repo.bookmarks = append(repo.bookmarks, store.Bookmark{ID: "folder", Type: store.Folder})
+
+	checker := New(client, repo, Options{HostDelay: 10 * time.Millisecond, Workers: 2})
+	assert.Equal(t, "link-health", checker.Name())
+
+	start := time.Now()
+	assert.NoError(t, checker.Run())
+	// the requests to the same host are spaced by the host-delay
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+
+	assert.Equal(t, 3, len(repo.checks))
+	assert.Equal(t, store.LinkOK, repo.checks["0"].Status)
+	assert.Equal(t, store.LinkBroken, repo.checks["1"].Status)
+	assert.Equal(t, store.LinkRedirected, repo.checks["2"].Status)
+	assert.True(t, repo.pruned)
+}
+
+func TestResolveRedirects(t *testing.T) {
+	ts := testServer()
+	defer ts.Close()
+
+	client, err := fetch.New(fetch.Options{AllowPrivateNetworks: true})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	repo := newCheckRepository()
+	for _, p := range []string{"/ok", "/moved", "/temporary", "/moved-gone"} {
+		repo.bookmarks = append(repo.bookmarks, store.Bookmark{ID: p, URL: ts.URL + p, Type: store.Node, UserName: "username"})
+	}
+	// an outdated suggestion is removed
+	repo.suggestions["/ok"] = store.URLSuggestion{BookmarkID: "/ok", SuggestedURL: "http://other"}
+
+	checker := New(client, repo, Options{HostDelay: -1})
+	checker.CheckBookmarks(repo.bookmarks)
+
+	assert.Equal(t, 1, len(repo.suggestions))
+	suggestion := repo.suggestions["/moved"]
+	assert.Equal(t, ts.URL+"/moved", suggestion.URL)
+	assert.Equal(t, ts.URL+"/ok", suggestion.SuggestedURL)
+	assert.Equal(t, http.StatusMovedPermanently, suggestion.StatusCode)
+	assert.Equal(t, "username", suggestion.UserName)
+	assert.Equal(t, 0, len(repo.updates))
+
+	// the permanent redirects are applied if the user opted in
+	repo = newCheckRepository()
+	repo.autoApply = true
+	repo.bookmarks = []store.Bookmark{{ID: "moved", URL: ts.URL + "/moved", Type: store.Node, UserName: "username"}}
+	checker = New(client, repo, Options{HostDelay: -1})
+	checker.CheckBookmarks(repo.bookmarks)
+	assert.Equal(t, 0, len(repo.suggestions))
+	assert.Equal(t, ts.URL+"/ok", repo.updates["moved"])
+	// the change of the URL is logged
+	if assert.Equal(t, 1, len(repo.changes)) {
+		assert.Equal(t, "moved", repo.changes[0].EntityID)
+		assert.Equal(t, store.ChangeUpdate, repo.changes[0].Operation)
+		assert.Contains(t, repo.changes[0].Before, ts.URL+"/moved")
+		assert.Contains(t, repo.changes[0].After, ts.URL+"/ok")
+	}
+}
+
+func TestHostLimiter(t *testing.T) {
+	l := newHostLimiter(20 * time.Millisecond)
+
+	start := time.Now()
+	l.Wait("example.com")
+	l.Wait("other.com")
+	assert.True(t, time.Since(start) < 20*time.Millisecond)
+
+	l.Wait("EXAMPLE.com")
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+}
@@ -0,0 +1,214 @@
+// Package metadata extracts descriptive information like title, description and icons from HTML pages
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/bihe/bookmarks/internal/fetch"
+)
+
+// PageTypes are the media-types accepted for HTML pages
+var PageTypes = []string{"text/html", "application/xhtml+xml"}
+
+// Icon is an icon definition of the page, e.g. <link rel="icon">
+type Icon struct {
+	// URL is the absolute URL of the icon, or a data: URI for inline icons
+	URL   string
+	Rel   string
+	Type  string
+	Sizes string
+}
+
+// Metadata holds the information found in the head of a page. Relative references are resolved
+// against the <base href> of the page or the page URL
+type Metadata struct {
+	// URL is the location the page was retrieved from
+	URL          string
+	Title        string
+	Description  string
+	CanonicalURL string
+	ImageURL     string
+	Language     string
+	SiteName     string
+	// OpenGraph holds the og:* properties without the prefix, e.g. "title" for og:title
+	OpenGraph map[string]string
+	// Twitter holds the twitter:* tags without the prefix, e.g. "card" for twitter:card
+	Twitter map[string]string
+	Icons   []Icon
+	// Manifest is the URL of the linked web manifest
+	Manifest string
+}
+
+// Fetch retrieves the page of the given URL and extracts the metadata. Relative references of the
+// page are resolved against the final URL after redirects
+func Fetch(client fetch.Fetcher, uri string) (*Metadata, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the supplied uri: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("the scheme '%s' of the supplied uri is not supported", u.Scheme)
+	}
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch page: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got status %d", resp.StatusCode)
+	}
+	if !resp.IsType(PageTypes...) {
+		return nil, fmt.Errorf("the content-type '%s' is not supported", resp.ContentType)
+	}
+	if len(resp.Body) == 0 {
+		return nil, fmt.Errorf("got an empty response")
+	}
+
+	pageURL := u
+	if final, err := url.Parse(resp.URL); err == nil && final.Host != "" {
+		pageURL = final
+	}
+	return Parse(resp.Body, pageURL)
+}
+
+// Parse extracts the metadata of the given HTML page. The <title> and the meta description are
+// preferred, Open Graph and Twitter tags are used if they are not available
+func Parse(page []byte, pageURL *url.URL) (*Metadata, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse page: %v", err)
+	}
+
+	m := &Metadata{
+		URL:       pageURL.String(),
+		OpenGraph: make(map[string]string),
+		Twitter:   make(map[string]string),
+	}
+
+	base := pageURL
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if u, err := pageURL.Parse(strings.TrimSpace(href)); err == nil {
+			base = u
+		}
+	}
+	resolve := func(ref string) string {
+		ref = strings.TrimSpace(ref)
+		if ref == "" || isDataURI(ref) {
+			return ref
+		}
+		u, err := base.Parse(ref)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return ""
+		}
+		return u.String()
+	}
+
+	var description string
+	doc.Find("meta[content]").Each(func(i int, s *goquery.Selection) {
+		content, _ := s.Attr("content")
+		content = collapse(content)
+		if content == "" {
+			return
+		}
+		name := strings.ToLower(strings.TrimSpace(s.AttrOr("name", "")))
+		// Open Graph uses the property attribute, but many pages use name instead
+		property := strings.ToLower(strings.TrimSpace(s.AttrOr("property", name)))
+		httpEquiv := strings.ToLower(strings.TrimSpace(s.AttrOr("http-equiv", "")))
+
+		switch {
+		case name == "description" && description == "":
+			description = content
+		case strings.HasPrefix(property, "og:"):
+			setFirst(m.OpenGraph, strings.TrimPrefix(property, "og:"), content)
+		case strings.HasPrefix(name, "twitter:"):
+			setFirst(m.Twitter, strings.TrimPrefix(name, "twitter:"), content)
+		case strings.HasPrefix(property, "twitter:"):
+			setFirst(m.Twitter, strings.TrimPrefix(property, "twitter:"), content)
+		case httpEquiv == "content-language" && m.Language == "":
+			m.Language = strings.TrimSpace(strings.Split(content, ",")[0])
+		}
+	})
+
+	doc.Find("link[rel][href]").Each(func(i int, s *goquery.Selection) {
+		rel, _ := s.Attr("rel")
+		href, _ := s.Attr("href")
+		if strings.TrimSpace(href) == "" {
+			return
+		}
+		for _, token := range strings.Fields(strings.ToLower(rel)) {
+			switch token {
+			case "canonical":
+				if m.CanonicalURL == "" {
+					m.CanonicalURL = resolve(href)
+				}
+			case "manifest":
+				if m.Manifest == "" {
+					m.Manifest = resolve(href)
+				}
+			case "icon", "shortcut", "apple-touch-icon", "apple-touch-icon-precomposed", "mask-icon":
+				if iconURL := resolve(href); iconURL != "" {
+					m.Icons = append(m.Icons, Icon{
+						URL:   iconURL,
+						Rel:   strings.ToLower(collapse(rel)),
+						Type:  s.AttrOr("type", ""),
+						Sizes: s.AttrOr("sizes", ""),
+					})
+				}
+			default:
+				continue
+			}
+			// the link is used once, even if the rel attribute has several matching tokens
+			break
+		}
+	})
+
+	m.Title = first(collapse(doc.Find("title").First().Text()), m.OpenGraph["title"], m.Twitter["title"])
+	m.Description = first(description, m.OpenGraph["description"], m.Twitter["description"])
+	m.SiteName = first(m.OpenGraph["site_name"], m.Twitter["site"])
+	if m.CanonicalURL == "" {
+		m.CanonicalURL = resolve(m.OpenGraph["url"])
+	}
+	m.ImageURL = first(resolve(m.OpenGraph["image"]), resolve(m.OpenGraph["image:url"]), resolve(m.Twitter["image"]), resolve(m.Twitter["image:src"]))
+	if isDataURI(m.ImageURL) {
+		m.ImageURL = ""
+	}
+	if lang := collapse(doc.Find("html").First().AttrOr("lang", "")); lang != "" {
+		m.Language = lang
+	}
+	if m.Language == "" && m.OpenGraph["locale"] != "" {
+		m.Language = strings.Replace(m.OpenGraph["locale"], "_", "-", 1)
+	}
+
+	return m, nil
+}
+
+// setFirst only keeps the first value of a property, e.g. the first of several og:image tags
+func setFirst(values map[string]string, key, value string) {
+	if _, ok := values[key]; !ok && key != "" {
+		values[key] = value
+	}
+}
+
+// first returns the first non-empty value
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// collapse replaces sequences of whitespace with a single space
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func isDataURI(uri string) bool {
+	return strings.HasPrefix(strings.ToLower(uri), "data:")
+}
@@ -0,0 +1,145 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/stretchr/testify/assert"
+)
+
+const page = `<!DOCTYPE html>
+<html lang="de-AT">
+    <head>
+        <base href="https://cdn.example.com/assets/">
+        <title>
+            The   Page
+        </title>
+        <meta name="description" content="A description of the page">
+        <meta property="og:title" content="OG Title">
+        <meta property="og:description" content="OG Description">
+        <meta property="og:image" content="/img/preview.png">
+        <meta property="og:image" content="/img/second.png">
+        <meta property="og:site_name" content="Example">
+        <meta name="twitter:card" content="summary">
+        <meta name="twitter:title" content="Twitter Title">
+        <meta name="twitter:image" content="https://img.example.com/twitter.png">
+        <link rel="canonical" href="/the-page">
+        <link rel="shortcut icon" href="favicon.ico">
+        <link rel="apple-touch-icon" href="../touch.png" sizes="180x180">
+        <link rel="manifest" href="/site.webmanifest">
+        <link rel="stylesheet" href="site.css">
+    </head>
+    <body>html</body>
+</html>`
+
+func TestParse(t *testing.T) {
+	pageURL, _ := url.Parse("https://www.example.com/a/index.html")
+
+	m, err := Parse([]byte(page), pageURL)
+	if err != nil {
+		t.Fatalf("could not parse page: %v", err)
+	}
+
+	assert.Equal(t, "https://www.example.com/a/index.html", m.URL)
+	assert.Equal(t, "The Page", m.Title)
+	assert.Equal(t, "A description of the page", m.Description)
+	assert.Equal(t, "https://cdn.example.com/the-page", m.CanonicalURL)
+	assert.Equal(t, "https://cdn.example.com/img/preview.png", m.ImageURL)
+	assert.Equal(t, "de-AT", m.Language)
+	assert.Equal(t, "Example", m.SiteName)
+	assert.Equal(t, "OG Title", m.OpenGraph["title"])
+	assert.Equal(t, "/img/preview.png", m.OpenGraph["image"])
+	assert.Equal(t, "summary", m.Twitter["card"])
+	assert.Equal(t, "https://cdn.example.com/site.webmanifest", m.Manifest)
+
+	assert.Equal(t, 2, len(m.Icons))
+	assert.Equal(t, "https://cdn.example.com/assets/favicon.ico", m.Icons[0].URL)
+	assert.Equal(t, "shortcut icon", m.Icons[0].Rel)
+	assert.Equal(t, "https://cdn.example.com/touch.png", m.Icons[1].URL)
+	assert.Equal(t, "180x180", m.Icons[1].Sizes)
+}
+
+func TestParseFallbacks(t *testing.T) {
+	pageURL, _ := url.Parse("https://www.example.com/")
+
+	m, err := Parse([]byte(`<html><head>
+        <meta property="og:title" content="OG Title">
+        <meta name="og:description" content="OG Description">
+        <meta property="og:url" content="https://www.example.com/canonical">
+        <meta property="og:locale" content="en_US">
+        <meta name="twitter:image" content="javascript:alert(1)">
+        <meta property="twitter:site" content="@example">
+    </head></html>`), pageURL)
+	if err != nil {
+		t.Fatalf("could not parse page: %v", err)
+	}
+	assert.Equal(t, "OG Title", m.Title)
+	assert.Equal(t, "OG Description", m.Description)
+	assert.Equal(t, "https://www.example.com/canonical", m.CanonicalURL)
+	assert.Equal(t, "en-US", m.Language)
+	assert.Equal(t, "@example", m.SiteName)
+	// only http(s) references are used
+	assert.Equal(t, "", m.ImageURL)
+	assert.Equal(t, 0, len(m.Icons))
+
+	m, err = Parse([]byte(`<html><head><meta http-equiv="Content-Language" content="fr, en"></head></html>`), pageURL)
+	if err != nil {
+		t.Fatalf("could not parse page: %v", err)
+	}
+	assert.Equal(t, "fr", m.Language)
+	assert.Equal(t, "", m.Title)
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>Page</title><link rel="icon" href="icon.png"></head></html>`))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/dir/page", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/dir/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><link rel="icon" href="icon.png"></head></html>`))
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client, err := fetch.New(fetch.Options{AllowPrivateNetworks: true})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
+	m, err := Fetch(client, ts.URL+"/page")
+	if err != nil {
+		t.Fatalf("could not fetch metadata: %v", err)
+	}
+	assert.Equal(t, "Page", m.Title)
+	assert.Equal(t, ts.URL+"/icon.png", m.Icons[0].URL)
+
+	// relative references are resolved against the final URL
+	m, err = Fetch(client, ts.URL+"/moved")
+	if err != nil {
+		t.Fatalf("could not fetch metadata: %v", err)
+	}
+	assert.Equal(t, ts.URL+"/dir/page", m.URL)
+	assert.Equal(t, ts.URL+"/dir/icon.png", m.Icons[0].URL)
+
+	_, err = Fetch(client, ts.URL+"/image")
+	assert.Error(t, err)
+	_, err = Fetch(client, ts.URL+"/missing")
+	assert.Error(t, err)
+	_, err = Fetch(client, "ftp://example.com")
+	assert.Error(t, err)
+}
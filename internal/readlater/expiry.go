@@ -0,0 +1,61 @@
+// Package readlater expires read bookmarks of the read-later queue
+package readlater
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bihe/bookmarks/internal"
+	"github.com/bihe/bookmarks/internal/store"
+)
+
+// Expiry is a job which moves the read bookmarks to the folder chosen by the user, after the configured
+// number of days. Users without these settings keep the read bookmarks in the read-later queue
+type Expiry struct {
+	Repository store.Repository
+}
+
+// Name of the job
+func (e *Expiry) Name() string {
+	return "readlater-expiry"
+}
+
+// Run expires the read bookmarks of all users. A failure for one user does not stop the expiry for others
+func (e *Expiry) Run() error {
+	settings, err := e.Repository.GetReadLaterExpirySettings()
+	if err != nil {
+		return fmt.Errorf("could not get the expiry settings: %v", err)
+	}
+
+	now := time.Now().UTC()
+	var failed int
+	for _, s := range settings {
+		readBefore := now.AddDate(0, 0, -s.ReadLaterExpiryDays)
+		var count int
+		err := e.Repository.InUnitOfWork(func(repo store.Repository) error {
+			expired, err := repo.ExpireReadLater(s.UserName, s.ReadLaterExpiryPath, readBefore)
+			if err != nil {
+				return err
+			}
+			for _, bm := range expired {
+				if err := store.LogChange(repo, "", store.ChangeUpdate, &bm.Before, &bm.After); err != nil {
+					return err
+				}
+			}
+			count = len(expired)
+			return nil
+		})
+		if err != nil {
+			internal.LogFunction("readlater.Run").Warnf("could not expire the read bookmarks of user '%s': %v", s.UserName, err)
+			failed++
+			continue
+		}
+		if count > 0 {
+			internal.LogFunction("readlater.Run").Infof("moved %d read bookmarks of user '%s' to '%s'", count, s.UserName, s.ReadLaterExpiryPath)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("could not expire the read bookmarks of %d users", failed)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package readlater
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+type expiry struct {
+	username   string
+	path       string
+	readBefore time.Time
+}
+
+// expiryRepository records the expired bookmarks of the users
+type expiryRepository struct {
+	store.Repository
+	settings []store.UserSettings
+	expired  []expiry
+	changes  []store.ChangeLog
+}
+
+func (r *expiryRepository) InUnitOfWork(fn func(repo store.Repository) error) error {
+	return fn(r)
+}
+
+func (r *expiryRepository) GetReadLaterExpirySettings() ([]store.UserSettings, error) {
+	return r.settings, nil
+}
+
+func (r *expiryRepository) ExpireReadLater(username, path string, readBefore time.Time) ([]store.ExpiredBookmark, error) {
+	if path == "/Unknown" {
+		return nil, fmt.Errorf("the path '%s' is not available", path)
+	}
+	r.expired = append(r.expired, expiry{username, path, readBefore})
+	before := store.Bookmark{ID: "id-" + username, Path: "/Inbox", ReadLater: true, UserName: username, Version: 1}
+	after := before
+	after.Path, after.ReadLater, after.Version = path, false, 2
+	return []store.ExpiredBookmark{{Before: before, After: after}}, nil
+}
+
+func (r *expiryRepository) AddChange(entry store.ChangeLog) error {
+	r.changes = append(r.changes, entry)
+	return nil
+}
+
+func TestExpiry(t *testing.T) {
+	repo := &expiryRepository{settings: []store.UserSettings{
+		{UserName: "a", ReadLaterExpiryDays: 7, ReadLaterExpiryPath: "/Read"},
+		{UserName: "b", ReadLaterExpiryDays: 1, ReadLaterExpiryPath: "/Unknown"},
+		{UserName: "c", ReadLaterExpiryDays: 30, ReadLaterExpiryPath: "/"},
+	}}
+	job := &Expiry{Repository: repo}
+	assert.Equal(t, "readlater-expiry", job.Name())
+
+	// the failure of user b is reported, the bookmarks of user c are expired nevertheless
+	assert.Error(t, job.Run())
+	if assert.Equal(t, 2, len(repo.expired)) {
+		assert.Equal(t, "a", repo.expired[0].username)
+		assert.Equal(t, "/Read", repo.expired[0].path)
+		assert.WithinDuration(t, time.Now().UTC().AddDate(0, 0, -7), repo.expired[0].readBefore, time.Minute)
+		assert.Equal(t, "c", repo.expired[1].username)
+		assert.WithinDuration(t, time.Now().UTC().AddDate(0, 0, -30), repo.expired[1].readBefore, time.Minute)
+	}
+	// the moved bookmarks are logged
+	if assert.Equal(t, 2, len(repo.changes)) {
+		assert.Equal(t, "id-a", repo.changes[0].EntityID)
+		assert.Equal(t, store.ChangeUpdate, repo.changes[0].Operation)
+		assert.Contains(t, repo.changes[0].Before, `"/Inbox"`)
+		assert.Contains(t, repo.changes[0].After, `"/Read"`)
+	}
+
+	repo.settings = repo.settings[:1]
+	repo.expired = nil
+	assert.NoError(t, job.Run())
+	assert.Equal(t, 1, len(repo.expired))
+}
@@ -0,0 +1,294 @@
+// Package revert undoes logged changes of bookmarks by applying the inverse operations
+package revert
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bihe/bookmarks/internal/store"
+)
+
+// Step is the inverse operation for a bookmark. Current is the state of the bookmark before the revert, Target
+// the state after the revert. A nil state is used for a bookmark which does not exist
+type Step struct {
+	EntityID  string
+	Operation store.ChangeOperation
+	Current   *store.Bookmark
+	Target    *store.Bookmark
+}
+
+// Conflict is a bookmark which cannot be reverted, e.g. because it was changed after the reverted changes
+type Conflict struct {
+	EntityID string
+	Reason   string
+}
+
+// Plan holds the steps to revert the changes. The plan can only be applied without conflicts
+type Plan struct {
+	Steps     []Step
+	Conflicts []Conflict
+	username  string
+}
+
+// NewPlan determines the inverse operations of the changes of the user, the changes have to be ordered with the
+// most recent change first. The net effect of the changes is reverted for every bookmark: the bookmark is restored
+// to the state before its oldest change, if the current state matches the state after its most recent change.
+// Data which is not part of the change log, like visits or link checks of deleted bookmarks, is not restored
+func NewPlan(repo store.Repository, username string, changes []store.ChangeLog) (Plan, error) {
+	var (
+		plan   = Plan{username: username}
+		order  []string
+		newest = make(map[string]store.ChangeLog)
+		oldest = make(map[string]store.ChangeLog)
+	)
+	for _, c := range changes {
+		if c.UserName != username {
+			return Plan{}, fmt.Errorf("the change '%d' was not made by the user '%s'", c.ID, username)
+		}
+		if _, ok := newest[c.EntityID]; !ok {
+			newest[c.EntityID] = c
+			order = append(order, c.EntityID)
+		}
+		oldest[c.EntityID] = c
+	}
+
+	for _, id := range order {
+		expected, err := changeState(newest[id].After)
+		if err != nil {
+			return Plan{}, err
+		}
+		target, err := changeState(oldest[id].Before)
+		if err != nil {
+			return Plan{}, err
+		}
+		current, err := currentState(repo, id, username)
+		if err != nil {
+			return Plan{}, err
+		}
+		if !sameState(current, expected) {
+			plan.Conflicts = append(plan.Conflicts, Conflict{EntityID: id, Reason: "the bookmark was changed after the reverted changes"})
+			continue
+		}
+
+		step := Step{EntityID: id, Current: current, Target: target}
+		switch {
+		case current == nil && target == nil:
+			// the bookmark was created and deleted again
+			continue
+		case current == nil:
+			step.Operation = store.ChangeCreate
+		case target == nil:
+			step.Operation = store.ChangeDelete
+		case sameState(current, target):
+			continue
+		default:
+			step.Operation = store.ChangeUpdate
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+
+	if err := plan.checkHierarchy(repo, username); err != nil {
+		return Plan{}, err
+	}
+	return plan, nil
+}
+
+// checkHierarchy adds conflicts for steps which need a folder which is not available after the revert, and for
+// folders which are removed but still contain bookmarks
+func (p *Plan) checkHierarchy(repo store.Repository, username string) error {
+	paths, err := repo.GetAllPaths(username)
+	if err != nil {
+		return fmt.Errorf("cannot get the folders of user '%s': %v", username, err)
+	}
+	folders := make(map[string]bool)
+	for _, path := range paths {
+		folders[path] = true
+	}
+	steps := make(map[string]Step)
+	for _, s := range p.Steps {
+		steps[s.EntityID] = s
+		if s.Current != nil && s.Current.Type == store.Folder {
+			delete(folders, fullPath(*s.Current))
+		}
+	}
+	for _, s := range p.Steps {
+		if s.Target != nil && s.Target.Type == store.Folder {
+			folders[fullPath(*s.Target)] = true
+		}
+	}
+
+	for _, s := range p.Steps {
+		if s.Target != nil && s.Target.Path != "/" && !folders[s.Target.Path] {
+			p.Conflicts = append(p.Conflicts, Conflict{
+				EntityID: s.EntityID,
+				Reason:   fmt.Sprintf("the folder '%s' is not available", s.Target.Path),
+			})
+		}
+		if s.Current == nil || s.Current.Type != store.Folder {
+			continue
+		}
+		path := fullPath(*s.Current)
+		if s.Target != nil && fullPath(*s.Target) == path {
+			continue
+		}
+		// the folder is removed or renamed, the child-elements have to be removed or moved as well
+		children, err := repo.GetBookmarksByPathStart(path, username)
+		if err != nil {
+			return fmt.Errorf("cannot get the bookmarks of folder '%s': %v", path, err)
+		}
+		for _, child := range children {
+			if !within(child.Path, path) {
+				continue
+			}
+			if c, ok := steps[child.ID]; ok && (c.Target == nil || !within(c.Target.Path, path)) {
+				continue
+			}
+			p.Conflicts = append(p.Conflicts, Conflict{
+				EntityID: s.EntityID,
+				Reason:   fmt.Sprintf("the folder '%s' still contains bookmarks", path),
+			})
+			break
+		}
+	}
+	return nil
+}
+
+// Apply executes the steps of the plan, the repository of a unit of work has to be used. The operations are
+// logged with the ID of the request, therefore a revert can be reverted as well
+func Apply(repo store.Repository, plan Plan, requestID string) error {
+	if len(plan.Conflicts) > 0 {
+		return fmt.Errorf("the changes cannot be reverted because of %d conflicts", len(plan.Conflicts))
+	}
+
+	var (
+		deletes []Step
+		changes []Step
+		paths   = make(map[string]bool)
+	)
+	for _, s := range plan.Steps {
+		if s.Operation == store.ChangeDelete {
+			deletes = append(deletes, s)
+		} else {
+			changes = append(changes, s)
+		}
+		for _, state := range []*store.Bookmark{s.Current, s.Target} {
+			if state == nil {
+				continue
+			}
+			paths[state.Path] = true
+			if state.Type == store.Folder {
+				paths[fullPath(*state)] = true
+			}
+		}
+	}
+	// folders are restored before their child-elements and removed after them
+	sort.SliceStable(changes, func(i, j int) bool {
+		return depth(*changes[i].Target) < depth(*changes[j].Target)
+	})
+	sort.SliceStable(deletes, func(i, j int) bool {
+		return depth(*deletes[i].Current) > depth(*deletes[j].Current)
+	})
+
+	for _, s := range changes {
+		var (
+			before = s.Current
+			after  store.Bookmark
+			err    error
+		)
+		switch s.Operation {
+		case store.ChangeCreate:
+			after, err = repo.Restore(*s.Target)
+		case store.ChangeUpdate:
+			item := *s.Target
+			after, err = repo.Update(item)
+			if err == nil && (s.Target.ReadAt != nil) != (s.Current.ReadAt != nil) {
+				after, err = repo.SetRead(item.ID, item.UserName, s.Target.ReadAt != nil)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("cannot revert bookmark '%s': %v", s.EntityID, err)
+		}
+		if err := store.LogChange(repo, requestID, s.Operation, before, &after); err != nil {
+			return err
+		}
+	}
+	for _, s := range deletes {
+		if err := repo.Delete(*s.Current); err != nil {
+			return fmt.Errorf("cannot revert bookmark '%s': %v", s.EntityID, err)
+		}
+		if err := store.LogChange(repo, requestID, s.Operation, s.Current, nil); err != nil {
+			return err
+		}
+	}
+
+	for path := range paths {
+		if path != "/" {
+			if _, err := repo.GetFolderByPath(path, plan.username); err != nil {
+				// the folder was removed by the revert
+				continue
+			}
+		}
+		if err := repo.UpdateChildCountOfPath(path, plan.username); err != nil {
+			return fmt.Errorf("cannot update the child-count of folder '%s': %v", path, err)
+		}
+	}
+	return nil
+}
+
+// changeState reads the state of the bookmark in the change log, an empty state is nil
+func changeState(state string) (*store.Bookmark, error) {
+	if state == "" {
+		return nil, nil
+	}
+	var bm store.Bookmark
+	if err := json.Unmarshal([]byte(state), &bm); err != nil {
+		return nil, fmt.Errorf("cannot read the state of the change: %v", err)
+	}
+	return &bm, nil
+}
+
+// currentState returns the stored bookmark, nil if the bookmark does not exist
+func currentState(repo store.Repository, id, username string) (*store.Bookmark, error) {
+	bms, err := repo.GetBookmarksByIds([]string{id}, username)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get bookmark '%s': %v", id, err)
+	}
+	if len(bms) == 0 {
+		return nil, nil
+	}
+	return &bms[0], nil
+}
+
+// sameState compares the values of the bookmarks which are changed by the user. the counters and the times
+// of the visits are changed without a log entry and are ignored
+func sameState(a, b *store.Bookmark) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Path == b.Path &&
+		a.DisplayName == b.DisplayName &&
+		a.URL == b.URL &&
+		a.SortOrder == b.SortOrder &&
+		a.Type == b.Type &&
+		a.Query == b.Query &&
+		a.ReadLater == b.ReadLater &&
+		(a.ReadAt == nil) == (b.ReadAt == nil) &&
+		a.Favicon == b.Favicon &&
+		a.Metadata == b.Metadata
+}
+
+// fullPath is the path of the bookmark including its name, the path of the child-elements of a folder
+func fullPath(bm store.Bookmark) string {
+	return store.FolderPath(bm.Path, bm.DisplayName)
+}
+
+func depth(bm store.Bookmark) int {
+	return strings.Count(fullPath(bm), "/")
+}
+
+// within checks if the path is the folder or one of its sub-folders
+func within(path, folder string) bool {
+	return path == folder || strings.HasPrefix(path, folder+"/")
+}
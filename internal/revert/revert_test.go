@@ -0,0 +1,135 @@
+package revert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// treeRepository holds the bookmarks of a user in memory
+type treeRepository struct {
+	store.Repository
+	bookmarks []store.Bookmark
+}
+
+func (r *treeRepository) GetBookmarksByIds(ids []string, username string) ([]store.Bookmark, error) {
+	var bms []store.Bookmark
+	for _, bm := range r.bookmarks {
+		for _, id := range ids {
+			if bm.ID == id && bm.UserName == username {
+				bms = append(bms, bm)
+			}
+		}
+	}
+	return bms, nil
+}
+
+func (r *treeRepository) GetAllPaths(username string) ([]string, error) {
+	paths := []string{"/"}
+	for _, bm := range r.bookmarks {
+		if bm.Type == store.Folder {
+			paths = append(paths, fullPath(bm))
+		}
+	}
+	return paths, nil
+}
+
+func (r *treeRepository) GetBookmarksByPathStart(path, username string) ([]store.Bookmark, error) {
+	var bms []store.Bookmark
+	for _, bm := range r.bookmarks {
+		if strings.HasPrefix(bm.Path, path) {
+			bms = append(bms, bm)
+		}
+	}
+	return bms, nil
+}
+
+func change(t *testing.T, op store.ChangeOperation, before, after *store.Bookmark) store.ChangeLog {
+	c, err := store.NewChange(op, "req", before, after)
+	if err != nil {
+		t.Fatalf("cannot create the change: %v", err)
+	}
+	return c
+}
+
+func TestNewPlan(t *testing.T) {
+	work := store.Bookmark{ID: "work", DisplayName: "Work", Path: "/", Type: store.Folder, UserName: "user"}
+	moved := store.Bookmark{ID: "a", DisplayName: "A", Path: "/Work", URL: "http://a", UserName: "user", AccessCount: 5}
+	original := moved
+	original.Path = "/"
+	deleted := store.Bookmark{ID: "b", DisplayName: "B", Path: "/Work", URL: "http://b", UserName: "user"}
+	temporary := store.Bookmark{ID: "c", DisplayName: "C", Path: "/", URL: "http://c", UserName: "user"}
+	repo := &treeRepository{bookmarks: []store.Bookmark{work, moved}}
+
+	plan, err := NewPlan(repo, "user", []store.ChangeLog{
+		change(t, store.ChangeDelete, &temporary, nil),
+		change(t, store.ChangeDelete, &deleted, nil),
+		change(t, store.ChangeCreate, nil, &temporary),
+		change(t, store.ChangeUpdate, &original, &moved),
+	})
+	if err != nil {
+		t.Fatalf("cannot plan the revert: %v", err)
+	}
+	assert.Equal(t, 0, len(plan.Conflicts))
+	// the bookmark which was created and deleted is skipped
+	assert.Equal(t, 2, len(plan.Steps))
+	assert.Equal(t, "b", plan.Steps[0].EntityID)
+	assert.Equal(t, store.ChangeCreate, plan.Steps[0].Operation)
+	assert.Nil(t, plan.Steps[0].Current)
+	assert.Equal(t, "a", plan.Steps[1].EntityID)
+	assert.Equal(t, store.ChangeUpdate, plan.Steps[1].Operation)
+	assert.Equal(t, "/", plan.Steps[1].Target.Path)
+
+	// the counters are not part of the comparison
+	repo.bookmarks[1].AccessCount = 7
+	plan, _ = NewPlan(repo, "user", []store.ChangeLog{change(t, store.ChangeUpdate, &original, &moved)})
+	assert.Equal(t, 0, len(plan.Conflicts))
+
+	// the bookmark was edited after the change
+	repo.bookmarks[1].DisplayName = "Edited"
+	plan, _ = NewPlan(repo, "user", []store.ChangeLog{change(t, store.ChangeUpdate, &original, &moved)})
+	assert.Equal(t, 1, len(plan.Conflicts))
+	assert.Equal(t, "a", plan.Conflicts[0].EntityID)
+
+	_, err = NewPlan(repo, "other", []store.ChangeLog{change(t, store.ChangeUpdate, &original, &moved)})
+	assert.Error(t, err)
+}
+
+func TestNewPlanHierarchy(t *testing.T) {
+	work := store.Bookmark{ID: "work", DisplayName: "Work", Path: "/", Type: store.Folder, UserName: "user"}
+	child := store.Bookmark{ID: "a", DisplayName: "A", Path: "/Work", URL: "http://a", UserName: "user"}
+	repo := &treeRepository{bookmarks: []store.Bookmark{work, child}}
+
+	// the folder cannot be removed, the bookmark was added later
+	plan, err := NewPlan(repo, "user", []store.ChangeLog{change(t, store.ChangeCreate, nil, &work)})
+	if err != nil {
+		t.Fatalf("cannot plan the revert: %v", err)
+	}
+	assert.Equal(t, 1, len(plan.Conflicts))
+	assert.Equal(t, "work", plan.Conflicts[0].EntityID)
+
+	plan, _ = NewPlan(repo, "user", []store.ChangeLog{
+		change(t, store.ChangeCreate, nil, &child),
+		change(t, store.ChangeCreate, nil, &work),
+	})
+	assert.Equal(t, 0, len(plan.Conflicts))
+	assert.Equal(t, 2, len(plan.Steps))
+
+	// the bookmark cannot be restored into a folder which is gone
+	repo.bookmarks = nil
+	plan, _ = NewPlan(repo, "user", []store.ChangeLog{change(t, store.ChangeDelete, &child, nil)})
+	assert.Equal(t, 1, len(plan.Conflicts))
+	plan, _ = NewPlan(repo, "user", []store.ChangeLog{
+		change(t, store.ChangeDelete, &work, nil),
+		change(t, store.ChangeDelete, &child, nil),
+	})
+	assert.Equal(t, 0, len(plan.Conflicts))
+	assert.Equal(t, 2, len(plan.Steps))
+}
+
+func TestApplyWithConflicts(t *testing.T) {
+	err := Apply(&treeRepository{}, Plan{Conflicts: []Conflict{{EntityID: "a"}}}, "req")
+	assert.Error(t, err)
+}
@@ -0,0 +1,140 @@
+package search
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bihe/bookmarks/internal"
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/bihe/bookmarks/internal/metadata"
+	"github.com/bihe/bookmarks/internal/store"
+)
+
+const (
+	// DefaultBatchSize is the number of bookmarks indexed with one run of the job
+	DefaultBatchSize = 100
+	// SnippetWidth is the approximate length in bytes of the snippets of search results
+	SnippetWidth = 160
+)
+
+// Indexer extracts the text of bookmark pages and stores it in the full-text index
+type Indexer struct {
+	Fetcher    fetch.Fetcher
+	Repository store.Repository
+	// BatchSize defines the number of bookmarks indexed by a run of the job, DefaultBatchSize is used if not set
+	BatchSize int
+}
+
+// Index fetches the page of the bookmark and replaces its indexed content. If the page cannot be
+// indexed, an empty content is stored to prevent repeated attempts for the same URL
+func (i *Indexer) Index(bm store.Bookmark) error {
+	if bm.Type != store.Node || bm.URL == "" {
+		return fmt.Errorf("only bookmarks with an URL can be indexed")
+	}
+
+	content := store.PageContent{
+		BookmarkID: bm.ID,
+		UserName:   bm.UserName,
+		URL:        bm.URL,
+	}
+	text, err := i.fetchText(bm.URL)
+	if err != nil {
+		if saveErr := i.Repository.SaveContent(content, nil); saveErr != nil {
+			return saveErr
+		}
+		return err
+	}
+	content.Content = text
+	return i.Repository.InUnitOfWork(func(repo store.Repository) error {
+		return repo.SaveContent(content, Terms(text))
+	})
+}
+
+func (i *Indexer) fetchText(url string) (string, error) {
+	resp, err := i.Fetcher.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch page '%s': %v", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not fetch page '%s': got status %d", url, resp.StatusCode)
+	}
+	if !resp.IsType(metadata.PageTypes...) {
+		return "", fmt.Errorf("the content-type '%s' of page '%s' is not supported", resp.ContentType, url)
+	}
+	return Extract(resp.Body)
+}
+
+// Name of the job
+func (i *Indexer) Name() string {
+	return "content-index"
+}
+
+// Run indexes the bookmarks which were never indexed or whose URL changed
+func (i *Indexer) Run() error {
+	batchSize := i.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	bookmarks, err := i.Repository.GetBookmarksToIndex(batchSize)
+	if err != nil {
+		return fmt.Errorf("could not get the bookmarks to index: %v", err)
+	}
+
+	failed := 0
+	for _, bm := range bookmarks {
+		if err := i.Index(bm); err != nil {
+			internal.LogFunction("search.Indexer").Debugf("could not index bookmark '%s': %v", bm.ID, err)
+			failed++
+		}
+	}
+	if len(bookmarks) > 0 {
+		internal.LogFunction("search.Indexer").Infof("indexed %d bookmarks, %d pages were not available", len(bookmarks), failed)
+	}
+	return nil
+}
+
+// Match is a bookmark found by the full-text search
+type Match struct {
+	BookmarkID string
+	Score      int
+	// Snippet is an excerpt of the page content around the first matching term
+	Snippet string
+}
+
+// Search returns the bookmarks of the user whose page content contains all terms of the query
+func Search(repo store.Repository, query, username string, limit int) ([]Match, error) {
+	terms := QueryTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	found, err := repo.SearchContent(terms, username, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not search the page contents: %v", err)
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(found))
+	for _, f := range found {
+		ids = append(ids, f.BookmarkID)
+	}
+	contents, err := repo.GetPageContents(ids)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the page contents: %v", err)
+	}
+	text := make(map[string]string)
+	for _, c := range contents {
+		text[c.BookmarkID] = c.Content
+	}
+
+	matches := make([]Match, 0, len(found))
+	for _, f := range found {
+		matches = append(matches, Match{
+			BookmarkID: f.BookmarkID,
+			Score:      f.Score,
+			Snippet:    Snippet(text[f.BookmarkID], terms, SnippetWidth),
+		})
+	}
+	return matches, nil
+}
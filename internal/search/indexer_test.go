@@ -0,0 +1,114 @@
+package search
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// indexRepository keeps the indexed contents in memory
+type indexRepository struct {
+	store.Repository
+	bookmarks []store.Bookmark
+	contents  map[string]store.PageContent
+	terms     map[string]map[string]int
+}
+
+func newIndexRepository(bookmarks ...store.Bookmark) *indexRepository {
+	return &indexRepository{
+		bookmarks: bookmarks,
+		contents:  make(map[string]store.PageContent),
+		terms:     make(map[string]map[string]int),
+	}
+}
+
+func (r *indexRepository) InUnitOfWork(fn func(repo store.Repository) error) error {
+	return fn(r)
+}
+
+func (r *indexRepository) SaveContent(content store.PageContent, terms map[string]int) error {
+	r.contents[content.BookmarkID] = content
+	r.terms[content.BookmarkID] = terms
+	return nil
+}
+
+func (r *indexRepository) GetBookmarksToIndex(limit int) ([]store.Bookmark, error) {
+	var bookmarks []store.Bookmark
+	for _, bm := range r.bookmarks {
+		if c, ok := r.contents[bm.ID]; !ok || c.URL != bm.URL {
+			bookmarks = append(bookmarks, bm)
+		}
+	}
+	return bookmarks, nil
+}
+
+func (r *indexRepository) SearchContent(terms []string, username string, limit int) ([]store.ContentMatch, error) {
+	var matches []store.ContentMatch
+	for id, t := range r.terms {
+		score := 0
+		for _, term := range terms {
+			if t[term] == 0 {
+				score = 0
+				break
+			}
+			score += t[term]
+		}
+		if score > 0 && r.contents[id].UserName == username {
+			matches = append(matches, store.ContentMatch{BookmarkID: id, Score: score})
+		}
+	}
+	return matches, nil
+}
+
+func (r *indexRepository) GetPageContents(bookmarkIDs []string) ([]store.PageContent, error) {
+	var contents []store.PageContent
+	for _, id := range bookmarkIDs {
+		contents = append(contents, r.contents[id])
+	}
+	return contents, nil
+}
+
+func TestIndexer(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Gophers</title></head><body><p>Gophers write Go code.</p></body></html>`))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client, err := fetch.New(fetch.Options{AllowPrivateNetworks: true})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+
+	repo := newIndexRepository(
+		store.Bookmark{ID: "page", Type: store.Node, URL: ts.URL + "/page", UserName: "username"},
+		store.Bookmark{ID: "missing", Type: store.Node, URL: ts.URL + "/missing", UserName: "username"},
+	)
+	indexer := &Indexer{Fetcher: client, Repository: repo}
+	assert.Equal(t, "content-index", indexer.Name())
+	assert.Error(t, indexer.Index(store.Bookmark{ID: "folder", Type: store.Folder}))
+
+	assert.NoError(t, indexer.Run())
+	assert.Equal(t, "Gophers\nGophers write Go code.", repo.contents["page"].Content)
+	assert.Equal(t, 2, repo.terms["page"]["gophers"])
+	// the unavailable page is not indexed again
+	assert.Equal(t, "", repo.contents["missing"].Content)
+	toIndex, _ := repo.GetBookmarksToIndex(10)
+	assert.Equal(t, 0, len(toIndex))
+
+	matches, err := Search(repo, "go CODE", "username", 10)
+	if err != nil {
+		t.Fatalf("could not search: %v", err)
+	}
+	assert.Equal(t, []Match{{BookmarkID: "page", Score: 2, Snippet: "Gophers Gophers write Go code."}}, matches)
+	matches, _ = Search(repo, "go", "other", 10)
+	assert.Equal(t, 0, len(matches))
+	matches, _ = Search(repo, "?", "username", 10)
+	assert.Equal(t, 0, len(matches))
+}
@@ -0,0 +1,253 @@
+// Package search extracts the readable text of pages and maintains the full-text index of the bookmarks
+package search
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	// MaxContentSize is the maximum number of bytes of the extracted text
+	MaxContentSize = 60000
+	// MaxTerms is the maximum number of distinct terms indexed for a page, the most frequent terms are kept
+	MaxTerms = 5000
+	// minTermLength and maxTermLength define the length in characters of indexed terms
+	minTermLength = 2
+	maxTermLength = 64
+)
+
+// elements which do not contribute readable text
+var skippedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Nav:      true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Head:     true,
+}
+
+// elements which start a new line of text
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Blockquote: true, atom.Br: true, atom.Dd: true,
+	atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Figcaption: true, atom.Figure: true,
+	atom.Footer: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true,
+	atom.H6: true, atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true, atom.Ol: true,
+	atom.P: true, atom.Pre: true, atom.Section: true, atom.Table: true, atom.Td: true, atom.Th: true,
+	atom.Tr: true, atom.Ul: true,
+}
+
+// Extract returns the readable text of the HTML page. The title is the first line, scripts, styles and
+// navigation elements are skipped. The text is limited to MaxContentSize bytes
+func Extract(page []byte) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	var line strings.Builder
+	flush := func() {
+		if l := strings.Join(strings.Fields(line.String()), " "); l != "" {
+			lines = append(lines, l)
+		}
+		line.Reset()
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			line.WriteString(n.Data)
+			line.WriteString(" ")
+			return
+		case html.ElementNode:
+			if n.DataAtom == atom.Title && title(n) != "" {
+				flush()
+				line.WriteString(title(n))
+				flush()
+				return
+			}
+			if skippedElements[n.DataAtom] {
+				if n.DataAtom == atom.Head {
+					// the title is the only readable part of the head
+					for c := n.FirstChild; c != nil; c = c.NextSibling {
+						if c.DataAtom == atom.Title {
+							walk(c)
+						}
+					}
+				}
+				return
+			}
+			if blockElements[n.DataAtom] {
+				flush()
+				defer flush()
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	flush()
+
+	return truncate(strings.Join(lines, "\n"), MaxContentSize), nil
+}
+
+func title(n *html.Node) string {
+	if n.FirstChild == nil || n.FirstChild.Type != html.TextNode {
+		return ""
+	}
+	return strings.Join(strings.Fields(n.FirstChild.Data), " ")
+}
+
+// truncate cuts the text to the given number of bytes without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// token is a word of the text with its position
+type token struct {
+	term  string
+	start int
+	end   int
+}
+
+// tokenize splits the text into lowercase words of letters and digits. words outside the supported
+// length are skipped
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	add := func(end int) {
+		if start < 0 {
+			return
+		}
+		if n := utf8.RuneCountInString(text[start:end]); n >= minTermLength && n <= maxTermLength {
+			tokens = append(tokens, token{term: strings.ToLower(text[start:end]), start: start, end: end})
+		}
+		start = -1
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		add(i)
+	}
+	add(len(text))
+	return tokens
+}
+
+// Terms returns the distinct terms of the text and their frequency. Only the MaxTerms most frequent
+// terms are returned
+func Terms(text string) map[string]int {
+	terms := make(map[string]int)
+	for _, t := range tokenize(text) {
+		terms[t.term]++
+	}
+	if len(terms) <= MaxTerms {
+		return terms
+	}
+
+	ranked := make([]string, 0, len(terms))
+	for t := range terms {
+		ranked = append(ranked, t)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if terms[ranked[i]] != terms[ranked[j]] {
+			return terms[ranked[i]] > terms[ranked[j]]
+		}
+		return ranked[i] < ranked[j]
+	})
+	for _, t := range ranked[MaxTerms:] {
+		delete(terms, t)
+	}
+	return terms
+}
+
+// QueryTerms returns the distinct terms of a search query in the order of their appearance
+func QueryTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, t := range tokenize(query) {
+		if !seen[t.term] {
+			seen[t.term] = true
+			terms = append(terms, t.term)
+		}
+	}
+	return terms
+}
+
+// Snippet returns an excerpt of about width bytes around the first occurrence of one of the terms.
+// The excerpt starts and ends at word boundaries, omitted text is indicated by an ellipsis
+func Snippet(text string, terms []string, width int) string {
+	wanted := make(map[string]bool)
+	for _, t := range terms {
+		wanted[t] = true
+	}
+	tokens := tokenize(text)
+	match := -1
+	for i, t := range tokens {
+		if wanted[t.term] {
+			match = i
+			break
+		}
+	}
+	if match < 0 {
+		return ""
+	}
+
+	// extend the excerpt by whole words around the match
+	first, last := match, match
+	for {
+		grown := false
+		if first > 0 && tokens[last].end-tokens[first-1].start <= width {
+			first--
+			grown = true
+		}
+		if last < len(tokens)-1 && tokens[last+1].end-tokens[first].start <= width {
+			last++
+			grown = true
+		}
+		if !grown {
+			break
+		}
+	}
+
+	start, end := tokens[first].start, tokens[last].end
+	if last == len(tokens)-1 {
+		end = len(text)
+	}
+	if first == 0 {
+		start = 0
+	}
+	snippet := strings.Join(strings.Fields(text[start:end]), " ")
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(text) {
+		snippet += "…"
+	}
+	return snippet
+}
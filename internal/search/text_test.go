@@ -0,0 +1,69 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtract(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html>
+<head>
+  <title>  The   Title </title>
+  <style>body { color: red }</style>
+  <script>var hidden = true;</script>
+</head>
+<body>
+  <nav><a href="/">Home</a></nav>
+  <h1>Heading</h1>
+  <p>First <b>bold</b>
+     paragraph.</p>
+  <div>Second<br>line</div>
+  <ul><li>one</li><li>two</li></ul>
+  <noscript>enable scripts</noscript>
+  <form><input name="q"><button>Search</button></form>
+  <svg><title>icon</title></svg>
+</body>
+</html>`
+
+	text, err := Extract([]byte(page))
+	if err != nil {
+		t.Fatalf("could not extract text: %v", err)
+	}
+	assert.Equal(t, "The Title\nHeading\nFirst bold paragraph.\nSecond\nline\none\ntwo", text)
+
+	// the text is limited without splitting characters
+	text, _ = Extract([]byte("<p>" + strings.Repeat("ä", MaxContentSize) + "</p>"))
+	assert.Equal(t, MaxContentSize, len(text))
+}
+
+func TestTerms(t *testing.T) {
+	terms := Terms("Go, go GO! a Über-cool 2020 " + strings.Repeat("x", maxTermLength+1))
+	assert.Equal(t, map[string]int{"go": 3, "über": 1, "cool": 1, "2020": 1}, terms)
+
+	// the most frequent terms are kept
+	var b strings.Builder
+	for i := 0; i < MaxTerms+10; i++ {
+		fmt.Fprintf(&b, "term%d ", i)
+	}
+	b.WriteString("term1 term1")
+	terms = Terms(b.String())
+	assert.Equal(t, MaxTerms, len(terms))
+	assert.Equal(t, 3, terms["term1"])
+
+	assert.Equal(t, []string{"full", "text"}, QueryTerms("Full TEXT full"))
+	assert.Equal(t, 0, len(QueryTerms("a !")))
+}
+
+func TestSnippet(t *testing.T) {
+	text := "The quick brown fox jumps over the lazy dog.\nThe dog sleeps."
+
+	assert.Equal(t, "…brown fox jumps…", Snippet(text, []string{"fox"}, 16))
+	assert.Equal(t, "The quick…", Snippet(text, []string{"quick"}, 10))
+	assert.Equal(t, "…The dog sleeps.", Snippet(text, []string{"sleeps"}, 16))
+	assert.Equal(t, "The quick brown fox jumps over the lazy dog. The dog sleeps.", Snippet(text, []string{"dog"}, 200))
+	assert.Equal(t, "", Snippet(text, []string{"cat"}, 16))
+}
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/bihe/bookmarks/internal/archive"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+// the latest version of the archives of a bookmark is requested by name
+const latestArchive = "latest"
+
+// snapshots are served without scripts, plugins or external resources
+const archivePolicy = "default-src 'none'; img-src data:; style-src 'unsafe-inline'; font-src data:; sandbox"
+
+// swagger:operation POST /api/v1/bookmarks/{id}/archive bookmarks CreateArchive
+//
+// archive the page of a bookmark
+//
+// creates a self-contained snapshot of the page of the bookmark. stylesheets and images of the same
+// origin are embedded, scripts are removed. if the page did not change since the latest version, the
+// existing version is returned.
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+// responses:
+//   '200':
+//     description: ArchiveVersion
+//     schema:
+//       "$ref": "#/definitions/ArchiveVersion"
+//   '201':
+//     description: ArchiveVersion
+//     schema:
+//       "$ref": "#/definitions/ArchiveVersion"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) CreateArchive(user security.User, w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+
+	bm, err := b.Repository.GetBookmarkById(id, user.Username)
+	if err != nil {
+		handler.LogFunction("api.CreateArchive").Warnf("could not find bookmark by id '%s': %v", id, err)
+		return errors.NotFoundError{Err: fmt.Errorf("could not find bookmark with ID '%s'", id), Request: r}
+	}
+	if bm.Type != store.Node || bm.URL == "" {
+		return errors.BadRequestError{Err: fmt.Errorf("only bookmarks with an URL can be archived"), Request: r}
+	}
+
+	archiver, err := b.archiver()
+	if err != nil {
+		handler.LogFunction("api.CreateArchive").Errorf("cannot get the archiver: %v", err)
+		return errors.ServerError{Err: fmt.Errorf("could not archive the page"), Request: r}
+	}
+	a, created, err := archiver.Archive(bm)
+	if err != nil {
+		handler.LogFunction("api.CreateArchive").Warnf("cannot archive the page '%s': %v", bm.URL, err)
+		return errors.BadRequestError{Err: fmt.Errorf("could not archive the page '%s'", bm.URL), Request: r}
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	version := archiveToModel(a)
+	return render.Render(w, r, ArchiveVersionResponse{
+		ArchiveVersion: &version,
+		Status:         status,
+	})
+}
+
+// swagger:operation GET /api/v1/bookmarks/{id}/archive bookmarks GetArchives
+//
+// get the archived versions of a bookmark
+//
+// returns the available snapshots of the bookmark page, the most recent version first
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+// responses:
+//   '200':
+//     description: ArchiveVersionList
+//     schema:
+//       "$ref": "#/definitions/ArchiveVersionList"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetArchives(user security.User, w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+
+	if _, err := b.Repository.GetBookmarkById(id, user.Username); err != nil {
+		handler.LogFunction("api.GetArchives").Warnf("could not find bookmark by id '%s': %v", id, err)
+		return errors.NotFoundError{Err: fmt.Errorf("could not find bookmark with ID '%s'", id), Request: r}
+	}
+	archives, err := b.Repository.GetArchives(id)
+	if err != nil {
+		handler.LogFunction("api.GetArchives").Errorf("cannot get archives of bookmark '%s': %v", id, err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the archives of the bookmark"), Request: r}
+	}
+
+	versions := make([]ArchiveVersion, 0, len(archives))
+	for _, a := range archives {
+		versions = append(versions, archiveToModel(a))
+	}
+	return render.Render(w, r, ArchiveVersionListResponse{
+		ArchiveVersionList: &ArchiveVersionList{
+			Success: true,
+			Count:   len(versions),
+			Message: fmt.Sprintf("Found %d archived versions", len(versions)),
+			Value:   versions,
+		},
+	})
+}
+
+// swagger:operation GET /api/v1/bookmarks/{id}/archive/{version} bookmarks GetArchive
+//
+// get an archived version of a bookmark
+//
+// delivers the self-contained snapshot of the bookmark page. the snapshot is served with a restrictive
+// content-security-policy, which prevents scripts and the access of external resources.
+//
+// ---
+// produces:
+// - text/html
+// parameters:
+// - name: id
+//   in: path
+// - name: version
+//   in: path
+//   description: the number of the version or 'latest'
+// responses:
+//   '200':
+//     description: the archived page
+//   '304':
+//     description: the archived page was not modified
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetArchive(user security.User, w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+	param := chi.URLParam(r, "version")
+
+	version := 0
+	if param != latestArchive {
+		v, err := strconv.Atoi(param)
+		if err != nil || v < 1 {
+			return errors.BadRequestError{Err: fmt.Errorf("invalid version '%s'", param), Request: r}
+		}
+		version = v
+	}
+
+	if _, err := b.Repository.GetBookmarkById(id, user.Username); err != nil {
+		handler.LogFunction("api.GetArchive").Warnf("could not find bookmark by id '%s': %v", id, err)
+		return errors.NotFoundError{Err: fmt.Errorf("could not find bookmark with ID '%s'", id), Request: r}
+	}
+	a, err := b.Repository.GetArchive(id, version)
+	if err != nil {
+		handler.LogFunction("api.GetArchive").Warnf("could not find archive '%s' of bookmark '%s': %v", param, id, err)
+		return errors.NotFoundError{Err: fmt.Errorf("could not find version '%s' of bookmark with ID '%s'", param, id), Request: r}
+	}
+
+	archiver, err := b.archiver()
+	if err != nil {
+		handler.LogFunction("api.GetArchive").Errorf("cannot get the archiver: %v", err)
+		return errors.ServerError{Err: fmt.Errorf("the archived version is not available"), Request: r}
+	}
+	fullPath, err := archiver.Store.File(a.FileName)
+	if err != nil {
+		handler.LogFunction("api.GetArchive").Errorf("invalid snapshot of archive '%d': %v", a.ID, err)
+		return errors.ServerError{Err: fmt.Errorf("the archived version is not available"), Request: r}
+	}
+	if info, err := os.Stat(fullPath); err != nil || info.IsDir() {
+		handler.LogFunction("api.GetArchive").Errorf("the snapshot '%s' is not available", a.FileName)
+		return errors.NotFoundError{Err: fmt.Errorf("the archived version is not available"), Request: r}
+	}
+
+	// 'latest' changes with new versions, the client needs to revalidate
+	w.Header().Set("ETag", `"`+archive.Hash(a.FileName)+`"`)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Content-Security-Policy", archivePolicy)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	http.ServeFile(w, r, fullPath)
+	return nil
+}
+
+// archivePage creates a snapshot of the bookmark page in the background
+func (b *BookmarksAPI) archivePage(bm store.Bookmark) {
+	archiver, err := b.archiver()
+	if err != nil {
+		handler.LogFunction("api.archivePage").Errorf("cannot get the archiver: %v", err)
+		return
+	}
+	if _, _, err := archiver.Archive(bm); err != nil {
+		handler.LogFunction("api.archivePage").Warnf("cannot archive the page '%s': %v", bm.URL, err)
+	}
+}
+
+// archiver returns the archiver, which is created from the configuration of the server
+func (b *BookmarksAPI) archiver() (*archive.Archiver, error) {
+	if b.Archiver == nil {
+		return nil, fmt.Errorf("no archiver is configured")
+	}
+	return b.Archiver, nil
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/archive"
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+)
+
+func archiveServer(content *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Add("content-type", "text/html")
+			w.Write([]byte(`<html><head><title>Page</title><script>alert(1)</script></head><body>` + *content + `<img src="/logo.png"></body></html>`))
+		case "/logo.png":
+			w.Header().Add("content-type", "image/png")
+			w.Write([]byte("png"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func archiveAPI(t *testing.T, repo store.Repository) (*BookmarksAPI, chi.Router, func()) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	client, err := fetch.New(fetch.Options{AllowPrivateNetworks: true})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	bookmarkAPI := &BookmarksAPI{
+		Handler:    baseHandler,
+		Repository: repo,
+		Fetcher:    client,
+		Archiver: &archive.Archiver{
+			Fetcher:    client,
+			Repository: repo,
+			Store:      archive.NewStore(dir),
+		},
+	}
+
+	r := chi.NewRouter()
+	r.Use(jwtUser)
+	r.Post("/{id}/archive", bookmarkAPI.Secure(bookmarkAPI.CreateArchive))
+	r.Get("/{id}/archive", bookmarkAPI.Secure(bookmarkAPI.GetArchives))
+	r.Get("/{id}/archive/{version}", bookmarkAPI.Secure(bookmarkAPI.GetArchive))
+	return bookmarkAPI, r, func() { os.RemoveAll(dir) }
+}
+
+func TestArchive(t *testing.T) {
+	content := "first"
+	ts := archiveServer(&content)
+	defer ts.Close()
+
+	repo, db := repository(t)
+	defer db.Close()
+	_, r, cleanup := archiveAPI(t, repo)
+	defer cleanup()
+
+	bm, err := repo.Create(store.Bookmark{DisplayName: "Page", Path: "/", Type: store.Node, URL: ts.URL + "/page", UserName: userName})
+	if err != nil {
+		t.Fatalf("could not create bookmark: %v", err)
+	}
+	missing, _ := repo.Create(store.Bookmark{DisplayName: "Missing", Path: "/", Type: store.Node, URL: ts.URL + "/missing", UserName: userName})
+	folder, _ := repo.Create(store.Bookmark{DisplayName: "Folder", Path: "/", Type: store.Folder, UserName: userName})
+
+	// nothing is archived yet
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/"+bm.ID+"/archive/latest", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/"+bm.ID+"/archive", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var version ArchiveVersion
+	if err := json.Unmarshal(rec.Body.Bytes(), &version); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 1, version.Version)
+	assert.Equal(t, bm.URL, version.URL)
+	assert.Equal(t, "/api/v1/bookmarks/"+bm.ID+"/archive/1", version.Link)
+
+	// the unchanged page keeps the version
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/"+bm.ID+"/archive", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	content = "second"
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/"+bm.ID+"/archive", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/"+bm.ID+"/archive", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var list ArchiveVersionList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 2, list.Count)
+	assert.Equal(t, 2, list.Value[0].Version)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/"+bm.ID+"/archive/1", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "sandbox")
+	body := rec.Body.String()
+	assert.Contains(t, body, "first")
+	assert.Contains(t, body, "data:image/png;base64,cG5n")
+	assert.NotContains(t, body, "<script")
+	etag := rec.Header().Get("ETag")
+	assert.NotEqual(t, "", etag)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/"+bm.ID+"/archive/latest", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "second")
+
+	// conditional requests are supported
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/"+bm.ID+"/archive/1", nil)
+	req.Header.Set("If-None-Match", etag)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	for _, tc := range []struct {
+		method, url string
+		status      int
+	}{
+		{"GET", "/" + bm.ID + "/archive/0", http.StatusBadRequest},
+		{"GET", "/" + bm.ID + "/archive/first", http.StatusBadRequest},
+		{"GET", "/" + bm.ID + "/archive/3", http.StatusNotFound},
+		{"GET", "/unknown/archive/1", http.StatusNotFound},
+		{"GET", "/unknown/archive", http.StatusNotFound},
+		{"POST", "/unknown/archive", http.StatusNotFound},
+		{"POST", "/" + folder.ID + "/archive", http.StatusBadRequest},
+		{"POST", "/" + missing.ID + "/archive", http.StatusBadRequest},
+	} {
+		rec = httptest.NewRecorder()
+		req, _ = http.NewRequest(tc.method, tc.url, nil)
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, tc.status, rec.Code, tc.url)
+	}
+}
+
+func TestCreateArchivesPage(t *testing.T) {
+	content := "created"
+	ts := archiveServer(&content)
+	defer ts.Close()
+
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := archiveAPI(t, repo)
+	defer cleanup()
+	bookmarkAPI.ArchiveOnCreate = true
+	r.Post("/", bookmarkAPI.Secure(bookmarkAPI.Create))
+
+	payload := `{"path": "/", "displayName": "Page", "url": "` + ts.URL + `/page", "type": "Node", "title": "Page"}`
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(payload))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var result Result
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+
+	// the page is archived in the background
+	var archives []store.Archive
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		archives, _ = repo.GetArchives(result.Value)
+		if len(archives) > 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Equal(t, 1, len(archives))
+}
@@ -0,0 +1,420 @@
+package api
+
+import (
+	er "errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/smartfolder"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+// maxBatchOperations limits the size of a batch, all operations are applied in one transaction
+const maxBatchOperations = 1000
+
+// swagger:operation POST /api/v1/bookmarks/batch bookmarks ApplyBatch
+//
+// apply a batch of operations
+//
+// the operations create, update, move and delete are applied in order within one transaction. created bookmarks
+// get a temporary ID of the client, later operations refer to the bookmarks and folders by the temporary ID. if
+// one operation fails no operation is applied, the result lists the outcome of every operation. the update, move
+// and delete of an existing bookmark need the version of the bookmark, bookmarks created in the batch are exempt
+//
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: BatchResult
+//     schema:
+//       "$ref": "#/definitions/BatchResult"
+//   '400':
+//     description: BatchResult
+//     schema:
+//       "$ref": "#/definitions/BatchResult"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: BatchResult
+//     schema:
+//       "$ref": "#/definitions/BatchResult"
+//   '412':
+//     description: BatchResult
+//     schema:
+//       "$ref": "#/definitions/BatchResult"
+//   '428':
+//     description: BatchResult
+//     schema:
+//       "$ref": "#/definitions/BatchResult"
+//   '500':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) ApplyBatch(user security.User, w http.ResponseWriter, r *http.Request) error {
+	payload := &BatchRequest{}
+	if err := render.Bind(r, payload); err != nil {
+		handler.LogFunction("api.ApplyBatch").Warnf("cannot bind payload: '%v'", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid request data supplied"), Request: r}
+	}
+	if len(payload.Operations) > maxBatchOperations {
+		return errors.BadRequestError{Err: fmt.Errorf("a batch is limited to %d operations", maxBatchOperations), Request: r}
+	}
+
+	handler.LogFunction("api.ApplyBatch").Debugf("apply %d operations of user '%s'", len(payload.Operations), user.Username)
+
+	batch := &bookmarkBatch{
+		api:    b,
+		r:      r,
+		user:   user,
+		ids:    make(map[string]string),
+		failed: -1,
+	}
+	if err := b.inUnitOfWork(func(repo store.Repository) error {
+		batch.repo = repo
+		for i, op := range payload.Operations {
+			result, err := batch.apply(op)
+			if err != nil {
+				batch.failed = i
+				return err
+			}
+			result.Index = i
+			batch.results = append(batch.results, result)
+		}
+		return batch.updateVersions()
+	}); err != nil {
+		handler.LogFunction("api.ApplyBatch").Errorf("could not apply the batch: %v", err)
+		var (
+			badRequest errors.BadRequestError
+			notFound   errors.NotFoundError
+			status     int
+		)
+		switch {
+		case batch.failed < 0:
+			return errors.ServerError{Err: fmt.Errorf("error applying the batch: %v", err), Request: r}
+		case er.As(err, &badRequest):
+			status, err = http.StatusBadRequest, badRequest.Err
+		case er.As(err, &notFound):
+			status, err = http.StatusNotFound, notFound.Err
+		case er.Is(err, store.ErrStaleVersion):
+			status = http.StatusPreconditionFailed
+		case er.Is(err, errPreconditionRequired):
+			status = http.StatusPreconditionRequired
+		default:
+			return errors.ServerError{Err: fmt.Errorf("error applying the batch: %v", err), Request: r}
+		}
+		return render.Render(w, r, BatchResultResponse{
+			BatchResult: batch.failure(payload.Operations, err),
+			Status:      status,
+		})
+	}
+
+	for _, name := range batch.released {
+		b.releaseFavicon(name)
+	}
+	for _, bm := range batch.created {
+		if bm.Type != store.Node || bm.URL == "" {
+			continue
+		}
+		// fire&forget, run this in background and do not wait for the result
+		if bm.Metadata.IsEmpty() {
+			go b.fetchMetadata(bm, user)
+		}
+		if b.ArchiveOnCreate {
+			go b.archivePage(bm)
+		}
+	}
+	for _, bm := range batch.reindex {
+		go b.indexPage(bm)
+	}
+	for _, bm := range batch.favicons {
+		go b.fetchFavicon(bm, user)
+	}
+
+	return render.Render(w, r, BatchResultResponse{
+		BatchResult: &BatchResult{
+			Success: true,
+			Message: fmt.Sprintf("Applied %d operations", len(batch.results)),
+			Results: batch.results,
+		},
+	})
+}
+
+// bookmarkBatch applies the operations of a batch within a unit of work
+type bookmarkBatch struct {
+	api  *BookmarksAPI
+	repo store.Repository
+	r    *http.Request
+	user security.User
+	// ids maps the temporary IDs of the client to the IDs of the created bookmarks
+	ids map[string]string
+	// failed is the index of the operation which stopped the batch
+	failed int
+
+	results  []BatchOperationResult
+	created  []store.Bookmark
+	reindex  []store.Bookmark
+	favicons []store.Bookmark
+	released []string
+}
+
+func (s *bookmarkBatch) apply(op BatchOperation) (BatchOperationResult, error) {
+	switch op.Operation {
+	case BatchCreate:
+		return s.create(op)
+	case BatchUpdate, BatchMove:
+		return s.update(op)
+	case BatchDelete:
+		return s.delete(op)
+	}
+	return BatchOperationResult{}, s.badRequest(fmt.Errorf("invalid operation '%s'", op.Operation))
+}
+
+func (s *bookmarkBatch) create(op BatchOperation) (BatchOperationResult, error) {
+	if op.Bookmark == nil {
+		return BatchOperationResult{}, s.badRequest(fmt.Errorf("the bookmark of the create is missing"))
+	}
+	if _, ok := s.ids[op.TempID]; ok {
+		return BatchOperationResult{}, s.badRequest(fmt.Errorf("the temporary ID '%s' is used twice", op.TempID))
+	}
+	m := *op.Bookmark
+	path, err := s.destination(op, m.Path)
+	if err != nil {
+		return BatchOperationResult{}, err
+	}
+	if path == "" || m.DisplayName == "" {
+		return BatchOperationResult{}, s.badRequest(fmt.Errorf("the path or the name of the bookmark is missing"))
+	}
+
+	t := modelEnumToEntity(m.Type)
+	url, query := m.URL, ""
+	if t == store.SmartFolder {
+		if _, err := smartfolder.Parse(m.Query, time.Now().UTC()); err != nil {
+			return BatchOperationResult{}, s.badRequest(fmt.Errorf("invalid query of smart folder: %v", err))
+		}
+		url, query = "", m.Query
+	}
+	if ok, err := pathAvailable(s.repo, path, s.user.Username); err != nil || !ok {
+		if err == nil {
+			err = s.badRequest(fmt.Errorf("the folder '%s' does not exist", path))
+		}
+		return BatchOperationResult{}, err
+	}
+	if err := ensureNoSmartFolder(s.repo, path, s.user.Username, s.r); err != nil {
+		return BatchOperationResult{}, err
+	}
+
+	item, err := s.repo.Create(store.Bookmark{
+		DisplayName: m.DisplayName,
+		Path:        path,
+		Type:        t,
+		URL:         url,
+		Query:       query,
+		ReadLater:   m.ReadLater && t == store.Node,
+		UserName:    s.user.Username,
+		Favicon:     m.Favicon,
+		SortOrder:   m.SortOrder,
+		Metadata:    m.pageMetadata(),
+	})
+	if err != nil {
+		return BatchOperationResult{}, err
+	}
+	if err := store.LogChange(s.repo, requestID(s.r), store.ChangeCreate, nil, &item); err != nil {
+		return BatchOperationResult{}, err
+	}
+	if op.TempID != "" {
+		s.ids[op.TempID] = item.ID
+	}
+	s.created = append(s.created, item)
+	if item.Type == store.Node && item.URL != "" {
+		s.reindex = append(s.reindex, item)
+	}
+	return BatchOperationResult{Operation: op.Operation, Status: BatchApplied, ID: item.ID, TempID: op.TempID}, nil
+}
+
+// update changes the bookmark like a single update, a move only changes the path of the bookmark
+func (s *bookmarkBatch) update(op BatchOperation) (BatchOperationResult, error) {
+	existing, err := s.existing(op)
+	if err != nil {
+		return BatchOperationResult{}, err
+	}
+
+	var payload Bookmark
+	if op.Operation == BatchMove {
+		if op.ParentID == "" && op.Path == "" {
+			return BatchOperationResult{}, s.badRequest(fmt.Errorf("the destination of the move of '%s' is missing", op.ID))
+		}
+		payload = *entityToModel(existing)
+		payload.Path = op.Path
+	} else {
+		if op.Bookmark == nil {
+			return BatchOperationResult{}, s.badRequest(fmt.Errorf("the bookmark of the update of '%s' is missing", op.ID))
+		}
+		payload = *op.Bookmark
+	}
+	if payload.Path, err = s.destination(op, payload.Path); err != nil {
+		return BatchOperationResult{}, err
+	}
+	if payload.Path == "" || payload.DisplayName == "" {
+		return BatchOperationResult{}, s.badRequest(fmt.Errorf("the path or the name of the bookmark '%s' is missing", op.ID))
+	}
+	if ok, err := pathAvailable(s.repo, payload.Path, s.user.Username); err != nil || !ok {
+		if err == nil {
+			err = s.badRequest(fmt.Errorf("the folder '%s' does not exist", payload.Path))
+		}
+		return BatchOperationResult{}, err
+	}
+
+	item, err := s.api.updateBookmark(s.repo, existing, payload, s.user, s.r)
+	if err != nil {
+		return BatchOperationResult{}, err
+	}
+	if existing.Favicon != item.Favicon {
+		s.released = append(s.released, existing.Favicon)
+	}
+	if existing.Type == store.Node && existing.URL != item.URL && item.URL != "" {
+		s.reindex = append(s.reindex, item)
+	}
+	if item.Favicon == "" {
+		s.favicons = append(s.favicons, item)
+	}
+	return s.applied(op, item.ID), nil
+}
+
+func (s *bookmarkBatch) delete(op BatchOperation) (BatchOperationResult, error) {
+	existing, err := s.existing(op)
+	if err != nil {
+		return BatchOperationResult{}, err
+	}
+	if existing.Type == store.Folder && existing.ChildCount > 0 {
+		return BatchOperationResult{}, s.badRequest(fmt.Errorf("cannot delete folder '%s' because of existing child-elements %d",
+			store.FolderPath(existing.Path, existing.DisplayName), existing.ChildCount))
+	}
+
+	if err := s.repo.Delete(existing); err != nil {
+		return BatchOperationResult{}, err
+	}
+	if err := store.LogChange(s.repo, requestID(s.r), store.ChangeDelete, &existing, nil); err != nil {
+		return BatchOperationResult{}, err
+	}
+	s.released = append(s.released, existing.Favicon)
+	return s.applied(op, existing.ID), nil
+}
+
+// existing returns the bookmark of the operation, the supplied version has to match the stored version. the
+// bookmarks created earlier in the batch are referred to by the temporary ID and need no version
+func (s *bookmarkBatch) existing(op BatchOperation) (store.Bookmark, error) {
+	if op.ID == "" {
+		return store.Bookmark{}, s.badRequest(fmt.Errorf("the ID of the bookmark is missing for the %s", op.Operation))
+	}
+	bm, err := s.repo.GetBookmarkById(s.resolve(op.ID), s.user.Username)
+	if err != nil {
+		handler.LogFunction("api.ApplyBatch").Warnf("could not find bookmark by id '%s': %v", op.ID, err)
+		return store.Bookmark{}, errors.NotFoundError{Err: fmt.Errorf("could not find bookmark with ID '%s'", op.ID), Request: s.r}
+	}
+	if _, created := s.ids[op.ID]; created {
+		return bm, nil
+	}
+	if op.Version == 0 {
+		return store.Bookmark{}, errPreconditionRequired
+	}
+	if op.Version != bm.Version {
+		return store.Bookmark{}, store.ErrStaleVersion
+	}
+	return bm, nil
+}
+
+// destination returns the path of the folder given by the ParentID, without a ParentID the path is used
+func (s *bookmarkBatch) destination(op BatchOperation, path string) (string, error) {
+	if op.ParentID == "" {
+		return path, nil
+	}
+	folder, err := s.repo.GetBookmarkById(s.resolve(op.ParentID), s.user.Username)
+	if err != nil || folder.Type != store.Folder {
+		return "", s.badRequest(fmt.Errorf("the folder '%s' does not exist", op.ParentID))
+	}
+	return store.FolderPath(folder.Path, folder.DisplayName), nil
+}
+
+// resolve replaces a temporary ID of the client with the ID of the created bookmark
+func (s *bookmarkBatch) resolve(id string) string {
+	if created, ok := s.ids[id]; ok {
+		return created
+	}
+	return id
+}
+
+func (s *bookmarkBatch) applied(op BatchOperation, id string) BatchOperationResult {
+	result := BatchOperationResult{Operation: op.Operation, Status: BatchApplied, ID: id}
+	if _, ok := s.ids[op.ID]; ok {
+		result.TempID = op.ID
+	}
+	return result
+}
+
+// updateVersions returns the versions after the batch, later operations change the version of a bookmark again
+func (s *bookmarkBatch) updateVersions() error {
+	ids := make([]string, 0, len(s.results))
+	for _, res := range s.results {
+		ids = append(ids, res.ID)
+	}
+	bookmarks, err := s.repo.GetBookmarksByIds(ids, s.user.Username)
+	if err != nil {
+		return err
+	}
+	versions := make(map[string]int)
+	for _, bm := range bookmarks {
+		versions[bm.ID] = bm.Version
+	}
+	for i := range s.results {
+		// deleted bookmarks have no version
+		s.results[i].Version = versions[s.results[i].ID]
+	}
+	return nil
+}
+
+// failure lists the outcome of the operations of the failed batch, the changes of the operations before the
+// failed operation were discarded
+func (s *bookmarkBatch) failure(ops []BatchOperation, err error) *BatchResult {
+	results := make([]BatchOperationResult, 0, len(ops))
+	for i, op := range ops {
+		result := BatchOperationResult{Index: i, Operation: op.Operation, ID: op.ID, TempID: op.TempID}
+		switch {
+		case i < s.failed:
+			result = s.results[i]
+			result.Status = BatchRolledBack
+			if op.Operation == BatchCreate {
+				// the created bookmark does not exist
+				result.ID = ""
+			}
+		case i == s.failed:
+			result.Status = BatchFailed
+			result.Error = err.Error()
+		default:
+			result.Status = BatchSkipped
+		}
+		results = append(results, result)
+	}
+	return &BatchResult{
+		Success: false,
+		Message: fmt.Sprintf("Operation %d of the batch failed, no operation was applied: %v", s.failed, err),
+		Results: results,
+	}
+}
+
+func (s *bookmarkBatch) badRequest(err error) error {
+	return errors.BadRequestError{Err: err, Request: s.r}
+}
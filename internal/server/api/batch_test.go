@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyBatch(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	// the pages of created bookmarks are processed in background
+	db.DB().SetMaxOpenConns(1)
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Post("/batch", bookmarkAPI.Secure(bookmarkAPI.ApplyBatch))
+
+	node, _ := repo.Create(store.Bookmark{DisplayName: "Go", Path: "/", Type: store.Node, URL: "http://localhost:1/go", UserName: userName, Favicon: "favicon.ico", AccessCount: 3})
+	old, _ := repo.Create(store.Bookmark{DisplayName: "Old", Path: "/", Type: store.Folder, UserName: userName})
+
+	apply := func(payload string) (int, BatchResult) {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/batch", strings.NewReader(payload))
+		req.Header.Add("Content-Type", "application/json")
+		r.ServeHTTP(rec, req)
+		var result BatchResult
+		json.Unmarshal(rec.Body.Bytes(), &result)
+		return rec.Code, result
+	}
+	get := func(id string) store.Bookmark {
+		bm, err := repo.GetBookmarkById(id, userName)
+		if err != nil {
+			t.Fatalf("cannot get bookmark '%s': %v", id, err)
+		}
+		return bm
+	}
+
+	code, result := apply(`{"operations": [
+		{"operation": "create", "tempId": "t1", "bookmark": {"path": "/", "displayName": "Dev", "type": "Folder"}},
+		{"operation": "create", "tempId": "t2", "parentId": "t1", "bookmark": {"displayName": "Lang", "type": "Folder"}},
+		{"operation": "create", "tempId": "t3", "parentId": "t2", "bookmark": {"displayName": "Rust", "type": "Node", "url": "http://localhost:1/rust"}},
+		{"operation": "move", "id": "` + node.ID + `", "parentId": "t2", "version": 1},
+		{"operation": "update", "id": "t3", "bookmark": {"path": "/Dev/Lang", "displayName": "Rustlang", "type": "Node", "url": "http://localhost:1/rust"}},
+		{"operation": "delete", "id": "` + old.ID + `", "version": 1}
+	]}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, result.Success)
+	assert.Equal(t, 6, len(result.Results))
+	for i, res := range result.Results {
+		assert.Equal(t, i, res.Index)
+		assert.Equal(t, BatchApplied, res.Status)
+	}
+	assert.Equal(t, "t1", result.Results[0].TempID)
+	assert.Equal(t, "t3", result.Results[4].TempID)
+	assert.Equal(t, result.Results[2].ID, result.Results[4].ID)
+
+	dev := get(result.Results[0].ID)
+	assert.Equal(t, 1, dev.ChildCount)
+	lang := get(result.Results[1].ID)
+	assert.Equal(t, "/Dev", lang.Path)
+	assert.Equal(t, 2, lang.ChildCount)
+	assert.Equal(t, lang.Version, result.Results[1].Version)
+	rust := get(result.Results[2].ID)
+	assert.Equal(t, "Rustlang", rust.DisplayName)
+	assert.Equal(t, "/Dev/Lang", rust.Path)
+	moved := get(node.ID)
+	assert.Equal(t, "/Dev/Lang", moved.Path)
+	assert.Equal(t, 3, moved.AccessCount)
+	assert.Equal(t, "favicon.ico", moved.Favicon)
+	_, err := repo.GetBookmarkById(old.ID, userName)
+	assert.Error(t, err)
+	assert.Equal(t, 0, result.Results[5].Version)
+
+	// a failed operation discards the batch
+	count := func() int {
+		all, _ := repo.GetAllBookmarks(userName)
+		return len(all)
+	}
+	before := count()
+	code, result = apply(`{"operations": [
+		{"operation": "create", "tempId": "t1", "bookmark": {"path": "/", "displayName": "New", "type": "Folder"}},
+		{"operation": "move", "id": "` + node.ID + `", "parentId": "t1", "version": ` + strconv.Itoa(get(node.ID).Version) + `},
+		{"operation": "delete", "id": "unknown"},
+		{"operation": "delete", "id": "t1"}
+	]}`)
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.False(t, result.Success)
+	assert.Equal(t, []BatchStatus{BatchRolledBack, BatchRolledBack, BatchFailed, BatchSkipped},
+		[]BatchStatus{result.Results[0].Status, result.Results[1].Status, result.Results[2].Status, result.Results[3].Status})
+	assert.Equal(t, "", result.Results[0].ID)
+	assert.NotEqual(t, "", result.Results[2].Error)
+	assert.Equal(t, before, count())
+	assert.Equal(t, "/Dev/Lang", get(node.ID).Path)
+
+	version := get(node.ID).Version
+	code, result = apply(`{"operations": [{"operation": "move", "id": "` + node.ID + `", "path": "/", "version": ` + strconv.Itoa(version-1) + `}]}`)
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	assert.Equal(t, BatchFailed, result.Results[0].Status)
+
+	// existing bookmarks need a version, the bookmarks created in the batch do not
+	for _, op := range []string{"move", "delete"} {
+		code, result = apply(`{"operations": [{"operation": "` + op + `", "id": "` + node.ID + `", "path": "/"}]}`)
+		assert.Equal(t, http.StatusPreconditionRequired, code)
+		assert.Equal(t, BatchFailed, result.Results[0].Status)
+		assert.NotEqual(t, "", result.Results[0].Error)
+	}
+	code, _ = apply(`{"operations": [
+		{"operation": "create", "tempId": "t1", "bookmark": {"path": "/", "displayName": "Temp", "type": "Folder"}},
+		{"operation": "delete", "id": "t1"}
+	]}`)
+	assert.Equal(t, http.StatusOK, code)
+
+	for _, tc := range []struct {
+		name    string
+		payload string
+	}{
+		{"invalid operation", `{"operations": [{"operation": "rename", "id": "` + node.ID + `"}]}`},
+		{"missing bookmark", `{"operations": [{"operation": "create", "tempId": "t1"}]}`},
+		{"missing folder", `{"operations": [{"operation": "create", "bookmark": {"path": "/Missing", "displayName": "A", "type": "Node"}}]}`},
+		{"parent is no folder", `{"operations": [{"operation": "create", "parentId": "` + node.ID + `", "bookmark": {"displayName": "A", "type": "Node"}}]}`},
+		{"temporary ID used twice", `{"operations": [
+			{"operation": "create", "tempId": "t1", "bookmark": {"path": "/", "displayName": "A", "type": "Folder"}},
+			{"operation": "create", "tempId": "t1", "bookmark": {"path": "/", "displayName": "B", "type": "Folder"}}
+		]}`},
+		{"missing destination", `{"operations": [{"operation": "move", "id": "` + node.ID + `", "version": ` + strconv.Itoa(version) + `}]}`},
+		{"folder with child-elements", `{"operations": [{"operation": "delete", "id": "` + dev.ID + `", "version": ` + strconv.Itoa(get(dev.ID).Version) + `}]}`},
+		{"folder into itself", `{"operations": [{"operation": "move", "id": "` + dev.ID + `", "parentId": "` + lang.ID + `", "version": ` + strconv.Itoa(get(dev.ID).Version) + `}]}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			code, result := apply(tc.payload)
+			assert.Equal(t, http.StatusBadRequest, code)
+			assert.False(t, result.Success)
+		})
+	}
+	assert.Equal(t, before, count())
+
+	code, _ = apply(`{"operations": []}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
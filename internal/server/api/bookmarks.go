@@ -20,19 +20,23 @@
 package api
 
 import (
-	"crypto/sha1"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
 	"path"
-	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	er "errors"
 
+	"github.com/bihe/bookmarks/internal/archive"
+	"github.com/bihe/bookmarks/internal/events"
 	"github.com/bihe/bookmarks/internal/favicon"
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/bihe/bookmarks/internal/linkhealth"
+	"github.com/bihe/bookmarks/internal/search"
+	"github.com/bihe/bookmarks/internal/smartfolder"
 	"github.com/bihe/bookmarks/internal/store"
 	"golang.binggl.net/commons/errors"
 	"golang.binggl.net/commons/handler"
@@ -48,13 +52,21 @@ type BookmarksAPI struct {
 	BasePath       string
 	FaviconPath    string
 	DefaultFavicon string
+	Fetcher        fetch.Fetcher
+	LinkChecker    *linkhealth.Checker
+	Archiver       *archive.Archiver
+	Indexer        *search.Indexer
+	// ArchiveOnCreate creates a snapshot of the page for new bookmarks
+	ArchiveOnCreate bool
+	// Events publishes the changes of the bookmarks to the event stream
+	Events *events.Bus
 }
 
 // swagger:operation GET /api/v1/bookmarks/{id} bookmarks GetBookmarkByID
 //
 // get a bookmark by id
 //
-// returns a single bookmark specified by it's ID
+// returns a single bookmark specified by it's ID, the version of the bookmark is returned as ETag
 //
 // ---
 // produces:
@@ -98,6 +110,7 @@ func (b *BookmarksAPI) GetBookmarkByID(user security.User, w http.ResponseWriter
 		return errors.NotFoundError{Err: fmt.Errorf("no bookmark with ID '%s' avaliable", id), Request: r}
 	}
 
+	w.Header().Set("ETag", bookmarkETag(bookmark.Version))
 	return render.Render(w, r, BookmarkResponse{Bookmark: entityToModel(bookmark)})
 }
 
@@ -105,7 +118,8 @@ func (b *BookmarksAPI) GetBookmarkByID(user security.User, w http.ResponseWriter
 //
 // get bookmarks by path
 //
-// returns a list of bookmarks for a given path
+// returns a list of bookmarks for a given path. the bookmarks of a smart folder are found by its query.
+// the list is ordered by the sortorder and the name, unless another sort key is requested
 //
 // ---
 // produces:
@@ -113,6 +127,21 @@ func (b *BookmarksAPI) GetBookmarkByID(user security.User, w http.ResponseWriter
 // parameters:
 // - name: path
 //   in: query
+// - name: limit
+//   in: query
+//   description: the number of bookmarks of a page, without a limit all bookmarks are returned
+// - name: cursor
+//   in: query
+//   description: the position of the page, the next link of the previous page
+// - name: sort
+//   in: query
+//   description: sortOrder, name, created, modified, accessCount or host
+// - name: order
+//   in: query
+//   description: asc or desc
+// - name: fields
+//   in: query
+//   description: comma separated list of the fields of the bookmarks
 // responses:
 //   '200':
 //     description: BookmarkList
@@ -137,23 +166,37 @@ func (b *BookmarksAPI) GetBookmarksByPath(user security.User, w http.ResponseWri
 		return errors.BadRequestError{Err: fmt.Errorf("missing path parameter"), Request: r}
 	}
 
+	opts, err := readListOptions(r, "", false, 0)
+	if err != nil {
+		return errors.BadRequestError{Err: err, Request: r}
+	}
+
 	handler.LogFunction("api.GetBookmarksByPath").Debugf("get bookmarks by path: '%s' for user: '%s'", path, user.Username)
 
-	bms, err := b.Repository.GetBookmarksByPath(path, user.Username)
-	var bookmarks []Bookmark
+	page, err := b.Repository.GetBookmarksByPath(path, user.Username, opts.ListOptions)
 	if err != nil {
 		handler.LogFunction("api.GetBookmarksByPath").Warnf("cannot get bookmark by path: '%s', %v", path, err)
 	}
-	bookmarks = entityListToModel(bms)
-	count := len(bookmarks)
-	result := BookmarkList{
-		Success: true,
-		Count:   count,
-		Message: fmt.Sprintf("Found %d items.", count),
-		Value:   bookmarks,
+	if page.Total == 0 {
+		// the content of a smart folder is found by its query
+		smart, found, err := b.listSmartFolder(path, user.Username)
+		if err != nil {
+			return errors.BadRequestError{Err: err, Request: r}
+		}
+		if found {
+			// the order of the query is kept, unless a page or an order is requested
+			page = store.BookmarkPage{Bookmarks: smart, Total: len(smart)}
+			if opts.sorted() {
+				if page, err = store.Paginate(smart, opts.ListOptions); err != nil {
+					return errors.BadRequestError{Err: err, Request: r}
+				}
+			}
+		}
 	}
+	bookmarks := entityListToModel(page.Bookmarks)
+	result := pagedList(r, bookmarks, page)
 
-	return render.Render(w, r, BookmarkListResponse{BookmarkList: &result})
+	return render.Render(w, r, BookmarkListResponse{BookmarkList: &result, Fields: opts.fields})
 }
 
 // swagger:operation GET /api/v1/bookmarks/folder bookmarks GetBookmarksFolderByPath
@@ -215,8 +258,12 @@ func (b *BookmarksAPI) GetBookmarksFolderByPath(user security.User, w http.Respo
 
 	bm, err := b.Repository.GetFolderByPath(path, user.Username)
 	if err != nil {
-		handler.LogFunction("api.GetBookmarksFolderByPath").Warnf("cannot get bookmark folder by path: '%s', %v", path, err)
-		return errors.NotFoundError{Err: fmt.Errorf("no folder for path '%s' found", path), Request: r}
+		smart, smartErr := b.Repository.GetSmartFolderByPath(path, user.Username)
+		if smartErr != nil {
+			handler.LogFunction("api.GetBookmarksFolderByPath").Warnf("cannot get bookmark folder by path: '%s', %v", path, err)
+			return errors.NotFoundError{Err: fmt.Errorf("no folder for path '%s' found", path), Request: r}
+		}
+		bm = smart
 	}
 
 	return render.Render(w, r, BookmarResultResponse{BookmarkResult: &BookmarkResult{
@@ -273,7 +320,8 @@ func (b *BookmarksAPI) GetAllPaths(user security.User, w http.ResponseWriter, r
 //
 // get bookmarks by name
 //
-// search for bookmarks by name and return a list of search-results
+// search for bookmarks by name and return a list of search-results. bookmarks whose page content
+// contains all words of the name are added to the results, including a snippet of the matching text
 //
 // ---
 // produces:
@@ -281,6 +329,24 @@ func (b *BookmarksAPI) GetAllPaths(user security.User, w http.ResponseWriter, r
 // parameters:
 // - name: name
 //   in: query
+// - name: content
+//   in: query
+//   description: set to false to search the names only
+// - name: limit
+//   in: query
+//   description: the number of bookmarks of a page, without a limit all bookmarks are returned
+// - name: cursor
+//   in: query
+//   description: the position of the page, the next link of the previous page
+// - name: sort
+//   in: query
+//   description: sortOrder, name, created, modified, accessCount or host
+// - name: order
+//   in: query
+//   description: asc or desc
+// - name: fields
+//   in: query
+//   description: comma separated list of the fields of the bookmarks
 // responses:
 //   '200':
 //     description: BookmarkList
@@ -305,30 +371,49 @@ func (b *BookmarksAPI) GetBookmarksByName(user security.User, w http.ResponseWri
 		return errors.BadRequestError{Err: fmt.Errorf("missing name parameter"), Request: r}
 	}
 
+	opts, err := readListOptions(r, "", false, 0)
+	if err != nil {
+		return errors.BadRequestError{Err: err, Request: r}
+	}
+	content := r.URL.Query().Get("content") != "false"
+	query := opts.ListOptions
+	if content {
+		// the content matches are added to all name matches, the page is taken from the combined results
+		query = store.ListOptions{Sort: opts.Sort, Desc: opts.Desc}
+	}
+
 	handler.LogFunction("api.GetBookmarksByName").Debugf("get bookmarks by name: '%s' for user: '%s'", name, user.Username)
 
-	bms, err := b.Repository.GetBookmarksByName(name, user.Username)
-	var bookmarks []Bookmark
+	page, err := b.Repository.GetBookmarksByName(name, user.Username, query)
 	if err != nil {
 		handler.LogFunction("api.GetBookmarksByName").Warnf("cannot get bookmark by name: '%s', %v", name, err)
 	}
-	bookmarks = entityListToModel(bms)
-	count := len(bookmarks)
-	result := BookmarkList{
-		Success: true,
-		Count:   count,
-		Message: fmt.Sprintf("Found %d items.", count),
-		Value:   bookmarks,
+	var snippets map[string]string
+	if content {
+		var found []store.Bookmark
+		found, snippets = b.searchContent(page.Bookmarks, name, user.Username)
+		page = store.BookmarkPage{Bookmarks: found, Total: len(found)}
+		if opts.sorted() {
+			if page, err = store.Paginate(found, opts.ListOptions); err != nil {
+				return errors.BadRequestError{Err: err, Request: r}
+			}
+		}
 	}
+	bookmarks := entityListToModel(page.Bookmarks)
+	for i := range bookmarks {
+		bookmarks[i].Snippet = snippets[bookmarks[i].ID]
+	}
+	result := pagedList(r, bookmarks, page)
 
-	return render.Render(w, r, BookmarkListResponse{BookmarkList: &result})
+	return render.Render(w, r, BookmarkListResponse{BookmarkList: &result, Fields: opts.fields})
 }
 
 // swagger:operation GET /api/v1/bookmarks/mostvisited/{num} bookmarks GetMostVisited
 //
-// get recent accessed bookmarks
+// get the most visited bookmarks
 //
-// return the most recently visited bookmarks
+// return the bookmarks with the highest access count, regardless of the time of the visits. num is the size of
+// the first page, unless a limit is given
 //
 // ---
 // produces:
@@ -336,11 +421,30 @@ func (b *BookmarksAPI) GetBookmarksByName(user security.User, w http.ResponseWri
 // parameters:
 // - name: num
 //   in: path
+// - name: limit
+//   in: query
+//   description: the number of bookmarks of a page, the default is num
+// - name: cursor
+//   in: query
+//   description: the position of the page, the next link of the previous page
+// - name: sort
+//   in: query
+//   description: sortOrder, name, created, modified, accessCount or host
+// - name: order
+//   in: query
+//   description: asc or desc
+// - name: fields
+//   in: query
+//   description: comma separated list of the fields of the bookmarks
 // responses:
 //   '200':
 //     description: BookmarkList
 //     schema:
 //       "$ref": "#/definitions/BookmarkList"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
 //   '401':
 //     description: ProblemDetail
 //     schema:
@@ -355,37 +459,43 @@ func (b *BookmarksAPI) GetMostVisited(user security.User, w http.ResponseWriter,
 	if num < 1 {
 		num = 100
 	}
+	if num > maxPageSize {
+		num = maxPageSize
+	}
+	opts, err := readListOptions(r, store.SortAccessCount, true, num)
+	if err != nil {
+		return errors.BadRequestError{Err: err, Request: r}
+	}
 
 	handler.LogFunction("api.GetMostVisited").Debugf("get the most recent, most often visited bookmarks for user: '%s'", user.Username)
 
-	bms, err := b.Repository.GetMostRecentBookmarks(user.Username, num)
-	var bookmarks []Bookmark
+	page, err := b.Repository.GetMostRecentBookmarks(user.Username, opts.ListOptions)
 	if err != nil {
 		handler.LogFunction("api.GetMostVisited").Warnf("cannot get most visited bookmarks: '%v'", err)
 	}
-	bookmarks = entityListToModel(bms)
-	count := len(bookmarks)
-	result := BookmarkList{
-		Success: true,
-		Count:   count,
-		Message: fmt.Sprintf("Found %d items.", count),
-		Value:   bookmarks,
-	}
+	bookmarks := entityListToModel(page.Bookmarks)
+	result := pagedList(r, bookmarks, page)
 
-	return render.Render(w, r, BookmarkListResponse{BookmarkList: &result})
+	return render.Render(w, r, BookmarkListResponse{BookmarkList: &result, Fields: opts.fields})
 }
 
 // swagger:operation POST /api/v1/bookmarks bookmarks CreateBookmark
 //
 // create a bookmark
 //
-// use the supplied payload to create a new bookmark
+// use the supplied payload to create a new bookmark. if requested, the IDs of existing bookmarks
+// with the same normalized URL are returned as a warning. smart folders need a valid query and
+// cannot contain other bookmarks
 //
 // ---
 // consumes:
 // - application/json
 // produces:
 // - application/json
+// parameters:
+// - name: checkDuplicates
+//   in: query
+//   description: set to true to return the IDs of existing bookmarks with the same URL
 // responses:
 //   '200':
 //     description: Result
@@ -408,6 +518,7 @@ func (b *BookmarksAPI) Create(user security.User, w http.ResponseWriter, r *http
 		id      string
 		payload *BookmarkRequest
 		t       store.NodeType
+		created store.Bookmark
 	)
 
 	payload = &BookmarkRequest{}
@@ -423,38 +534,78 @@ func (b *BookmarksAPI) Create(user security.User, w http.ResponseWriter, r *http
 
 	handler.LogFunction("api.Create").Debugf("will try to create a new bookmark entry: '%s'", payload)
 
-	t = store.Node
-	if payload.Type == Folder {
-		t = store.Folder
+	t = modelEnumToEntity(payload.Type)
+	url, query := payload.URL, ""
+	if t == store.SmartFolder {
+		if _, err := smartfolder.Parse(payload.Query, time.Now().UTC()); err != nil {
+			handler.LogFunction("api.Create").Warnf("invalid query of smart folder: %v", err)
+			return errors.BadRequestError{Err: fmt.Errorf("invalid query of smart folder: %v", err), Request: r}
+		}
+		// a smart folder has no URL, the bookmarks are found by the query
+		url, query = "", payload.Query
 	}
 
-	if err := b.Repository.InUnitOfWork(func(repo store.Repository) error {
+	if err := b.inUnitOfWork(func(repo store.Repository) error {
+		if err := ensureNoSmartFolder(repo, payload.Path, user.Username, r); err != nil {
+			return err
+		}
 		item, err := repo.Create(store.Bookmark{
 			DisplayName: payload.DisplayName,
 			Path:        payload.Path,
 			Type:        t,
-			URL:         payload.URL,
+			URL:         url,
+			Query:       query,
+			ReadLater:   payload.ReadLater && t == store.Node,
 			UserName:    user.Username,
 			Favicon:     payload.Favicon,
 			SortOrder:   payload.SortOrder,
+			Metadata:    payload.pageMetadata(),
 		})
 		if err != nil {
 			return err
 		}
+		if err := store.LogChange(repo, requestID(r), store.ChangeCreate, nil, &item); err != nil {
+			return err
+		}
 		id = item.ID
+		created = item
 		return nil
 	}); err != nil {
 		handler.LogFunction("api.Create").Errorf("could not create a new bookmark: %v", err)
+
+		var badRequest errors.BadRequestError
+		if er.As(err, &badRequest) {
+			return badRequest
+		}
 		return errors.ServerError{Err: fmt.Errorf("error creating a new bookmark: %v", err), Request: r}
 	}
 
 	handler.LogFunction("api.Create").Infof("bookmark created with ID: %s", id)
+
+	// complete the bookmark with the metadata of the page
+	if created.Type == store.Node && created.URL != "" && created.Metadata.IsEmpty() {
+		// fire&forget, run this in background and do not wait for the result
+		go b.fetchMetadata(created, user)
+	}
+	if created.Type == store.Node && created.URL != "" {
+		go b.indexPage(created)
+	}
+	if b.ArchiveOnCreate && created.Type == store.Node && created.URL != "" {
+		go b.archivePage(created)
+	}
+	result := &Result{
+		Success: true,
+		Message: fmt.Sprintf("Bookmark created with ID '%s'", id),
+		Value:   id,
+	}
+	if r.URL.Query().Get("checkDuplicates") == "true" && created.Type == store.Node && created.URL != "" {
+		result.Duplicates = b.findDuplicates(created, user.Username)
+		if n := len(result.Duplicates); n > 0 {
+			result.Message += fmt.Sprintf(", the URL is already bookmarked %d time(s)", n)
+		}
+	}
 	return render.Render(w, r, ResultResponse{
-		Result: &Result{
-			Success: true,
-			Message: fmt.Sprintf("Bookmark created with ID '%s'", id),
-			Value:   id,
-		},
+		Result: result,
 		Status: http.StatusCreated,
 	})
 }
@@ -463,13 +614,18 @@ func (b *BookmarksAPI) Create(user security.User, w http.ResponseWriter, r *http
 //
 // update a bookmark
 //
-// use the supplied payload to update a existing bookmark
+// use the supplied payload to update a existing bookmark. the version of the bookmark is required, either as
+// If-Match header or as version of the payload. a bookmark changed in the meantime is not updated. the fields
+// maintained by the server, like the accessCount, are not changed
 //
 // ---
 // consumes:
 // - application/json
 // produces:
 // - application/json
+// parameters:
+// - name: If-Match
+//   in: header
 // responses:
 //   '200':
 //     description: Result
@@ -487,10 +643,22 @@ func (b *BookmarksAPI) Create(user security.User, w http.ResponseWriter, r *http
 //     description: ProblemDetail
 //     schema:
 //       "$ref": "#/definitions/ProblemDetail"
+//   '412':
+//     description: VersionConflict
+//     schema:
+//       "$ref": "#/definitions/VersionConflict"
+//   '428':
+//     description: VersionConflict
+//     schema:
+//       "$ref": "#/definitions/VersionConflict"
 func (b *BookmarksAPI) Update(user security.User, w http.ResponseWriter, r *http.Request) error {
 	var (
-		id      string
-		payload *BookmarkRequest
+		id         string
+		version    int
+		payload    *BookmarkRequest
+		oldFavicon string
+		reindex    *store.Bookmark
+		favicon    *store.Bookmark
 	)
 
 	payload = &BookmarkRequest{}
@@ -504,125 +672,45 @@ func (b *BookmarksAPI) Update(user security.User, w http.ResponseWriter, r *http
 		return errors.BadRequestError{Err: fmt.Errorf("invalid request data supplied, missing ID, Path or DisplayName"), Request: r}
 
 	}
+	expected, err := readPrecondition(r, payload.Version)
+	if err != nil {
+		return errors.BadRequestError{Err: err, Request: r}
+	}
 
 	handler.LogFunction("api.Update").Debugf("will try to update existing bookmark entry: '%s'", payload)
 
-	if err := b.Repository.InUnitOfWork(func(repo store.Repository) error {
+	if err := b.inUnitOfWork(func(repo store.Repository) error {
 		// 1) fetch the existing bookmark by id
 		existing, err := repo.GetBookmarkById(payload.ID, user.Username)
 		if err != nil {
 			handler.LogFunction("api.Update").Warnf("could not find bookmark by id '%s': %v", payload.ID, err)
 			return err
 		}
-		childCount := existing.ChildCount
-		if existing.Type == store.Folder {
-			// 2) ensure that the existing folder is not moved to itself
-			folderPath := ensureFolderPath(existing.Path, existing.DisplayName)
-			if payload.Path == folderPath {
-				handler.LogFunction("api.Update").Warnf("a folder cannot be moved into itself: folder-path: '%s', destination: '%s'", folderPath, payload.Path)
-				return errors.BadRequestError{Err: fmt.Errorf("cannot move folder into itself"), Request: r}
-			}
-
-			// 3) get the folder child-count
-			// on save of a folder, update the child-count
-			parentPath := existing.Path
-			path := ensureFolderPath(parentPath, existing.DisplayName)
-			nodeCount, err := repo.GetPathChildCount(path, user.Username)
-			if err != nil {
-				handler.LogFunction("api.Update").Warnf("could not get child-count of path '%s': %v", path, err)
-				return err
-			}
-			if len(nodeCount) > 0 {
-				for _, n := range nodeCount {
-					if n.Path == path {
-						childCount = n.Count
-						break
-					}
-				}
-			}
+		if err := expected.check(existing.Version); err != nil {
+			return err
 		}
-
-		existingDisplayName := existing.DisplayName
-		existingPath := existing.Path
-
-		// 4) update the bookmark
-		item, err := repo.Update(store.Bookmark{
-			ID:          payload.ID,
-			Created:     existing.Created,
-			DisplayName: payload.DisplayName,
-			Path:        payload.Path,
-			Type:        existing.Type, // it does not make any sense to change the type of a bookmark!
-			URL:         payload.URL,
-			SortOrder:   payload.SortOrder,
-			UserName:    user.Username,
-			ChildCount:  childCount,
-			Favicon:     payload.Favicon,
-			AccessCount: payload.AccessCount,
-		})
+		item, err := b.updateBookmark(repo, existing, *payload.Bookmark, user, r)
 		if err != nil {
-			handler.LogFunction("api.Update").Warnf("could not update bookmark: %v", err)
 			return err
 		}
 		id = item.ID
-
-		// also update the favicon if not available
-		if item.Favicon == "" {
-			// fire&forget, run this in background and do not wait for the result
-			go b.fetchFavicon(item, user)
+		version = item.Version
+		if existing.Favicon != item.Favicon {
+			oldFavicon = existing.Favicon
 		}
-
-		if existing.Type == store.Folder && (existingDisplayName != payload.DisplayName || existingPath != payload.Path) {
-			// if we have a folder and change the displayname or the parent-path, this also affects ALL sub-elements
-			// therefore all paths of sub-elements where this folder-path is present, need to be updated
-			newPath := ensureFolderPath(payload.Path, payload.DisplayName)
-			oldPath := ensureFolderPath(existingPath, existingDisplayName)
-
-			handler.LogFunction("api.Update").Warnf("will update all old paths '%s' to new path '%s'", oldPath, newPath)
-
-			bookmarks, err := repo.GetBookmarksByPathStart(oldPath, user.Username)
-			if err != nil {
-				handler.LogFunction("api.Update").Warnf("could not get bookmarks by path '%s': %v", oldPath, err)
-				return err
-			}
-
-			for _, bm := range bookmarks {
-				updatePath := strings.ReplaceAll(bm.Path, oldPath, newPath)
-				if _, err := repo.Update(store.Bookmark{
-					ID:          bm.ID,
-					Created:     bm.Created,
-					DisplayName: bm.DisplayName,
-					Path:        updatePath,
-					SortOrder:   bm.SortOrder,
-					Type:        bm.Type,
-					URL:         bm.URL,
-					UserName:    user.Username,
-					ChildCount:  bm.ChildCount,
-					AccessCount: bm.AccessCount,
-					Favicon:     bm.Favicon,
-				}); err != nil {
-					handler.LogFunction("api.Update").Warnf("cannot update bookmark path: %v", err)
-					return err
-				}
-			}
+		if existing.Type == store.Node && existing.URL != item.URL && item.URL != "" {
+			reindex = &item
 		}
-
-		// if the path has changed - update the childcount of affected paths
-		if existingPath != payload.Path {
-			// the affected paths are the origin-path and the destination-path
-			if err := updateChildCountOfPath(existingPath, user.Username, repo); err != nil {
-				handler.LogFunction("api.Update").Errorf("could not update child-count of path '%s': %v", existingPath, err)
-				return err
-			}
-			// and the destination-path
-			if err := updateChildCountOfPath(payload.Path, user.Username, repo); err != nil {
-				handler.LogFunction("api.Update").Errorf("could not update child-count of path '%s': %v", payload.Path, err)
-				return err
-			}
+		if item.Favicon == "" {
+			favicon = &item
 		}
 
 		return nil
 	}); err != nil {
 		handler.LogFunction("api.Update").Errorf("could not update bookmark because of error: %v", err)
+		if isPreconditionError(err) {
+			return b.renderPreconditionError(err, payload.ID, user.Username, w, r)
+		}
 
 		var badRequest errors.BadRequestError
 		if er.As(err, &badRequest) {
@@ -633,6 +721,16 @@ func (b *BookmarksAPI) Update(user security.User, w http.ResponseWriter, r *http
 	}
 
 	handler.LogFunction("api.Update").Infof("updated bookmark with ID '%s'", id)
+	w.Header().Set("ETag", bookmarkETag(version))
+	b.releaseFavicon(oldFavicon)
+	if reindex != nil {
+		// fire&forget, index the page of the changed URL in background
+		go b.indexPage(*reindex)
+	}
+	if favicon != nil {
+		// fire&forget, fetch the missing favicon in background
+		go b.fetchFavicon(*favicon, user)
+	}
 
 	return render.Render(w, r, ResultResponse{
 		Result: &Result{
@@ -643,36 +741,174 @@ func (b *BookmarksAPI) Update(user security.User, w http.ResponseWriter, r *http
 	})
 }
 
-func updateChildCountOfPath(path, username string, repo store.Repository) error {
-	if path == "/" {
-		handler.LogFunction("api.updateChildCountOfPath").Debugf("skip the ROOT path '/'")
-		return nil
+// updateBookmark changes the existing bookmark to the values of the payload. the sub-elements of a renamed or
+// moved folder and the child-counts of the affected paths are updated as well. a missing favicon is fetched by
+// the caller, after the changes are committed
+func (b *BookmarksAPI) updateBookmark(repo store.Repository, existing store.Bookmark, payload Bookmark, user security.User, r *http.Request) (store.Bookmark, error) {
+	childCount := existing.ChildCount
+	if existing.Type == store.Folder {
+		// 2) ensure that the existing folder is not moved to itself
+		folderPath := store.FolderPath(existing.Path, existing.DisplayName)
+		if payload.Path == folderPath || strings.HasPrefix(payload.Path, folderPath+"/") {
+			handler.LogFunction("api.updateBookmark").Warnf("a folder cannot be moved into itself: folder-path: '%s', destination: '%s'", folderPath, payload.Path)
+			return store.Bookmark{}, errors.BadRequestError{Err: fmt.Errorf("cannot move folder into itself"), Request: r}
+		}
+
+		// 3) get the folder child-count
+		// on save of a folder, update the child-count
+		parentPath := existing.Path
+		path := store.FolderPath(parentPath, existing.DisplayName)
+		nodeCount, err := repo.GetPathChildCount(path, user.Username)
+		if err != nil {
+			handler.LogFunction("api.updateBookmark").Warnf("could not get child-count of path '%s': %v", path, err)
+			return store.Bookmark{}, err
+		}
+		if len(nodeCount) > 0 {
+			for _, n := range nodeCount {
+				if n.Path == path {
+					childCount = n.Count
+					break
+				}
+			}
+		}
 	}
 
-	folder, err := repo.GetFolderByPath(path, username)
-	if err != nil {
-		handler.LogFunction("api.updateChildCountOfPath").Errorf("cannot get the folder for path '%s': %v", path, err)
-		return err
+	existingDisplayName := existing.DisplayName
+	existingPath := existing.Path
+
+	if err := ensureNoSmartFolder(repo, payload.Path, user.Username, r); err != nil {
+		return store.Bookmark{}, err
+	}
+	url, query := payload.URL, ""
+	if existing.Type == store.SmartFolder {
+		if _, err := smartfolder.Parse(payload.Query, time.Now().UTC()); err != nil {
+			handler.LogFunction("api.updateBookmark").Warnf("invalid query of smart folder: %v", err)
+			return store.Bookmark{}, errors.BadRequestError{Err: fmt.Errorf("invalid query of smart folder: %v", err), Request: r}
+		}
+		url, query = "", payload.Query
 	}
 
-	nodeCount, err := repo.GetPathChildCount(path, username)
+	// 4) update the bookmark
+	item, err := repo.Update(store.Bookmark{
+		ID:          existing.ID,
+		Created:     existing.Created,
+		DisplayName: payload.DisplayName,
+		Path:        payload.Path,
+		Type:        existing.Type, // it does not make any sense to change the type of a bookmark!
+		URL:         url,
+		Query:       query,
+		ReadLater:   payload.ReadLater && existing.Type == store.Node,
+		SortOrder:   payload.SortOrder,
+		UserName:    user.Username,
+		ChildCount:  childCount,
+		Favicon:     payload.Favicon,
+		AccessCount: existing.AccessCount, // the visits are counted by the server
+		Metadata:    payload.pageMetadata(),
+	})
 	if err != nil {
-		handler.LogFunction("api.updateChildCountOfPath").Errorf("could not get the child-count of the path '%s': %v", path, err)
-		return err
+		handler.LogFunction("api.updateBookmark").Warnf("could not update bookmark: %v", err)
+		return store.Bookmark{}, err
+	}
+	if err := store.LogChange(repo, requestID(r), store.ChangeUpdate, &existing, &item); err != nil {
+		return store.Bookmark{}, err
 	}
+	// keep the previous URL, a pending suggestion is replaced by the changed URL
+	if existing.Type == store.Node && existing.URL != item.URL {
+		if err := recordURLChange(repo, existing, item); err != nil {
+			return store.Bookmark{}, err
+		}
+	}
+
+	if existing.Type == store.Folder && (existingDisplayName != payload.DisplayName || existingPath != payload.Path) {
+		// if we have a folder and change the displayname or the parent-path, this also affects ALL sub-elements
+		// therefore all paths of sub-elements where this folder-path is present, need to be updated
+		newPath := store.FolderPath(payload.Path, payload.DisplayName)
+		oldPath := store.FolderPath(existingPath, existingDisplayName)
+
+		handler.LogFunction("api.updateBookmark").Warnf("will update all old paths '%s' to new path '%s'", oldPath, newPath)
 
-	var childCount int
-	if len(nodeCount) > 0 {
-		// use the firest element, because the count was queried for a specific path
-		childCount = nodeCount[0].Count
+		if err := moveFolderContent(repo, requestID(r), oldPath, newPath, user.Username); err != nil {
+			return store.Bookmark{}, err
+		}
 	}
 
-	folder.ChildCount = childCount
+	// if the path has changed - update the childcount of affected paths
+	if existingPath != payload.Path {
+		// the affected paths are the origin-path and the destination-path
+		if err := repo.UpdateChildCountOfPath(existingPath, user.Username); err != nil {
+			handler.LogFunction("api.updateBookmark").Errorf("could not update child-count of path '%s': %v", existingPath, err)
+			return store.Bookmark{}, err
+		}
+		// and the destination-path
+		if err := repo.UpdateChildCountOfPath(payload.Path, user.Username); err != nil {
+			handler.LogFunction("api.updateBookmark").Errorf("could not update child-count of path '%s': %v", payload.Path, err)
+			return store.Bookmark{}, err
+		}
+	}
+
+	return item, nil
+}
 
-	if _, err := repo.Update(folder); err != nil {
-		handler.LogFunction("api.updateChildCountOfPath").Errorf("could not update the child-count of folder '%s': %v", path, err)
+// recordURLChange keeps the previous URL of the bookmark in the history and removes the URL suggestion and the
+// indexed content of the previous URL
+func recordURLChange(repo store.Repository, existing, item store.Bookmark) error {
+	if err := repo.AddURLHistory(store.URLHistory{
+		BookmarkID: item.ID,
+		UserName:   item.UserName,
+		OldURL:     existing.URL,
+		NewURL:     item.URL,
+		Reason:     store.URLChangeManual,
+	}); err != nil {
+		handler.LogFunction("api.recordURLChange").Warnf("could not save the URL history: %v", err)
+		return err
+	}
+	if err := repo.DeleteURLSuggestion(item.ID); err != nil {
+		handler.LogFunction("api.recordURLChange").Warnf("could not remove the URL suggestion: %v", err)
 		return err
 	}
+	// the content of the previous URL is no longer searchable
+	if err := repo.DeleteContent(item.ID); err != nil {
+		handler.LogFunction("api.recordURLChange").Warnf("could not remove the indexed content: %v", err)
+		return err
+	}
+	return nil
+}
+
+// moveFolderContent changes the path of all sub-elements of a renamed or moved folder
+func moveFolderContent(repo store.Repository, reqID, oldPath, newPath, username string) error {
+	bookmarks, err := repo.GetBookmarksByPathStart(oldPath, username)
+	if err != nil {
+		handler.LogFunction("api.moveFolderContent").Warnf("could not get bookmarks by path '%s': %v", oldPath, err)
+		return err
+	}
+
+	for _, bm := range bookmarks {
+		updatePath := strings.ReplaceAll(bm.Path, oldPath, newPath)
+		updated, err := repo.Update(store.Bookmark{
+			ID:          bm.ID,
+			Created:     bm.Created,
+			DisplayName: bm.DisplayName,
+			Path:        updatePath,
+			SortOrder:   bm.SortOrder,
+			Type:        bm.Type,
+			URL:         bm.URL,
+			Query:       bm.Query,
+			ReadLater:   bm.ReadLater,
+			UserName:    username,
+			ChildCount:  bm.ChildCount,
+			AccessCount: bm.AccessCount,
+			Favicon:     bm.Favicon,
+			Metadata:    bm.Metadata,
+		})
+		if err != nil {
+			handler.LogFunction("api.moveFolderContent").Warnf("cannot update bookmark path: %v", err)
+			return err
+		}
+		// the changed path of every sub-element is logged, the changes share the ID of the request
+		if err := store.LogChange(repo, reqID, store.ChangeUpdate, &bm, &updated); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -680,7 +916,8 @@ func updateChildCountOfPath(path, username string, repo store.Repository) error
 //
 // delete a bookmark
 //
-// delete the bookmark identified by the supplied id
+// delete the bookmark identified by the supplied id. the version of the bookmark is required, either as
+// If-Match header or as version parameter. a bookmark changed in the meantime is not deleted
 //
 // ---
 // produces:
@@ -688,6 +925,10 @@ func updateChildCountOfPath(path, username string, repo store.Repository) error
 // parameters:
 // - name: id
 //   in: path
+// - name: version
+//   in: query
+// - name: If-Match
+//   in: header
 // responses:
 //   '200':
 //     description: Result
@@ -705,6 +946,14 @@ func updateChildCountOfPath(path, username string, repo store.Repository) error
 //     description: ProblemDetail
 //     schema:
 //       "$ref": "#/definitions/ProblemDetail"
+//   '412':
+//     description: VersionConflict
+//     schema:
+//       "$ref": "#/definitions/VersionConflict"
+//   '428':
+//     description: VersionConflict
+//     schema:
+//       "$ref": "#/definitions/VersionConflict"
 func (b *BookmarksAPI) Delete(user security.User, w http.ResponseWriter, r *http.Request) error {
 	id := chi.URLParam(r, "id")
 
@@ -712,33 +961,58 @@ func (b *BookmarksAPI) Delete(user security.User, w http.ResponseWriter, r *http
 		return errors.BadRequestError{Err: fmt.Errorf("missing id parameter"), Request: r}
 	}
 
+	var version int
+	if v := r.URL.Query().Get("version"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return errors.BadRequestError{Err: fmt.Errorf("invalid version '%s'", v), Request: r}
+		}
+		version = n
+	}
+	expected, err := readPrecondition(r, version)
+	if err != nil {
+		return errors.BadRequestError{Err: err, Request: r}
+	}
+
 	handler.LogFunction("api.Delete").Debugf("will try to delete bookmark with ID '%s'", id)
 
-	if err := b.Repository.InUnitOfWork(func(repo store.Repository) error {
+	var oldFavicon string
+	if err := b.inUnitOfWork(func(repo store.Repository) error {
 		// 1) fetch the existing bookmark by id
 		existing, err := repo.GetBookmarkById(id, user.Username)
 		if err != nil {
 			handler.LogFunction("api.Update").Warnf("could not find bookmark by id '%s': %v", id, err)
 			return err
 		}
+		if err := expected.check(existing.Version); err != nil {
+			return err
+		}
 
 		// if the element is a folder and there are child-elements
 		// prevent the deletion - this can only be done via a recursive deletion like rm -rf
 		if existing.Type == store.Folder && existing.ChildCount > 0 {
 			return fmt.Errorf("cannot delete folder '%s' because of existing child-elements %d",
-				ensureFolderPath(existing.Path, existing.DisplayName), existing.ChildCount)
+				store.FolderPath(existing.Path, existing.DisplayName), existing.ChildCount)
 		}
 
 		err = repo.Delete(existing)
 		if err != nil {
 			return err
 		}
+		if err := store.LogChange(repo, requestID(r), store.ChangeDelete, &existing, nil); err != nil {
+			return err
+		}
+		oldFavicon = existing.Favicon
 
 		return nil
 	}); err != nil {
 		handler.LogFunction("api.Delete").Errorf("could not delete bookmark because of error: %v", err)
+		if isPreconditionError(err) {
+			return b.renderPreconditionError(err, id, user.Username, w, r)
+		}
 		return errors.ServerError{Err: fmt.Errorf("error deleting bookmark: %v", err), Request: r}
 	}
+	b.releaseFavicon(oldFavicon)
 
 	return render.Render(w, r, ResultResponse{
 		Result: &Result{
@@ -790,7 +1064,7 @@ func (b *BookmarksAPI) UpdateSortOrder(user security.User, w http.ResponseWriter
 	}
 
 	var updates int
-	if err := b.Repository.InUnitOfWork(func(repo store.Repository) error {
+	if err := b.inUnitOfWork(func(repo store.Repository) error {
 		for i, item := range payload.IDs {
 			bm, err := repo.GetBookmarkById(item, user.Username)
 			if err != nil {
@@ -799,12 +1073,16 @@ func (b *BookmarksAPI) UpdateSortOrder(user security.User, w http.ResponseWriter
 			}
 
 			handler.LogFunction("api.UpdateSortOrder").Debugf("will update sortOrder of bookmark '%s' with value %d", bm.DisplayName, payload.SortOrder[i])
+			before := bm
 			bm.SortOrder = payload.SortOrder[i]
-			_, err = repo.Update(bm)
+			updated, err := repo.Update(bm)
 			if err != nil {
 				handler.LogFunction("api.UpdateSortOrder").Errorf("could not update bookmark: %v", err)
 				return err
 			}
+			if err := store.LogChange(repo, requestID(r), store.ChangeUpdate, &before, &updated); err != nil {
+				return err
+			}
 			updates += 1
 		}
 		return nil
@@ -826,7 +1104,8 @@ func (b *BookmarksAPI) UpdateSortOrder(user security.User, w http.ResponseWriter
 //
 // forward to the bookmark
 //
-// fetch the URL of the specified bookmark and forward to the destination
+// fetch the URL of the specified bookmark and forward to the destination. read-later bookmarks are
+// marked as read, if the user enabled it in the settings
 //
 // ---
 // produces:
@@ -863,24 +1142,40 @@ func (b *BookmarksAPI) FetchAndForward(user security.User, w http.ResponseWriter
 	handler.LogFunction("api.FetchAndForward").Debugf("try to fetch bookmark with ID '%s'", id)
 
 	redirectURL := ""
-	if err := b.Repository.InUnitOfWork(func(repo store.Repository) error {
+	if err := b.inUnitOfWork(func(repo store.Repository) error {
 		existing, err := repo.GetBookmarkById(id, user.Username)
 		if err != nil {
 			handler.LogFunction("api.FetchAndForward").Warnf("could not find bookmark by id '%s': %v", id, err)
 			return err
 		}
 
-		if existing.Type == store.Folder {
+		if existing.Type != store.Node {
 			handler.LogFunction("api.FetchAndForward").Warnf("accessCount and redirect only valid for Nodes: ID '%s'", id)
 			return errors.BadRequestError{Err: fmt.Errorf("cannot fetch and forward folder - ID '%s'", id), Request: r}
 		}
 
-		// update the access-count of nodes
-		existing.AccessCount += 1
-		if _, err := repo.Update(existing); err != nil {
-			handler.LogFunction("api.FetchAndForward").Warnf("could not update bookmark '%s': %v", id, err)
+		// the visit updates the access-count of nodes
+		if err := repo.AddVisit(store.Visit{BookmarkID: existing.ID, UserName: user.Username}); err != nil {
+			handler.LogFunction("api.FetchAndForward").Warnf("could not record the visit of bookmark '%s': %v", id, err)
 			return err
 		}
+		if existing.ReadLater && existing.ReadAt == nil {
+			settings, err := repo.GetUserSettings(user.Username)
+			if err != nil {
+				handler.LogFunction("api.FetchAndForward").Warnf("could not get the settings of user '%s': %v", user.Username, err)
+				return err
+			}
+			if settings.MarkReadOnOpen {
+				read, err := repo.SetRead(existing.ID, user.Username, true)
+				if err != nil {
+					handler.LogFunction("api.FetchAndForward").Warnf("could not mark bookmark '%s' as read: %v", id, err)
+					return err
+				}
+				if err := store.LogChange(repo, requestID(r), store.ChangeUpdate, &existing, &read); err != nil {
+					return err
+				}
+			}
+		}
 
 		redirectURL = existing.URL
 
@@ -920,6 +1215,8 @@ func (b *BookmarksAPI) FetchAndForward(user security.User, w http.ResponseWriter
 // responses:
 //   '200':
 //     description: Favicon as a file
+//   '304':
+//     description: Not Modified, the ETag matches the favicon
 //   '400':
 //     description: ProblemDetail
 //     schema:
@@ -948,82 +1245,24 @@ func (b *BookmarksAPI) GetFavicon(user security.User, w http.ResponseWriter, r *
 	}
 
 	fullPath := path.Join(b.BasePath, b.FaviconPath, existing.Favicon)
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+	if info, err := os.Stat(fullPath); existing.Favicon == "" || os.IsNotExist(err) || (err == nil && info.IsDir()) {
 		handler.LogFunction("api.GetFavicon").Errorf("the specified favicon '%s' is not available", fullPath)
 		// not found - use default
 		fullPath = path.Join(b.BasePath, b.DefaultFavicon)
+	} else if favicon.ValidName(existing.Favicon) {
+		// the favicon of the bookmark might change, the client needs to revalidate
+		w.Header().Set("ETag", faviconETag(existing.Favicon))
+		w.Header().Set("Cache-Control", "private, no-cache")
 	}
 
-	http.ServeFile(w, r, fullPath)
+	serveFavicon(w, r, fullPath)
 	return nil
 }
 
-func (b *BookmarksAPI) fetchFavicon(bm store.Bookmark, user security.User) {
-	favicon, payload, err := favicon.GetFaviconFromURL(bm.URL)
-	if err != nil {
-		handler.LogFunction("api.fetchFavicon").Errorf("cannot fetch favicon from URL '%s': %v", bm.URL, err)
-		return
-	}
-
-	if len(payload) > 0 {
-
-		hashPayload, err := hashInput(payload)
-		if err != nil {
-			handler.LogFunction("api.fetchFavicon").Errorf("could not hash payload: '%v'", err)
-			return
-		}
-		hashFilename, err := hashInput([]byte(favicon))
-		if err != nil {
-			handler.LogFunction("api.fetchFavicon").Errorf("could not hash filename: '%v'", err)
-			return
-		}
-		ext := filepath.Ext(favicon)
-		filename := fmt.Sprintf("%s_%s%s", hashFilename, hashPayload, ext)
-		fullPath := path.Join(b.BasePath, b.FaviconPath, filename)
-
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-			handler.LogFunction("api.fetchFavicon").Warnf("got favicon payload length of '%d' for URL '%s'", len(payload), bm.URL)
-
-			if err := ioutil.WriteFile(fullPath, payload, 0644); err != nil {
-				handler.LogFunction("api.fetchFavicon").Errorf("could not write favicon to file '%s': %v", fullPath, err)
-				return
-			}
-		}
-
-		// also update the favicon for the bookmark
-		if err := b.Repository.InUnitOfWork(func(repo store.Repository) error {
-			bm.Favicon = filename
-			_, err := repo.Update(bm)
-			return err
-		}); err != nil {
-			handler.LogFunction("api.fetchFavicon").Errorf("could not update bookmark with favicon '%s': %v", filename, err)
-		}
-
-	} else {
-		handler.LogFunction("api.fetchFavicon").Warnf("not payload for favicon from URL '%s'", bm.URL)
-	}
-}
-
-func hashInput(input []byte) (string, error) {
-	hash := sha1.New()
-	if _, err := hash.Write(input); err != nil {
-		return "", fmt.Errorf("could not hash input: %v", err)
-	}
-	bs := hash.Sum(nil)
-	return fmt.Sprintf("%x", bs), nil
-}
-
-// EnsureFolderPath takes care that the supplied path is valid
-// e.g. it does not start with two slashes '//' and that the resulting
-// path is valid, with all necessary delimitors
-func ensureFolderPath(path, displayName string) string {
-	folderPath := path
-	if !strings.HasSuffix(path, "/") {
-		folderPath = path + "/"
-	}
-	if strings.HasPrefix(folderPath, "//") {
-		folderPath = strings.ReplaceAll(folderPath, "//", "/")
+// fetcher returns the configured client for outbound requests or a client with the default restrictions
+func (b *BookmarksAPI) fetcher() (fetch.Fetcher, error) {
+	if b.Fetcher != nil {
+		return b.Fetcher, nil
 	}
-	folderPath += displayName
-	return folderPath
+	return fetch.New(fetch.Options{})
 }
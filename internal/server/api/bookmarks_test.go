@@ -136,9 +136,9 @@ func TestGetBookmarkById(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 }
 
-func (r *MockRepository) GetBookmarksByPath(path, username string) ([]store.Bookmark, error) {
+func (r *MockRepository) GetBookmarksByPath(path, username string, opts store.ListOptions) (store.BookmarkPage, error) {
 	if r.fail {
-		return make([]store.Bookmark, 0), raisedError
+		return store.BookmarkPage{}, raisedError
 	}
 
 	bm := store.Bookmark{
@@ -152,8 +152,7 @@ func (r *MockRepository) GetBookmarksByPath(path, username string) ([]store.Book
 		URL:         "http://url",
 		UserName:    username,
 	}
-	var bms []store.Bookmark
-	return append(bms, bm), nil
+	return store.BookmarkPage{Bookmarks: []store.Bookmark{bm}, Total: 1}, nil
 }
 
 func TestGetBookmarkByPath(t *testing.T) {
@@ -301,9 +300,9 @@ func TestGetBookmarkFolderBypath(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 }
 
-func (r *MockRepository) GetBookmarksByName(name, username string) ([]store.Bookmark, error) {
+func (r *MockRepository) GetBookmarksByName(name, username string, opts store.ListOptions) (store.BookmarkPage, error) {
 	if r.fail {
-		return make([]store.Bookmark, 0), raisedError
+		return store.BookmarkPage{}, raisedError
 	}
 
 	bm := store.Bookmark{
@@ -317,8 +316,7 @@ func (r *MockRepository) GetBookmarksByName(name, username string) ([]store.Book
 		URL:         "http://url",
 		UserName:    username,
 	}
-	var bms []store.Bookmark
-	return append(bms, bm), nil
+	return store.BookmarkPage{Bookmarks: []store.Bookmark{bm}, Total: 1}, nil
 }
 
 func TestGetBookmarkByName(t *testing.T) {
@@ -380,9 +378,9 @@ func TestGetBookmarkByName(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 }
 
-func (r *MockRepository) GetMostRecentBookmarks(username string, limit int) ([]store.Bookmark, error) {
+func (r *MockRepository) GetMostRecentBookmarks(username string, opts store.ListOptions) (store.BookmarkPage, error) {
 	if r.fail {
-		return make([]store.Bookmark, 0), raisedError
+		return store.BookmarkPage{}, raisedError
 	}
 
 	bm := store.Bookmark{
@@ -396,8 +394,7 @@ func (r *MockRepository) GetMostRecentBookmarks(username string, limit int) ([]s
 		URL:         "http://url",
 		UserName:    username,
 	}
-	var bms []store.Bookmark
-	return append(bms, bm), nil
+	return store.BookmarkPage{Bookmarks: []store.Bookmark{bm}, Total: 1}, nil
 }
 
 func TestGetMostVisited(t *testing.T) {
@@ -472,7 +469,7 @@ func repository(t *testing.T) (store.Repository, *gorm.DB) {
 		t.Fatalf("cannot create database connection: %v", err)
 	}
 	// Migrate the schema
-	DB.AutoMigrate(&store.Bookmark{})
+	store.Migrate(DB)
 
 	DB.LogMode(true)
 	return store.Create(DB), DB
@@ -700,7 +697,8 @@ func TestUpdateBookmark(t *testing.T) {
 		"displayName": "Node_updated",
 		"path": "/",
 		"type": "Node",
-		"url": "http://url"
+		"url": "http://url",
+		"version": 1
 	}`
 	rec = httptest.NewRecorder()
 	req, _ = http.NewRequest("PUT", url, strings.NewReader(fmt.Sprintf(payload, id)))
@@ -936,6 +934,7 @@ func TestDeleteBookmark(t *testing.T) {
 	// ---------------------------------------------------------------
 	rec = httptest.NewRecorder()
 	req, _ = http.NewRequest("DELETE", url+"/"+id, nil)
+	req.Header.Add("If-Match", `"1"`)
 	r.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusOK, rec.Code)
@@ -1006,7 +1005,7 @@ func TestDeleteBookmarkHierarchy(t *testing.T) {
 	// delete the created item
 	// ---------------------------------------------------------------
 	rec = httptest.NewRecorder()
-	req, _ = http.NewRequest("DELETE", url+"/"+id, nil)
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("%s/%s?version=%d", url, id, bookmarkFolder.Value.Version), nil)
 	r.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
@@ -1551,24 +1550,36 @@ func (m *mockRepository) DeletePath(path, username string) error {
 	return nil
 }
 
+func (m *mockRepository) UpdateChildCountOfPath(path, username string) error {
+	return nil
+}
+
+func (m *mockRepository) AddAccessCount(id, username string, count int) error {
+	return nil
+}
+
 func (m *mockRepository) GetAllBookmarks(username string) ([]store.Bookmark, error) {
 	return nil, nil
 }
 
-func (m *mockRepository) GetBookmarksByPath(path, username string) ([]store.Bookmark, error) {
-	return nil, nil
+func (m *mockRepository) GetBookmarksByPath(path, username string, opts store.ListOptions) (store.BookmarkPage, error) {
+	return store.BookmarkPage{}, nil
 }
 
 func (m *mockRepository) GetBookmarksByPathStart(path, username string) ([]store.Bookmark, error) {
 	return nil, nil
 }
 
-func (m *mockRepository) GetBookmarksByName(name, username string) ([]store.Bookmark, error) {
+func (m *mockRepository) GetBookmarkTree(path, username string) ([]store.Bookmark, error) {
 	return nil, nil
 }
 
-func (m *mockRepository) GetMostRecentBookmarks(username string, limit int) ([]store.Bookmark, error) {
-	return nil, nil
+func (m *mockRepository) GetBookmarksByName(name, username string, opts store.ListOptions) (store.BookmarkPage, error) {
+	return store.BookmarkPage{}, nil
+}
+
+func (m *mockRepository) GetMostRecentBookmarks(username string, opts store.ListOptions) (store.BookmarkPage, error) {
+	return store.BookmarkPage{}, nil
 }
 
 func (m *mockRepository) GetPathChildCount(path, username string) ([]store.NodeCount, error) {
@@ -1579,10 +1590,246 @@ func (m *mockRepository) GetBookmarkById(id, username string) (store.Bookmark, e
 	return store.Bookmark{}, nil
 }
 
+func (m *mockRepository) GetBookmarksByIds(ids []string, username string) ([]store.Bookmark, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) IDExists(id string) (bool, error) {
+	return false, nil
+}
+
 func (m *mockRepository) GetFolderByPath(path, username string) (store.Bookmark, error) {
 	return store.Bookmark{}, nil
 }
 
+func (m *mockRepository) GetSmartFolderByPath(path, username string) (store.Bookmark, error) {
+	return store.Bookmark{}, fmt.Errorf("no smart folder")
+}
+
+func (m *mockRepository) FindBookmarks(filter store.BookmarkFilter, username string, limit int) ([]store.Bookmark, error) {
+	return nil, nil
+}
+
 func (m *mockRepository) GetAllPaths(username string) ([]string, error) {
 	return nil, nil
 }
+
+func (m *mockRepository) GetFaviconReferences(favicon string) (int, error) {
+	return 0, nil
+}
+
+func (m *mockRepository) GetAllFavicons() ([]string, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) SaveLinkCheck(check store.LinkCheck, username string) (store.LinkHealth, error) {
+	return store.LinkHealth{}, nil
+}
+
+func (m *mockRepository) GetLinkHealth(username string, status store.LinkStatus) ([]store.LinkHealth, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) GetLinkChecks(bookmarkID string, limit int) ([]store.LinkCheck, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) GetBookmarksToCheck(checkedBefore time.Time, limit int) ([]store.Bookmark, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) DeleteLinkChecksBefore(t time.Time) error {
+	return nil
+}
+
+func (m *mockRepository) SaveURLSuggestion(suggestion store.URLSuggestion) error {
+	return nil
+}
+
+func (m *mockRepository) DeleteURLSuggestion(bookmarkID string) error {
+	return nil
+}
+
+func (m *mockRepository) GetURLSuggestions(username string) ([]store.URLSuggestion, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) UpdateURL(id, username, url string, reason store.URLChangeReason) (store.Bookmark, error) {
+	return store.Bookmark{}, nil
+}
+
+func (m *mockRepository) AddURLHistory(entry store.URLHistory) error {
+	return nil
+}
+
+func (m *mockRepository) GetURLHistory(bookmarkID string) ([]store.URLHistory, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) GetUserSettings(username string) (store.UserSettings, error) {
+	return store.UserSettings{}, nil
+}
+
+func (m *mockRepository) SaveUserSettings(settings store.UserSettings) (store.UserSettings, error) {
+	return store.UserSettings{}, nil
+}
+
+func (m *mockRepository) CreateArchive(archive store.Archive) (store.Archive, error) {
+	return store.Archive{}, nil
+}
+
+func (m *mockRepository) GetArchives(bookmarkID string) ([]store.Archive, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) GetArchive(bookmarkID string, version int) (store.Archive, error) {
+	return store.Archive{}, nil
+}
+
+func (m *mockRepository) DeleteArchive(id uint) error {
+	return nil
+}
+
+func (m *mockRepository) GetArchiveReferences(fileName string) (int, error) {
+	return 0, nil
+}
+
+func (m *mockRepository) GetAllArchiveFiles() ([]string, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) SaveContent(content store.PageContent, terms map[string]int) error {
+	return nil
+}
+
+func (m *mockRepository) DeleteContent(bookmarkID string) error {
+	return nil
+}
+
+func (m *mockRepository) GetPageContents(bookmarkIDs []string) ([]store.PageContent, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) SearchContent(terms []string, username string, limit int) ([]store.ContentMatch, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) GetBookmarksToIndex(limit int) ([]store.Bookmark, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) AddVisit(visit store.Visit) error {
+	return nil
+}
+
+func (m *mockRepository) GetVisits(bookmarkID string, limit int) ([]store.Visit, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) MoveVisits(fromID, toID, username string) error {
+	return nil
+}
+
+func (m *mockRepository) GetRecentlyVisited(username string, limit int) ([]store.Bookmark, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) GetUserVisits(username string, since time.Time) ([]store.Visit, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) DeleteVisitsBefore(t time.Time) error {
+	return nil
+}
+
+func (m *mockRepository) GetStatistics(username string, opts store.StatisticsOptions) (store.Statistics, error) {
+	return store.Statistics{}, nil
+}
+
+func (m *mockRepository) GetReadLater(username string, unreadOnly bool) ([]store.Bookmark, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) SetRead(id, username string, read bool) (store.Bookmark, error) {
+	return store.Bookmark{}, nil
+}
+
+func (m *mockRepository) GetReadLaterExpirySettings() ([]store.UserSettings, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) ExpireReadLater(username, path string, readBefore time.Time) ([]store.ExpiredBookmark, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) AddChange(entry store.ChangeLog) error {
+	return nil
+}
+
+func (m *mockRepository) GetChanges(entityID, username string, limit int) ([]store.ChangeLog, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) GetUserChanges(username string, limit int) ([]store.ChangeLog, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) GetChangesSince(username string, since time.Time) ([]store.ChangeLog, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) GetRequestChanges(username, requestID string) ([]store.ChangeLog, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) Restore(item store.Bookmark) (store.Bookmark, error) {
+	return store.Bookmark{}, nil
+}
+
+func (m *mockRepository) GetSequence(username string) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockRepository) GetChangedBookmarks(username string, since int64) ([]store.Bookmark, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) GetTombstones(username string, since int64) ([]store.Tombstone, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) GetWebhooks(username string) ([]store.Webhook, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) GetWebhookByID(id, username string) (store.Webhook, error) {
+	return store.Webhook{}, nil
+}
+
+func (m *mockRepository) SaveWebhook(webhook store.Webhook) (store.Webhook, error) {
+	return store.Webhook{}, nil
+}
+
+func (m *mockRepository) DeleteWebhook(id, username string) error {
+	return nil
+}
+
+func (m *mockRepository) AddDelivery(delivery store.WebhookDelivery) (store.WebhookDelivery, error) {
+	return store.WebhookDelivery{}, nil
+}
+
+func (m *mockRepository) UpdateDelivery(delivery store.WebhookDelivery) error {
+	return nil
+}
+
+func (m *mockRepository) GetDueDeliveries(now time.Time, limit int) ([]store.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) GetDeliveries(webhookID string, limit int) ([]store.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) DeleteDeliveriesBefore(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+// the number of changes returned if no limit is given
+const defaultChangeHistory = 50
+
+// requestID returns the ID assigned to the request by the middleware
+func requestID(r *http.Request) string {
+	return middleware.GetReqID(r.Context())
+}
+
+// swagger:operation GET /api/v1/bookmarks/{id}/changes bookmarks GetBookmarkChanges
+//
+// get the change history of a bookmark
+//
+// return the logged changes of the bookmark, the most recent change first. the history is also available
+// for deleted bookmarks
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+// - name: limit
+//   in: query
+// responses:
+//   '200':
+//     description: ChangeList
+//     schema:
+//       "$ref": "#/definitions/ChangeList"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetBookmarkChanges(user security.User, w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+
+	if id == "" {
+		return errors.BadRequestError{Err: fmt.Errorf("missing id parameter"), Request: r}
+	}
+	limit, err := changeLimit(r)
+	if err != nil {
+		return err
+	}
+
+	handler.LogFunction("api.GetBookmarkChanges").Debugf("get the changes of bookmark '%s'", id)
+
+	entries, err := b.Repository.GetChanges(id, user.Username, limit)
+	if err != nil {
+		handler.LogFunction("api.GetBookmarkChanges").Errorf("cannot get the changes of bookmark '%s': %v", id, err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the changes: %v", err), Request: r}
+	}
+	return renderChanges(entries, w, r)
+}
+
+// swagger:operation GET /api/v1/bookmarks/changes bookmarks GetUserChanges
+//
+// get the change history of the user
+//
+// return the logged changes of all bookmarks of the user, the most recent change first
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: limit
+//   in: query
+// responses:
+//   '200':
+//     description: ChangeList
+//     schema:
+//       "$ref": "#/definitions/ChangeList"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetUserChanges(user security.User, w http.ResponseWriter, r *http.Request) error {
+	limit, err := changeLimit(r)
+	if err != nil {
+		return err
+	}
+
+	handler.LogFunction("api.GetUserChanges").Debugf("get the changes of user '%s'", user.Username)
+
+	entries, err := b.Repository.GetUserChanges(user.Username, limit)
+	if err != nil {
+		handler.LogFunction("api.GetUserChanges").Errorf("cannot get the changes of user '%s': %v", user.Username, err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the changes: %v", err), Request: r}
+	}
+	return renderChanges(entries, w, r)
+}
+
+func changeLimit(r *http.Request) (int, error) {
+	l := r.URL.Query().Get("limit")
+	if l == "" {
+		return defaultChangeHistory, nil
+	}
+	n, err := strconv.Atoi(l)
+	if err != nil || n <= 0 {
+		return 0, errors.BadRequestError{Err: fmt.Errorf("invalid limit '%s'", l), Request: r}
+	}
+	return n, nil
+}
+
+func renderChanges(entries []store.ChangeLog, w http.ResponseWriter, r *http.Request) error {
+	changes := make([]Change, 0, len(entries))
+	for _, e := range entries {
+		c, err := changeToModel(e)
+		if err != nil {
+			handler.LogFunction("api.renderChanges").Errorf("cannot read change '%d': %v", e.ID, err)
+			return errors.ServerError{Err: fmt.Errorf("could not read the changes: %v", err), Request: r}
+		}
+		changes = append(changes, c)
+	}
+
+	return render.Render(w, r, ChangeListResponse{
+		ChangeList: &ChangeList{
+			Success: true,
+			Count:   len(changes),
+			Message: fmt.Sprintf("Found %d changes", len(changes)),
+			Value:   changes,
+		},
+	})
+}
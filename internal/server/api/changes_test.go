@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeLog(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, _, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+
+	r := newChangeRouter(bookmarkAPI)
+
+	folder, _ := repo.Create(store.Bookmark{DisplayName: "Work", Path: "/", Type: store.Folder, UserName: userName, ChildCount: 1})
+	child, _ := repo.Create(store.Bookmark{DisplayName: "Go", Path: "/Work", Type: store.Node, URL: "http://go", UserName: userName, Favicon: "favicon.ico"})
+
+	send := func(method, url, payload string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(payload))
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("If-Match", "*")
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	changes := func(url string) []Change {
+		rec := send("GET", url, "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var list ChangeList
+		if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+			t.Errorf("could not unmarshal body: %v", err)
+		}
+		return list.Value
+	}
+
+	rec := send("POST", "/", `{"path": "/", "displayName": "Other", "type": "Node", "url": "http://other", "favicon": "favicon.ico"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var result Result
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	created := changes("/" + result.Value + "/changes")
+	assert.Equal(t, 1, len(created))
+	assert.Equal(t, "create", created[0].Operation)
+	assert.Nil(t, created[0].Before)
+	assert.Equal(t, "Other", created[0].After.DisplayName)
+	assert.NotEqual(t, "", created[0].RequestID)
+
+	// renaming the folder changes the path of the child-elements
+	assert.Equal(t, http.StatusOK, send("PUT", "/", `{"id": "`+folder.ID+`", "path": "/", "displayName": "Job"}`).Code)
+	folderChanges := changes("/" + folder.ID + "/changes")
+	assert.Equal(t, 1, len(folderChanges))
+	assert.Equal(t, "Work", folderChanges[0].Before.DisplayName)
+	assert.Equal(t, "Job", folderChanges[0].After.DisplayName)
+	childChanges := changes("/" + child.ID + "/changes")
+	assert.Equal(t, 1, len(childChanges))
+	assert.Equal(t, "/Work", childChanges[0].Before.Path)
+	assert.Equal(t, "/Job", childChanges[0].After.Path)
+	assert.Equal(t, folderChanges[0].RequestID, childChanges[0].RequestID)
+
+	assert.Equal(t, http.StatusOK, send("DELETE", "/"+result.Value, "").Code)
+	deleted := changes("/" + result.Value + "/changes")
+	assert.Equal(t, 2, len(deleted))
+	assert.Equal(t, "delete", deleted[0].Operation)
+	assert.Nil(t, deleted[0].After)
+
+	all := changes("/changes?limit=2")
+	assert.Equal(t, 2, len(all))
+	assert.Equal(t, "delete", all[0].Operation)
+	assert.Equal(t, 4, len(changes("/changes")))
+
+	assert.Equal(t, http.StatusBadRequest, send("GET", "/changes?limit=x", "").Code)
+}
+
+func TestChangeLogFailedChange(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, _, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+
+	r := newChangeRouter(bookmarkAPI)
+
+	// the folder with child-elements cannot be deleted, the change is not logged
+	folder, _ := repo.Create(store.Bookmark{DisplayName: "Work", Path: "/", Type: store.Folder, UserName: userName, ChildCount: 1})
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/"+folder.ID, nil)
+	req.Header.Add("If-Match", "*")
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	changes, err := repo.GetUserChanges(userName, 10)
+	if err != nil {
+		t.Fatalf("cannot get the changes: %v", err)
+	}
+	assert.Equal(t, 0, len(changes))
+}
+
+// newChangeRouter assigns request IDs, the IDs are logged with the changes
+func newChangeRouter(bookmarkAPI *BookmarksAPI) chi.Router {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(jwtUser)
+	r.Post("/", bookmarkAPI.Secure(bookmarkAPI.Create))
+	r.Put("/", bookmarkAPI.Secure(bookmarkAPI.Update))
+	r.Delete("/{id}", bookmarkAPI.Secure(bookmarkAPI.Delete))
+	r.Get("/changes", bookmarkAPI.Secure(bookmarkAPI.GetUserChanges))
+	r.Get("/{id}/changes", bookmarkAPI.Secure(bookmarkAPI.GetBookmarkChanges))
+	r.Post("/revert", bookmarkAPI.Secure(bookmarkAPI.RevertChanges))
+	return r
+}
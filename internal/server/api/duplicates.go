@@ -0,0 +1,250 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	er "errors"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/bihe/bookmarks/internal/urlnorm"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+// swagger:operation GET /api/v1/bookmarks/duplicates bookmarks GetDuplicates
+//
+// get duplicate bookmarks
+//
+// returns the bookmarks of the user grouped by their normalized URL, only URLs which are bookmarked
+// more than once are returned. the normalization ignores the case of the host, default ports, trailing
+// slashes, fragments, tracking parameters and the order of the query.
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: DuplicateList
+//     schema:
+//       "$ref": "#/definitions/DuplicateList"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetDuplicates(user security.User, w http.ResponseWriter, r *http.Request) error {
+	bookmarks, err := b.Repository.GetAllBookmarks(user.Username)
+	if err != nil {
+		handler.LogFunction("api.GetDuplicates").Errorf("cannot get bookmarks of user '%s': %v", user.Username, err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the bookmarks"), Request: r}
+	}
+
+	groups := duplicateGroups(bookmarks)
+	return render.Render(w, r, DuplicateListResponse{
+		DuplicateList: &DuplicateList{
+			Success: true,
+			Count:   len(groups),
+			Message: fmt.Sprintf("Found %d duplicate URLs", len(groups)),
+			Value:   groups,
+		},
+	})
+}
+
+// swagger:operation POST /api/v1/bookmarks/duplicates/merge bookmarks MergeDuplicates
+//
+// merge duplicate bookmarks
+//
+// keeps one bookmark and removes the duplicates with the same normalized URL. the access counts are
+// summed up, a missing favicon and missing metadata are taken from the duplicates. the child-count of
+// the affected folders is updated.
+//
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: Result
+//     schema:
+//       "$ref": "#/definitions/Result"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) MergeDuplicates(user security.User, w http.ResponseWriter, r *http.Request) error {
+	payload := &MergeDuplicatesRequest{}
+	if err := render.Bind(r, payload); err != nil {
+		handler.LogFunction("api.MergeDuplicates").Warnf("cannot bind payload: '%v'", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid request data supplied"), Request: r}
+	}
+	if payload.Keep == "" || len(payload.IDs) == 0 {
+		return errors.BadRequestError{Err: fmt.Errorf("the bookmark to keep and the duplicates are required"), Request: r}
+	}
+
+	handler.LogFunction("api.MergeDuplicates").Debugf("merge %d duplicates into bookmark '%s'", len(payload.IDs), payload.Keep)
+
+	var (
+		merged   int
+		released []string
+	)
+	if err := b.inUnitOfWork(func(repo store.Repository) error {
+		keep, err := repo.GetBookmarkById(payload.Keep, user.Username)
+		if err != nil {
+			return errors.NotFoundError{Err: fmt.Errorf("could not find bookmark with ID '%s'", payload.Keep), Request: r}
+		}
+		normalized, err := urlnorm.Normalize(keep.URL)
+		if keep.Type != store.Node || err != nil {
+			return errors.BadRequestError{Err: fmt.Errorf("the bookmark '%s' has no valid URL", keep.ID), Request: r}
+		}
+
+		original := keep
+		paths := map[string]bool{keep.Path: true}
+		seen := map[string]bool{keep.ID: true}
+		var accesses int
+		for _, id := range payload.IDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			dup, err := repo.GetBookmarkById(id, user.Username)
+			if err != nil {
+				return errors.NotFoundError{Err: fmt.Errorf("could not find bookmark with ID '%s'", id), Request: r}
+			}
+			if n, err := urlnorm.Normalize(dup.URL); dup.Type != store.Node || err != nil || n != normalized {
+				return errors.BadRequestError{Err: fmt.Errorf("the bookmark '%s' is not a duplicate of '%s'", id, keep.ID), Request: r}
+			}
+
+			accesses += dup.AccessCount
+			if keep.Favicon == "" {
+				keep.Favicon = dup.Favicon
+			} else if dup.Favicon != keep.Favicon {
+				released = append(released, dup.Favicon)
+			}
+			keep.Metadata = mergeMetadata(keep.Metadata, dup.Metadata)
+
+			// the visits would be removed with the duplicate
+			if err := repo.MoveVisits(dup.ID, keep.ID, user.Username); err != nil {
+				return err
+			}
+			if err := repo.Delete(dup); err != nil {
+				return err
+			}
+			if err := store.LogChange(repo, requestID(r), store.ChangeDelete, &dup, nil); err != nil {
+				return err
+			}
+			paths[dup.Path] = true
+			merged++
+		}
+
+		// the accesses of the duplicates are added to the counter, the update reads the new value
+		if err := repo.AddAccessCount(keep.ID, user.Username, accesses); err != nil {
+			return err
+		}
+		updated, err := repo.Update(keep)
+		if err != nil {
+			return err
+		}
+		if err := store.LogChange(repo, requestID(r), store.ChangeUpdate, &original, &updated); err != nil {
+			return err
+		}
+		// the duplicates might have been located in other folders
+		for path := range paths {
+			if err := repo.UpdateChildCountOfPath(path, user.Username); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		handler.LogFunction("api.MergeDuplicates").Errorf("could not merge the duplicates: %v", err)
+		var (
+			badRequest errors.BadRequestError
+			notFound   errors.NotFoundError
+		)
+		if er.As(err, &badRequest) {
+			return badRequest
+		}
+		if er.As(err, &notFound) {
+			return notFound
+		}
+		return errors.ServerError{Err: fmt.Errorf("error merging the duplicates: %v", err), Request: r}
+	}
+
+	for _, name := range released {
+		b.releaseFavicon(name)
+	}
+	return render.Render(w, r, ResultResponse{
+		Result: &Result{
+			Success: true,
+			Message: fmt.Sprintf("Merged %d duplicates into bookmark '%s'", merged, payload.Keep),
+			Value:   payload.Keep,
+		},
+	})
+}
+
+// findDuplicates returns the IDs of the other bookmarks of the user with the same normalized URL
+func (b *BookmarksAPI) findDuplicates(bm store.Bookmark, username string) []string {
+	normalized, err := urlnorm.Normalize(bm.URL)
+	if err != nil {
+		return nil
+	}
+	bookmarks, err := b.Repository.GetAllBookmarks(username)
+	if err != nil {
+		handler.LogFunction("api.findDuplicates").Warnf("cannot get bookmarks of user '%s': %v", username, err)
+		return nil
+	}
+	var ids []string
+	for _, other := range bookmarks {
+		if other.ID == bm.ID || other.Type != store.Node {
+			continue
+		}
+		if n, err := urlnorm.Normalize(other.URL); err == nil && n == normalized {
+			ids = append(ids, other.ID)
+		}
+	}
+	return ids
+}
+
+// duplicateGroups groups the nodes by their normalized URL, only URLs with more than one bookmark are returned
+func duplicateGroups(bookmarks []store.Bookmark) []DuplicateGroup {
+	byURL := make(map[string][]Bookmark)
+	for _, bm := range bookmarks {
+		if bm.Type != store.Node {
+			continue
+		}
+		normalized, err := urlnorm.Normalize(bm.URL)
+		if err != nil {
+			continue
+		}
+		byURL[normalized] = append(byURL[normalized], *entityToModel(bm))
+	}
+
+	groups := make([]DuplicateGroup, 0)
+	for url, bms := range byURL {
+		if len(bms) > 1 {
+			groups = append(groups, DuplicateGroup{URL: url, Bookmarks: bms})
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].URL < groups[j].URL })
+	return groups
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicates(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Get("/duplicates", bookmarkAPI.Secure(bookmarkAPI.GetDuplicates))
+	r.Post("/duplicates/merge", bookmarkAPI.Secure(bookmarkAPI.MergeDuplicates))
+
+	folder, err := repo.Create(store.Bookmark{DisplayName: "Folder", Path: "/", Type: store.Folder, UserName: userName})
+	if err != nil {
+		t.Fatalf("could not create folder: %v", err)
+	}
+	keep, _ := repo.Create(store.Bookmark{DisplayName: "Page", Path: "/", Type: store.Node, URL: "http://Example.com/page/?utm_source=mail", UserName: userName, AccessCount: 2})
+	dup, _ := repo.Create(store.Bookmark{DisplayName: "Copy", Path: "/Folder", Type: store.Node, URL: "http://example.com:80/page#top", UserName: userName, AccessCount: 3,
+		Favicon: "favicon.ico", Metadata: store.PageMetadata{Title: "The Page"}})
+	other, _ := repo.Create(store.Bookmark{DisplayName: "Other", Path: "/", Type: store.Node, URL: "http://example.com/other", UserName: userName})
+	repo.Create(store.Bookmark{DisplayName: "Foreign", Path: "/", Type: store.Node, URL: "http://example.com/page", UserName: "other"})
+
+	folder, _ = repo.GetBookmarkById(folder.ID, userName)
+	assert.Equal(t, 1, folder.ChildCount)
+	// the visit increments the access-count of the duplicate
+	repo.AddVisit(store.Visit{BookmarkID: dup.ID, UserName: userName})
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/duplicates", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var list DuplicateList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 1, list.Count)
+	assert.Equal(t, "http://example.com/page", list.Value[0].URL)
+	assert.Equal(t, 2, len(list.Value[0].Bookmarks))
+
+	for _, tc := range []struct {
+		payload string
+		status  int
+	}{
+		{`{}`, http.StatusBadRequest},
+		{`{"keep": "` + keep.ID + `", "ids": ["` + other.ID + `"]}`, http.StatusBadRequest},
+		{`{"keep": "` + keep.ID + `", "ids": ["` + folder.ID + `"]}`, http.StatusBadRequest},
+		{`{"keep": "` + keep.ID + `", "ids": ["unknown"]}`, http.StatusNotFound},
+		{`{"keep": "unknown", "ids": ["` + dup.ID + `"]}`, http.StatusNotFound},
+	} {
+		rec = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/duplicates/merge", strings.NewReader(tc.payload))
+		req.Header.Add("Content-Type", "application/json")
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, tc.status, rec.Code, tc.payload)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/duplicates/merge", strings.NewReader(`{"keep": "`+keep.ID+`", "ids": ["`+dup.ID+`", "`+keep.ID+`"]}`))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	merged, _ := repo.GetBookmarkById(keep.ID, userName)
+	assert.Equal(t, 6, merged.AccessCount)
+	assert.Equal(t, "favicon.ico", merged.Favicon)
+	assert.Equal(t, "The Page", merged.Metadata.Title)
+	assert.Equal(t, "http://Example.com/page/?utm_source=mail", merged.URL)
+	_, err = repo.GetBookmarkById(dup.ID, userName)
+	assert.Error(t, err)
+	visits, _ := repo.GetVisits(keep.ID, 10)
+	assert.Equal(t, 1, len(visits))
+	folder, _ = repo.GetBookmarkById(folder.ID, userName)
+	assert.Equal(t, 0, folder.ChildCount)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/duplicates", nil)
+	r.ServeHTTP(rec, req)
+	list = DuplicateList{}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	assert.Equal(t, 0, list.Count)
+}
+
+func TestCreateWarnsAboutDuplicates(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Post("/", bookmarkAPI.Secure(bookmarkAPI.Create))
+
+	existing, _ := repo.Create(store.Bookmark{DisplayName: "Page", Path: "/", Type: store.Node, URL: "https://example.com/page", UserName: userName})
+
+	payload := `{"path": "/", "displayName": "Again", "url": "https://EXAMPLE.com/page/#top", "type": "Node", "title": "Page", "favicon": "favicon.ico"}`
+	for _, tc := range []struct {
+		query      string
+		duplicates []string
+	}{
+		{"?checkDuplicates=true", []string{existing.ID}},
+		{"", nil},
+	} {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/"+tc.query, strings.NewReader(payload))
+		req.Header.Add("Content-Type", "application/json")
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var result Result
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Errorf("could not unmarshal body: %v", err)
+		}
+		assert.Equal(t, tc.duplicates, result.Duplicates)
+	}
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/events"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/google/uuid"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+const (
+	// comments keep the connection open, while no events are sent
+	eventHeartbeat = 15 * time.Second
+	// the stream is closed before the timeout of the request, the client reconnects with the last event ID
+	maxStreamDuration = 50 * time.Second
+	// the delay of the client before a reconnect
+	eventRetry = 3 * time.Second
+	// resetEvent tells the client to reload the bookmarks, because the missed events are no longer available
+	resetEvent = "reset"
+)
+
+// swagger:operation GET /api/v1/events events StreamEvents
+//
+// stream the changes of bookmarks
+//
+// the created, updated, moved and deleted bookmarks of the user and the bookmarks with a new favicon are sent
+// as server-sent events, the data of an event is a BookmarkEvent. a client resumes the stream with the header
+// Last-Event-ID or the parameter lastEventId. if the missed events are no longer available, a reset event is
+// sent and the client has to reload the bookmarks
+//
+// ---
+// produces:
+// - text/event-stream
+// parameters:
+// - name: Last-Event-ID
+//   in: header
+// - name: lastEventId
+//   in: query
+// responses:
+//   '200':
+//     description: BookmarkEvent
+//     schema:
+//       "$ref": "#/definitions/BookmarkEvent"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) StreamEvents(user security.User, w http.ResponseWriter, r *http.Request) error {
+	if b.Events == nil {
+		return errors.NotFoundError{Err: fmt.Errorf("the event stream is not available"), Request: r}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.ServerError{Err: fmt.Errorf("the response cannot be streamed"), Request: r}
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	var after uint64
+	if lastID != "" {
+		id, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			return errors.BadRequestError{Err: fmt.Errorf("invalid last event ID '%s'", lastID), Request: r}
+		}
+		after = id
+	}
+
+	sub, missed, complete := b.Events.Subscribe(user.Username, after)
+	defer sub.Close()
+	handler.LogFunction("api.StreamEvents").Debugf("stream the events of user '%s' after event %d", user.Username, after)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetry/time.Millisecond)
+	if !complete {
+		writeEvent(w, events.Event{ID: sub.Start, Type: resetEvent})
+	}
+	for _, e := range missed {
+		writeEvent(w, e)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	end := time.NewTimer(maxStreamDuration)
+	defer end.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-end.C:
+			return nil
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case e, ok := <-sub.Events():
+			if !ok {
+				// the client did not keep up with the events, it resumes with the last received event
+				handler.LogFunction("api.StreamEvents").Warnf("the event stream of user '%s' fell behind", user.Username)
+				return nil
+			}
+			writeEvent(w, e)
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent sends the event in the format of server-sent events
+func writeEvent(w http.ResponseWriter, e events.Event) {
+	data := []byte("{}")
+	if e.Data != nil {
+		payload, err := json.Marshal(e.Data)
+		if err != nil {
+			handler.LogFunction("api.writeEvent").Errorf("cannot marshal event %d: %v", e.ID, err)
+			return
+		}
+		data = payload
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
+
+// publishingRepository collects the changes logged in a unit of work, the events of the changes are published
+// after the unit of work is committed. every modification of a bookmark is logged with AddChange. the deliveries
+// of the webhooks are added to the outbox within the unit of work, they are only sent if the changes are committed
+type publishingRepository struct {
+	store.Repository
+	changes *[]BookmarkEvent
+	// the active webhooks of the users, they are retrieved once per unit of work
+	webhooks map[string][]store.Webhook
+}
+
+// AddChange logs the change, records its event and adds the deliveries of the event to the outbox
+func (p publishingRepository) AddChange(entry store.ChangeLog) error {
+	if err := p.Repository.AddChange(entry); err != nil {
+		return err
+	}
+	before, err := changeStateToModel(entry.Before)
+	if err != nil {
+		handler.LogFunction("api.AddChange").Errorf("no event for the change of '%s': %v", entry.EntityID, err)
+		return nil
+	}
+	after, err := changeStateToModel(entry.After)
+	if err != nil {
+		handler.LogFunction("api.AddChange").Errorf("no event for the change of '%s': %v", entry.EntityID, err)
+		return nil
+	}
+
+	e := BookmarkEvent{userName: entry.UserName}
+	switch {
+	case after == nil:
+		e.Type = string(events.Deleted)
+		e.Bookmark = before
+	case before == nil:
+		e.Type = string(events.Created)
+		e.Bookmark = after
+	default:
+		e.Type = string(changeEventType(*before, *after))
+		e.Bookmark = after
+		if before.Path != after.Path {
+			e.PreviousPath = before.Path
+		}
+	}
+	if e.Bookmark == nil {
+		return nil
+	}
+	e.ID = e.Bookmark.ID
+	e.Path = e.Bookmark.Path
+	*p.changes = append(*p.changes, e)
+	return p.enqueue(e)
+}
+
+// enqueue adds a delivery of the event for every webhook of the user, which subscribed to the type of the event
+func (p publishingRepository) enqueue(e BookmarkEvent) error {
+	webhooks, ok := p.webhooks[e.userName]
+	if !ok {
+		all, err := p.Repository.GetWebhooks(e.userName)
+		if err != nil {
+			return fmt.Errorf("cannot get the webhooks of user '%s': %v", e.userName, err)
+		}
+		for _, w := range all {
+			if w.Active {
+				webhooks = append(webhooks, w)
+			}
+		}
+		p.webhooks[e.userName] = webhooks
+	}
+
+	var payload []byte
+	for _, w := range webhooks {
+		if !w.Subscribes(e.Type) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			payload, err = json.Marshal(WebhookPayload{
+				ID:      uuid.New().String(),
+				Type:    e.Type,
+				Created: time.Now().UTC(),
+				Data:    e,
+			})
+			if err != nil {
+				return fmt.Errorf("cannot marshal the payload of the event of '%s': %v", e.ID, err)
+			}
+		}
+		if _, err := p.Repository.AddDelivery(store.WebhookDelivery{
+			WebhookID: w.ID,
+			UserName:  e.userName,
+			EventType: e.Type,
+			Payload:   string(payload),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changeEventType returns the type of event of the change, a change of the favicon is only reported as such, if
+// the name and the URL are unchanged
+func changeEventType(before, after Bookmark) events.Type {
+	switch {
+	case before.Path != after.Path:
+		return events.Moved
+	case before.Favicon != after.Favicon && before.DisplayName == after.DisplayName && before.URL == after.URL:
+		return events.FaviconUpdated
+	default:
+		return events.Updated
+	}
+}
+
+// inUnitOfWork executes the function in a transaction of the repository. the changes logged within the
+// transaction are published as events, once the transaction is committed
+func (b *BookmarksAPI) inUnitOfWork(fn func(repo store.Repository) error) error {
+	var changes []BookmarkEvent
+	if err := b.Repository.InUnitOfWork(func(repo store.Repository) error {
+		return fn(publishingRepository{
+			Repository: repo,
+			changes:    &changes,
+			webhooks:   make(map[string][]store.Webhook),
+		})
+	}); err != nil {
+		return err
+	}
+	if b.Events == nil {
+		return nil
+	}
+	for _, e := range changes {
+		b.Events.Publish(e.userName, events.Type(e.Type), e)
+	}
+	return nil
+}
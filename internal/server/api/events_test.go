@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/events"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+type streamEvent struct {
+	ID    string
+	Event string
+	Data  BookmarkEvent
+}
+
+// readEvents returns the next events of the stream, comments and the retry field are skipped
+func readEvents(t *testing.T, stream *bufio.Reader, n int) []streamEvent {
+	var result []streamEvent
+	var current streamEvent
+	for len(result) < n {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("could not read the event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			current.ID = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			current.Event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &current.Data); err != nil {
+				t.Fatalf("invalid data of event: %v", err)
+			}
+		case line == "" && current.Event != "":
+			result = append(result, current)
+			current = streamEvent{}
+		}
+	}
+	return result
+}
+
+func TestStreamEvents(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	db.DB().SetMaxOpenConns(1)
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	bookmarkAPI.Events = events.NewBus(10)
+	r.Post("/", bookmarkAPI.Secure(bookmarkAPI.Create))
+	r.Patch("/{id}", bookmarkAPI.Secure(bookmarkAPI.Patch))
+	r.Delete("/{id}", bookmarkAPI.Secure(bookmarkAPI.Delete))
+	r.Get("/events", bookmarkAPI.Secure(bookmarkAPI.StreamEvents))
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	repo.Create(store.Bookmark{DisplayName: "Archive", Path: "/", Type: store.Folder, UserName: userName})
+
+	open := func(lastID string) (*http.Response, *bufio.Reader) {
+		req, _ := http.NewRequest("GET", ts.URL+"/events", nil)
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("could not open the event stream: %v", err)
+		}
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		return resp, bufio.NewReader(resp.Body)
+	}
+	send := func(method, url, contentType, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("If-Match", "*")
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	resp, stream := open("")
+	defer resp.Body.Close()
+
+	rec := send("POST", "/", "application/json", `{"path": "/", "displayName": "Work", "type": "Folder"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var created Result
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	id := created.Value
+
+	// the events of other users are not sent
+	bookmarkAPI.Events.Publish("other", events.Created, BookmarkEvent{ID: "foreign"})
+
+	assert.NoError(t, bookmarkAPI.setFavicon(id, userName, "icon.png", ""))
+	assert.Equal(t, http.StatusOK, send("PATCH", "/"+id, mergePatchType, `{"path": "/Archive"}`).Code)
+	assert.Equal(t, http.StatusOK, send("PATCH", "/"+id, mergePatchType, `{"displayName": "Jobs"}`).Code)
+	assert.Equal(t, http.StatusOK, send("DELETE", "/"+id, "", "").Code)
+
+	received := readEvents(t, stream, 5)
+	assert.Equal(t, streamEvent{ID: "1", Event: "created", Data: received[0].Data}, received[0])
+	assert.Equal(t, "Work", received[0].Data.Bookmark.DisplayName)
+	assert.Equal(t, id, received[0].Data.ID)
+	assert.Equal(t, "/", received[0].Data.Path)
+
+	assert.Equal(t, "3", received[1].ID)
+	assert.Equal(t, "favicon-updated", received[1].Event)
+	assert.Equal(t, "icon.png", received[1].Data.Bookmark.Favicon)
+
+	assert.Equal(t, "moved", received[2].Event)
+	assert.Equal(t, "/Archive", received[2].Data.Path)
+	assert.Equal(t, "/", received[2].Data.PreviousPath)
+
+	assert.Equal(t, "updated", received[3].Event)
+	assert.Equal(t, "Jobs", received[3].Data.Bookmark.DisplayName)
+
+	assert.Equal(t, "6", received[4].ID)
+	assert.Equal(t, "deleted", received[4].Event)
+	assert.Equal(t, id, received[4].Data.ID)
+	resp.Body.Close()
+
+	// the stream is resumed after the last received event
+	resp, stream = open("4")
+	received = readEvents(t, stream, 2)
+	assert.Equal(t, []string{"5", "6"}, []string{received[0].ID, received[1].ID})
+	assert.Equal(t, []string{"updated", "deleted"}, []string{received[0].Event, received[1].Event})
+	resp.Body.Close()
+
+	// the events of the ID are no longer available
+	resp, stream = open("42")
+	received = readEvents(t, stream, 1)
+	assert.Equal(t, streamEvent{ID: "6", Event: "reset"}, received[0])
+	resp.Body.Close()
+
+	// a failed change is not published
+	assert.NotEqual(t, http.StatusOK, send("DELETE", "/"+id, "", "").Code)
+	bookmarkAPI.Events.Publish(userName, events.Updated, BookmarkEvent{ID: "last"})
+	resp, stream = open("6")
+	received = readEvents(t, stream, 1)
+	assert.Equal(t, "7", received[0].ID)
+	assert.Equal(t, "last", received[0].Data.ID)
+	resp.Body.Close()
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/events", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
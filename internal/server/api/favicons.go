@@ -0,0 +1,495 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/bihe/bookmarks/internal/favicon"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+// the form-field used for multipart uploads of favicons
+const faviconFormField = "file"
+
+// additional bytes allowed for the multipart envelope of an upload
+const multipartOverhead = 64 * 1024
+
+// the favicons are delivered by their content-hash below this URL
+const faviconsURL = "/favicons/"
+
+// the maximum number of bookmarks for the batch retrieval of favicon URLs
+const maxFaviconBatch = 1000
+
+// swagger:operation GET /favicons/{hash} favicons GetFaviconByHash
+//
+// get a favicon by its content-hash
+//
+// the favicons are addressed by the hash of their content, therefore the responses are cached by the client
+//
+// ---
+// produces:
+// - image/png
+// - image/svg+xml
+// parameters:
+// - name: hash
+//   in: path
+// responses:
+//   '200':
+//     description: Favicon as a file
+//   '304':
+//     description: Not Modified, the ETag matches the favicon
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetFaviconByHash(user security.User, w http.ResponseWriter, r *http.Request) error {
+	hash := chi.URLParam(r, "hash")
+
+	name, err := b.favicons().FileByHash(hash)
+	if err != nil {
+		handler.LogFunction("api.GetFaviconByHash").Warnf("invalid favicon requested: %v", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid favicon hash '%s'", hash), Request: r}
+	}
+	if name == "" {
+		handler.LogFunction("api.GetFaviconByHash").Warnf("the favicon '%s' is not available", hash)
+		return errors.NotFoundError{Err: fmt.Errorf("the favicon '%s' is not available", hash), Request: r}
+	}
+	fullPath, err := b.favicons().File(name)
+	if err != nil {
+		return errors.ServerError{Err: fmt.Errorf("could not get the favicon '%s': %v", hash, err), Request: r}
+	}
+
+	// the content of the URL never changes
+	w.Header().Set("ETag", faviconETag(name))
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	serveFavicon(w, r, fullPath)
+	return nil
+}
+
+// swagger:operation POST /api/v1/bookmarks/favicons bookmarks GetFaviconURLs
+//
+// get the favicon URLs of bookmarks
+//
+// returns the URLs of the favicons for the given list of bookmark IDs. bookmarks with a stored favicon
+// use the content-addressed URL, otherwise the URL provides the default favicon.
+//
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: BookmarkFaviconList
+//     schema:
+//       "$ref": "#/definitions/BookmarkFaviconList"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetFaviconURLs(user security.User, w http.ResponseWriter, r *http.Request) error {
+	payload := &BookmarkIDsRequest{}
+	if err := render.Bind(r, payload); err != nil {
+		handler.LogFunction("api.GetFaviconURLs").Warnf("cannot bind payload: '%v'", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid request data supplied"), Request: r}
+	}
+	if len(payload.IDs) > maxFaviconBatch {
+		return errors.BadRequestError{Err: fmt.Errorf("a maximum of %d IDs is supported", maxFaviconBatch), Request: r}
+	}
+
+	bookmarks, err := b.Repository.GetBookmarksByIds(payload.IDs, user.Username)
+	if err != nil {
+		handler.LogFunction("api.GetFaviconURLs").Errorf("cannot get bookmarks by IDs: %v", err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the bookmarks: %v", err), Request: r}
+	}
+
+	favicons := make([]BookmarkFavicon, 0, len(bookmarks))
+	for _, bm := range bookmarks {
+		url := "/api/v1/bookmarks/favicon/" + bm.ID
+		if favicon.ValidName(bm.Favicon) {
+			url = faviconsURL + favicon.Hash(bm.Favicon)
+		}
+		favicons = append(favicons, BookmarkFavicon{
+			ID:      bm.ID,
+			Favicon: bm.Favicon,
+			URL:     url,
+		})
+	}
+
+	return render.Render(w, r, BookmarkFaviconListResponse{
+		BookmarkFaviconList: &BookmarkFaviconList{
+			Success: true,
+			Count:   len(favicons),
+			Message: fmt.Sprintf("Found %d favicons", len(favicons)),
+			Value:   favicons,
+		},
+	})
+}
+
+// swagger:operation POST /api/v1/bookmarks/{id}/favicon bookmarks UploadFavicon
+//
+// upload a custom favicon
+//
+// the favicon is provided as multipart/form-data (field 'file') or as the raw request body.
+// the image is validated, normalized and replaces the favicon of the bookmark
+//
+// ---
+// consumes:
+// - multipart/form-data
+// - image/png
+// - image/x-icon
+// - image/svg+xml
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+// responses:
+//   '200':
+//     description: Result
+//     schema:
+//       "$ref": "#/definitions/Result"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) UploadFavicon(user security.User, w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+
+	if id == "" {
+		return errors.BadRequestError{Err: fmt.Errorf("missing id parameter"), Request: r}
+	}
+
+	if _, err := b.Repository.GetBookmarkById(id, user.Username); err != nil {
+		handler.LogFunction("api.UploadFavicon").Warnf("could not find bookmark by id '%s': %v", id, err)
+		return errors.NotFoundError{Err: fmt.Errorf("could not find bookmark with ID '%s'", id), Request: r}
+	}
+
+	payload, err := readFavicon(w, r)
+	if err != nil {
+		handler.LogFunction("api.UploadFavicon").Warnf("cannot read the uploaded favicon: %v", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid favicon supplied: %v", err), Request: r}
+	}
+
+	name, err := b.favicons().Save(payload)
+	if err != nil {
+		handler.LogFunction("api.UploadFavicon").Warnf("the uploaded favicon is not valid: %v", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid favicon supplied: %v", err), Request: r}
+	}
+
+	if err := b.setFavicon(id, user.Username, name, requestID(r)); err != nil {
+		handler.LogFunction("api.UploadFavicon").Errorf("could not update favicon of bookmark '%s': %v", id, err)
+		return errors.ServerError{Err: fmt.Errorf("error updating favicon of bookmark: %v", err), Request: r}
+	}
+
+	handler.LogFunction("api.UploadFavicon").Infof("uploaded favicon '%s' for bookmark '%s'", name, id)
+
+	return render.Render(w, r, ResultResponse{
+		Result: &Result{
+			Success: true,
+			Message: fmt.Sprintf("Favicon of bookmark with ID '%s' was updated", id),
+			Value:   name,
+		},
+	})
+}
+
+// swagger:operation DELETE /api/v1/bookmarks/{id}/favicon bookmarks DeleteFavicon
+//
+// remove the favicon
+//
+// the favicon of the bookmark is removed and automatically detected again
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+// responses:
+//   '200':
+//     description: Result
+//     schema:
+//       "$ref": "#/definitions/Result"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) DeleteFavicon(user security.User, w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+
+	if id == "" {
+		return errors.BadRequestError{Err: fmt.Errorf("missing id parameter"), Request: r}
+	}
+
+	existing, err := b.Repository.GetBookmarkById(id, user.Username)
+	if err != nil {
+		handler.LogFunction("api.DeleteFavicon").Warnf("could not find bookmark by id '%s': %v", id, err)
+		return errors.NotFoundError{Err: fmt.Errorf("could not find bookmark with ID '%s'", id), Request: r}
+	}
+
+	if err := b.setFavicon(id, user.Username, "", requestID(r)); err != nil {
+		handler.LogFunction("api.DeleteFavicon").Errorf("could not remove favicon of bookmark '%s': %v", id, err)
+		return errors.ServerError{Err: fmt.Errorf("error removing favicon of bookmark: %v", err), Request: r}
+	}
+
+	if existing.Type == store.Node {
+		// fire&forget, run this in background and do not wait for the result
+		existing.Favicon = ""
+		go b.fetchFavicon(existing, user)
+	}
+
+	return render.Render(w, r, ResultResponse{
+		Result: &Result{
+			Success: true,
+			Message: fmt.Sprintf("Favicon of bookmark with ID '%s' was removed", id),
+			Value:   id,
+		},
+	})
+}
+
+// swagger:operation POST /api/v1/bookmarks/{id}/favicon/refresh bookmarks RefreshFavicon
+//
+// fetch the favicon again
+//
+// the favicon is fetched from the URL of the bookmark and replaces the current favicon
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+// responses:
+//   '200':
+//     description: Result
+//     schema:
+//       "$ref": "#/definitions/Result"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) RefreshFavicon(user security.User, w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+
+	if id == "" {
+		return errors.BadRequestError{Err: fmt.Errorf("missing id parameter"), Request: r}
+	}
+
+	existing, err := b.Repository.GetBookmarkById(id, user.Username)
+	if err != nil {
+		handler.LogFunction("api.RefreshFavicon").Warnf("could not find bookmark by id '%s': %v", id, err)
+		return errors.NotFoundError{Err: fmt.Errorf("could not find bookmark with ID '%s'", id), Request: r}
+	}
+
+	if existing.Type == store.Folder {
+		return errors.BadRequestError{Err: fmt.Errorf("cannot fetch a favicon for folder - ID '%s'", id), Request: r}
+	}
+
+	name, err := b.downloadFavicon(existing.URL)
+	if err != nil {
+		handler.LogFunction("api.RefreshFavicon").Warnf("cannot fetch favicon from URL '%s': %v", existing.URL, err)
+		return errors.BadRequestError{Err: fmt.Errorf("could not fetch favicon from URL '%s'", existing.URL), Request: r}
+	}
+
+	if err := b.setFavicon(id, user.Username, name, requestID(r)); err != nil {
+		handler.LogFunction("api.RefreshFavicon").Errorf("could not update favicon of bookmark '%s': %v", id, err)
+		return errors.ServerError{Err: fmt.Errorf("error updating favicon of bookmark: %v", err), Request: r}
+	}
+
+	return render.Render(w, r, ResultResponse{
+		Result: &Result{
+			Success: true,
+			Message: fmt.Sprintf("Favicon of bookmark with ID '%s' was fetched", id),
+			Value:   name,
+		},
+	})
+}
+
+// fetchFavicon retrieves the favicon of the bookmark in the background
+func (b *BookmarksAPI) fetchFavicon(bm store.Bookmark, user security.User) {
+	name, err := b.downloadFavicon(bm.URL)
+	if err != nil {
+		handler.LogFunction("api.fetchFavicon").Errorf("cannot fetch favicon from URL '%s': %v", bm.URL, err)
+		return
+	}
+	if err := b.setFavicon(bm.ID, user.Username, name, ""); err != nil {
+		handler.LogFunction("api.fetchFavicon").Errorf("could not update bookmark with favicon '%s': %v", name, err)
+	}
+}
+
+// downloadFavicon fetches the favicon of the given URL and saves it in the favicon store
+func (b *BookmarksAPI) downloadFavicon(url string) (string, error) {
+	client, err := b.fetcher()
+	if err != nil {
+		return "", fmt.Errorf("cannot create a client to fetch the favicon: %v", err)
+	}
+
+	_, payload, err := favicon.GetFaviconFromURL(client, url)
+	if err != nil {
+		return "", err
+	}
+	if len(payload) == 0 {
+		return "", fmt.Errorf("no payload for favicon")
+	}
+
+	// the favicon is normalized and stored by the hash of the content, identical favicons are shared
+	return b.favicons().Save(payload)
+}
+
+// setFavicon updates the favicon of the bookmark, the previous favicon is released. the change is logged
+// with the ID of the request, background updates use an empty ID
+func (b *BookmarksAPI) setFavicon(id, username, name, reqID string) error {
+	var oldFavicon string
+	if err := b.inUnitOfWork(func(repo store.Repository) error {
+		bm, err := repo.GetBookmarkById(id, username)
+		if err != nil {
+			return err
+		}
+		if bm.Favicon == name {
+			return nil
+		}
+		before := bm
+		oldFavicon = bm.Favicon
+		bm.Favicon = name
+		updated, err := repo.Update(bm)
+		if err != nil {
+			return err
+		}
+		return store.LogChange(repo, reqID, store.ChangeUpdate, &before, &updated)
+	}); err != nil {
+		return err
+	}
+	b.releaseFavicon(oldFavicon)
+	return nil
+}
+
+// favicons returns the store which holds the favicon files
+func (b *BookmarksAPI) favicons() *favicon.Store {
+	return favicon.NewStore(path.Join(b.BasePath, b.FaviconPath))
+}
+
+// releaseFavicon removes the favicon file if it is no longer used by any bookmark
+func (b *BookmarksAPI) releaseFavicon(name string) {
+	if name == "" {
+		return
+	}
+	if err := b.favicons().Release(name, b.Repository); err != nil {
+		handler.LogFunction("api.releaseFavicon").Warnf("could not release favicon '%s': %v", name, err)
+	}
+}
+
+// faviconAvailable checks if the file of the favicon exists
+func (b *BookmarksAPI) faviconAvailable(name string) bool {
+	file, err := b.favicons().File(name)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(file)
+	return err == nil && !info.IsDir()
+}
+
+// faviconETag uses the content-hash of the favicon as a strong ETag
+func faviconETag(name string) string {
+	return `"` + favicon.Hash(name) + `"`
+}
+
+// serveFavicon delivers the file and handles conditional requests
+func serveFavicon(w http.ResponseWriter, r *http.Request, fullPath string) {
+	// SVG favicons must not be able to run any scripts if opened directly
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	http.ServeFile(w, r, fullPath)
+}
+
+// readFavicon returns the uploaded favicon either from a multipart form or the request body
+func readFavicon(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, fmt.Errorf("empty payload")
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, favicon.MaxPayloadSize+multipartOverhead)
+
+	var reader io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile(faviconFormField)
+		if err != nil {
+			return nil, fmt.Errorf("no file in field '%s': %v", faviconFormField, err)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	payload, err := ioutil.ReadAll(io.LimitReader(reader, favicon.MaxPayloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("empty payload")
+	}
+	return payload, nil
+}
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+)
+
+func faviconAPI(t *testing.T, repo store.Repository) (*BookmarksAPI, chi.Router, func()) {
+	dir, err := ioutil.TempDir("", "favicons")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	client, err := fetch.New(fetch.Options{AllowPrivateNetworks: true})
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	bookmarkAPI := &BookmarksAPI{
+		Handler:        baseHandler,
+		Repository:     repo,
+		FaviconPath:    dir,
+		DefaultFavicon: "../../../assets/favicon.ico",
+		Fetcher:        client,
+	}
+
+	r := chi.NewRouter()
+	r.Use(jwtUser)
+	r.Post("/{id}/favicon", bookmarkAPI.Secure(bookmarkAPI.UploadFavicon))
+	r.Delete("/{id}/favicon", bookmarkAPI.Secure(bookmarkAPI.DeleteFavicon))
+	r.Post("/{id}/favicon/refresh", bookmarkAPI.Secure(bookmarkAPI.RefreshFavicon))
+	r.Post("/favicons", bookmarkAPI.Secure(bookmarkAPI.GetFaviconURLs))
+	r.Get("/favicon/{id}", bookmarkAPI.Secure(bookmarkAPI.GetFavicon))
+	r.Get("/favicons/{hash}", bookmarkAPI.Secure(bookmarkAPI.GetFaviconByHash))
+	return bookmarkAPI, r, func() { os.RemoveAll(dir) }
+}
+
+func TestUploadFavicon(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	_, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+
+	bm, err := repo.Create(store.Bookmark{
+		DisplayName: "Intranet",
+		Path:        "/",
+		Type:        store.Node,
+		URL:         "http://intranet",
+		UserName:    userName,
+	})
+	if err != nil {
+		t.Fatalf("could not create bookmark: %v", err)
+	}
+	ico, _ := ioutil.ReadFile("../../../assets/favicon.ico")
+
+	// upload as raw body
+	// ---------------------------------------------------------------
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/"+bm.ID+"/favicon", bytes.NewReader(ico))
+	req.Header.Add("Content-Type", "image/x-icon")
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var result Result
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Regexp(t, `^[0-9a-f]{40}\.png$`, result.Value)
+
+	stored, _ := repo.GetBookmarkById(bm.ID, userName)
+	assert.Equal(t, result.Value, stored.Favicon)
+
+	// upload as multipart form
+	// ---------------------------------------------------------------
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 16 16"><circle cx="8" cy="8" r="8"/></svg>`
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "icon.svg")
+	part.Write([]byte(svg))
+	mw.Close()
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/"+bm.ID+"/favicon", &body)
+	req.Header.Add("Content-Type", mw.FormDataContentType())
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Regexp(t, `^[0-9a-f]{40}\.svg$`, result.Value)
+
+	// invalid image
+	// ---------------------------------------------------------------
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/"+bm.ID+"/favicon", bytes.NewReader([]byte("<html></html>")))
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// empty body
+	// ---------------------------------------------------------------
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/"+bm.ID+"/favicon", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// unknown bookmark
+	// ---------------------------------------------------------------
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/unknown/favicon", bytes.NewReader(ico))
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestDeleteAndRefreshFavicon(t *testing.T) {
+	ico, _ := ioutil.ReadFile("../../../assets/favicon.ico")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/favicon.ico" {
+			w.Header().Add("content-type", "image/x-icon")
+			w.Write(ico)
+			return
+		}
+		w.Header().Add("content-type", "text/html")
+		w.Write([]byte("<html><head></head></html>"))
+	}))
+	defer ts.Close()
+
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+
+	bm, err := repo.Create(store.Bookmark{
+		DisplayName: "Page",
+		Path:        "/",
+		Type:        store.Node,
+		URL:         ts.URL,
+		UserName:    userName,
+		Favicon:     "custom.png",
+	})
+	if err != nil {
+		t.Fatalf("could not create bookmark: %v", err)
+	}
+	folder, err := repo.Create(store.Bookmark{
+		DisplayName: "Folder",
+		Path:        "/",
+		Type:        store.Folder,
+		UserName:    userName,
+	})
+	if err != nil {
+		t.Fatalf("could not create bookmark: %v", err)
+	}
+
+	// refresh the favicon
+	// ---------------------------------------------------------------
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/"+bm.ID+"/favicon/refresh", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var result Result
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.FileExists(t, filepath.Join(bookmarkAPI.FaviconPath, result.Value))
+	stored, _ := repo.GetBookmarkById(bm.ID, userName)
+	assert.Equal(t, result.Value, stored.Favicon)
+
+	// folders do not have a favicon to fetch
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/"+folder.ID+"/favicon/refresh", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// delete the favicon
+	// ---------------------------------------------------------------
+	bookmarkAPI.Fetcher = nil
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/"+folder.ID+"/favicon", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/unknown/favicon", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/unknown/favicon/refresh", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestFaviconCaching(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+
+	ico, _ := ioutil.ReadFile("../../../assets/favicon.ico")
+	name, err := bookmarkAPI.favicons().Save(ico)
+	if err != nil {
+		t.Fatalf("could not save favicon: %v", err)
+	}
+	hash := name[:40]
+
+	var ids []string
+	for _, f := range []string{name, ""} {
+		bm, err := repo.Create(store.Bookmark{
+			DisplayName: "Node",
+			Path:        "/",
+			Type:        store.Node,
+			URL:         "http://url",
+			UserName:    userName,
+			Favicon:     f,
+		})
+		if err != nil {
+			t.Fatalf("could not create bookmark: %v", err)
+		}
+		ids = append(ids, bm.ID)
+	}
+
+	// content-addressed favicon
+	// ---------------------------------------------------------------
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/favicons/"+hash, nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"`+hash+`"`, rec.Header().Get("ETag"))
+	assert.Contains(t, rec.Header().Get("Cache-Control"), "max-age=31536000")
+	assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/favicons/"+hash, nil)
+	req.Header.Add("If-None-Match", `"`+hash+`"`)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, 0, rec.Body.Len())
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/favicons/favicon.ico", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// the file name is not the address of the favicon
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/favicons/"+name, nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/favicons/0123456789abcdef0123456789abcdef01234567", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// favicon by bookmark
+	// ---------------------------------------------------------------
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/favicon/"+ids[0], nil)
+	req.Header.Add("If-None-Match", `"`+hash+`"`)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/favicon/"+ids[1], nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "", rec.Header().Get("ETag"))
+
+	// batch of favicon URLs
+	// ---------------------------------------------------------------
+	payload, _ := json.Marshal(BookmarkIDs{IDs: append(ids, "unknown")})
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/favicons", bytes.NewReader(payload))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var list BookmarkFaviconList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 2, list.Count)
+	urls := make(map[string]string)
+	for _, f := range list.Value {
+		urls[f.ID] = f.URL
+	}
+	assert.Equal(t, "/favicons/"+hash, urls[ids[0]])
+	assert.Equal(t, "/api/v1/bookmarks/favicon/"+ids[1], urls[ids[1]])
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/favicons", strings.NewReader(`{"ids": []}`))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/favicons", strings.NewReader(""))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
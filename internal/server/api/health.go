@@ -0,0 +1,411 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	er "errors"
+
+	"github.com/bihe/bookmarks/internal/linkhealth"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+// the number of link checks returned for the history of a bookmark if not specified
+const defaultHealthHistory = 20
+
+// swagger:operation GET /api/v1/bookmarks/health bookmarks GetBookmarksHealth
+//
+// get the link health of bookmarks
+//
+// returns the result of the latest link check of the bookmarks, optionally filtered by status
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: status
+//   in: query
+//   description: ok, redirected, broken or error
+// responses:
+//   '200':
+//     description: BookmarkHealthList
+//     schema:
+//       "$ref": "#/definitions/BookmarkHealthList"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetBookmarksHealth(user security.User, w http.ResponseWriter, r *http.Request) error {
+	status := store.LinkStatus(r.URL.Query().Get("status"))
+
+	switch status {
+	case "", store.LinkOK, store.LinkRedirected, store.LinkBroken, store.LinkError:
+	default:
+		return errors.BadRequestError{Err: fmt.Errorf("invalid status '%s'", status), Request: r}
+	}
+
+	handler.LogFunction("api.GetBookmarksHealth").Debugf("get link health with status '%s' for user: '%s'", status, user.Username)
+
+	health, err := b.Repository.GetLinkHealth(user.Username, status)
+	if err != nil {
+		handler.LogFunction("api.GetBookmarksHealth").Errorf("cannot get the link health: %v", err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the link health: %v", err), Request: r}
+	}
+
+	ids := make([]string, 0, len(health))
+	for _, h := range health {
+		ids = append(ids, h.BookmarkID)
+	}
+	bookmarks, err := b.Repository.GetBookmarksByIds(ids, user.Username)
+	if err != nil {
+		handler.LogFunction("api.GetBookmarksHealth").Errorf("cannot get bookmarks by IDs: %v", err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the bookmarks: %v", err), Request: r}
+	}
+	lookup := make(map[string]store.Bookmark)
+	for _, bm := range bookmarks {
+		lookup[bm.ID] = bm
+	}
+
+	result := make([]BookmarkHealth, 0, len(health))
+	for _, h := range health {
+		bm, ok := lookup[h.BookmarkID]
+		if !ok {
+			continue
+		}
+		result = append(result, BookmarkHealth{
+			Bookmark:    *entityToModel(bm),
+			Status:      string(h.Status),
+			StatusCode:  h.StatusCode,
+			FinalURL:    h.FinalURL,
+			Latency:     h.Latency,
+			Error:       h.Error,
+			Failures:    h.Failures,
+			LastChecked: h.LastChecked,
+		})
+	}
+
+	return render.Render(w, r, BookmarkHealthListResponse{
+		BookmarkHealthList: &BookmarkHealthList{
+			Success: true,
+			Count:   len(result),
+			Message: fmt.Sprintf("Found %d bookmarks", len(result)),
+			Value:   result,
+		},
+	})
+}
+
+// swagger:operation GET /api/v1/bookmarks/{id}/health bookmarks GetBookmarkHealthHistory
+//
+// get the history of link checks
+//
+// returns the most recent link checks of the bookmark
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+// - name: limit
+//   in: query
+// responses:
+//   '200':
+//     description: LinkCheckList
+//     schema:
+//       "$ref": "#/definitions/LinkCheckList"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetBookmarkHealthHistory(user security.User, w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+
+	if id == "" {
+		return errors.BadRequestError{Err: fmt.Errorf("missing id parameter"), Request: r}
+	}
+
+	limit := defaultHealthHistory
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return errors.BadRequestError{Err: fmt.Errorf("invalid limit '%s'", l), Request: r}
+		}
+		limit = n
+	}
+
+	if _, err := b.Repository.GetBookmarkById(id, user.Username); err != nil {
+		handler.LogFunction("api.GetBookmarkHealthHistory").Warnf("could not find bookmark by id '%s': %v", id, err)
+		return errors.NotFoundError{Err: fmt.Errorf("could not find bookmark with ID '%s'", id), Request: r}
+	}
+
+	checks, err := b.Repository.GetLinkChecks(id, limit)
+	if err != nil {
+		handler.LogFunction("api.GetBookmarkHealthHistory").Errorf("cannot get the link checks of bookmark '%s': %v", id, err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the link checks: %v", err), Request: r}
+	}
+
+	history := make([]LinkCheck, 0, len(checks))
+	for _, c := range checks {
+		history = append(history, linkCheckToModel(c))
+	}
+
+	return render.Render(w, r, LinkCheckListResponse{
+		LinkCheckList: &LinkCheckList{
+			Success: true,
+			Count:   len(history),
+			Message: fmt.Sprintf("Found %d link checks", len(history)),
+			Value:   history,
+		},
+	})
+}
+
+// swagger:operation POST /api/v1/bookmarks/health/actions bookmarks HealthActions
+//
+// apply an action to bookmarks with link problems
+//
+// the bookmarks are either deleted, moved to the given path or checked again. the delete and the move need the
+// versions of the bookmarks. the check is performed in the background, therefore the request is accepted and not finished
+//
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: Result
+//     schema:
+//       "$ref": "#/definitions/Result"
+//   '202':
+//     description: Result
+//     schema:
+//       "$ref": "#/definitions/Result"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '412':
+//     description: VersionConflict
+//     schema:
+//       "$ref": "#/definitions/VersionConflict"
+//   '428':
+//     description: VersionConflict
+//     schema:
+//       "$ref": "#/definitions/VersionConflict"
+func (b *BookmarksAPI) HealthActions(user security.User, w http.ResponseWriter, r *http.Request) error {
+	payload := &HealthActionRequest{}
+	if err := render.Bind(r, payload); err != nil {
+		handler.LogFunction("api.HealthActions").Warnf("cannot bind payload: '%v'", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid request data supplied"), Request: r}
+	}
+	if len(payload.IDs) == 0 {
+		return errors.BadRequestError{Err: fmt.Errorf("no bookmarks supplied"), Request: r}
+	}
+
+	handler.LogFunction("api.HealthActions").Debugf("apply action '%s' to %d bookmarks", payload.Action, len(payload.IDs))
+
+	var (
+		count    int
+		err      error
+		favicons []string
+	)
+	switch payload.Action {
+	case HealthDelete:
+		favicons, err = b.deleteNodes(payload.IDs, payload.Versions, user.Username, r)
+		count = len(favicons)
+	case HealthMove:
+		if payload.Path == "" {
+			return errors.BadRequestError{Err: fmt.Errorf("missing path to move the bookmarks"), Request: r}
+		}
+		count, err = b.moveNodes(payload.IDs, payload.Versions, payload.Path, user.Username, r)
+	case HealthRetry:
+		count, err = b.recheckNodes(payload.IDs, user.Username)
+	default:
+		return errors.BadRequestError{Err: fmt.Errorf("invalid action '%s'", payload.Action), Request: r}
+	}
+
+	if err != nil {
+		handler.LogFunction("api.HealthActions").Errorf("could not apply action '%s': %v", payload.Action, err)
+		var (
+			badRequest errors.BadRequestError
+			version    versionError
+		)
+		if er.As(err, &version) {
+			return b.renderPreconditionError(version.err, version.id, user.Username, w, r)
+		}
+		if er.As(err, &badRequest) {
+			return badRequest
+		}
+		return errors.ServerError{Err: fmt.Errorf("error applying action '%s': %v", payload.Action, err), Request: r}
+	}
+
+	for _, f := range favicons {
+		b.releaseFavicon(f)
+	}
+
+	status := http.StatusOK
+	if payload.Action == HealthRetry {
+		status = http.StatusAccepted
+	}
+	return render.Render(w, r, ResultResponse{
+		Result: &Result{
+			Success: true,
+			Message: fmt.Sprintf("Applied action '%s' to %d bookmarks", payload.Action, count),
+			Value:   fmt.Sprintf("%d", count),
+		},
+		Status: status,
+	})
+}
+
+// versionError is a missing or an outdated version of a bookmark of the action
+type versionError struct {
+	id  string
+	err error
+}
+
+func (e versionError) Error() string {
+	return fmt.Sprintf("bookmark '%s': %v", e.id, e.err)
+}
+
+func (e versionError) Unwrap() error {
+	return e.err
+}
+
+// checkNodeVersion compares the supplied version of the bookmark with the stored version
+func checkNodeVersion(versions map[string]int, bm store.Bookmark) error {
+	version := versions[bm.ID]
+	switch {
+	case version == 0:
+		return versionError{id: bm.ID, err: errPreconditionRequired}
+	case version != bm.Version:
+		return versionError{id: bm.ID, err: store.ErrStaleVersion}
+	}
+	return nil
+}
+
+// deleteNodes removes the given bookmarks, the favicons of the removed bookmarks are returned
+func (b *BookmarksAPI) deleteNodes(ids []string, versions map[string]int, username string, r *http.Request) ([]string, error) {
+	var favicons []string
+	err := b.inUnitOfWork(func(repo store.Repository) error {
+		for _, id := range ids {
+			existing, err := repo.GetBookmarkById(id, username)
+			if err != nil {
+				return errors.BadRequestError{Err: fmt.Errorf("could not find bookmark with ID '%s'", id), Request: r}
+			}
+			if existing.Type != store.Node {
+				return errors.BadRequestError{Err: fmt.Errorf("only bookmarks can be deleted, '%s' is a folder", id), Request: r}
+			}
+			if err := checkNodeVersion(versions, existing); err != nil {
+				return err
+			}
+			if err := repo.Delete(existing); err != nil {
+				return err
+			}
+			if err := store.LogChange(repo, requestID(r), store.ChangeDelete, &existing, nil); err != nil {
+				return err
+			}
+			favicons = append(favicons, existing.Favicon)
+		}
+		return nil
+	})
+	return favicons, err
+}
+
+// moveNodes changes the path of the given bookmarks and updates the child-count of the affected folders
+func (b *BookmarksAPI) moveNodes(ids []string, versions map[string]int, path, username string, r *http.Request) (int, error) {
+	var count int
+	err := b.inUnitOfWork(func(repo store.Repository) error {
+		paths := map[string]bool{path: true}
+		for _, id := range ids {
+			existing, err := repo.GetBookmarkById(id, username)
+			if err != nil {
+				return errors.BadRequestError{Err: fmt.Errorf("could not find bookmark with ID '%s'", id), Request: r}
+			}
+			if existing.Type != store.Node {
+				return errors.BadRequestError{Err: fmt.Errorf("only bookmarks can be moved, '%s' is a folder", id), Request: r}
+			}
+			if err := checkNodeVersion(versions, existing); err != nil {
+				return err
+			}
+			paths[existing.Path] = true
+			before := existing
+			existing.Path = path
+			updated, err := repo.Update(existing)
+			if err != nil {
+				return errors.BadRequestError{Err: fmt.Errorf("could not move bookmark '%s': %v", id, err), Request: r}
+			}
+			if err := store.LogChange(repo, requestID(r), store.ChangeUpdate, &before, &updated); err != nil {
+				return err
+			}
+			count++
+		}
+		for p := range paths {
+			if err := repo.UpdateChildCountOfPath(p, username); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+// recheckNodes checks the links of the given bookmarks again in the background
+func (b *BookmarksAPI) recheckNodes(ids []string, username string) (int, error) {
+	checker, err := b.linkChecker()
+	if err != nil {
+		return 0, err
+	}
+	bookmarks, err := b.Repository.GetBookmarksByIds(ids, username)
+	if err != nil {
+		return 0, err
+	}
+	// fire&forget, the checks are rate-limited and might take some time
+	go checker.CheckBookmarks(bookmarks)
+	return len(bookmarks), nil
+}
+
+// linkChecker returns the configured link checker or a checker with the default options
+func (b *BookmarksAPI) linkChecker() (*linkhealth.Checker, error) {
+	if b.LinkChecker != nil {
+		return b.LinkChecker, nil
+	}
+	client, err := b.fetcher()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create a client for the link checker: %v", err)
+	}
+	return linkhealth.New(client, b.Repository, linkhealth.Options{}), nil
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookmarksHealth(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	bookmarkAPI := &BookmarksAPI{
+		Handler:    baseHandler,
+		Repository: repo,
+	}
+	r := chi.NewRouter()
+	r.Use(jwtUser)
+	r.Get("/health", bookmarkAPI.Secure(bookmarkAPI.GetBookmarksHealth))
+	r.Get("/{id}/health", bookmarkAPI.Secure(bookmarkAPI.GetBookmarkHealthHistory))
+
+	var ids []string
+	for _, name := range []string{"ok", "broken"} {
+		bm, err := repo.Create(store.Bookmark{
+			DisplayName: name,
+			Path:        "/",
+			Type:        store.Node,
+			URL:         "http://url/" + name,
+			UserName:    userName,
+		})
+		if err != nil {
+			t.Fatalf("could not create bookmark: %v", err)
+		}
+		ids = append(ids, bm.ID)
+	}
+	repo.SaveLinkCheck(store.LinkCheck{BookmarkID: ids[0], Status: store.LinkOK, StatusCode: 200}, userName)
+	repo.SaveLinkCheck(store.LinkCheck{BookmarkID: ids[1], Status: store.LinkError, StatusCode: 503}, userName)
+	repo.SaveLinkCheck(store.LinkCheck{BookmarkID: ids[1], Status: store.LinkBroken, StatusCode: 404}, userName)
+
+	// broken bookmarks
+	// ---------------------------------------------------------------
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/health?status=broken", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var list BookmarkHealthList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 1, list.Count)
+	assert.Equal(t, "broken", list.Value[0].Bookmark.DisplayName)
+	assert.Equal(t, 404, list.Value[0].StatusCode)
+	assert.Equal(t, 2, list.Value[0].Failures)
+
+	// all bookmarks
+	// ---------------------------------------------------------------
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/health", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 2, list.Count)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/health?status=unknown", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// history
+	// ---------------------------------------------------------------
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/"+ids[1]+"/health", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var history LinkCheckList
+	if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 2, history.Count)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/"+ids[1]+"/health?limit=1", nil)
+	r.ServeHTTP(rec, req)
+	if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 1, history.Count)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/"+ids[1]+"/health?limit=x", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/unknown/health", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHealthActions(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	bookmarkAPI := &BookmarksAPI{
+		Handler:    baseHandler,
+		Repository: repo,
+	}
+	r := chi.NewRouter()
+	r.Use(jwtUser)
+	r.Post("/health/actions", bookmarkAPI.Secure(bookmarkAPI.HealthActions))
+
+	folder, err := repo.Create(store.Bookmark{DisplayName: "Broken", Path: "/", Type: store.Folder, UserName: userName})
+	if err != nil {
+		t.Fatalf("could not create bookmark: %v", err)
+	}
+	var ids []string
+	for _, name := range []string{"a", "b", "c"} {
+		bm, err := repo.Create(store.Bookmark{
+			DisplayName: name,
+			Path:        "/",
+			Type:        store.Node,
+			URL:         "http://url/" + name,
+			UserName:    userName,
+		})
+		if err != nil {
+			t.Fatalf("could not create bookmark: %v", err)
+		}
+		ids = append(ids, bm.ID)
+	}
+
+	action := func(payload string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/health/actions", strings.NewReader(payload))
+		req.Header.Add("Content-Type", "application/json")
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// move
+	// ---------------------------------------------------------------
+	rec := action(`{"action": "move", "ids": ["` + ids[0] + `", "` + ids[1] + `"], "path": "/Broken"}`)
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+	rec = action(`{"action": "move", "ids": ["` + ids[0] + `", "` + ids[1] + `"], "path": "/Broken", "versions": {"` + ids[0] + `": 2, "` + ids[1] + `": 1}}`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	rec = action(`{"action": "move", "ids": ["` + ids[0] + `", "` + ids[1] + `"], "path": "/Broken", "versions": {"` + ids[0] + `": 1, "` + ids[1] + `": 1}}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	bm, _ := repo.GetBookmarkById(ids[0], userName)
+	assert.Equal(t, "/Broken", bm.Path)
+	folder, _ = repo.GetBookmarkById(folder.ID, userName)
+	assert.Equal(t, 2, folder.ChildCount)
+
+	rec = action(`{"action": "move", "ids": ["` + ids[2] + `"], "path": "/missing", "versions": {"` + ids[2] + `": 1}}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = action(`{"action": "move", "ids": ["` + folder.ID + `"], "path": "/"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// delete
+	// ---------------------------------------------------------------
+	rec = action(`{"action": "delete", "ids": ["` + ids[0] + `"]}`)
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+	rec = action(`{"action": "delete", "ids": ["` + ids[0] + `"], "versions": {"` + ids[0] + `": 2}}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	_, err = repo.GetBookmarkById(ids[0], userName)
+	assert.Error(t, err)
+	folder, _ = repo.GetBookmarkById(folder.ID, userName)
+	assert.Equal(t, 1, folder.ChildCount)
+
+	rec = action(`{"action": "delete", "ids": ["unknown"]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// retry
+	// ---------------------------------------------------------------
+	rec = action(`{"action": "retry", "ids": ["` + ids[2] + `"]}`)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	var result Result
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, "1", result.Value)
+
+	// invalid requests
+	// ---------------------------------------------------------------
+	rec = action(`{"action": "unknown", "ids": ["` + ids[2] + `"]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = action(`{"action": "delete", "ids": []}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = action(`{"action": "move", "ids": ["` + ids[2] + `"]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
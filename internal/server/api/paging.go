@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/bihe/bookmarks/internal/store"
+)
+
+// the maximum number of bookmarks of a page
+const maxPageSize = 1000
+
+// listOptions are the paging, sorting and field selection of a list request
+type listOptions struct {
+	store.ListOptions
+	fields []string
+}
+
+// sorted returns true if the request asks for a page or a specific order
+func (o listOptions) sorted() bool {
+	return o.Sort != "" || o.Limit > 0 || o.After != nil
+}
+
+// readListOptions reads the query parameters limit, cursor, sort, order and fields. Without a sort parameter the
+// default sort key and direction are used, without a limit all bookmarks are returned unless a default limit is
+// given. the cursor has to belong to the order of the request
+func readListOptions(r *http.Request, defaultSort store.SortKey, defaultDesc bool, defaultLimit int) (listOptions, error) {
+	q := r.URL.Query()
+	opts := listOptions{ListOptions: store.ListOptions{Sort: defaultSort, Desc: defaultDesc, Limit: defaultLimit}}
+
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxPageSize {
+			return listOptions{}, fmt.Errorf("invalid limit '%s', the limit has to be between 1 and %d", l, maxPageSize)
+		}
+		opts.Limit = n
+	}
+
+	if s := q.Get("sort"); s != "" {
+		switch key := store.SortKey(s); key {
+		case store.SortOrder, store.SortName, store.SortCreated, store.SortModified, store.SortAccessCount, store.SortHost:
+			opts.Sort = key
+			opts.Desc = false
+		default:
+			return listOptions{}, fmt.Errorf("invalid sort key '%s'", s)
+		}
+	}
+	switch o := q.Get("order"); o {
+	case "":
+	case "asc":
+		opts.Desc = false
+	case "desc":
+		opts.Desc = true
+	default:
+		return listOptions{}, fmt.Errorf("invalid order '%s', use 'asc' or 'desc'", o)
+	}
+
+	if c := q.Get("cursor"); c != "" {
+		cursor, err := store.ParseCursor(c, opts.Sort, opts.Desc)
+		if err != nil {
+			return listOptions{}, fmt.Errorf("invalid cursor '%s' for the requested order", c)
+		}
+		opts.After = cursor
+	}
+
+	if f := q.Get("fields"); f != "" {
+		known := bookmarkFields()
+		for _, field := range strings.Split(f, ",") {
+			field = strings.TrimSpace(field)
+			if !known[field] {
+				return listOptions{}, fmt.Errorf("unknown field '%s'", field)
+			}
+			opts.fields = append(opts.fields, field)
+		}
+	}
+	return opts, nil
+}
+
+// bookmarkFields returns the JSON names of the fields of the bookmark model
+func bookmarkFields() map[string]bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(Bookmark{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// projectFields returns the given fields of the bookmark, empty fields which are omitted are not included
+func projectFields(bm Bookmark, fields []string) (map[string]interface{}, error) {
+	payload, err := json.Marshal(bm)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]interface{}
+	if err := json.Unmarshal(payload, &all); err != nil {
+		return nil, err
+	}
+	projected := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if v, ok := all[f]; ok {
+			projected[f] = v
+		}
+	}
+	return projected, nil
+}
+
+// pagedList returns the list of the page with the link to the next page. the link is the request with the cursor
+// of the next page
+func pagedList(r *http.Request, bookmarks []Bookmark, page store.BookmarkPage) BookmarkList {
+	count := len(bookmarks)
+	list := BookmarkList{
+		Success: true,
+		Count:   count,
+		Message: fmt.Sprintf("Found %d items.", count),
+		Total:   page.Total,
+		Value:   bookmarks,
+	}
+	if page.Next != nil {
+		next := *r.URL
+		q := next.Query()
+		q.Set("cursor", page.Next.String())
+		next.RawQuery = q.Encode()
+		list.Next = next.RequestURI()
+	}
+	return list
+}
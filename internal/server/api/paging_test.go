@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPagedBookmarkLists(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Get("/bypath", bookmarkAPI.Secure(bookmarkAPI.GetBookmarksByPath))
+	r.Get("/byname", bookmarkAPI.Secure(bookmarkAPI.GetBookmarksByName))
+	r.Get("/mostvisited/{num}", bookmarkAPI.Secure(bookmarkAPI.GetMostVisited))
+
+	for _, bm := range []store.Bookmark{
+		{DisplayName: "Delta", Path: "/", Type: store.Node, URL: "https://b.example.com/x", SortOrder: 1, AccessCount: 3},
+		{DisplayName: "Alpha", Path: "/", Type: store.Node, URL: "http://a.example.com", SortOrder: 2, AccessCount: 1},
+		{DisplayName: "Charlie", Path: "/", Type: store.Node, URL: "https://c.example.com", AccessCount: 4},
+		{DisplayName: "Bravo", Path: "/", Type: store.Node, URL: "https://b.example.com/y", SortOrder: 1},
+		{DisplayName: "Recent", Path: "/", Type: store.SmartFolder, Query: "url:example", SortOrder: 3},
+	} {
+		bm.UserName = userName
+		if _, err := repo.Create(bm); err != nil {
+			t.Fatalf("could not create bookmark: %v", err)
+		}
+	}
+
+	list := func(url string, status int) (BookmarkList, string) {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, status, rec.Code, url)
+		var result BookmarkList
+		if status == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatalf("could not unmarshal body: %v", err)
+			}
+		}
+		return result, rec.Body.String()
+	}
+	names := func(bookmarks []Bookmark) []string {
+		var result []string
+		for _, bm := range bookmarks {
+			result = append(result, bm.DisplayName)
+		}
+		return result
+	}
+	// pages follows the next links and returns the names of all pages
+	pages := func(url string) []string {
+		var result []string
+		for i := 0; url != "" && i < 10; i++ {
+			page, _ := list(url, http.StatusOK)
+			result = append(result, names(page.Value)...)
+			url = page.Next
+		}
+		return result
+	}
+
+	// without parameters the list is not paged
+	result, _ := list("/bypath?path=/", http.StatusOK)
+	assert.Equal(t, []string{"Charlie", "Bravo", "Delta", "Alpha", "Recent"}, names(result.Value))
+	assert.Equal(t, 5, result.Total)
+	assert.Equal(t, "", result.Next)
+
+	result, _ = list("/bypath?path=/&limit=2", http.StatusOK)
+	assert.Equal(t, 2, result.Count)
+	assert.Equal(t, 5, result.Total)
+	assert.True(t, strings.HasPrefix(result.Next, "/bypath?"))
+	assert.Contains(t, result.Next, "cursor=")
+	assert.Contains(t, result.Next, "limit=2")
+
+	assert.Equal(t, []string{"Charlie", "Bravo", "Delta", "Alpha", "Recent"}, pages("/bypath?path=/&limit=2"))
+	assert.Equal(t, []string{"Recent", "Alpha", "Delta", "Bravo", "Charlie"}, pages("/bypath?path=/&limit=2&order=desc"))
+	assert.Equal(t, []string{"Alpha", "Bravo", "Charlie", "Delta", "Recent"}, pages("/bypath?path=/&limit=3&sort=name"))
+	assert.Equal(t, []string{"Charlie", "Delta", "Alpha", "Bravo", "Recent"}, pages("/bypath?path=/&limit=2&sort=accessCount&order=desc"))
+	assert.Equal(t, []string{"Recent", "Alpha", "Bravo", "Delta", "Charlie"}, pages("/bypath?path=/&limit=2&sort=host"))
+
+	// the bookmarks of a smart folder are paged in memory
+	assert.Equal(t, []string{"Alpha", "Bravo", "Charlie", "Delta"}, pages("/bypath?path=/Recent&limit=3&sort=name"))
+
+	assert.Equal(t, []string{"Alpha", "Bravo", "Charlie", "Delta"}, pages("/byname?name=a&content=false&limit=1&sort=name"))
+	assert.Equal(t, []string{"Charlie", "Delta", "Alpha"}, pages("/mostvisited/2"))
+	assert.Equal(t, []string{"Charlie", "Delta", "Alpha"}, pages("/mostvisited/10?limit=1"))
+	result, _ = list("/mostvisited/2", http.StatusOK)
+	assert.Equal(t, 2, result.Count)
+	assert.Equal(t, 3, result.Total)
+
+	// the fields of the bookmarks are selected
+	_, body := list("/bypath?path=/&limit=1&fields=id,displayName", http.StatusOK)
+	var projected struct {
+		Total int                      `json:"total"`
+		Value []map[string]interface{} `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(body), &projected); err != nil {
+		t.Fatalf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 5, projected.Total)
+	assert.Equal(t, 1, len(projected.Value))
+	assert.Equal(t, 2, len(projected.Value[0]))
+	assert.Equal(t, "Charlie", projected.Value[0]["displayName"])
+	assert.NotEmpty(t, projected.Value[0]["id"])
+
+	// invalid parameters
+	list("/bypath?path=/&limit=0", http.StatusBadRequest)
+	list("/bypath?path=/&limit=1001", http.StatusBadRequest)
+	list("/bypath?path=/&sort=unknown", http.StatusBadRequest)
+	list("/bypath?path=/&order=up", http.StatusBadRequest)
+	list("/bypath?path=/&fields=id,unknown", http.StatusBadRequest)
+	list("/bypath?path=/&cursor=invalid", http.StatusBadRequest)
+
+	// the cursor belongs to the order of the first page
+	result, _ = list("/bypath?path=/&limit=2&sort=name", http.StatusOK)
+	list(strings.Replace(result.Next, "sort=name", "sort=created", 1), http.StatusBadRequest)
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"encoding/json"
+	er "errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+
+	"github.com/bihe/bookmarks/internal/jsonpatch"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+// the media-types of the supported patch formats
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// serverFields are maintained by the server and cannot be changed by a patch
+var serverFields = []string{"id", "type", "created", "modified", "childCount", "accessCount", "lastAccessed",
+	"frecency", "snippet", "readAt", "version"}
+
+// swagger:operation PATCH /api/v1/bookmarks/{id} bookmarks PatchBookmark
+//
+// change fields of a bookmark
+//
+// only the fields of the patch are changed, the patch is either a JSON Merge Patch (RFC 7396) or a JSON Patch
+// (RFC 6902). the fields maintained by the server, like accessCount or childCount, cannot be changed. the
+// version of the bookmark is required, either as If-Match header or as version of the merge patch
+//
+// ---
+// consumes:
+// - application/merge-patch+json
+// - application/json-patch+json
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+// - name: If-Match
+//   in: header
+// responses:
+//   '200':
+//     description: Result
+//     schema:
+//       "$ref": "#/definitions/Result"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '412':
+//     description: VersionConflict
+//     schema:
+//       "$ref": "#/definitions/VersionConflict"
+//   '428':
+//     description: VersionConflict
+//     schema:
+//       "$ref": "#/definitions/VersionConflict"
+func (b *BookmarksAPI) Patch(user security.User, w http.ResponseWriter, r *http.Request) error {
+	var (
+		version    int
+		oldFavicon string
+		reindex    *store.Bookmark
+		favicon    *store.Bookmark
+	)
+
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		return errors.BadRequestError{Err: fmt.Errorf("missing id parameter"), Request: r}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != mergePatchType && mediaType != jsonPatchType {
+		return errors.BadRequestError{Err: fmt.Errorf("unsupported patch format '%s', use '%s' or '%s'", mediaType, mergePatchType, jsonPatchType), Request: r}
+	}
+	patch, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		handler.LogFunction("api.Patch").Warnf("cannot read the patch: %v", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid request data supplied"), Request: r}
+	}
+
+	// the patch is parsed before the transaction, like the payload of an update a merge patch can supply the version
+	var (
+		apply  func(doc, patch []byte) ([]byte, error)
+		merged struct {
+			Version int `json:"version"`
+		}
+	)
+	switch mediaType {
+	case mergePatchType:
+		apply = jsonpatch.MergePatch
+		err = json.Unmarshal(patch, &merged)
+	case jsonPatchType:
+		apply = jsonpatch.Apply
+		var ops []jsonpatch.Operation
+		err = json.Unmarshal(patch, &ops)
+	}
+	if err != nil {
+		handler.LogFunction("api.Patch").Warnf("cannot parse the patch: %v", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid patch of format '%s': %v", mediaType, err), Request: r}
+	}
+	expected, err := readPrecondition(r, merged.Version)
+	if err != nil {
+		return errors.BadRequestError{Err: err, Request: r}
+	}
+
+	handler.LogFunction("api.Patch").Debugf("will try to patch bookmark with ID '%s'", id)
+
+	if err := b.inUnitOfWork(func(repo store.Repository) error {
+		existing, err := repo.GetBookmarkById(id, user.Username)
+		if err != nil {
+			handler.LogFunction("api.Patch").Warnf("could not find bookmark by id '%s': %v", id, err)
+			return errors.NotFoundError{Err: fmt.Errorf("could not find bookmark with ID '%s'", id), Request: r}
+		}
+		if err := expected.check(existing.Version); err != nil {
+			return err
+		}
+
+		payload, err := patchBookmark(*entityToModel(existing), patch, apply)
+		if err != nil {
+			handler.LogFunction("api.Patch").Warnf("cannot apply the patch to bookmark '%s': %v", id, err)
+			return errors.BadRequestError{Err: err, Request: r}
+		}
+		if payload.Path == "" || payload.DisplayName == "" {
+			return errors.BadRequestError{Err: fmt.Errorf("invalid request data supplied, missing Path or DisplayName"), Request: r}
+		}
+
+		item, err := b.updateBookmark(repo, existing, payload, user, r)
+		if err != nil {
+			return err
+		}
+		version = item.Version
+		if existing.Favicon != item.Favicon {
+			oldFavicon = existing.Favicon
+		}
+		if existing.Type == store.Node && existing.URL != item.URL && item.URL != "" {
+			reindex = &item
+		}
+		if item.Favicon == "" {
+			favicon = &item
+		}
+		return nil
+	}); err != nil {
+		handler.LogFunction("api.Patch").Errorf("could not patch bookmark because of error: %v", err)
+		if isPreconditionError(err) {
+			return b.renderPreconditionError(err, id, user.Username, w, r)
+		}
+
+		var (
+			badRequest errors.BadRequestError
+			notFound   errors.NotFoundError
+		)
+		if er.As(err, &badRequest) {
+			return badRequest
+		}
+		if er.As(err, &notFound) {
+			return notFound
+		}
+		return errors.ServerError{Err: fmt.Errorf("error patching bookmark: %v", err), Request: r}
+	}
+
+	handler.LogFunction("api.Patch").Infof("patched bookmark with ID '%s'", id)
+	w.Header().Set("ETag", bookmarkETag(version))
+	b.releaseFavicon(oldFavicon)
+	if reindex != nil {
+		// fire&forget, index the page of the changed URL in background
+		go b.indexPage(*reindex)
+	}
+	if favicon != nil {
+		// fire&forget, fetch the missing favicon in background
+		go b.fetchFavicon(*favicon, user)
+	}
+
+	return render.Render(w, r, ResultResponse{
+		Result: &Result{
+			Success: true,
+			Message: fmt.Sprintf("Bookmark with ID '%s' was updated", id),
+			Value:   id,
+		},
+	})
+}
+
+// patchBookmark applies the patch to the current bookmark and rejects changes of the fields maintained by the server
+func patchBookmark(current Bookmark, patch []byte, apply func(doc, patch []byte) ([]byte, error)) (Bookmark, error) {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return Bookmark{}, err
+	}
+	patched, err := apply(doc, patch)
+	if err != nil {
+		return Bookmark{}, err
+	}
+
+	var before, after map[string]interface{}
+	if err := json.Unmarshal(doc, &before); err != nil {
+		return Bookmark{}, err
+	}
+	if err := json.Unmarshal(patched, &after); err != nil || after == nil {
+		return Bookmark{}, fmt.Errorf("the patched bookmark is not an object")
+	}
+	for _, field := range serverFields {
+		if !reflect.DeepEqual(before[field], after[field]) {
+			return Bookmark{}, fmt.Errorf("the field '%s' is maintained by the server and cannot be changed", field)
+		}
+	}
+
+	var bookmark Bookmark
+	if err := json.Unmarshal(patched, &bookmark); err != nil {
+		return Bookmark{}, fmt.Errorf("invalid patched bookmark: %v", err)
+	}
+	return bookmark, nil
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatchBookmark(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Patch("/{id}", bookmarkAPI.Secure(bookmarkAPI.Patch))
+
+	folder, _ := repo.Create(store.Bookmark{DisplayName: "Work", Path: "/", Type: store.Folder, UserName: userName})
+	node, _ := repo.Create(store.Bookmark{DisplayName: "Go", Path: "/Work", Type: store.Node, URL: "http://go", UserName: userName,
+		Favicon: "favicon.ico", AccessCount: 7, Metadata: store.PageMetadata{Title: "The Go Programming Language"}})
+	other, _ := repo.Create(store.Bookmark{DisplayName: "Other", Path: "/", Type: store.Folder, UserName: userName})
+
+	send := func(id, contentType, patch, ifMatch string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/"+id, strings.NewReader(patch))
+		req.Header.Add("Content-Type", contentType)
+		if ifMatch != "" {
+			req.Header.Add("If-Match", ifMatch)
+		}
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(id string) store.Bookmark {
+		bm, err := repo.GetBookmarkById(id, userName)
+		if err != nil {
+			t.Fatalf("cannot get bookmark '%s': %v", id, err)
+		}
+		return bm
+	}
+
+	// only the supplied fields are changed
+	rec := send(node.ID, mergePatchType, `{"displayName": "Golang", "version": 1}`, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	patched := get(node.ID)
+	assert.Equal(t, "Golang", patched.DisplayName)
+	assert.Equal(t, "http://go", patched.URL)
+	assert.Equal(t, "/Work", patched.Path)
+	assert.Equal(t, 7, patched.AccessCount)
+	assert.Equal(t, "The Go Programming Language", patched.Metadata.Title)
+
+	// the fields of the server cannot be changed
+	for _, patch := range []string{`{"accessCount": 0}`, `{"childCount": 3}`, `{"type": "Folder"}`, `{"id": "other"}`, `{"created": null}`} {
+		rec = send(node.ID, mergePatchType, patch, `"2"`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, patch)
+	}
+	assert.Equal(t, 7, get(node.ID).AccessCount)
+
+	// the path of the sub-elements and the child-count are updated for a moved folder
+	rec = send(folder.ID, jsonPatchType, `[
+		{"op": "test", "path": "/displayName", "value": "Work"},
+		{"op": "replace", "path": "/path", "value": "/Other"}
+	]`, "*")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/Other", get(folder.ID).Path)
+	assert.Equal(t, 1, get(folder.ID).ChildCount)
+	assert.Equal(t, "/Other/Work", get(node.ID).Path)
+	assert.Equal(t, 1, get(other.ID).ChildCount)
+
+	rec = send(folder.ID, mergePatchType, `{"displayName": "Job"}`, "*")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/Other/Job", get(node.ID).Path)
+
+	// the changed state is returned for an outdated version
+	rec = send(node.ID, mergePatchType, `{"displayName": "Go"}`, `"2"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	var conflict VersionConflict
+	if err := json.Unmarshal(rec.Body.Bytes(), &conflict); err != nil {
+		t.Fatalf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, "/Other/Job", conflict.Current.Path)
+
+	for _, tc := range []struct {
+		name        string
+		id          string
+		contentType string
+		patch       string
+		ifMatch     string
+		status      int
+	}{
+		{"missing version", node.ID, mergePatchType, `{"displayName": "Go"}`, "", http.StatusPreconditionRequired},
+		{"unknown bookmark", "unknown", mergePatchType, `{"displayName": "Go"}`, "*", http.StatusNotFound},
+		{"unsupported format", node.ID, "text/plain", `displayName=Go`, "*", http.StatusBadRequest},
+		{"invalid merge patch", node.ID, mergePatchType, `{`, "*", http.StatusBadRequest},
+		{"invalid JSON patch", node.ID, jsonPatchType, `{"op": "replace"}`, "*", http.StatusBadRequest},
+		{"plain JSON", node.ID, "application/json", `{"displayName": "Go"}`, "*", http.StatusBadRequest},
+		{"no object", node.ID, mergePatchType, `["Go"]`, "*", http.StatusBadRequest},
+		{"empty name", node.ID, mergePatchType, `{"displayName": null}`, "*", http.StatusBadRequest},
+		{"invalid field", node.ID, mergePatchType, `{"sortOrder": "first"}`, "*", http.StatusBadRequest},
+		{"failed test", node.ID, jsonPatchType, `[{"op": "test", "path": "/displayName", "value": "Go"}]`, "*", http.StatusBadRequest},
+		{"move into itself", folder.ID, jsonPatchType, `[{"op": "replace", "path": "/path", "value": "/Other/Job"}]`, "*", http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := send(tc.id, tc.contentType, tc.patch, tc.ifMatch)
+			assert.Equal(t, tc.status, rec.Code)
+		})
+	}
+	assert.Equal(t, "Golang", get(node.ID).DisplayName)
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bihe/bookmarks/internal/favicon"
+	"github.com/bihe/bookmarks/internal/metadata"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+// swagger:operation POST /api/v1/bookmarks/preview bookmarks PreviewPage
+//
+// get the metadata of a page
+//
+// the page is fetched and the title, description, canonical URL, image and language are extracted.
+// the result is used to prefill a new bookmark
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: url
+//   in: query
+// responses:
+//   '200':
+//     description: PagePreview
+//     schema:
+//       "$ref": "#/definitions/PagePreview"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) PreviewPage(user security.User, w http.ResponseWriter, r *http.Request) error {
+	url := r.URL.Query().Get("url")
+
+	if url == "" {
+		return errors.BadRequestError{Err: fmt.Errorf("missing url parameter"), Request: r}
+	}
+
+	handler.LogFunction("api.PreviewPage").Debugf("get the metadata of page '%s'", url)
+
+	client, err := b.fetcher()
+	if err != nil {
+		handler.LogFunction("api.PreviewPage").Errorf("cannot create a client to fetch the page: %v", err)
+		return errors.ServerError{Err: fmt.Errorf("could not fetch the page: %v", err), Request: r}
+	}
+	meta, err := metadata.Fetch(client, url)
+	if err != nil {
+		handler.LogFunction("api.PreviewPage").Warnf("cannot fetch the metadata of page '%s': %v", url, err)
+		return errors.BadRequestError{Err: fmt.Errorf("could not fetch the page '%s'", url), Request: r}
+	}
+
+	return render.Render(w, r, PagePreviewResponse{
+		PagePreview: &PagePreview{
+			URL:          meta.URL,
+			Title:        meta.Title,
+			Description:  meta.Description,
+			CanonicalURL: meta.CanonicalURL,
+			ImageURL:     meta.ImageURL,
+			Language:     meta.Language,
+			SiteName:     meta.SiteName,
+			OpenGraph:    meta.OpenGraph,
+			Twitter:      meta.Twitter,
+		},
+	})
+}
+
+// fetchMetadata retrieves the metadata of the bookmark page in the background. The favicon is fetched
+// from the same page if the bookmark has none
+func (b *BookmarksAPI) fetchMetadata(bm store.Bookmark, user security.User) {
+	client, err := b.fetcher()
+	if err != nil {
+		handler.LogFunction("api.fetchMetadata").Errorf("cannot create a client to fetch the page: %v", err)
+		return
+	}
+	meta, err := metadata.Fetch(client, bm.URL)
+	if err != nil {
+		handler.LogFunction("api.fetchMetadata").Warnf("cannot fetch the metadata of page '%s': %v", bm.URL, err)
+		// the favicon might still be available
+		meta = &metadata.Metadata{URL: bm.URL}
+	} else if err := b.setMetadata(bm.ID, user.Username, metadataToEntity(meta)); err != nil {
+		handler.LogFunction("api.fetchMetadata").Errorf("could not update bookmark with metadata: %v", err)
+	}
+
+	if bm.Favicon != "" {
+		return
+	}
+	_, payload, err := favicon.GetFaviconFromMetadata(client, meta)
+	if err != nil {
+		handler.LogFunction("api.fetchMetadata").Errorf("cannot fetch favicon from URL '%s': %v", bm.URL, err)
+		return
+	}
+	name, err := b.favicons().Save(payload)
+	if err != nil {
+		handler.LogFunction("api.fetchMetadata").Errorf("cannot save favicon of URL '%s': %v", bm.URL, err)
+		return
+	}
+	if err := b.setFavicon(bm.ID, user.Username, name, ""); err != nil {
+		handler.LogFunction("api.fetchMetadata").Errorf("could not update bookmark with favicon '%s': %v", name, err)
+	}
+}
+
+// setMetadata updates the metadata of the bookmark, values which are already available are kept
+func (b *BookmarksAPI) setMetadata(id, username string, m store.PageMetadata) error {
+	return b.inUnitOfWork(func(repo store.Repository) error {
+		bm, err := repo.GetBookmarkById(id, username)
+		if err != nil {
+			return err
+		}
+		before := bm
+		bm.Metadata = mergeMetadata(before.Metadata, m)
+		if bm.Metadata == before.Metadata {
+			return nil
+		}
+		updated, err := repo.Update(bm)
+		if err != nil {
+			return err
+		}
+		// the metadata is fetched in the background, no request is available
+		return store.LogChange(repo, "", store.ChangeUpdate, &before, &updated)
+	})
+}
+
+// mergeMetadata keeps the available values and fills the missing ones
+func mergeMetadata(m, other store.PageMetadata) store.PageMetadata {
+	return store.PageMetadata{
+		Title:        firstNonEmpty(m.Title, other.Title),
+		Description:  firstNonEmpty(m.Description, other.Description),
+		CanonicalURL: firstNonEmpty(m.CanonicalURL, other.CanonicalURL),
+		ImageURL:     firstNonEmpty(m.ImageURL, other.ImageURL),
+		Language:     firstNonEmpty(m.Language, other.Language),
+		SiteName:     firstNonEmpty(m.SiteName, other.SiteName),
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/events"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func previewServer() *httptest.Server {
+	ico, _ := ioutil.ReadFile("../../../assets/favicon.ico")
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/favicon.ico":
+			w.Header().Add("content-type", "image/x-icon")
+			w.Write(ico)
+		case "/page":
+			w.Header().Add("content-type", "text/html")
+			w.Write([]byte(`<html lang="en"><head>
+                <title>The Page</title>
+                <meta name="description" content="Description">
+                <meta property="og:image" content="/preview.png">
+                <meta property="og:type" content="article">
+                <meta name="twitter:card" content="summary">
+                <link rel="canonical" href="/canonical">
+            </head></html>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestPreviewPage(t *testing.T) {
+	ts := previewServer()
+	defer ts.Close()
+
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Post("/preview", bookmarkAPI.Secure(bookmarkAPI.PreviewPage))
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/preview?url="+url.QueryEscape(ts.URL+"/page"), nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var preview PagePreview
+	if err := json.Unmarshal(rec.Body.Bytes(), &preview); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, ts.URL+"/page", preview.URL)
+	assert.Equal(t, "The Page", preview.Title)
+	assert.Equal(t, "Description", preview.Description)
+	assert.Equal(t, ts.URL+"/canonical", preview.CanonicalURL)
+	assert.Equal(t, ts.URL+"/preview.png", preview.ImageURL)
+	assert.Equal(t, "en", preview.Language)
+	assert.Equal(t, "article", preview.OpenGraph["type"])
+	assert.Equal(t, "summary", preview.Twitter["card"])
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/preview", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/preview?url="+url.QueryEscape(ts.URL+"/missing"), nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCreateFetchesMetadata(t *testing.T) {
+	ts := previewServer()
+	defer ts.Close()
+
+	repo, db := repository(t)
+	defer db.Close()
+	// the metadata is stored in background
+	db.DB().SetMaxOpenConns(1)
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	bookmarkAPI.Events = events.NewBus(10)
+	r.Post("/", bookmarkAPI.Secure(bookmarkAPI.Create))
+
+	// the supplied metadata is stored
+	payload := `{"path": "/", "displayName": "Supplied", "url": "` + ts.URL + `/page", "type": "Node", "favicon": "favicon.ico", "description": "Mine"}`
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(payload))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var result Result
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	bm, _ := repo.GetBookmarkById(result.Value, userName)
+	assert.Equal(t, "Mine", bm.Metadata.Description)
+
+	// the metadata and the favicon are fetched in the background, the favicon is stored after the metadata
+	sub, _, _ := bookmarkAPI.Events.Subscribe(userName, 0)
+	defer sub.Close()
+	payload = `{"path": "/", "displayName": "Fetched", "url": "` + ts.URL + `/page", "type": "Node"}`
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/", strings.NewReader(payload))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+
+	for e := range sub.Events() {
+		if e.Type == events.FaviconUpdated && e.Data.(BookmarkEvent).ID == result.Value {
+			break
+		}
+	}
+	bm, _ = repo.GetBookmarkById(result.Value, userName)
+	assert.Equal(t, store.PageMetadata{
+		Title:        "The Page",
+		Description:  "Description",
+		CanonicalURL: ts.URL + "/canonical",
+		ImageURL:     ts.URL + "/preview.png",
+		Language:     "en",
+	}, bm.Metadata)
+	assert.NotEqual(t, "", bm.Favicon)
+}
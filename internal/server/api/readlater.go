@@ -0,0 +1,223 @@
+package api
+
+import (
+	er "errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+// swagger:operation GET /api/v1/bookmarks/readlater bookmarks GetReadLater
+//
+// get the read-later bookmarks
+//
+// return the bookmarks of the read-later queue, the oldest first. only unread bookmarks are returned,
+// unless the state 'all' is requested
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: state
+//   in: query
+//   description: unread (default) or all
+// responses:
+//   '200':
+//     description: BookmarkList
+//     schema:
+//       "$ref": "#/definitions/BookmarkList"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetReadLater(user security.User, w http.ResponseWriter, r *http.Request) error {
+	var unreadOnly bool
+	switch state := r.URL.Query().Get("state"); state {
+	case "", "unread":
+		unreadOnly = true
+	case "all":
+		unreadOnly = false
+	default:
+		return errors.BadRequestError{Err: fmt.Errorf("invalid state '%s'", state), Request: r}
+	}
+
+	handler.LogFunction("api.GetReadLater").Debugf("get the read-later bookmarks for user: '%s'", user.Username)
+
+	bms, err := b.Repository.GetReadLater(user.Username, unreadOnly)
+	if err != nil {
+		handler.LogFunction("api.GetReadLater").Errorf("cannot get the read-later bookmarks: '%v'", err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the read-later bookmarks: %v", err), Request: r}
+	}
+	bookmarks := entityListToModel(bms)
+	count := len(bookmarks)
+	return render.Render(w, r, BookmarkListResponse{BookmarkList: &BookmarkList{
+		Success: true,
+		Count:   count,
+		Message: fmt.Sprintf("Found %d items.", count),
+		Value:   bookmarks,
+	}})
+}
+
+// swagger:operation POST /api/v1/bookmarks/readlater/actions bookmarks ReadLaterActions
+//
+// apply an action to read-later bookmarks
+//
+// mark the given bookmarks as read or unread, or archive them. archived bookmarks are removed from the
+// read-later queue and moved to the path, if one is supplied
+//
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: Result
+//     schema:
+//       "$ref": "#/definitions/Result"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '500':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) ReadLaterActions(user security.User, w http.ResponseWriter, r *http.Request) error {
+	payload := &ReadLaterActionRequest{}
+	if err := render.Bind(r, payload); err != nil {
+		handler.LogFunction("api.ReadLaterActions").Warnf("cannot bind payload: '%v'", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid request data supplied"), Request: r}
+	}
+	if len(payload.IDs) == 0 {
+		return errors.BadRequestError{Err: fmt.Errorf("no bookmarks supplied"), Request: r}
+	}
+
+	handler.LogFunction("api.ReadLaterActions").Debugf("apply action '%s' to %d bookmarks", payload.Action, len(payload.IDs))
+
+	var (
+		count int
+		err   error
+	)
+	switch payload.Action {
+	case ReadLaterRead, ReadLaterUnread:
+		count, err = b.markRead(payload.IDs, payload.Action == ReadLaterRead, user.Username, r)
+	case ReadLaterArchive:
+		count, err = b.archiveReadLater(payload.IDs, payload.Path, user.Username, r)
+	default:
+		return errors.BadRequestError{Err: fmt.Errorf("invalid action '%s'", payload.Action), Request: r}
+	}
+
+	if err != nil {
+		handler.LogFunction("api.ReadLaterActions").Errorf("could not apply action '%s': %v", payload.Action, err)
+		var badRequest errors.BadRequestError
+		if er.As(err, &badRequest) {
+			return badRequest
+		}
+		return errors.ServerError{Err: fmt.Errorf("error applying action '%s': %v", payload.Action, err), Request: r}
+	}
+
+	return render.Render(w, r, ResultResponse{
+		Result: &Result{
+			Success: true,
+			Message: fmt.Sprintf("Applied action '%s' to %d bookmarks", payload.Action, count),
+			Value:   fmt.Sprintf("%d", count),
+		},
+		Status: http.StatusOK,
+	})
+}
+
+// readLaterNode returns the bookmark if it is part of the read-later queue
+func readLaterNode(id, username string, repo store.Repository, r *http.Request) (store.Bookmark, error) {
+	existing, err := repo.GetBookmarkById(id, username)
+	if err != nil {
+		return store.Bookmark{}, errors.BadRequestError{Err: fmt.Errorf("could not find bookmark with ID '%s'", id), Request: r}
+	}
+	if existing.Type != store.Node || !existing.ReadLater {
+		return store.Bookmark{}, errors.BadRequestError{Err: fmt.Errorf("the bookmark '%s' is not in the read-later queue", id), Request: r}
+	}
+	return existing, nil
+}
+
+// markRead changes the read state of the given read-later bookmarks
+func (b *BookmarksAPI) markRead(ids []string, read bool, username string, r *http.Request) (int, error) {
+	var count int
+	err := b.inUnitOfWork(func(repo store.Repository) error {
+		for _, id := range ids {
+			existing, err := readLaterNode(id, username, repo, r)
+			if err != nil {
+				return err
+			}
+			updated, err := repo.SetRead(id, username, read)
+			if err != nil {
+				return err
+			}
+			if err := store.LogChange(repo, requestID(r), store.ChangeUpdate, &existing, &updated); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// archiveReadLater removes the given bookmarks from the read-later queue. if a path is supplied the bookmarks
+// are moved and the child-count of the affected folders is updated
+func (b *BookmarksAPI) archiveReadLater(ids []string, path, username string, r *http.Request) (int, error) {
+	var count int
+	err := b.inUnitOfWork(func(repo store.Repository) error {
+		paths := make(map[string]bool)
+		if path != "" {
+			paths[path] = true
+		}
+		for _, id := range ids {
+			existing, err := readLaterNode(id, username, repo, r)
+			if err != nil {
+				return err
+			}
+			before := existing
+			existing.ReadLater = false
+			if path != "" {
+				paths[existing.Path] = true
+				existing.Path = path
+			}
+			updated, err := repo.Update(existing)
+			if err != nil {
+				return errors.BadRequestError{Err: fmt.Errorf("could not archive bookmark '%s': %v", id, err), Request: r}
+			}
+			if err := store.LogChange(repo, requestID(r), store.ChangeUpdate, &before, &updated); err != nil {
+				return err
+			}
+			count++
+		}
+		for p := range paths {
+			if err := repo.UpdateChildCountOfPath(p, username); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return count, err
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadLater(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	// the pages of created bookmarks are processed in background
+	db.DB().SetMaxOpenConns(1)
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Post("/", bookmarkAPI.Secure(bookmarkAPI.Create))
+	r.Get("/readlater", bookmarkAPI.Secure(bookmarkAPI.GetReadLater))
+	r.Post("/readlater/actions", bookmarkAPI.Secure(bookmarkAPI.ReadLaterActions))
+
+	repo.Create(store.Bookmark{DisplayName: "Archive", Path: "/", Type: store.Folder, UserName: userName})
+	plain, _ := repo.Create(store.Bookmark{DisplayName: "Plain", Path: "/", Type: store.Node, URL: "http://plain", UserName: userName, Favicon: "favicon.ico"})
+
+	post := func(url, payload string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", url, strings.NewReader(payload))
+		req.Header.Add("Content-Type", "application/json")
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	list := func(state string) []Bookmark {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/readlater?state="+state, nil)
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var bookmarks BookmarkList
+		if err := json.Unmarshal(rec.Body.Bytes(), &bookmarks); err != nil {
+			t.Errorf("could not unmarshal body: %v", err)
+		}
+		return bookmarks.Value
+	}
+
+	rec := post("/", `{"path": "/", "displayName": "Article", "type": "Node", "url": "http://article", "readLater": true}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var result Result
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	id := result.Value
+
+	unread := list("")
+	assert.Equal(t, 1, len(unread))
+	assert.Equal(t, "Article", unread[0].DisplayName)
+	assert.True(t, unread[0].ReadLater)
+	assert.Nil(t, unread[0].ReadAt)
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/readlater?state=other", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// only read-later bookmarks are accepted
+	assert.Equal(t, http.StatusBadRequest, post("/readlater/actions", `{"action": "read", "ids": ["`+plain.ID+`"]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/readlater/actions", `{"action": "other", "ids": ["`+id+`"]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/readlater/actions", `{"action": "read", "ids": []}`).Code)
+
+	assert.Equal(t, http.StatusOK, post("/readlater/actions", `{"action": "read", "ids": ["`+id+`"]}`).Code)
+	assert.Equal(t, 0, len(list("unread")))
+	all := list("all")
+	assert.Equal(t, 1, len(all))
+	assert.NotNil(t, all[0].ReadAt)
+
+	assert.Equal(t, http.StatusOK, post("/readlater/actions", `{"action": "unread", "ids": ["`+id+`"]}`).Code)
+	assert.Equal(t, 1, len(list("unread")))
+
+	assert.Equal(t, http.StatusOK, post("/readlater/actions", `{"action": "archive", "ids": ["`+id+`"], "path": "/Archive"}`).Code)
+	assert.Equal(t, 0, len(list("all")))
+	archived, _ := repo.GetBookmarkById(id, userName)
+	assert.False(t, archived.ReadLater)
+	assert.Equal(t, "/Archive", archived.Path)
+	folder, _ := repo.GetFolderByPath("/Archive", userName)
+	assert.Equal(t, 1, folder.ChildCount)
+}
+
+func TestReadLaterMarkReadOnOpen(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Get("/fetch/{id}", bookmarkAPI.Secure(bookmarkAPI.FetchAndForward))
+
+	bm, _ := repo.Create(store.Bookmark{DisplayName: "Article", Path: "/", Type: store.Node, URL: "http://article", ReadLater: true, UserName: userName, Favicon: "favicon.ico"})
+
+	fetch := func() {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/fetch/"+bm.ID, nil)
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusFound, rec.Code)
+	}
+
+	fetch()
+	existing, _ := repo.GetBookmarkById(bm.ID, userName)
+	assert.Nil(t, existing.ReadAt)
+
+	if _, err := repo.SaveUserSettings(store.UserSettings{UserName: userName, MarkReadOnOpen: true}); err != nil {
+		t.Fatalf("could not save the settings: %v", err)
+	}
+	fetch()
+	existing, _ = repo.GetBookmarkById(bm.ID, userName)
+	assert.NotNil(t, existing.ReadAt)
+
+	// the change is logged
+	changes, err := repo.GetChanges(bm.ID, userName, 10)
+	if err != nil {
+		t.Fatalf("could not get the changes: %v", err)
+	}
+	assert.Equal(t, 1, len(changes))
+	assert.Equal(t, store.ChangeUpdate, changes[0].Operation)
+}
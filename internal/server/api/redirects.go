@@ -0,0 +1,335 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	er "errors"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+// swagger:operation GET /api/v1/bookmarks/suggestions bookmarks GetURLSuggestions
+//
+// get the suggested URL updates
+//
+// the URLs of the listed bookmarks permanently redirect to a new location
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: URLSuggestionList
+//     schema:
+//       "$ref": "#/definitions/URLSuggestionList"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetURLSuggestions(user security.User, w http.ResponseWriter, r *http.Request) error {
+	handler.LogFunction("api.GetURLSuggestions").Debugf("get URL suggestions for user: '%s'", user.Username)
+
+	suggestions, err := b.Repository.GetURLSuggestions(user.Username)
+	if err != nil {
+		handler.LogFunction("api.GetURLSuggestions").Errorf("cannot get the URL suggestions: %v", err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the URL suggestions: %v", err), Request: r}
+	}
+
+	ids := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		ids = append(ids, s.BookmarkID)
+	}
+	bookmarks, err := b.Repository.GetBookmarksByIds(ids, user.Username)
+	if err != nil {
+		handler.LogFunction("api.GetURLSuggestions").Errorf("cannot get bookmarks by IDs: %v", err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the bookmarks: %v", err), Request: r}
+	}
+	lookup := make(map[string]store.Bookmark)
+	for _, bm := range bookmarks {
+		lookup[bm.ID] = bm
+	}
+
+	result := make([]URLSuggestion, 0, len(suggestions))
+	for _, s := range suggestions {
+		bm, ok := lookup[s.BookmarkID]
+		// the suggestion is outdated if the URL was changed in the meantime
+		if !ok || bm.URL != s.URL {
+			continue
+		}
+		result = append(result, URLSuggestion{
+			Bookmark:     *entityToModel(bm),
+			SuggestedURL: s.SuggestedURL,
+			StatusCode:   s.StatusCode,
+			Detected:     s.Detected,
+		})
+	}
+
+	return render.Render(w, r, URLSuggestionListResponse{
+		URLSuggestionList: &URLSuggestionList{
+			Success: true,
+			Count:   len(result),
+			Message: fmt.Sprintf("Found %d URL suggestions", len(result)),
+			Value:   result,
+		},
+	})
+}
+
+// swagger:operation POST /api/v1/bookmarks/suggestions/apply bookmarks ApplyURLSuggestions
+//
+// apply suggested URL updates
+//
+// the URLs of the given bookmarks are replaced by the suggested URLs, the previous URLs are kept in the history
+//
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: Result
+//     schema:
+//       "$ref": "#/definitions/Result"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) ApplyURLSuggestions(user security.User, w http.ResponseWriter, r *http.Request) error {
+	payload := &BookmarkIDsRequest{}
+	if err := render.Bind(r, payload); err != nil {
+		handler.LogFunction("api.ApplyURLSuggestions").Warnf("cannot bind payload: '%v'", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid request data supplied"), Request: r}
+	}
+	if len(payload.IDs) == 0 {
+		return errors.BadRequestError{Err: fmt.Errorf("no bookmarks supplied"), Request: r}
+	}
+
+	handler.LogFunction("api.ApplyURLSuggestions").Debugf("apply URL suggestions of %d bookmarks", len(payload.IDs))
+
+	var count int
+	if err := b.inUnitOfWork(func(repo store.Repository) error {
+		suggestions, err := repo.GetURLSuggestions(user.Username)
+		if err != nil {
+			return err
+		}
+		lookup := make(map[string]store.URLSuggestion)
+		for _, s := range suggestions {
+			lookup[s.BookmarkID] = s
+		}
+
+		for _, id := range payload.IDs {
+			s, ok := lookup[id]
+			if !ok {
+				return errors.BadRequestError{Err: fmt.Errorf("no URL suggestion available for bookmark '%s'", id), Request: r}
+			}
+			before, err := repo.GetBookmarkById(id, user.Username)
+			if err != nil {
+				return err
+			}
+			updated, err := repo.UpdateURL(id, user.Username, s.SuggestedURL, store.URLChangeRedirect)
+			if err != nil {
+				return err
+			}
+			if err := store.LogChange(repo, requestID(r), store.ChangeUpdate, &before, &updated); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	}); err != nil {
+		handler.LogFunction("api.ApplyURLSuggestions").Errorf("could not apply the URL suggestions: %v", err)
+		var badRequest errors.BadRequestError
+		if er.As(err, &badRequest) {
+			return badRequest
+		}
+		return errors.ServerError{Err: fmt.Errorf("error applying the URL suggestions: %v", err), Request: r}
+	}
+
+	return render.Render(w, r, ResultResponse{
+		Result: &Result{
+			Success: true,
+			Message: fmt.Sprintf("Updated the URL of %d bookmarks", count),
+			Value:   fmt.Sprintf("%d", count),
+		},
+	})
+}
+
+// swagger:operation GET /api/v1/bookmarks/{id}/urlhistory bookmarks GetURLHistory
+//
+// get the URL history of a bookmark
+//
+// returns the previous URLs of the bookmark, the most recent change first
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+// responses:
+//   '200':
+//     description: URLHistory
+//     schema:
+//       "$ref": "#/definitions/URLHistory"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetURLHistory(user security.User, w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+
+	if id == "" {
+		return errors.BadRequestError{Err: fmt.Errorf("missing id parameter"), Request: r}
+	}
+
+	if _, err := b.Repository.GetBookmarkById(id, user.Username); err != nil {
+		handler.LogFunction("api.GetURLHistory").Warnf("could not find bookmark by id '%s': %v", id, err)
+		return errors.NotFoundError{Err: fmt.Errorf("could not find bookmark with ID '%s'", id), Request: r}
+	}
+
+	entries, err := b.Repository.GetURLHistory(id)
+	if err != nil {
+		handler.LogFunction("api.GetURLHistory").Errorf("cannot get the URL history of bookmark '%s': %v", id, err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the URL history: %v", err), Request: r}
+	}
+
+	history := make([]URLChange, 0, len(entries))
+	for _, e := range entries {
+		history = append(history, urlHistoryToModel(e))
+	}
+
+	return render.Render(w, r, URLHistoryResponse{
+		URLHistory: &URLHistory{
+			Success: true,
+			Count:   len(history),
+			Message: fmt.Sprintf("Found %d URL changes", len(history)),
+			Value:   history,
+		},
+	})
+}
+
+// swagger:operation GET /api/v1/settings settings GetUserSettings
+//
+// get the settings of the user
+//
+// returns the preferences of the user, the defaults are returned if nothing was saved yet
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: UserSettings
+//     schema:
+//       "$ref": "#/definitions/UserSettings"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetUserSettings(user security.User, w http.ResponseWriter, r *http.Request) error {
+	settings, err := b.Repository.GetUserSettings(user.Username)
+	if err != nil {
+		handler.LogFunction("api.GetUserSettings").Errorf("cannot get the settings of user '%s': %v", user.Username, err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the settings: %v", err), Request: r}
+	}
+	return render.Render(w, r, UserSettingsResponse{
+		UserSettings: userSettingsToModel(settings),
+	})
+}
+
+// swagger:operation PUT /api/v1/settings settings UpdateUserSettings
+//
+// change the settings of the user
+//
+// the settings are replaced by the supplied values, the folder for expired read bookmarks has to exist
+//
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: UserSettings
+//     schema:
+//       "$ref": "#/definitions/UserSettings"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) UpdateUserSettings(user security.User, w http.ResponseWriter, r *http.Request) error {
+	payload := &UserSettingsRequest{}
+	if err := render.Bind(r, payload); err != nil {
+		handler.LogFunction("api.UpdateUserSettings").Warnf("cannot bind payload: '%v'", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid request data supplied"), Request: r}
+	}
+
+	handler.LogFunction("api.UpdateUserSettings").Debugf("update settings of user '%s': %+v", user.Username, *payload.UserSettings)
+
+	if payload.ReadLaterExpiryDays < 0 {
+		return errors.BadRequestError{Err: fmt.Errorf("invalid number of days '%d'", payload.ReadLaterExpiryDays), Request: r}
+	}
+	if p := payload.ReadLaterExpiryPath; p != "" && p != "/" {
+		if _, err := b.Repository.GetFolderByPath(p, user.Username); err != nil {
+			handler.LogFunction("api.UpdateUserSettings").Warnf("the expiry path '%s' is not available: %v", p, err)
+			return errors.BadRequestError{Err: fmt.Errorf("the folder '%s' for read bookmarks is not available", p), Request: r}
+		}
+	}
+
+	settings, err := b.Repository.SaveUserSettings(store.UserSettings{
+		UserName:            user.Username,
+		AutoApplyRedirects:  payload.AutoApplyRedirects,
+		MarkReadOnOpen:      payload.MarkReadOnOpen,
+		ReadLaterExpiryDays: payload.ReadLaterExpiryDays,
+		ReadLaterExpiryPath: payload.ReadLaterExpiryPath,
+	})
+	if err != nil {
+		handler.LogFunction("api.UpdateUserSettings").Errorf("cannot save the settings of user '%s': %v", user.Username, err)
+		return errors.ServerError{Err: fmt.Errorf("could not save the settings: %v", err), Request: r}
+	}
+	return render.Render(w, r, UserSettingsResponse{
+		UserSettings: userSettingsToModel(settings),
+	})
+}
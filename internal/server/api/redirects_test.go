@@ -0,0 +1,218 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURLSuggestions(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	bookmarkAPI := &BookmarksAPI{
+		Handler:    baseHandler,
+		Repository: repo,
+	}
+	r := chi.NewRouter()
+	r.Use(jwtUser)
+	r.Get("/suggestions", bookmarkAPI.Secure(bookmarkAPI.GetURLSuggestions))
+	r.Post("/suggestions/apply", bookmarkAPI.Secure(bookmarkAPI.ApplyURLSuggestions))
+	r.Get("/{id}/urlhistory", bookmarkAPI.Secure(bookmarkAPI.GetURLHistory))
+
+	var ids []string
+	for _, name := range []string{"wiki", "repo", "changed"} {
+		bm, err := repo.Create(store.Bookmark{
+			DisplayName: name,
+			Path:        "/",
+			Type:        store.Node,
+			URL:         "http://old/" + name,
+			UserName:    userName,
+		})
+		if err != nil {
+			t.Fatalf("could not create bookmark: %v", err)
+		}
+		ids = append(ids, bm.ID)
+		repo.SaveURLSuggestion(store.URLSuggestion{
+			BookmarkID:   bm.ID,
+			UserName:     userName,
+			URL:          bm.URL,
+			SuggestedURL: "http://new/" + name,
+			StatusCode:   http.StatusMovedPermanently,
+		})
+	}
+	// the URL was changed after the suggestion was made
+	repo.InUnitOfWork(func(repo store.Repository) error {
+		bm, _ := repo.GetBookmarkById(ids[2], userName)
+		bm.URL = "http://manual"
+		_, err := repo.Update(bm)
+		return err
+	})
+
+	// list
+	// ---------------------------------------------------------------
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/suggestions", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var list URLSuggestionList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 2, list.Count)
+	for _, s := range list.Value {
+		assert.Equal(t, "http://new/"+s.Bookmark.DisplayName, s.SuggestedURL)
+		assert.Equal(t, http.StatusMovedPermanently, s.StatusCode)
+	}
+
+	// apply
+	// ---------------------------------------------------------------
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/suggestions/apply", strings.NewReader(`{"ids": []}`))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/suggestions/apply", strings.NewReader(`{"ids": ["`+ids[0]+`", "unknown"]}`))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	// nothing is applied if one of the suggestions is not available
+	bm, _ := repo.GetBookmarkById(ids[0], userName)
+	assert.Equal(t, "http://old/wiki", bm.URL)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/suggestions/apply", strings.NewReader(`{"ids": ["`+ids[0]+`", "`+ids[1]+`"]}`))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	bm, _ = repo.GetBookmarkById(ids[0], userName)
+	assert.Equal(t, "http://new/wiki", bm.URL)
+	bm, _ = repo.GetBookmarkById(ids[1], userName)
+	assert.Equal(t, "http://new/repo", bm.URL)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/suggestions", nil)
+	r.ServeHTTP(rec, req)
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 0, list.Count)
+
+	// history
+	// ---------------------------------------------------------------
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/"+ids[0]+"/urlhistory", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var history URLHistory
+	if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 1, history.Count)
+	assert.Equal(t, "http://old/wiki", history.Value[0].OldURL)
+	assert.Equal(t, "http://new/wiki", history.Value[0].NewURL)
+	assert.Equal(t, string(store.URLChangeRedirect), history.Value[0].Reason)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/unknown/urlhistory", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestUserSettings(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	bookmarkAPI := &BookmarksAPI{
+		Handler:    baseHandler,
+		Repository: repo,
+	}
+	r := chi.NewRouter()
+	r.Use(jwtUser)
+	r.Get("/settings", bookmarkAPI.Secure(bookmarkAPI.GetUserSettings))
+	r.Put("/settings", bookmarkAPI.Secure(bookmarkAPI.UpdateUserSettings))
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/settings", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var settings UserSettings
+	if err := json.Unmarshal(rec.Body.Bytes(), &settings); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.False(t, settings.AutoApplyRedirects)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/settings", strings.NewReader(`{"autoApplyRedirects": true}`))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/settings", nil)
+	r.ServeHTTP(rec, req)
+	if err := json.Unmarshal(rec.Body.Bytes(), &settings); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.True(t, settings.AutoApplyRedirects)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/settings", strings.NewReader(`invalid`))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestUpdateKeepsURLHistory(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	bookmarkAPI := &BookmarksAPI{
+		Handler:    baseHandler,
+		Repository: repo,
+	}
+	r := chi.NewRouter()
+	r.Use(jwtUser)
+	r.Put("/", bookmarkAPI.Secure(bookmarkAPI.Update))
+
+	bm, err := repo.Create(store.Bookmark{
+		DisplayName: "node",
+		Path:        "/",
+		Type:        store.Node,
+		URL:         "http://old",
+		UserName:    userName,
+		Favicon:     "favicon.ico",
+	})
+	if err != nil {
+		t.Fatalf("could not create bookmark: %v", err)
+	}
+	repo.SaveURLSuggestion(store.URLSuggestion{BookmarkID: bm.ID, UserName: userName, URL: bm.URL, SuggestedURL: "http://moved"})
+
+	payload := `{"id": "` + bm.ID + `", "path": "/", "displayName": "node", "url": "http://new", "type": "Node", "favicon": "favicon.ico"}`
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/", strings.NewReader(payload))
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("If-Match", bookmarkETag(bm.Version))
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	history, _ := repo.GetURLHistory(bm.ID)
+	assert.Equal(t, 1, len(history))
+	assert.Equal(t, store.URLChangeManual, history[0].Reason)
+	assert.Equal(t, "http://old", history[0].OldURL)
+
+	suggestions, _ := repo.GetURLSuggestions(userName)
+	assert.Equal(t, 0, len(suggestions))
+}
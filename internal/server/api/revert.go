@@ -0,0 +1,160 @@
+package api
+
+import (
+	er "errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bihe/bookmarks/internal/revert"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+// swagger:operation POST /api/v1/bookmarks/revert bookmarks RevertChanges
+//
+// revert changes of bookmarks
+//
+// revert the last operation, the changes of a given request or all changes after a given time. the bookmarks
+// are restored to the state before the changes in one transaction. bookmarks changed later prevent the revert,
+// they are returned as conflicts. the steps of the revert are returned without changes for a dry-run
+//
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: RevertResult
+//     schema:
+//       "$ref": "#/definitions/RevertResult"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '409':
+//     description: RevertResult
+//     schema:
+//       "$ref": "#/definitions/RevertResult"
+//   '500':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) RevertChanges(user security.User, w http.ResponseWriter, r *http.Request) error {
+	payload := &RevertRequest{}
+	if err := render.Bind(r, payload); err != nil {
+		handler.LogFunction("api.RevertChanges").Warnf("cannot bind payload: '%v'", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid request data supplied"), Request: r}
+	}
+	if payload.RequestID != "" && payload.Until != nil {
+		return errors.BadRequestError{Err: fmt.Errorf("either the request or the time can be supplied"), Request: r}
+	}
+
+	handler.LogFunction("api.RevertChanges").Debugf("revert changes of user '%s', dry-run: %t", user.Username, payload.DryRun)
+
+	var (
+		plan    revert.Plan
+		missing []store.Bookmark
+	)
+	if err := b.inUnitOfWork(func(repo store.Repository) error {
+		changes, err := changesToRevert(repo, payload.Revert, user.Username)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			return errors.BadRequestError{Err: fmt.Errorf("no changes available to revert"), Request: r}
+		}
+		if plan, err = revert.NewPlan(repo, user.Username, changes); err != nil {
+			return err
+		}
+		if payload.DryRun || len(plan.Conflicts) > 0 {
+			return nil
+		}
+		missing = b.withoutMissingFavicons(plan)
+		return revert.Apply(repo, plan, requestID(r))
+	}); err != nil {
+		handler.LogFunction("api.RevertChanges").Errorf("could not revert the changes: %v", err)
+		var badRequest errors.BadRequestError
+		if er.As(err, &badRequest) {
+			return badRequest
+		}
+		return errors.ServerError{Err: fmt.Errorf("error reverting the changes: %v", err), Request: r}
+	}
+
+	steps, conflicts := revertPlanToModel(plan)
+	result := &RevertResult{
+		Success:   len(conflicts) == 0,
+		Steps:     steps,
+		Conflicts: conflicts,
+	}
+	status := http.StatusOK
+	switch {
+	case len(conflicts) > 0:
+		result.Message = fmt.Sprintf("The changes cannot be reverted because of %d conflicts", len(conflicts))
+		status = http.StatusConflict
+	case payload.DryRun:
+		result.Message = fmt.Sprintf("Reverting the changes affects %d bookmarks", len(steps))
+	default:
+		result.Applied = true
+		result.Message = fmt.Sprintf("Reverted the changes of %d bookmarks", len(steps))
+		for _, s := range plan.Steps {
+			if s.Operation == store.ChangeDelete {
+				b.releaseFavicon(s.Current.Favicon)
+			}
+		}
+		for _, bm := range missing {
+			// fire&forget, fetch the favicon of the restored bookmark in background
+			go b.fetchFavicon(bm, user)
+		}
+	}
+	return render.Render(w, r, RevertResultResponse{RevertResult: result, Status: status})
+}
+
+// withoutMissingFavicons removes the favicons which are no longer available from the reverted states, e.g. the
+// favicon of a deleted bookmark is released. the bookmarks are returned to fetch their favicons again
+func (b *BookmarksAPI) withoutMissingFavicons(plan revert.Plan) []store.Bookmark {
+	var missing []store.Bookmark
+	for i, s := range plan.Steps {
+		if s.Target == nil || s.Target.Favicon == "" || b.faviconAvailable(s.Target.Favicon) {
+			continue
+		}
+		target := *s.Target
+		target.Favicon = ""
+		plan.Steps[i].Target = &target
+		if target.Type == store.Node && target.URL != "" {
+			missing = append(missing, target)
+		}
+	}
+	return missing
+}
+
+// changesToRevert returns the changes selected by the payload, the most recent change first. the last operation
+// are the changes of the request of the most recent change
+func changesToRevert(repo store.Repository, payload *Revert, username string) ([]store.ChangeLog, error) {
+	switch {
+	case payload.RequestID != "":
+		return repo.GetRequestChanges(username, payload.RequestID)
+	case payload.Until != nil:
+		return repo.GetChangesSince(username, payload.Until.UTC())
+	}
+	last, err := repo.GetUserChanges(username, 1)
+	if err != nil || len(last) == 0 {
+		return last, err
+	}
+	// background changes have no request, only the single change is reverted
+	if last[0].RequestID == "" {
+		return last, nil
+	}
+	return repo.GetRequestChanges(username, last[0].RequestID)
+}
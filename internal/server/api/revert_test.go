@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/favicon"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevertChanges(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, _, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+
+	r := newChangeRouter(bookmarkAPI)
+
+	send := func(method, url, payload string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(payload))
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("If-Match", "*")
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	revert := func(payload string, status int) RevertResult {
+		rec := send("POST", "/revert", payload)
+		assert.Equal(t, status, rec.Code)
+		var result RevertResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Errorf("could not unmarshal body: %v", err)
+		}
+		return result
+	}
+	get := func(id string) store.Bookmark {
+		bm, err := repo.GetBookmarkById(id, userName)
+		if err != nil {
+			t.Fatalf("cannot get bookmark '%s': %v", id, err)
+		}
+		return bm
+	}
+
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/revert", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/revert", `{"requestId": "x", "until": "2020-01-01T00:00:00Z"}`).Code)
+
+	folder, _ := repo.Create(store.Bookmark{DisplayName: "Work", Path: "/", Type: store.Folder, UserName: userName})
+	child, _ := repo.Create(store.Bookmark{DisplayName: "Go", Path: "/Work", Type: store.Node, URL: "http://go", UserName: userName, Favicon: "favicon.ico"})
+	assert.Equal(t, http.StatusOK, send("PUT", "/", `{"id": "`+folder.ID+`", "path": "/", "displayName": "Job"}`).Code)
+	assert.Equal(t, "/Job", get(child.ID).Path)
+
+	// the dry-run lists the steps without changes
+	result := revert(`{"dryRun": true}`, http.StatusOK)
+	assert.False(t, result.Applied)
+	assert.Equal(t, 2, len(result.Steps))
+	assert.Equal(t, "/Job", get(child.ID).Path)
+
+	result = revert(`{}`, http.StatusOK)
+	assert.True(t, result.Applied)
+	assert.Equal(t, "Work", get(folder.ID).DisplayName)
+	assert.Equal(t, "/Work", get(child.ID).Path)
+	assert.Equal(t, 1, get(folder.ID).ChildCount)
+
+	// the revert is the last operation, reverting it restores the renamed folder
+	revert(`{}`, http.StatusOK)
+	assert.Equal(t, "Job", get(folder.ID).DisplayName)
+	assert.Equal(t, "/Job", get(child.ID).Path)
+	redo, _ := repo.GetUserChanges(userName, 1)
+
+	// a later change of the bookmark prevents the revert
+	assert.Equal(t, http.StatusOK, send("PUT", "/", `{"id": "`+child.ID+`", "path": "/Job", "displayName": "Golang", "url": "http://go", "favicon": "favicon.ico"}`).Code)
+	result = revert(`{"requestId": "`+redo[0].RequestID+`"}`, http.StatusConflict)
+	assert.False(t, result.Applied)
+	// the folder cannot be renamed as well, the changed bookmark would remain in it
+	assert.Equal(t, 2, len(result.Conflicts))
+	assert.Equal(t, child.ID, result.Conflicts[0].EntityID)
+	assert.Equal(t, folder.ID, result.Conflicts[1].EntityID)
+	assert.Equal(t, "Job", get(folder.ID).DisplayName)
+
+	// a deleted bookmark is restored with its ID
+	assert.Equal(t, http.StatusOK, send("DELETE", "/"+child.ID, "").Code)
+	assert.Equal(t, 0, get(folder.ID).ChildCount)
+	result = revert(`{}`, http.StatusOK)
+	assert.Equal(t, "create", result.Steps[0].Operation)
+	restored := get(child.ID)
+	assert.Equal(t, "Golang", restored.DisplayName)
+	assert.Equal(t, "/Job", restored.Path)
+	assert.Equal(t, 1, get(folder.ID).ChildCount)
+	// the favicon was released on delete, it is fetched again
+	assert.Equal(t, "", restored.Favicon)
+
+	// an available favicon is kept
+	payload, err := ioutil.ReadFile(bookmarkAPI.DefaultFavicon)
+	if err != nil {
+		t.Fatalf("could not read favicon: %v", err)
+	}
+	name, err := favicon.NewStore(bookmarkAPI.FaviconPath).Save(payload)
+	if err != nil {
+		t.Fatalf("could not save favicon: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, send("PUT", "/", `{"id": "`+child.ID+`", "path": "/Job", "displayName": "Golang", "url": "http://go", "favicon": "`+name+`"}`).Code)
+	assert.Equal(t, http.StatusOK, send("DELETE", "/"+child.ID, "").Code)
+	revert(`{}`, http.StatusOK)
+	assert.Equal(t, name, get(child.ID).Favicon)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"github.com/bihe/bookmarks/internal/search"
+	"github.com/bihe/bookmarks/internal/store"
+	"golang.binggl.net/commons/handler"
+)
+
+// the maximum number of bookmarks found by the page content
+const maxContentResults = 50
+
+// searchContent adds the bookmarks whose page content matches the query to the results of the
+// name search. the snippets of the matching text are returned by the ID of the bookmarks
+func (b *BookmarksAPI) searchContent(bookmarks []store.Bookmark, query, username string) ([]store.Bookmark, map[string]string) {
+	snippets := make(map[string]string)
+	matches, err := search.Search(b.Repository, query, username, maxContentResults)
+	if err != nil {
+		handler.LogFunction("api.searchContent").Warnf("cannot search the page contents for '%s': %v", query, err)
+		return bookmarks, snippets
+	}
+	if len(matches) == 0 {
+		return bookmarks, snippets
+	}
+
+	var ids []string
+	for _, m := range matches {
+		snippets[m.BookmarkID] = m.Snippet
+		ids = append(ids, m.BookmarkID)
+	}
+	included := make(map[string]bool)
+	for _, bm := range bookmarks {
+		included[bm.ID] = true
+	}
+
+	found, err := b.Repository.GetBookmarksByIds(ids, username)
+	if err != nil {
+		handler.LogFunction("api.searchContent").Warnf("cannot get the bookmarks of the content search: %v", err)
+		return bookmarks, snippets
+	}
+	byID := make(map[string]store.Bookmark)
+	for _, bm := range found {
+		byID[bm.ID] = bm
+	}
+	// content matches follow the name matches in the order of their score
+	for _, id := range ids {
+		bm, exists := byID[id]
+		if included[id] || !exists {
+			continue
+		}
+		bookmarks = append(bookmarks, bm)
+	}
+	return bookmarks, snippets
+}
+
+// indexPage adds the page of the bookmark to the full-text index
+func (b *BookmarksAPI) indexPage(bm store.Bookmark) {
+	indexer, err := b.indexer()
+	if err != nil {
+		handler.LogFunction("api.indexPage").Errorf("cannot create the indexer: %v", err)
+		return
+	}
+	if err := indexer.Index(bm); err != nil {
+		handler.LogFunction("api.indexPage").Warnf("cannot index the page '%s': %v", bm.URL, err)
+	}
+}
+
+// indexer returns the configured indexer or an indexer with the default settings
+func (b *BookmarksAPI) indexer() (*search.Indexer, error) {
+	if b.Indexer != nil {
+		return b.Indexer, nil
+	}
+	client, err := b.fetcher()
+	if err != nil {
+		return nil, err
+	}
+	return &search.Indexer{
+		Fetcher:    client,
+		Repository: b.Repository,
+	}, nil
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/search"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchContent(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Get("/byname", bookmarkAPI.Secure(bookmarkAPI.GetBookmarksByName))
+
+	byName, _ := repo.Create(store.Bookmark{DisplayName: "Gopher news", Path: "/", Type: store.Node, URL: "http://news", UserName: userName})
+	byContent, _ := repo.Create(store.Bookmark{DisplayName: "Blog", Path: "/", Type: store.Node, URL: "http://blog", UserName: userName})
+	repo.Create(store.Bookmark{DisplayName: "Other", Path: "/", Type: store.Node, URL: "http://other", UserName: userName})
+
+	for _, c := range []store.PageContent{
+		{BookmarkID: byName.ID, UserName: userName, URL: byName.URL, Content: "Weekly news about the gopher community."},
+		{BookmarkID: byContent.ID, UserName: userName, URL: byContent.URL, Content: "How to feed your gopher.\nGophers like carrots."},
+	} {
+		if err := repo.SaveContent(c, search.Terms(c.Content)); err != nil {
+			t.Fatalf("could not save content: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/byname?name=gopher", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var list BookmarkList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	// the name matches are followed by the content matches
+	assert.Equal(t, 2, list.Count)
+	assert.Equal(t, byName.ID, list.Value[0].ID)
+	assert.Equal(t, "Weekly news about the gopher community.", list.Value[0].Snippet)
+	assert.Equal(t, byContent.ID, list.Value[1].ID)
+	assert.Equal(t, "How to feed your gopher. Gophers like carrots.", list.Value[1].Snippet)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/byname?name=gopher&content=false", nil)
+	r.ServeHTTP(rec, req)
+	list = BookmarkList{}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	assert.Equal(t, 1, list.Count)
+	assert.Equal(t, "", list.Value[0].Snippet)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/byname?name=CARROTS", nil)
+	r.ServeHTTP(rec, req)
+	list = BookmarkList{}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	assert.Equal(t, 1, list.Count)
+	assert.Equal(t, byContent.ID, list.Value[0].ID)
+}
+
+func TestUpdateReindexesPage(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("content-type", "text/html")
+		w.Write([]byte(`<html><head><title>Moved</title></head><body><p>The new page</p></body></html>`))
+	}))
+	defer ts.Close()
+
+	repo, db := repository(t)
+	defer db.Close()
+	// the page is indexed in the background, a second connection would open a different in-memory database
+	db.DB().SetMaxOpenConns(1)
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Put("/", bookmarkAPI.Secure(bookmarkAPI.Update))
+
+	bm, _ := repo.Create(store.Bookmark{DisplayName: "Page", Path: "/", Type: store.Node, URL: "http://old", UserName: userName, Favicon: "favicon.ico"})
+	repo.SaveContent(store.PageContent{BookmarkID: bm.ID, UserName: userName, URL: bm.URL, Content: "old page"}, map[string]int{"old": 1, "page": 1})
+
+	payload := `{"id": "` + bm.ID + `", "path": "/", "displayName": "Page", "url": "` + ts.URL + `", "type": "Node", "favicon": "favicon.ico"}`
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/", strings.NewReader(payload))
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("If-Match", bookmarkETag(bm.Version))
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// the content of the old URL is removed, the new page is indexed in the background
+	matches, _ := repo.SearchContent([]string{"old"}, userName, 10)
+	assert.Equal(t, 0, len(matches))
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if matches, _ = repo.SearchContent([]string{"new"}, userName, 10); len(matches) > 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Equal(t, 1, len(matches))
+}
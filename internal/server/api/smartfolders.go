@@ -0,0 +1,43 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/smartfolder"
+	"github.com/bihe/bookmarks/internal/store"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+)
+
+// listSmartFolder returns the bookmarks of the smart folder with the given path. if the path is no
+// smart folder, found is false
+func (b *BookmarksAPI) listSmartFolder(path, username string) (bookmarks []store.Bookmark, found bool, err error) {
+	if path == "/" {
+		return nil, false, nil
+	}
+	folder, err := b.Repository.GetSmartFolderByPath(path, username)
+	if err != nil {
+		return nil, false, nil
+	}
+	bookmarks, err = smartfolder.Evaluate(b.Repository, folder, time.Now().UTC())
+	if err != nil {
+		handler.LogFunction("api.listSmartFolder").Warnf("cannot evaluate the smart folder '%s': %v", path, err)
+		return nil, true, err
+	}
+	return bookmarks, true, nil
+}
+
+// ensureNoSmartFolder prevents the creation of child-elements in a smart folder, the content of a smart
+// folder is determined by its query
+func ensureNoSmartFolder(repo store.Repository, path, username string, r *http.Request) error {
+	if path == "/" {
+		return nil
+	}
+	if _, err := repo.GetSmartFolderByPath(path, username); err == nil {
+		handler.LogFunction("api.ensureNoSmartFolder").Warnf("cannot add items to the smart folder '%s'", path)
+		return errors.BadRequestError{Err: fmt.Errorf("cannot add items to the smart folder '%s'", path), Request: r}
+	}
+	return nil
+}
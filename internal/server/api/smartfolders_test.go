@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSmartFolders(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Post("/", bookmarkAPI.Secure(bookmarkAPI.Create))
+	r.Put("/", bookmarkAPI.Secure(bookmarkAPI.Update))
+	r.Get("/bypath", bookmarkAPI.Secure(bookmarkAPI.GetBookmarksByPath))
+	r.Get("/folder", bookmarkAPI.Secure(bookmarkAPI.GetBookmarksFolderByPath))
+
+	work, _ := repo.Create(store.Bookmark{DisplayName: "Work", Path: "/", Type: store.Folder, UserName: userName})
+	repo.Create(store.Bookmark{DisplayName: "Go", Path: "/Work", Type: store.Node, URL: "https://github.com/golang/go", UserName: userName, Favicon: "favicon.ico"})
+	repo.Create(store.Bookmark{DisplayName: "Gist", Path: "/", Type: store.Node, URL: "https://gist.github.com/x", UserName: userName, Favicon: "favicon.ico"})
+	repo.Create(store.Bookmark{DisplayName: "News", Path: "/", Type: store.Node, URL: "https://news.example.com", UserName: userName, Favicon: "favicon.ico"})
+
+	post := func(method, payload string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/", strings.NewReader(payload))
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("If-Match", "*")
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusBadRequest, post("POST", `{"path": "/Work", "displayName": "Smart", "type": "SmartFolder", "query": ""}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("POST", `{"path": "/Work", "displayName": "Smart", "type": "SmartFolder", "query": "tag:urgent"}`).Code)
+
+	rec := post("POST", `{"path": "/Work", "displayName": "GitHub", "type": "SmartFolder", "query": "domain:github.com", "url": "http://ignored"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var result Result
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	smart, err := repo.GetBookmarkById(result.Value, userName)
+	if err != nil {
+		t.Fatalf("could not get the smart folder: %v", err)
+	}
+	assert.Equal(t, store.SmartFolder, smart.Type)
+	assert.Equal(t, "", smart.URL)
+	assert.Equal(t, "domain:github.com", smart.Query)
+
+	// the smart folder cannot have children
+	assert.Equal(t, http.StatusBadRequest, post("POST", `{"path": "/Work/GitHub", "displayName": "Child", "type": "Node", "url": "http://child"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("PUT", `{"id": "`+work.ID+`", "path": "/Work/GitHub", "displayName": "Work"}`).Code)
+
+	list := func(path string) []string {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/bypath?path="+path, nil)
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var bookmarks BookmarkList
+		if err := json.Unmarshal(rec.Body.Bytes(), &bookmarks); err != nil {
+			t.Errorf("could not unmarshal body: %v", err)
+		}
+		var names []string
+		for _, bm := range bookmarks.Value {
+			names = append(names, bm.DisplayName)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"Gist", "Go"}, list("/Work/GitHub"))
+	assert.ElementsMatch(t, []string{"Go", "GitHub"}, list("/Work"))
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/folder?path=/Work/GitHub", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var folder BookmarkResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &folder); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, SmartFolder, folder.Value.Type)
+	assert.Equal(t, "domain:github.com", folder.Value.Query)
+
+	// the query is changed with the smart folder, renaming the parent keeps it
+	assert.Equal(t, http.StatusBadRequest, post("PUT", `{"id": "`+smart.ID+`", "path": "/Work", "displayName": "GitHub", "query": "unvisited:x"}`).Code)
+	assert.Equal(t, http.StatusOK, post("PUT", `{"id": "`+smart.ID+`", "path": "/Work", "displayName": "Example", "query": "domain:example.com"}`).Code)
+	assert.Equal(t, http.StatusOK, post("PUT", `{"id": "`+work.ID+`", "path": "/", "displayName": "Job"}`).Code)
+	assert.Equal(t, []string{"News"}, list("/Job/Example"))
+}
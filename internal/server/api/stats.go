@@ -0,0 +1,89 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+const (
+	// the number of entries of the statistic lists if no limit is given
+	defaultStatisticsLimit = 10
+	// the maximum number of entries of the statistic lists
+	maxStatisticsLimit = 100
+	// bookmarks not visited for this number of days are stale, if no other value is given
+	defaultStaleDays = 180
+)
+
+// swagger:operation GET /api/v1/bookmarks/stats bookmarks GetStatistics
+//
+// get statistics of the bookmarks
+//
+// return the number of bookmarks and folders, the deepest paths, the biggest folders, the top domains,
+// the bookmarks never visited or not visited for some time, the growth per month and the visits per
+// weekday and hour
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: limit
+//   in: query
+//   description: the maximum number of entries of the lists, defaults to 10
+// - name: staleDays
+//   in: query
+//   description: bookmarks not visited for this number of days are stale, defaults to 180
+// responses:
+//   '200':
+//     description: BookmarkStatistics
+//     schema:
+//       "$ref": "#/definitions/BookmarkStatistics"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetStatistics(user security.User, w http.ResponseWriter, r *http.Request) error {
+	limit := defaultStatisticsLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > maxStatisticsLimit {
+			return errors.BadRequestError{Err: fmt.Errorf("invalid limit '%s'", l), Request: r}
+		}
+		limit = n
+	}
+	staleDays := defaultStaleDays
+	if d := r.URL.Query().Get("staleDays"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n <= 0 {
+			return errors.BadRequestError{Err: fmt.Errorf("invalid number of days '%s'", d), Request: r}
+		}
+		staleDays = n
+	}
+
+	handler.LogFunction("api.GetStatistics").Debugf("get the statistics of the bookmarks for user: '%s'", user.Username)
+
+	stats, err := b.Repository.GetStatistics(user.Username, store.StatisticsOptions{
+		Limit:       limit,
+		StaleBefore: time.Now().UTC().AddDate(0, 0, -staleDays),
+	})
+	if err != nil {
+		handler.LogFunction("api.GetStatistics").Errorf("cannot get the statistics: %v", err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the statistics: %v", err), Request: r}
+	}
+	return render.Render(w, r, BookmarkStatisticsResponse{BookmarkStatistics: statisticsToModel(stats)})
+}
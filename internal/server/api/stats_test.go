@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatistics(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Get("/stats", bookmarkAPI.Secure(bookmarkAPI.GetStatistics))
+
+	repo.Create(store.Bookmark{DisplayName: "Folder", Path: "/", Type: store.Folder, UserName: userName})
+	repo.Create(store.Bookmark{DisplayName: "A", Path: "/Folder", Type: store.Node, URL: "https://example.com/a", UserName: userName})
+	visited, _ := repo.Create(store.Bookmark{DisplayName: "B", Path: "/", Type: store.Node, URL: "https://example.com/b", UserName: userName, AccessCount: 1})
+	// Wednesday, 08:00 UTC
+	repo.AddVisit(store.Visit{BookmarkID: visited.ID, UserName: userName, Visited: time.Date(2020, 5, 6, 8, 0, 0, 0, time.UTC)})
+
+	for _, url := range []string{"/stats?limit=0", "/stats?limit=1000", "/stats?staleDays=x"} {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, url)
+	}
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/stats?staleDays=30", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var stats BookmarkStatistics
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 2, stats.Nodes)
+	assert.Equal(t, 1, stats.Folders)
+	assert.Equal(t, []PathDepth{{Path: "/Folder", Depth: 1}, {Path: "/", Depth: 0}}, stats.DeepestPaths)
+	assert.Equal(t, []FolderSize{{Path: "/Folder", ChildCount: 1}}, stats.BiggestFolders)
+	assert.Equal(t, []DomainCount{{Domain: "example.com", Count: 2}}, stats.TopDomains)
+	assert.Equal(t, 1, stats.NeverVisited.Count)
+	assert.Equal(t, "A", stats.NeverVisited.Value[0].DisplayName)
+	assert.Equal(t, 1, stats.Stale.Count)
+	assert.Equal(t, visited.ID, stats.Stale.Value[0].ID)
+	assert.Equal(t, []MonthlyGrowth{{Month: time.Now().UTC().Format("2006-01"), Count: 2, Total: 2}}, stats.Growth)
+	assert.Equal(t, 7, len(stats.VisitHeatmap))
+	assert.Equal(t, 24, len(stats.VisitHeatmap[0]))
+	assert.Equal(t, 1, stats.VisitHeatmap[3][8])
+}
@@ -0,0 +1,543 @@
+package api
+
+import (
+	er "errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/smartfolder"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+// swagger:operation GET /api/v1/sync sync GetSyncChanges
+//
+// get the changes since the last sync
+//
+// return the bookmarks which were created, updated or deleted after the given token, together with the token for
+// the next sync. without a token all bookmarks are returned as created
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: since
+//   in: query
+// responses:
+//   '200':
+//     description: SyncDelta
+//     schema:
+//       "$ref": "#/definitions/SyncDelta"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '500':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetSyncChanges(user security.User, w http.ResponseWriter, r *http.Request) error {
+	since, err := parseSyncToken(r.URL.Query().Get("since"))
+	if err != nil {
+		return errors.BadRequestError{Err: err, Request: r}
+	}
+
+	handler.LogFunction("api.GetSyncChanges").Debugf("get the changes of user '%s' since '%d'", user.Username, since)
+
+	var (
+		token      int64
+		bookmarks  []store.Bookmark
+		tombstones []store.Tombstone
+	)
+	if err := b.inUnitOfWork(func(repo store.Repository) error {
+		var err error
+		if token, err = repo.GetSequence(user.Username); err != nil {
+			return err
+		}
+		if since > token {
+			return errors.BadRequestError{Err: fmt.Errorf("the token '%d' is unknown", since), Request: r}
+		}
+		// the first sync returns all bookmarks, also the bookmarks which were not changed since the sequence exists
+		if since == 0 {
+			bookmarks, err = repo.GetAllBookmarks(user.Username)
+			return err
+		}
+		if bookmarks, err = repo.GetChangedBookmarks(user.Username, since); err != nil {
+			return err
+		}
+		tombstones, err = repo.GetTombstones(user.Username, since)
+		return err
+	}); err != nil {
+		handler.LogFunction("api.GetSyncChanges").Errorf("cannot get the changes of user '%s': %v", user.Username, err)
+		var badRequest errors.BadRequestError
+		if er.As(err, &badRequest) {
+			return badRequest
+		}
+		return errors.ServerError{Err: fmt.Errorf("could not get the changes: %v", err), Request: r}
+	}
+
+	delta := &SyncDelta{
+		Success: true,
+		Token:   formatSyncToken(token),
+		Full:    since == 0,
+		Created: make([]Bookmark, 0),
+		Updated: make([]Bookmark, 0),
+		Deleted: make([]SyncTombstone, 0, len(tombstones)),
+	}
+	for _, bm := range bookmarks {
+		if since == 0 || bm.CreatedSequence > since {
+			delta.Created = append(delta.Created, *entityToModel(bm))
+		} else {
+			delta.Updated = append(delta.Updated, *entityToModel(bm))
+		}
+	}
+	for _, t := range tombstones {
+		delta.Deleted = append(delta.Deleted, SyncTombstone{ID: t.BookmarkID, Deleted: t.Deleted})
+	}
+	delta.Message = fmt.Sprintf("Found %d created, %d updated and %d deleted bookmarks",
+		len(delta.Created), len(delta.Updated), len(delta.Deleted))
+	return render.Render(w, r, SyncDeltaResponse{SyncDelta: delta})
+}
+
+// swagger:operation POST /api/v1/sync sync ApplySyncChanges
+//
+// apply the changes of a client
+//
+// the changes are applied in one transaction. bookmarks changed on the server after the token of the client are
+// conflicts: the changes are skipped and returned as conflicts, or the newer change is kept for the strategy
+// lastWriterWins. the returned token includes the applied changes
+//
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: SyncResult
+//     schema:
+//       "$ref": "#/definitions/SyncResult"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '500':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) ApplySyncChanges(user security.User, w http.ResponseWriter, r *http.Request) error {
+	payload := &SyncBatchRequest{}
+	if err := render.Bind(r, payload); err != nil {
+		handler.LogFunction("api.ApplySyncChanges").Warnf("cannot bind payload: '%v'", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid request data supplied"), Request: r}
+	}
+	if payload.Strategy != SyncReport && payload.Strategy != SyncLastWriterWins {
+		return errors.BadRequestError{Err: fmt.Errorf("invalid strategy '%s'", payload.Strategy), Request: r}
+	}
+	base, err := parseSyncToken(payload.Token)
+	if err != nil {
+		return errors.BadRequestError{Err: err, Request: r}
+	}
+
+	handler.LogFunction("api.ApplySyncChanges").Debugf("apply %d changes of user '%s'", len(payload.Changes), user.Username)
+
+	var (
+		batch *syncBatch
+		token int64
+	)
+	if err := b.inUnitOfWork(func(repo store.Repository) error {
+		start, err := repo.GetSequence(user.Username)
+		if err != nil {
+			return err
+		}
+		batch = &syncBatch{
+			repo:     repo,
+			r:        r,
+			username: user.Username,
+			base:     base,
+			start:    start,
+			strategy: payload.Strategy,
+			paths:    make(map[string]bool),
+			// the lists are returned also without changes
+			applied:   make([]SyncApplied, 0),
+			conflicts: make([]SyncConflict, 0),
+		}
+		for _, c := range payload.Changes {
+			if err := batch.apply(c); err != nil {
+				return err
+			}
+		}
+		if err := batch.updateChildCounts(); err != nil {
+			return err
+		}
+		token, err = repo.GetSequence(user.Username)
+		return err
+	}); err != nil {
+		handler.LogFunction("api.ApplySyncChanges").Errorf("could not apply the changes: %v", err)
+		var badRequest errors.BadRequestError
+		if er.As(err, &badRequest) {
+			return badRequest
+		}
+		return errors.ServerError{Err: fmt.Errorf("error applying the changes: %v", err), Request: r}
+	}
+
+	for _, name := range batch.released {
+		b.releaseFavicon(name)
+	}
+	for _, bm := range batch.created {
+		if bm.Type == store.Node && bm.URL != "" && bm.Metadata.IsEmpty() {
+			// fire&forget, run this in background and do not wait for the result
+			go b.fetchMetadata(bm, user)
+		}
+	}
+	for _, bm := range batch.reindex {
+		go b.indexPage(bm)
+	}
+
+	return render.Render(w, r, SyncResultResponse{
+		SyncResult: &SyncResult{
+			Success:   len(batch.conflicts) == 0,
+			Message:   fmt.Sprintf("Applied %d changes, %d conflicts", len(batch.applied), len(batch.conflicts)),
+			Token:     formatSyncToken(token),
+			Applied:   batch.applied,
+			Conflicts: batch.conflicts,
+		},
+	})
+}
+
+// syncBatch applies the changes of a client within a unit of work
+type syncBatch struct {
+	repo     store.Repository
+	r        *http.Request
+	username string
+	// base is the token of the client, start the sequence before the batch. bookmarks with a sequence between
+	// base and start were changed by others, later sequences are changes of this batch
+	base     int64
+	start    int64
+	strategy SyncStrategy
+	// paths holds the folders which need a new child-count
+	paths map[string]bool
+
+	applied   []SyncApplied
+	conflicts []SyncConflict
+	created   []store.Bookmark
+	reindex   []store.Bookmark
+	released  []string
+}
+
+func (s *syncBatch) apply(c SyncChange) error {
+	op := store.ChangeOperation(c.Operation)
+	id := c.ID
+	if id == "" && c.Bookmark != nil {
+		id = c.Bookmark.ID
+	}
+	switch op {
+	case store.ChangeCreate, store.ChangeUpdate:
+		if c.Bookmark == nil {
+			return s.badRequest(fmt.Errorf("the bookmark of the %s of '%s' is missing", op, id))
+		}
+		if c.Bookmark.Path == "" || c.Bookmark.DisplayName == "" {
+			return s.badRequest(fmt.Errorf("the path or the name of the bookmark '%s' is missing", id))
+		}
+	case store.ChangeDelete:
+	default:
+		return s.badRequest(fmt.Errorf("invalid operation '%s'", c.Operation))
+	}
+	if id == "" && op != store.ChangeCreate {
+		return s.badRequest(fmt.Errorf("the ID of the bookmark is missing for the %s", op))
+	}
+
+	var existing *store.Bookmark
+	if id != "" {
+		bms, err := s.repo.GetBookmarksByIds([]string{id}, s.username)
+		if err != nil {
+			return fmt.Errorf("cannot get bookmark '%s': %v", id, err)
+		}
+		if len(bms) > 0 {
+			existing = &bms[0]
+		}
+	}
+
+	switch {
+	case op == store.ChangeCreate && existing != nil:
+		s.conflict(c, id, "the bookmark already exists", existing)
+		return nil
+	case op == store.ChangeUpdate && existing == nil:
+		s.conflict(c, id, "the bookmark was deleted", nil)
+		return nil
+	case op == store.ChangeDelete && existing == nil:
+		// the bookmark was already deleted
+		s.applied = append(s.applied, SyncApplied{ID: id, Operation: c.Operation})
+		return nil
+	case existing != nil && existing.Sequence > s.base && existing.Sequence <= s.start:
+		if reason := s.resolve(c, *existing); reason != "" {
+			s.conflict(c, id, reason, existing)
+			return nil
+		}
+	}
+
+	switch op {
+	case store.ChangeCreate:
+		return s.create(c, id)
+	case store.ChangeUpdate:
+		return s.update(c, *existing)
+	}
+	return s.delete(c, *existing)
+}
+
+// resolve returns the reason of the conflict with the change on the server, without a reason the change of
+// the client is applied
+func (s *syncBatch) resolve(c SyncChange, existing store.Bookmark) string {
+	if s.strategy != SyncLastWriterWins {
+		return "the bookmark was changed on the server"
+	}
+	changed := existing.Created
+	if existing.Modified != nil {
+		changed = *existing.Modified
+	}
+	if !c.Modified.IsZero() && c.Modified.After(changed) {
+		return ""
+	}
+	return "the bookmark was changed on the server after the change of the client"
+}
+
+func (s *syncBatch) create(c SyncChange, id string) error {
+	m := *c.Bookmark
+	if id != "" {
+		// the ID is used by a bookmark of another user, the bookmark is not part of the conflict
+		taken, err := s.repo.IDExists(id)
+		if err != nil {
+			return fmt.Errorf("cannot check the ID '%s': %v", id, err)
+		}
+		if taken {
+			s.conflict(c, id, "the ID is not available", nil)
+			return nil
+		}
+	}
+	t := modelEnumToEntity(m.Type)
+	url, query := m.URL, ""
+	if t == store.SmartFolder {
+		if _, err := smartfolder.Parse(m.Query, time.Now().UTC()); err != nil {
+			return s.badRequest(fmt.Errorf("invalid query of smart folder '%s': %v", id, err))
+		}
+		url, query = "", m.Query
+	}
+	if ok, err := pathAvailable(s.repo, m.Path, s.username); err != nil || !ok {
+		if err == nil {
+			s.conflict(c, id, fmt.Sprintf("the folder '%s' is not available", m.Path), nil)
+		}
+		return err
+	}
+	if err := ensureNoSmartFolder(s.repo, m.Path, s.username, s.r); err != nil {
+		return err
+	}
+
+	item, err := s.repo.Create(store.Bookmark{
+		ID:          id,
+		DisplayName: m.DisplayName,
+		Path:        m.Path,
+		Type:        t,
+		URL:         url,
+		Query:       query,
+		ReadLater:   m.ReadLater && t == store.Node,
+		UserName:    s.username,
+		Favicon:     m.Favicon,
+		SortOrder:   m.SortOrder,
+		Metadata:    m.pageMetadata(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := store.LogChange(s.repo, requestID(s.r), store.ChangeCreate, nil, &item); err != nil {
+		return err
+	}
+	s.created = append(s.created, item)
+	if item.Type == store.Node && item.URL != "" {
+		s.reindex = append(s.reindex, item)
+	}
+	s.applied = append(s.applied, SyncApplied{ID: item.ID, Operation: c.Operation})
+	return nil
+}
+
+func (s *syncBatch) update(c SyncChange, existing store.Bookmark) error {
+	m := *c.Bookmark
+	oldPath := store.FolderPath(existing.Path, existing.DisplayName)
+	if existing.Type == store.Folder && (m.Path == oldPath || strings.HasPrefix(m.Path, oldPath+"/")) {
+		return s.badRequest(fmt.Errorf("cannot move folder '%s' into itself", oldPath))
+	}
+	url, query := m.URL, ""
+	if existing.Type == store.SmartFolder {
+		if _, err := smartfolder.Parse(m.Query, time.Now().UTC()); err != nil {
+			return s.badRequest(fmt.Errorf("invalid query of smart folder '%s': %v", existing.ID, err))
+		}
+		url, query = "", m.Query
+	}
+	if ok, err := pathAvailable(s.repo, m.Path, s.username); err != nil || !ok {
+		if err == nil {
+			s.conflict(c, existing.ID, fmt.Sprintf("the folder '%s' is not available", m.Path), &existing)
+		}
+		return err
+	}
+	if err := ensureNoSmartFolder(s.repo, m.Path, s.username, s.r); err != nil {
+		return err
+	}
+	// clients which do not keep the favicons do not change the favicon
+	favicon := m.Favicon
+	if favicon == "" {
+		favicon = existing.Favicon
+	}
+
+	// the counters and the metadata are maintained by the server
+	item, err := s.repo.Update(store.Bookmark{
+		ID:          existing.ID,
+		Created:     existing.Created,
+		DisplayName: m.DisplayName,
+		Path:        m.Path,
+		Type:        existing.Type,
+		URL:         url,
+		Query:       query,
+		ReadLater:   m.ReadLater && existing.Type == store.Node,
+		SortOrder:   m.SortOrder,
+		UserName:    s.username,
+		ChildCount:  existing.ChildCount,
+		Favicon:     favicon,
+		AccessCount: existing.AccessCount,
+		Metadata:    existing.Metadata,
+	})
+	if err != nil {
+		return err
+	}
+	if err := store.LogChange(s.repo, requestID(s.r), store.ChangeUpdate, &existing, &item); err != nil {
+		return err
+	}
+	if existing.Favicon != item.Favicon {
+		s.released = append(s.released, existing.Favicon)
+	}
+	if existing.Type == store.Node && existing.URL != item.URL {
+		if err := recordURLChange(s.repo, existing, item); err != nil {
+			return err
+		}
+		if item.URL != "" {
+			s.reindex = append(s.reindex, item)
+		}
+	}
+	if newPath := store.FolderPath(item.Path, item.DisplayName); existing.Type == store.Folder && newPath != oldPath {
+		if err := moveFolderContent(s.repo, requestID(s.r), oldPath, newPath, s.username); err != nil {
+			return err
+		}
+	}
+	if existing.Path != item.Path {
+		s.paths[existing.Path] = true
+		s.paths[item.Path] = true
+	}
+	s.applied = append(s.applied, SyncApplied{ID: item.ID, Operation: c.Operation})
+	return nil
+}
+
+func (s *syncBatch) delete(c SyncChange, existing store.Bookmark) error {
+	if existing.Type == store.Folder {
+		// the child-elements could have been moved by this batch, the child-count is updated at the end
+		counts, err := s.repo.GetPathChildCount(store.FolderPath(existing.Path, existing.DisplayName), s.username)
+		if err != nil {
+			return err
+		}
+		if len(counts) > 0 && counts[0].Count > 0 {
+			s.conflict(c, existing.ID, "the folder still contains bookmarks", &existing)
+			return nil
+		}
+	}
+	if err := s.repo.Delete(existing); err != nil {
+		return err
+	}
+	if err := store.LogChange(s.repo, requestID(s.r), store.ChangeDelete, &existing, nil); err != nil {
+		return err
+	}
+	s.released = append(s.released, existing.Favicon)
+	s.applied = append(s.applied, SyncApplied{ID: existing.ID, Operation: c.Operation})
+	return nil
+}
+
+// updateChildCounts counts the child-elements of the folders, which are the source or the destination of moved
+// bookmarks. folders deleted by the batch are skipped
+func (s *syncBatch) updateChildCounts() error {
+	for path := range s.paths {
+		if path == "/" {
+			continue
+		}
+		if _, err := s.repo.GetFolderByPath(path, s.username); err != nil {
+			continue
+		}
+		if err := s.repo.UpdateChildCountOfPath(path, s.username); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syncBatch) conflict(c SyncChange, id, reason string, server *store.Bookmark) {
+	conflict := SyncConflict{ID: id, Operation: c.Operation, Reason: reason}
+	if server != nil {
+		conflict.Server = entityToModel(*server)
+	}
+	s.conflicts = append(s.conflicts, conflict)
+}
+
+func (s *syncBatch) badRequest(err error) error {
+	return errors.BadRequestError{Err: err, Request: s.r}
+}
+
+// pathAvailable checks if the folder of the path exists, the root is always available
+func pathAvailable(repo store.Repository, path, username string) (bool, error) {
+	if path == "/" {
+		return true, nil
+	}
+	paths, err := repo.GetAllPaths(username)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range paths {
+		if p == path {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parseSyncToken reads the token of a sync, the token is the change sequence of the user. An empty token is
+// used for the first sync
+func parseSyncToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseInt(token, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, fmt.Errorf("invalid token '%s'", token)
+	}
+	return seq, nil
+}
+
+func formatSyncToken(seq int64) string {
+	return strconv.FormatInt(seq, 10)
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncChanges(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Get("/sync", bookmarkAPI.Secure(bookmarkAPI.GetSyncChanges))
+
+	repo.Create(store.Bookmark{DisplayName: "Work", Path: "/", Type: store.Folder, UserName: userName})
+	node, _ := repo.Create(store.Bookmark{DisplayName: "Go", Path: "/Work", Type: store.Node, URL: "http://go", UserName: userName})
+	removed, _ := repo.Create(store.Bookmark{DisplayName: "Old", Path: "/", Type: store.Node, URL: "http://old", UserName: userName})
+
+	sync := func(since string) SyncDelta {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/sync?since="+since, nil)
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var delta SyncDelta
+		if err := json.Unmarshal(rec.Body.Bytes(), &delta); err != nil {
+			t.Errorf("could not unmarshal body: %v", err)
+		}
+		return delta
+	}
+
+	full := sync("")
+	assert.True(t, full.Full)
+	assert.Equal(t, 3, len(full.Created))
+	assert.Equal(t, 0, len(full.Updated))
+	assert.Equal(t, 0, len(full.Deleted))
+
+	// nothing changed since the last sync
+	delta := sync(full.Token)
+	assert.False(t, delta.Full)
+	assert.Equal(t, full.Token, delta.Token)
+	assert.Equal(t, 0, len(delta.Created)+len(delta.Updated)+len(delta.Deleted))
+
+	node.DisplayName = "Golang"
+	repo.Update(node)
+	repo.Delete(removed)
+	added, _ := repo.Create(store.Bookmark{DisplayName: "New", Path: "/", Type: store.Node, URL: "http://new", UserName: userName})
+
+	delta = sync(full.Token)
+	assert.NotEqual(t, full.Token, delta.Token)
+	assert.Equal(t, 1, len(delta.Created))
+	assert.Equal(t, added.ID, delta.Created[0].ID)
+	assert.Equal(t, 1, len(delta.Updated))
+	assert.Equal(t, "Golang", delta.Updated[0].DisplayName)
+	assert.Equal(t, 1, len(delta.Deleted))
+	assert.Equal(t, removed.ID, delta.Deleted[0].ID)
+
+	for _, since := range []string{"x", "-1", "1000"} {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/sync?since="+since, nil)
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestApplySyncChanges(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	// the pages of created bookmarks are processed in background
+	db.DB().SetMaxOpenConns(1)
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Post("/sync", bookmarkAPI.Secure(bookmarkAPI.ApplySyncChanges))
+
+	folder, _ := repo.Create(store.Bookmark{DisplayName: "Work", Path: "/", Type: store.Folder, UserName: userName})
+	node, _ := repo.Create(store.Bookmark{DisplayName: "Go", Path: "/Work", Type: store.Node, URL: "http://localhost:1/go", UserName: userName})
+	token, _ := repo.GetSequence(userName)
+
+	apply := func(payload string) (int, SyncResult) {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/sync", strings.NewReader(payload))
+		req.Header.Add("Content-Type", "application/json")
+		r.ServeHTTP(rec, req)
+		var result SyncResult
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Errorf("could not unmarshal body: %v", err)
+			}
+		}
+		return rec.Code, result
+	}
+
+	code, result := apply(`{"token": "` + formatSyncToken(token) + `", "changes": [
+		{"operation": "create", "bookmark": {"id": "client-1", "path": "/Work", "displayName": "Docs", "type": "Node", "url": "http://localhost:1/docs"}},
+		{"operation": "update", "bookmark": {"id": "` + node.ID + `", "path": "/", "displayName": "Go", "type": "Node", "url": "http://localhost:1/go"}},
+		{"operation": "delete", "id": "unknown"}
+	]}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, result.Success)
+	assert.Equal(t, 3, len(result.Applied))
+	assert.Equal(t, "client-1", result.Applied[0].ID)
+	assert.Equal(t, 0, len(result.Conflicts))
+	seq, _ := repo.GetSequence(userName)
+	assert.Equal(t, formatSyncToken(seq), result.Token)
+
+	created, err := repo.GetBookmarkById("client-1", userName)
+	if err != nil {
+		t.Fatalf("the bookmark was not created: %v", err)
+	}
+	assert.Equal(t, "/Work", created.Path)
+	moved, _ := repo.GetBookmarkById(node.ID, userName)
+	assert.Equal(t, "/", moved.Path)
+	work, _ := repo.GetBookmarkById(folder.ID, userName)
+	assert.Equal(t, 1, work.ChildCount)
+
+	// the bookmark was changed on the server after the old token
+	code, result = apply(`{"token": "` + formatSyncToken(token) + `", "changes": [
+		{"operation": "update", "bookmark": {"id": "` + node.ID + `", "path": "/", "displayName": "Golang", "type": "Node"}},
+		{"operation": "create", "bookmark": {"id": "client-1", "path": "/", "displayName": "Docs", "type": "Node"}},
+		{"operation": "create", "bookmark": {"path": "/Missing", "displayName": "Lost", "type": "Node"}}
+	]}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.False(t, result.Success)
+	assert.Equal(t, 0, len(result.Applied))
+	assert.Equal(t, 3, len(result.Conflicts))
+	assert.Equal(t, "Go", result.Conflicts[0].Server.DisplayName)
+	assert.Equal(t, formatSyncToken(seq), result.Token)
+
+	// the newer change wins
+	older := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	newer := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	change := func(modified string) string {
+		return `{"token": "` + formatSyncToken(token) + `", "strategy": "lastWriterWins", "changes": [
+			{"operation": "update", "modified": "` + modified + `", "bookmark": {"id": "` + node.ID + `", "path": "/", "displayName": "Golang", "type": "Node", "url": "http://localhost:1/go"}}
+		]}`
+	}
+	_, result = apply(change(older))
+	assert.Equal(t, 1, len(result.Conflicts))
+	_, result = apply(change(newer))
+	assert.Equal(t, 1, len(result.Applied))
+	updated, _ := repo.GetBookmarkById(node.ID, userName)
+	assert.Equal(t, "Golang", updated.DisplayName)
+
+	// renaming the folder moves the child-elements, the folder with child-elements is not deleted
+	_, result = apply(`{"token": "` + result.Token + `", "changes": [
+		{"operation": "update", "bookmark": {"id": "` + folder.ID + `", "path": "/", "displayName": "Job", "type": "Folder"}},
+		{"operation": "delete", "id": "` + folder.ID + `"}
+	]}`)
+	assert.Equal(t, 1, len(result.Applied))
+	assert.Equal(t, 1, len(result.Conflicts))
+	created, _ = repo.GetBookmarkById("client-1", userName)
+	assert.Equal(t, "/Job", created.Path)
+
+	// the ID of another user is a conflict of the change
+	foreign, _ := repo.Create(store.Bookmark{DisplayName: "Other", Path: "/", Type: store.Node, URL: "http://localhost:1/other", UserName: "other"})
+	code, result = apply(`{"token": "` + result.Token + `", "changes": [
+		{"operation": "create", "bookmark": {"id": "` + foreign.ID + `", "path": "/", "displayName": "Docs", "type": "Node"}},
+		{"operation": "create", "bookmark": {"id": "client-2", "path": "/", "displayName": "Docs", "type": "Node"}}
+	]}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, len(result.Applied))
+	assert.Equal(t, "client-2", result.Applied[0].ID)
+	assert.Equal(t, 1, len(result.Conflicts))
+	assert.Equal(t, foreign.ID, result.Conflicts[0].ID)
+	assert.Nil(t, result.Conflicts[0].Server)
+	other, _ := repo.GetBookmarkById(foreign.ID, "other")
+	assert.Equal(t, "Other", other.DisplayName)
+
+	code, _ = apply(`{"changes": [{"operation": "rename", "id": "a"}]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = apply(`{"strategy": "other", "changes": []}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = apply(`{"token": "x", "changes": []}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = apply(`{"changes": [{"operation": "update", "id": "` + node.ID + `"}]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+// swagger:operation GET /api/v1/bookmarks/tree bookmarks GetBookmarkTree
+//
+// get the bookmark tree
+//
+// returns the folders and nodes below the path as nested structure, ordered by the sortorder. the depth limits
+// the levels of the tree, without a depth all levels are returned. foldersOnly omits the nodes, counts adds the
+// number of folders and nodes below every folder
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: path
+//   in: query
+// - name: depth
+//   in: query
+// - name: foldersOnly
+//   in: query
+// - name: counts
+//   in: query
+// responses:
+//   '200':
+//     description: BookmarkTree
+//     schema:
+//       "$ref": "#/definitions/BookmarkTree"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetBookmarkTree(user security.User, w http.ResponseWriter, r *http.Request) error {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		path = "/"
+	}
+	var depth int
+	if d := r.URL.Query().Get("depth"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 1 {
+			return errors.BadRequestError{Err: fmt.Errorf("invalid depth '%s'", d), Request: r}
+		}
+		depth = n
+	}
+	tree := bookmarkTree{
+		depth:       depth,
+		foldersOnly: r.URL.Query().Get("foldersOnly") == "true",
+		counts:      r.URL.Query().Get("counts") == "true",
+	}
+
+	handler.LogFunction("api.GetBookmarkTree").Debugf("get the tree of path '%s' for user '%s'", path, user.Username)
+
+	root := Bookmark{
+		DisplayName: "Root",
+		Path:        "/",
+		Type:        Folder,
+		ID:          fmt.Sprintf("%s_ROOT", user.Username),
+	}
+	if path != "/" {
+		folder, err := b.Repository.GetFolderByPath(path, user.Username)
+		if err != nil {
+			handler.LogFunction("api.GetBookmarkTree").Warnf("cannot get bookmark folder by path: '%s', %v", path, err)
+			return errors.NotFoundError{Err: fmt.Errorf("no folder for path '%s' found", path), Request: r}
+		}
+		root = *entityToModel(folder)
+	}
+
+	bookmarks, err := b.Repository.GetBookmarkTree(path, user.Username)
+	if err != nil {
+		handler.LogFunction("api.GetBookmarkTree").Errorf("cannot get the tree of path '%s': %v", path, err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the tree of path '%s'", path), Request: r}
+	}
+	tree.children = make(map[string][]store.Bookmark)
+	for _, bm := range bookmarks {
+		tree.children[bm.Path] = append(tree.children[bm.Path], bm)
+	}
+	if path == "/" {
+		root.ChildCount = len(tree.children[path])
+	}
+
+	value, _ := tree.folder(root, path, 0)
+	return render.Render(w, r, BookmarkTreeResponse{BookmarkTree: &BookmarkTree{
+		Success: true,
+		Count:   tree.count,
+		Message: fmt.Sprintf("Found %d items.", tree.count),
+		Value:   value,
+	}})
+}
+
+// bookmarkTree builds the nested structure of the bookmarks
+type bookmarkTree struct {
+	depth       int
+	foldersOnly bool
+	counts      bool
+	// children are the bookmarks grouped by the path, in the order of the query
+	children map[string][]store.Bookmark
+	// count is the number of elements in the tree
+	count int
+}
+
+// folder returns the folder with the child-elements of the path on the given level. the counts include all
+// levels, also the levels below the depth
+func (t *bookmarkTree) folder(folder Bookmark, path string, level int) (BookmarkTreeNode, TreeCounts) {
+	node := BookmarkTreeNode{Bookmark: folder}
+	var counts TreeCounts
+	include := t.depth == 0 || level < t.depth
+	for _, bm := range t.children[path] {
+		var child BookmarkTreeNode
+		if bm.Type == store.Folder {
+			var sub TreeCounts
+			child, sub = t.folder(*entityToModel(bm), store.FolderPath(bm.Path, bm.DisplayName), level+1)
+			counts.Folders += sub.Folders + 1
+			counts.Nodes += sub.Nodes
+		} else {
+			child = BookmarkTreeNode{Bookmark: *entityToModel(bm)}
+			if bm.Type == store.SmartFolder {
+				counts.Folders++
+			} else {
+				counts.Nodes++
+			}
+		}
+		if t.foldersOnly && bm.Type == store.Node {
+			continue
+		}
+		if !include {
+			node.Truncated = true
+			continue
+		}
+		node.Children = append(node.Children, child)
+		t.count++
+	}
+	if t.counts {
+		node.Counts = &TreeCounts{Folders: counts.Folders, Nodes: counts.Nodes}
+	}
+	return node, counts
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBookmarkTree(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Get("/tree", bookmarkAPI.Secure(bookmarkAPI.GetBookmarkTree))
+
+	for _, bm := range []store.Bookmark{
+		{DisplayName: "Work", Path: "/", Type: store.Folder, SortOrder: 1},
+		{DisplayName: "Home", Path: "/", Type: store.Folder, SortOrder: 2},
+		{DisplayName: "News", Path: "/", Type: store.Node, URL: "http://news"},
+		{DisplayName: "Go", Path: "/Work", Type: store.Node, URL: "http://go", SortOrder: 2},
+		{DisplayName: "Docs", Path: "/Work", Type: store.Folder, SortOrder: 1},
+		{DisplayName: "Spec", Path: "/Work/Docs", Type: store.Node, URL: "http://spec"},
+		{DisplayName: "Recent", Path: "/Work/Docs", Type: store.SmartFolder, Query: "added:7d"},
+	} {
+		bm.UserName = userName
+		if _, err := repo.Create(bm); err != nil {
+			t.Fatalf("could not create bookmark: %v", err)
+		}
+	}
+
+	tree := func(query string, status int) BookmarkTree {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/tree"+query, nil)
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, status, rec.Code)
+		var result BookmarkTree
+		if status == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatalf("could not unmarshal body: %v", err)
+			}
+		}
+		return result
+	}
+	names := func(nodes []BookmarkTreeNode) []string {
+		var result []string
+		for _, n := range nodes {
+			result = append(result, n.DisplayName)
+		}
+		return result
+	}
+
+	result := tree("", http.StatusOK)
+	assert.Equal(t, 7, result.Count)
+	assert.Equal(t, "/", result.Value.Path)
+	assert.Equal(t, []string{"News", "Work", "Home"}, names(result.Value.Children))
+	work := result.Value.Children[1]
+	assert.Equal(t, []string{"Docs", "Go"}, names(work.Children))
+	assert.Equal(t, []string{"Recent", "Spec"}, names(work.Children[0].Children))
+	assert.Nil(t, result.Value.Counts)
+
+	result = tree("?path=/Work&depth=1&counts=true", http.StatusOK)
+	assert.Equal(t, 2, result.Count)
+	assert.Equal(t, "Work", result.Value.DisplayName)
+	assert.Equal(t, []string{"Docs", "Go"}, names(result.Value.Children))
+	docs := result.Value.Children[0]
+	assert.True(t, docs.Truncated)
+	assert.Equal(t, 0, len(docs.Children))
+	assert.Equal(t, TreeCounts{Folders: 1, Nodes: 1}, *docs.Counts)
+	assert.Equal(t, TreeCounts{Folders: 2, Nodes: 2}, *result.Value.Counts)
+
+	// the counts include the nodes, which are not part of the tree
+	result = tree("?foldersOnly=true&counts=true", http.StatusOK)
+	assert.Equal(t, 4, result.Count)
+	assert.Equal(t, []string{"Work", "Home"}, names(result.Value.Children))
+	assert.Equal(t, []string{"Docs"}, names(result.Value.Children[0].Children))
+	assert.Equal(t, []string{"Recent"}, names(result.Value.Children[0].Children[0].Children))
+	assert.Equal(t, TreeCounts{Folders: 4, Nodes: 3}, *result.Value.Counts)
+
+	tree("?path=/Missing", http.StatusNotFound)
+	tree("?depth=0", http.StatusBadRequest)
+	tree("?depth=x", http.StatusBadRequest)
+}
@@ -1,12 +1,15 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/bihe/bookmarks/internal/metadata"
+	"github.com/bihe/bookmarks/internal/revert"
 	"github.com/bihe/bookmarks/internal/store"
 	"github.com/go-chi/render"
 )
@@ -20,8 +23,9 @@ import (
 type NodeType string
 
 const (
-	Node   NodeType = "Node"
-	Folder NodeType = "Folder"
+	Node        NodeType = "Node"
+	Folder      NodeType = "Folder"
+	SmartFolder NodeType = "SmartFolder"
 )
 
 // Bookmark is the model provided via the REST API
@@ -38,17 +42,95 @@ type Bookmark struct {
 	ChildCount  int        `json:"childCount"`
 	AccessCount int        `json:"accessCount"`
 	Favicon     string     `json:"favicon"`
+	// LastAccessed is the time of the most recent visit of the bookmark
+	LastAccessed *time.Time `json:"lastAccessed,omitempty"`
+	// Frecency is the score of the bookmark for the ranking by frequency and recency of the visits
+	Frecency float64 `json:"frecency,omitempty"`
+	// the metadata of the bookmark page
+	Title        string `json:"title,omitempty"`
+	Description  string `json:"description,omitempty"`
+	CanonicalURL string `json:"canonicalUrl,omitempty"`
+	ImageURL     string `json:"imageUrl,omitempty"`
+	Language     string `json:"language,omitempty"`
+	SiteName     string `json:"siteName,omitempty"`
+	// Snippet is the matching text of the page content for search results
+	Snippet string `json:"snippet,omitempty"`
+	// Query is the saved search of a smart folder
+	Query string `json:"query,omitempty"`
+	// ReadLater puts the bookmark into the read-later queue, ReadAt is the time it was marked as read
+	ReadLater bool       `json:"readLater,omitempty"`
+	ReadAt    *time.Time `json:"readAt,omitempty"`
+	// Version is changed with every modification, changes have to supply the version they are based on
+	Version int `json:"version"`
 }
 
-// BookmarkList is a collection of Bookmarks
+// PagePreview is the metadata of a page, which is used to prefill a new bookmark
+// swagger:model
+type PagePreview struct {
+	URL          string            `json:"url"`
+	Title        string            `json:"title"`
+	Description  string            `json:"description"`
+	CanonicalURL string            `json:"canonicalUrl"`
+	ImageURL     string            `json:"imageUrl"`
+	Language     string            `json:"language"`
+	SiteName     string            `json:"siteName"`
+	OpenGraph    map[string]string `json:"openGraph"`
+	Twitter      map[string]string `json:"twitter"`
+}
+
+// BookmarkList is a collection of Bookmarks. A paged list has the number of bookmarks of all pages as Total,
+// and the link to the next page as Next
 // swagger:model
 type BookmarkList struct {
 	Success bool       `json:"success"`
 	Count   int        `json:"count"`
+	Total   int        `json:"total,omitempty"`
+	Next    string     `json:"next,omitempty"`
 	Message string     `json:"message"`
 	Value   []Bookmark `json:"value"`
 }
 
+// BookmarkEvent is the change of a bookmark, sent by the event stream. Type is created, updated, moved, deleted
+// or favicon-updated. Bookmark is the changed bookmark, for deletions the deleted bookmark. PreviousPath is the
+// path of a moved bookmark before the move
+// swagger:model
+type BookmarkEvent struct {
+	Type         string    `json:"type"`
+	ID           string    `json:"id"`
+	Path         string    `json:"path"`
+	PreviousPath string    `json:"previousPath,omitempty"`
+	Bookmark     *Bookmark `json:"bookmark"`
+	// the owner of the bookmark, the event is only sent to the user
+	userName string
+}
+
+// TreeCounts is the number of folders and nodes below a folder, including all sub-folders
+// swagger:model
+type TreeCounts struct {
+	Folders int `json:"folders"`
+	Nodes   int `json:"nodes"`
+}
+
+// BookmarkTreeNode is an element of the bookmark tree with its child-elements. Truncated marks a folder with
+// child-elements below the requested depth, which are not included
+// swagger:model
+type BookmarkTreeNode struct {
+	Bookmark
+	Children  []BookmarkTreeNode `json:"children,omitempty"`
+	Truncated bool               `json:"truncated,omitempty"`
+	Counts    *TreeCounts        `json:"counts,omitempty"`
+}
+
+// BookmarkTree is the nested structure of the folders and nodes below a path. Count is the number of elements
+// of the tree
+// swagger:model
+type BookmarkTree struct {
+	Success bool             `json:"success"`
+	Count   int              `json:"count"`
+	Message string           `json:"message"`
+	Value   BookmarkTreeNode `json:"value"`
+}
+
 // BookmarkResult has additional information about a Bookmark
 // swagger:model
 type BookmarkResult struct {
@@ -63,6 +145,8 @@ type Result struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
 	Value   string `json:"value"`
+	// Duplicates are the IDs of existing bookmarks with the same URL
+	Duplicates []string `json:"duplicates,omitempty"`
 }
 
 // BookmarksSortOrder contains a sorting for a list of ids
@@ -72,6 +156,549 @@ type BookmarksSortOrder struct {
 	SortOrder []int    `json:"sortOrder"`
 }
 
+// BookmarkIDs is a list of bookmark IDs
+// swagger:model
+type BookmarkIDs struct {
+	IDs []string `json:"ids"`
+}
+
+// BookmarkFavicon provides the URL of the favicon for a bookmark
+// swagger:model
+type BookmarkFavicon struct {
+	ID      string `json:"id"`
+	Favicon string `json:"favicon"`
+	URL     string `json:"url"`
+}
+
+// BookmarkFaviconList is a collection of BookmarkFavicons
+// swagger:model
+type BookmarkFaviconList struct {
+	Success bool              `json:"success"`
+	Count   int               `json:"count"`
+	Message string            `json:"message"`
+	Value   []BookmarkFavicon `json:"value"`
+}
+
+// BookmarkHealth is the result of the latest link check of a bookmark
+// swagger:model
+type BookmarkHealth struct {
+	Bookmark    Bookmark  `json:"bookmark"`
+	Status      string    `json:"status"`
+	StatusCode  int       `json:"statusCode"`
+	FinalURL    string    `json:"finalUrl"`
+	Latency     int64     `json:"latency"`
+	Error       string    `json:"error,omitempty"`
+	Failures    int       `json:"failures"`
+	LastChecked time.Time `json:"lastChecked"`
+}
+
+// BookmarkHealthList is a collection of BookmarkHealth entries
+// swagger:model
+type BookmarkHealthList struct {
+	Success bool             `json:"success"`
+	Count   int              `json:"count"`
+	Message string           `json:"message"`
+	Value   []BookmarkHealth `json:"value"`
+}
+
+// LinkCheck is an entry of the history of link checks
+// swagger:model
+type LinkCheck struct {
+	Status     string    `json:"status"`
+	StatusCode int       `json:"statusCode"`
+	FinalURL   string    `json:"finalUrl"`
+	Latency    int64     `json:"latency"`
+	Error      string    `json:"error,omitempty"`
+	Checked    time.Time `json:"checked"`
+}
+
+// LinkCheckList is the history of link checks of a bookmark
+// swagger:model
+type LinkCheckList struct {
+	Success bool        `json:"success"`
+	Count   int         `json:"count"`
+	Message string      `json:"message"`
+	Value   []LinkCheck `json:"value"`
+}
+
+// Visit is an access of a bookmark
+// swagger:model
+type Visit struct {
+	Visited time.Time `json:"visited"`
+}
+
+// Change is an entry of the change log, the state of the bookmark before and after the modification
+// swagger:model
+type Change struct {
+	ID        uint      `json:"id"`
+	EntityID  string    `json:"entityId"`
+	UserName  string    `json:"userName"`
+	Operation string    `json:"operation"`
+	Before    *Bookmark `json:"before,omitempty"`
+	After     *Bookmark `json:"after,omitempty"`
+	RequestID string    `json:"requestId"`
+	Changed   time.Time `json:"changed"`
+}
+
+// ChangeList is a list of entries of the change log
+// swagger:model
+type ChangeList struct {
+	Success bool     `json:"success"`
+	Count   int      `json:"count"`
+	Message string   `json:"message"`
+	Value   []Change `json:"value"`
+}
+
+// Revert selects the changes to revert. The changes of the request with the given ID or all changes after the
+// given time are reverted, without both values the last operation is reverted
+// swagger:model
+type Revert struct {
+	RequestID string     `json:"requestId,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	// DryRun returns the steps of the revert without applying them
+	DryRun bool `json:"dryRun"`
+}
+
+// RevertStep is the inverse operation for a bookmark, the state of the bookmark before and after the revert
+// swagger:model
+type RevertStep struct {
+	EntityID  string    `json:"entityId"`
+	Operation string    `json:"operation"`
+	Before    *Bookmark `json:"before,omitempty"`
+	After     *Bookmark `json:"after,omitempty"`
+}
+
+// RevertConflict is a bookmark which prevents the revert
+// swagger:model
+type RevertConflict struct {
+	EntityID string `json:"entityId"`
+	Reason   string `json:"reason"`
+}
+
+// RevertResult lists the steps of the revert, the revert is only applied without conflicts
+// swagger:model
+type RevertResult struct {
+	Success   bool             `json:"success"`
+	Applied   bool             `json:"applied"`
+	Message   string           `json:"message"`
+	Steps     []RevertStep     `json:"steps"`
+	Conflicts []RevertConflict `json:"conflicts"`
+}
+
+// SyncTombstone is a deleted bookmark
+// swagger:model
+type SyncTombstone struct {
+	ID      string    `json:"id"`
+	Deleted time.Time `json:"deleted"`
+}
+
+// SyncDelta holds the bookmarks which were created, updated or deleted after the supplied token. Without a token
+// all bookmarks are returned as created. The Token is used for the next sync
+// swagger:model
+type SyncDelta struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Token   string          `json:"token"`
+	Full    bool            `json:"full"`
+	Created []Bookmark      `json:"created"`
+	Updated []Bookmark      `json:"updated"`
+	Deleted []SyncTombstone `json:"deleted"`
+}
+
+// available strategies for changes of the client which conflict with changes on the server
+// swagger:enum SyncStrategy
+type SyncStrategy string
+
+const (
+	// SyncReport skips conflicting changes and returns them as conflicts
+	SyncReport SyncStrategy = "report"
+	// SyncLastWriterWins applies the change, if it was made after the change on the server
+	SyncLastWriterWins SyncStrategy = "lastWriterWins"
+)
+
+// SyncChange is a change of the client. The bookmark is required to create or update a bookmark, Modified is the
+// time of the change on the client
+// swagger:model
+type SyncChange struct {
+	Operation string    `json:"operation"`
+	ID        string    `json:"id"`
+	Bookmark  *Bookmark `json:"bookmark,omitempty"`
+	Modified  time.Time `json:"modified"`
+}
+
+// SyncBatch is a list of changes of the client, which is applied in one transaction. The token is the token of
+// the last sync of the client, bookmarks changed on the server after the token are conflicts
+// swagger:model
+type SyncBatch struct {
+	Token    string       `json:"token"`
+	Strategy SyncStrategy `json:"strategy"`
+	Changes  []SyncChange `json:"changes"`
+}
+
+// SyncApplied is a change of the client which was applied
+// swagger:model
+type SyncApplied struct {
+	ID        string `json:"id"`
+	Operation string `json:"operation"`
+}
+
+// SyncConflict is a change of the client which was not applied, Server is the state of the bookmark on the server
+// swagger:model
+type SyncConflict struct {
+	ID        string    `json:"id"`
+	Operation string    `json:"operation"`
+	Reason    string    `json:"reason"`
+	Server    *Bookmark `json:"server,omitempty"`
+}
+
+// SyncResult is the result of a batch of changes. The Token includes the applied changes
+// swagger:model
+type SyncResult struct {
+	Success   bool           `json:"success"`
+	Message   string         `json:"message"`
+	Token     string         `json:"token"`
+	Applied   []SyncApplied  `json:"applied"`
+	Conflicts []SyncConflict `json:"conflicts"`
+}
+
+// available operations of a batch
+// swagger:enum BatchOperationType
+type BatchOperationType string
+
+const (
+	// BatchCreate creates a new bookmark
+	BatchCreate BatchOperationType = "create"
+	// BatchUpdate changes a bookmark, like the update of a single bookmark
+	BatchUpdate BatchOperationType = "update"
+	// BatchMove puts a bookmark into another folder
+	BatchMove BatchOperationType = "move"
+	// BatchDelete removes a bookmark, folders need to be empty
+	BatchDelete BatchOperationType = "delete"
+)
+
+// BatchOperation is a single operation of a batch. The ID refers to an existing bookmark or to the TempID of a
+// bookmark created earlier in the batch. The destination folder is either given by the ParentID, which also
+// accepts a TempID, or by the path. The version is required for existing bookmarks and has to match
+// swagger:model
+type BatchOperation struct {
+	Operation BatchOperationType `json:"operation"`
+	ID        string             `json:"id,omitempty"`
+	TempID    string             `json:"tempId,omitempty"`
+	ParentID  string             `json:"parentId,omitempty"`
+	Path      string             `json:"path,omitempty"`
+	Version   int                `json:"version,omitempty"`
+	Bookmark  *Bookmark          `json:"bookmark,omitempty"`
+}
+
+// Batch is an ordered list of operations, which are applied in one transaction. Either all operations are
+// applied or none
+// swagger:model
+type Batch struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// the outcome of an operation of a batch
+// swagger:enum BatchStatus
+type BatchStatus string
+
+const (
+	// BatchApplied is an operation of a successful batch
+	BatchApplied BatchStatus = "applied"
+	// BatchFailed is the operation which stopped the batch
+	BatchFailed BatchStatus = "failed"
+	// BatchRolledBack is an operation before the failed operation, the changes were discarded
+	BatchRolledBack BatchStatus = "rolledBack"
+	// BatchSkipped is an operation after the failed operation
+	BatchSkipped BatchStatus = "skipped"
+)
+
+// BatchOperationResult is the outcome of an operation of the batch. The ID is the ID of the bookmark, for
+// created bookmarks also the TempID is returned
+// swagger:model
+type BatchOperationResult struct {
+	Index     int                `json:"index"`
+	Operation BatchOperationType `json:"operation"`
+	Status    BatchStatus        `json:"status"`
+	ID        string             `json:"id,omitempty"`
+	TempID    string             `json:"tempId,omitempty"`
+	Version   int                `json:"version,omitempty"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// BatchResult is the result of a batch with the outcome of every operation
+// swagger:model
+type BatchResult struct {
+	Success bool                   `json:"success"`
+	Message string                 `json:"message"`
+	Results []BatchOperationResult `json:"results"`
+}
+
+// VisitList is the history of visits of a bookmark
+// swagger:model
+type VisitList struct {
+	Success bool    `json:"success"`
+	Count   int     `json:"count"`
+	Message string  `json:"message"`
+	Value   []Visit `json:"value"`
+}
+
+// BookmarkStatistics summarizes the bookmark collection and its usage
+// swagger:model
+type BookmarkStatistics struct {
+	Success        bool            `json:"success"`
+	Nodes          int             `json:"nodes"`
+	Folders        int             `json:"folders"`
+	DeepestPaths   []PathDepth     `json:"deepestPaths"`
+	BiggestFolders []FolderSize    `json:"biggestFolders"`
+	TopDomains     []DomainCount   `json:"topDomains"`
+	NeverVisited   BookmarkSample  `json:"neverVisited"`
+	Stale          BookmarkSample  `json:"stale"`
+	Growth         []MonthlyGrowth `json:"growth"`
+	// VisitHeatmap is the number of visits per weekday (0 = Sunday) and hour in UTC
+	VisitHeatmap [][]int `json:"visitHeatmap"`
+}
+
+// PathDepth is a path with the number of its levels
+// swagger:model
+type PathDepth struct {
+	Path  string `json:"path"`
+	Depth int    `json:"depth"`
+}
+
+// FolderSize is a folder with the number of its child-elements
+// swagger:model
+type FolderSize struct {
+	Path       string `json:"path"`
+	ChildCount int    `json:"childCount"`
+}
+
+// DomainCount is the number of bookmarks of a domain
+// swagger:model
+type DomainCount struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+}
+
+// BookmarkSample is the total number of matching bookmarks and the first of them
+// swagger:model
+type BookmarkSample struct {
+	Count int        `json:"count"`
+	Value []Bookmark `json:"value"`
+}
+
+// MonthlyGrowth is the number of bookmarks created in a month and the total at the end of the month
+// swagger:model
+type MonthlyGrowth struct {
+	Month string `json:"month"`
+	Count int    `json:"count"`
+	Total int    `json:"total"`
+}
+
+// available actions for bookmarks with link problems
+// swagger:enum HealthActionType
+type HealthActionType string
+
+const (
+	HealthDelete HealthActionType = "delete"
+	HealthMove   HealthActionType = "move"
+	HealthRetry  HealthActionType = "retry"
+)
+
+// HealthAction is applied to the given list of bookmarks
+// swagger:model
+type HealthAction struct {
+	Action HealthActionType `json:"action"`
+	IDs    []string         `json:"ids"`
+	// Path is the destination for the move action
+	Path string `json:"path,omitempty"`
+	// Versions maps the IDs to the versions of the bookmarks, the delete and the move need the version of every bookmark
+	Versions map[string]int `json:"versions,omitempty"`
+}
+
+// URLSuggestion proposes a new URL for a bookmark which permanently redirects
+// swagger:model
+type URLSuggestion struct {
+	Bookmark     Bookmark  `json:"bookmark"`
+	SuggestedURL string    `json:"suggestedUrl"`
+	StatusCode   int       `json:"statusCode"`
+	Detected     time.Time `json:"detected"`
+}
+
+// URLSuggestionList is a collection of URLSuggestion entries
+// swagger:model
+type URLSuggestionList struct {
+	Success bool            `json:"success"`
+	Count   int             `json:"count"`
+	Message string          `json:"message"`
+	Value   []URLSuggestion `json:"value"`
+}
+
+// URLChange is an entry of the URL history of a bookmark
+// swagger:model
+type URLChange struct {
+	OldURL  string    `json:"oldUrl"`
+	NewURL  string    `json:"newUrl"`
+	Reason  string    `json:"reason"`
+	Changed time.Time `json:"changed"`
+}
+
+// URLHistory is the list of URL changes of a bookmark
+// swagger:model
+type URLHistory struct {
+	Success bool        `json:"success"`
+	Count   int         `json:"count"`
+	Message string      `json:"message"`
+	Value   []URLChange `json:"value"`
+}
+
+// UserSettings are the preferences of the user
+// swagger:model
+type UserSettings struct {
+	// AutoApplyRedirects updates the URL of bookmarks with permanent redirects (301, 308) automatically
+	AutoApplyRedirects bool `json:"autoApplyRedirects"`
+	// MarkReadOnOpen marks read-later bookmarks as read, when they are opened
+	MarkReadOnOpen bool `json:"markReadOnOpen"`
+	// ReadLaterExpiryDays is the number of days after which read bookmarks are moved to the ReadLaterExpiryPath,
+	// 0 keeps the read bookmarks in the read-later queue
+	ReadLaterExpiryDays int    `json:"readLaterExpiryDays"`
+	ReadLaterExpiryPath string `json:"readLaterExpiryPath"`
+}
+
+// available actions for read-later bookmarks
+// swagger:enum ReadLaterActionType
+type ReadLaterActionType string
+
+const (
+	// ReadLaterRead marks the bookmarks as read
+	ReadLaterRead ReadLaterActionType = "read"
+	// ReadLaterUnread marks the bookmarks as unread
+	ReadLaterUnread ReadLaterActionType = "unread"
+	// ReadLaterArchive removes the bookmarks from the read-later queue and keeps them, optionally in the given path
+	ReadLaterArchive ReadLaterActionType = "archive"
+)
+
+// ReadLaterAction is applied to the given list of read-later bookmarks
+// swagger:model
+type ReadLaterAction struct {
+	Action ReadLaterActionType `json:"action"`
+	IDs    []string            `json:"ids"`
+	// Path is the optional destination for the archive action
+	Path string `json:"path,omitempty"`
+}
+
+// VersionConflict is the problem detail of a change, which is based on an outdated version of the bookmark.
+// Current is the stored state of the bookmark
+// swagger:model
+type VersionConflict struct {
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	Status   int       `json:"status"`
+	Detail   string    `json:"detail,omitempty"`
+	Instance string    `json:"instance,omitempty"`
+	Current  *Bookmark `json:"current,omitempty"`
+}
+
+// DuplicateGroup are the bookmarks which refer to the same normalized URL
+// swagger:model
+type DuplicateGroup struct {
+	URL       string     `json:"url"`
+	Bookmarks []Bookmark `json:"bookmarks"`
+}
+
+// DuplicateList is a collection of DuplicateGroups
+// swagger:model
+type DuplicateList struct {
+	Success bool             `json:"success"`
+	Count   int              `json:"count"`
+	Message string           `json:"message"`
+	Value   []DuplicateGroup `json:"value"`
+}
+
+// MergeDuplicates keeps one bookmark and removes the given duplicates
+// swagger:model
+type MergeDuplicates struct {
+	// Keep is the ID of the bookmark which is kept
+	Keep string `json:"keep"`
+	// IDs of the duplicates which are merged into the kept bookmark and removed
+	IDs []string `json:"ids"`
+}
+
+// ArchiveVersion is a stored snapshot of the page of a bookmark
+// swagger:model
+type ArchiveVersion struct {
+	Version int       `json:"version"`
+	URL     string    `json:"url"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+	// Link to retrieve the snapshot
+	Link string `json:"link"`
+}
+
+// ArchiveVersionList is the list of archived versions of a bookmark
+// swagger:model
+type ArchiveVersionList struct {
+	Success bool             `json:"success"`
+	Count   int              `json:"count"`
+	Message string           `json:"message"`
+	Value   []ArchiveVersion `json:"value"`
+}
+
+// Webhook receives the events of the bookmarks of the user. Events are the subscribed types of BookmarkEvents,
+// all events are sent without a type. The secret signs the payloads, it is only returned on creation
+// swagger:model
+type Webhook struct {
+	ID       string     `json:"id"`
+	URL      string     `json:"url"`
+	Events   []string   `json:"events"`
+	Active   bool       `json:"active"`
+	Secret   string     `json:"secret,omitempty"`
+	Created  time.Time  `json:"created"`
+	Modified *time.Time `json:"modified,omitempty"`
+}
+
+// WebhookList is the list of webhooks of the user
+// swagger:model
+type WebhookList struct {
+	Success bool      `json:"success"`
+	Count   int       `json:"count"`
+	Message string    `json:"message"`
+	Value   []Webhook `json:"value"`
+}
+
+// WebhookPayload is the body sent to the webhook. The ID is the same for all webhooks receiving the event
+// swagger:model
+type WebhookPayload struct {
+	ID      string        `json:"id"`
+	Type    string        `json:"type"`
+	Created time.Time     `json:"created"`
+	Data    BookmarkEvent `json:"data"`
+}
+
+// WebhookDelivery is an entry of the delivery log of a webhook. Status is pending, delivered or failed, the
+// StatusCode and the Error are the outcome of the last attempt
+// swagger:model
+type WebhookDelivery struct {
+	ID          uint       `json:"id"`
+	EventType   string     `json:"eventType"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	StatusCode  int        `json:"statusCode,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	Created     time.Time  `json:"created"`
+	LastAttempt *time.Time `json:"lastAttempt,omitempty"`
+	// NextAttempt is the time of the next attempt of a pending delivery
+	NextAttempt *time.Time `json:"nextAttempt,omitempty"`
+}
+
+// WebhookDeliveryList is the delivery log of a webhook
+// swagger:model
+type WebhookDeliveryList struct {
+	Success bool              `json:"success"`
+	Count   int               `json:"count"`
+	Message string            `json:"message"`
+	Value   []WebhookDelivery `json:"value"`
+}
+
 // --------------------------------------------------------------------------
 // convert entities to models
 // --------------------------------------------------------------------------
@@ -89,6 +716,42 @@ func entityToModel(b store.Bookmark) *Bookmark {
 		AccessCount: b.AccessCount,
 		ChildCount:  b.ChildCount,
 		Favicon:     b.Favicon,
+
+		LastAccessed: b.LastAccessed,
+		Query:        b.Query,
+		ReadLater:    b.ReadLater,
+		ReadAt:       b.ReadAt,
+		Version:      b.Version,
+
+		Title:        b.Metadata.Title,
+		Description:  b.Metadata.Description,
+		CanonicalURL: b.Metadata.CanonicalURL,
+		ImageURL:     b.Metadata.ImageURL,
+		Language:     b.Metadata.Language,
+		SiteName:     b.Metadata.SiteName,
+	}
+}
+
+// pageMetadata returns the metadata of the bookmark model
+func (b Bookmark) pageMetadata() store.PageMetadata {
+	return store.PageMetadata{
+		Title:        b.Title,
+		Description:  b.Description,
+		CanonicalURL: b.CanonicalURL,
+		ImageURL:     b.ImageURL,
+		Language:     b.Language,
+		SiteName:     b.SiteName,
+	}
+}
+
+func metadataToEntity(m *metadata.Metadata) store.PageMetadata {
+	return store.PageMetadata{
+		Title:        m.Title,
+		Description:  m.Description,
+		CanonicalURL: m.CanonicalURL,
+		ImageURL:     m.ImageURL,
+		Language:     m.Language,
+		SiteName:     m.SiteName,
 	}
 }
 
@@ -100,13 +763,186 @@ func entityListToModel(bms []store.Bookmark) []Bookmark {
 	return model
 }
 
+func linkCheckToModel(c store.LinkCheck) LinkCheck {
+	return LinkCheck{
+		Status:     string(c.Status),
+		StatusCode: c.StatusCode,
+		FinalURL:   c.FinalURL,
+		Latency:    c.Latency,
+		Error:      c.Error,
+		Checked:    c.Checked,
+	}
+}
+
+func urlHistoryToModel(h store.URLHistory) URLChange {
+	return URLChange{
+		OldURL:  h.OldURL,
+		NewURL:  h.NewURL,
+		Reason:  string(h.Reason),
+		Changed: h.Changed,
+	}
+}
+
+func changeToModel(c store.ChangeLog) (Change, error) {
+	change := Change{
+		ID:        c.ID,
+		EntityID:  c.EntityID,
+		UserName:  c.UserName,
+		Operation: string(c.Operation),
+		RequestID: c.RequestID,
+		Changed:   c.Changed,
+	}
+	var err error
+	if change.Before, err = changeStateToModel(c.Before); err != nil {
+		return Change{}, err
+	}
+	if change.After, err = changeStateToModel(c.After); err != nil {
+		return Change{}, err
+	}
+	return change, nil
+}
+
+// changeStateToModel reads the JSON representation of the bookmark in the change log, an empty state is nil
+func changeStateToModel(state string) (*Bookmark, error) {
+	if state == "" {
+		return nil, nil
+	}
+	var bm store.Bookmark
+	if err := json.Unmarshal([]byte(state), &bm); err != nil {
+		return nil, fmt.Errorf("cannot read the state of the change: %v", err)
+	}
+	return entityToModel(bm), nil
+}
+
+func revertPlanToModel(plan revert.Plan) ([]RevertStep, []RevertConflict) {
+	steps := make([]RevertStep, 0, len(plan.Steps))
+	for _, s := range plan.Steps {
+		step := RevertStep{EntityID: s.EntityID, Operation: string(s.Operation)}
+		if s.Current != nil {
+			step.Before = entityToModel(*s.Current)
+		}
+		if s.Target != nil {
+			step.After = entityToModel(*s.Target)
+		}
+		steps = append(steps, step)
+	}
+	conflicts := make([]RevertConflict, 0, len(plan.Conflicts))
+	for _, c := range plan.Conflicts {
+		conflicts = append(conflicts, RevertConflict{EntityID: c.EntityID, Reason: c.Reason})
+	}
+	return steps, conflicts
+}
+
+func archiveToModel(a store.Archive) ArchiveVersion {
+	return ArchiveVersion{
+		Version: a.Version,
+		URL:     a.URL,
+		Size:    a.Size,
+		Created: a.Created,
+		Link:    fmt.Sprintf("/api/v1/bookmarks/%s/archive/%d", a.BookmarkID, a.Version),
+	}
+}
+
+func statisticsToModel(s store.Statistics) *BookmarkStatistics {
+	stats := &BookmarkStatistics{
+		Success:        true,
+		Nodes:          s.Nodes,
+		Folders:        s.Folders,
+		DeepestPaths:   make([]PathDepth, 0, len(s.DeepestPaths)),
+		BiggestFolders: make([]FolderSize, 0, len(s.BiggestFolders)),
+		TopDomains:     make([]DomainCount, 0, len(s.TopDomains)),
+		NeverVisited:   BookmarkSample{Count: s.NeverVisitedCount, Value: entityListToModel(s.NeverVisited)},
+		Stale:          BookmarkSample{Count: s.StaleCount, Value: entityListToModel(s.Stale)},
+		Growth:         make([]MonthlyGrowth, 0, len(s.Growth)),
+		VisitHeatmap:   make([][]int, 7),
+	}
+	for _, p := range s.DeepestPaths {
+		stats.DeepestPaths = append(stats.DeepestPaths, PathDepth{Path: p.Path, Depth: p.Depth})
+	}
+	for _, f := range s.BiggestFolders {
+		stats.BiggestFolders = append(stats.BiggestFolders, FolderSize{Path: f.Path, ChildCount: f.Count})
+	}
+	for _, d := range s.TopDomains {
+		stats.TopDomains = append(stats.TopDomains, DomainCount{Domain: d.Domain, Count: d.Count})
+	}
+	total := 0
+	for _, m := range s.Growth {
+		total += m.Count
+		stats.Growth = append(stats.Growth, MonthlyGrowth{Month: m.Month, Count: m.Count, Total: total})
+	}
+	for day := range stats.VisitHeatmap {
+		stats.VisitHeatmap[day] = make([]int, 24)
+	}
+	for _, slot := range s.Heatmap {
+		if slot.Weekday >= 0 && slot.Weekday < 7 && slot.Hour >= 0 && slot.Hour < 24 {
+			stats.VisitHeatmap[slot.Weekday][slot.Hour] = slot.Count
+		}
+	}
+	return stats
+}
+
+func userSettingsToModel(s store.UserSettings) *UserSettings {
+	return &UserSettings{
+		AutoApplyRedirects:  s.AutoApplyRedirects,
+		MarkReadOnOpen:      s.MarkReadOnOpen,
+		ReadLaterExpiryDays: s.ReadLaterExpiryDays,
+		ReadLaterExpiryPath: s.ReadLaterExpiryPath,
+	}
+}
+
+func webhookToModel(w store.Webhook) Webhook {
+	events := make([]string, 0)
+	if w.Events != "" {
+		events = strings.Split(w.Events, ",")
+	}
+	return Webhook{
+		ID:       w.ID,
+		URL:      w.URL,
+		Events:   events,
+		Active:   w.Active,
+		Created:  w.Created,
+		Modified: w.Modified,
+	}
+}
+
+func webhookDeliveryToModel(d store.WebhookDelivery) WebhookDelivery {
+	delivery := WebhookDelivery{
+		ID:          d.ID,
+		EventType:   d.EventType,
+		Status:      string(d.Status),
+		Attempts:    d.Attempts,
+		StatusCode:  d.StatusCode,
+		Error:       d.Error,
+		Created:     d.Created,
+		LastAttempt: d.LastAttempt,
+	}
+	if d.Status == store.DeliveryPending {
+		next := d.NextAttempt
+		delivery.NextAttempt = &next
+	}
+	return delivery
+}
+
 func entityEnumToModel(t store.NodeType) NodeType {
-	if t == store.Folder {
+	switch t {
+	case store.Folder:
 		return Folder
+	case store.SmartFolder:
+		return SmartFolder
 	}
 	return Node
 }
 
+func modelEnumToEntity(t NodeType) store.NodeType {
+	switch t {
+	case Folder:
+		return store.Folder
+	case SmartFolder:
+		return store.SmartFolder
+	}
+	return store.Node
+}
+
 // --------------------------------------------------------------------------
 // Swagger specific definitions
 // --------------------------------------------------------------------------
@@ -123,21 +959,75 @@ type BookmarksSortOrderRequestSwagger struct {
 	Body BookmarksSortOrder
 }
 
-// --------------------------------------------------------------------------
-// BookmarkRequest
-// --------------------------------------------------------------------------
+// swagger:parameters HealthActions
+type HealthActionRequestSwagger struct {
+	// In: body
+	Body HealthAction
+}
 
-// BookmarkRequest is the request payload for Bookmark data model.
-type BookmarkRequest struct {
-	*Bookmark
+// swagger:parameters RevertChanges
+type RevertRequestSwagger struct {
+	// In: body
+	Body Revert
 }
 
-// Bind assigns the the provided data to a BookmarkRequest
-func (b *BookmarkRequest) Bind(r *http.Request) error {
-	// a Bookmark is nil if no Bookmark fields are sent in the request. Return an
-	// error to avoid a nil pointer dereference.
-	if b.Bookmark == nil {
-		return fmt.Errorf("missing required Bookmarks fields")
+// swagger:parameters ApplySyncChanges
+type SyncBatchRequestSwagger struct {
+	// In: body
+	Body SyncBatch
+}
+
+// swagger:parameters ApplyBatch
+type BatchRequestSwagger struct {
+	// In: body
+	Body Batch
+}
+
+// swagger:parameters GetFaviconURLs ApplyURLSuggestions
+type BookmarkIDsRequestSwagger struct {
+	// In: body
+	Body BookmarkIDs
+}
+
+// swagger:parameters MergeDuplicates
+type MergeDuplicatesRequestSwagger struct {
+	// In: body
+	Body MergeDuplicates
+}
+
+// swagger:parameters ReadLaterActions
+type ReadLaterActionRequestSwagger struct {
+	// In: body
+	Body ReadLaterAction
+}
+
+// swagger:parameters UpdateUserSettings
+type UserSettingsRequestSwagger struct {
+	// In: body
+	Body UserSettings
+}
+
+// swagger:parameters CreateWebhook UpdateWebhook
+type WebhookRequestSwagger struct {
+	// In: body
+	Body Webhook
+}
+
+// --------------------------------------------------------------------------
+// BookmarkRequest
+// --------------------------------------------------------------------------
+
+// BookmarkRequest is the request payload for Bookmark data model.
+type BookmarkRequest struct {
+	*Bookmark
+}
+
+// Bind assigns the the provided data to a BookmarkRequest
+func (b *BookmarkRequest) Bind(r *http.Request) error {
+	// a Bookmark is nil if no Bookmark fields are sent in the request. Return an
+	// error to avoid a nil pointer dereference.
+	if b.Bookmark == nil {
+		return fmt.Errorf("missing required Bookmarks fields")
 	}
 	return nil
 }
@@ -173,6 +1063,162 @@ func (b *BookmarksSortOrderRequest) String() string {
 	return fmt.Sprintf("IDs: '%s', SortOrder: %s", strings.Join(b.IDs, ","), strings.Join(order, ","))
 }
 
+// --------------------------------------------------------------------------
+// BookmarkIDsRequest
+// --------------------------------------------------------------------------
+
+// BookmarkIDsRequest is the request payload for a list of bookmark IDs
+type BookmarkIDsRequest struct {
+	*BookmarkIDs
+}
+
+// Bind assigns the the provided data to a BookmarkIDsRequest
+func (b *BookmarkIDsRequest) Bind(r *http.Request) error {
+	if b.BookmarkIDs == nil {
+		return fmt.Errorf("missing required BookmarkIDs fields")
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// ReadLaterActionRequest
+// --------------------------------------------------------------------------
+
+// ReadLaterActionRequest is the request payload for the ReadLaterAction model
+type ReadLaterActionRequest struct {
+	*ReadLaterAction
+}
+
+// Bind assigns the the provided data to a ReadLaterActionRequest
+func (b *ReadLaterActionRequest) Bind(r *http.Request) error {
+	if b.ReadLaterAction == nil {
+		return fmt.Errorf("missing required ReadLaterAction fields")
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// UserSettingsRequest
+// --------------------------------------------------------------------------
+
+// UserSettingsRequest is the request payload for the UserSettings model
+type UserSettingsRequest struct {
+	*UserSettings
+}
+
+// Bind assigns the the provided data to a UserSettingsRequest
+func (b *UserSettingsRequest) Bind(r *http.Request) error {
+	if b.UserSettings == nil {
+		return fmt.Errorf("missing required UserSettings fields")
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// WebhookRequest
+// --------------------------------------------------------------------------
+
+// WebhookRequest is the request payload for the Webhook model
+type WebhookRequest struct {
+	*Webhook
+}
+
+// Bind assigns the the provided data to a WebhookRequest
+func (b *WebhookRequest) Bind(r *http.Request) error {
+	if b.Webhook == nil {
+		return fmt.Errorf("missing required Webhook fields")
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// MergeDuplicatesRequest
+// --------------------------------------------------------------------------
+
+// MergeDuplicatesRequest is the request payload for the MergeDuplicates model
+type MergeDuplicatesRequest struct {
+	*MergeDuplicates
+}
+
+// Bind assigns the the provided data to a MergeDuplicatesRequest
+func (b *MergeDuplicatesRequest) Bind(r *http.Request) error {
+	if b.MergeDuplicates == nil {
+		return fmt.Errorf("missing required MergeDuplicates fields")
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// HealthActionRequest
+// --------------------------------------------------------------------------
+
+// HealthActionRequest is the request payload for the HealthAction model
+type HealthActionRequest struct {
+	*HealthAction
+}
+
+// Bind assigns the the provided data to a HealthActionRequest
+func (b *HealthActionRequest) Bind(r *http.Request) error {
+	if b.HealthAction == nil {
+		return fmt.Errorf("missing required HealthAction fields")
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// RevertRequest
+// --------------------------------------------------------------------------
+
+// RevertRequest is the request payload for the Revert model
+type RevertRequest struct {
+	*Revert
+}
+
+// Bind assigns the the provided data to a RevertRequest, an empty payload reverts the last operation
+func (b *RevertRequest) Bind(r *http.Request) error {
+	if b.Revert == nil {
+		b.Revert = &Revert{}
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// SyncBatchRequest
+// --------------------------------------------------------------------------
+
+// SyncBatchRequest is the request payload for the SyncBatch model
+type SyncBatchRequest struct {
+	*SyncBatch
+}
+
+// Bind assigns the the provided data to a SyncBatchRequest
+func (b *SyncBatchRequest) Bind(r *http.Request) error {
+	if b.SyncBatch == nil {
+		return fmt.Errorf("missing required SyncBatch fields")
+	}
+	if b.Strategy == "" {
+		b.Strategy = SyncReport
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// BatchRequest
+// --------------------------------------------------------------------------
+
+// BatchRequest is the request payload for the Batch model
+type BatchRequest struct {
+	*Batch
+}
+
+// Bind assigns the the provided data to a BatchRequest
+func (b *BatchRequest) Bind(r *http.Request) error {
+	if b.Batch == nil || len(b.Operations) == 0 {
+		return fmt.Errorf("missing operations of the batch")
+	}
+	return nil
+}
+
 // --------------------------------------------------------------------------
 // BookmarkResponse
 // --------------------------------------------------------------------------
@@ -195,6 +1241,8 @@ func (b BookmarkResponse) Render(w http.ResponseWriter, r *http.Request) error {
 // BookmarkListResponse returns a list of Bookmark Items
 type BookmarkListResponse struct {
 	*BookmarkList
+	// Fields restricts the bookmarks to the given fields
+	Fields []string `json:"-"`
 }
 
 // Render the specific response
@@ -203,6 +1251,29 @@ func (b BookmarkListResponse) Render(w http.ResponseWriter, r *http.Request) err
 	return nil
 }
 
+// MarshalJSON returns the list, the bookmarks only contain the selected fields
+func (b BookmarkListResponse) MarshalJSON() ([]byte, error) {
+	if len(b.Fields) == 0 {
+		return json.Marshal(b.BookmarkList)
+	}
+	values := make([]map[string]interface{}, 0, len(b.Value))
+	for _, bm := range b.Value {
+		v, err := projectFields(bm, b.Fields)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return json.Marshal(struct {
+		Success bool                     `json:"success"`
+		Count   int                      `json:"count"`
+		Total   int                      `json:"total,omitempty"`
+		Next    string                   `json:"next,omitempty"`
+		Message string                   `json:"message"`
+		Value   []map[string]interface{} `json:"value"`
+	}{b.Success, b.Count, b.Total, b.Next, b.Message, values})
+}
+
 // --------------------------------------------------------------------------
 // BookmarResultResponse
 // --------------------------------------------------------------------------
@@ -218,6 +1289,335 @@ func (b BookmarResultResponse) Render(w http.ResponseWriter, r *http.Request) er
 	return nil
 }
 
+// --------------------------------------------------------------------------
+// BookmarkFaviconListResponse
+// --------------------------------------------------------------------------
+
+// BookmarkFaviconListResponse returns a list of BookmarkFavicons
+type BookmarkFaviconListResponse struct {
+	*BookmarkFaviconList
+}
+
+// Render the specific response
+func (b BookmarkFaviconListResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// BookmarkHealthListResponse
+// --------------------------------------------------------------------------
+
+// BookmarkHealthListResponse returns a list of BookmarkHealth entries
+type BookmarkHealthListResponse struct {
+	*BookmarkHealthList
+}
+
+// Render the specific response
+func (b BookmarkHealthListResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// LinkCheckListResponse
+// --------------------------------------------------------------------------
+
+// LinkCheckListResponse returns the history of link checks
+type LinkCheckListResponse struct {
+	*LinkCheckList
+}
+
+// Render the specific response
+func (b LinkCheckListResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// VisitListResponse
+// --------------------------------------------------------------------------
+
+// VisitListResponse returns the history of visits
+type VisitListResponse struct {
+	*VisitList
+}
+
+// Render the specific response
+func (b VisitListResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// RevertResultResponse
+// --------------------------------------------------------------------------
+
+// RevertResultResponse returns the result of a revert
+type RevertResultResponse struct {
+	*RevertResult
+	Status int `json:"-"`
+}
+
+// Render the specific response
+func (b RevertResultResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	if b.Status == 0 {
+		render.Status(r, http.StatusOK)
+	} else {
+		render.Status(r, b.Status)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// BookmarkTreeResponse
+// --------------------------------------------------------------------------
+
+// BookmarkTreeResponse returns the nested bookmarks below a path
+type BookmarkTreeResponse struct {
+	*BookmarkTree
+}
+
+// Render the specific response
+func (b BookmarkTreeResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// SyncDeltaResponse
+// --------------------------------------------------------------------------
+
+// SyncDeltaResponse returns the changed bookmarks
+type SyncDeltaResponse struct {
+	*SyncDelta
+}
+
+// Render the specific response
+func (b SyncDeltaResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// SyncResultResponse
+// --------------------------------------------------------------------------
+
+// SyncResultResponse returns the result of a batch of changes
+type SyncResultResponse struct {
+	*SyncResult
+}
+
+// Render the specific response
+func (b SyncResultResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// BatchResultResponse
+// --------------------------------------------------------------------------
+
+// BatchResultResponse returns the outcome of the operations of a batch
+type BatchResultResponse struct {
+	*BatchResult
+	Status int `json:"-"` // ignore this
+}
+
+// Render the specific response
+func (b BatchResultResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	if b.Status != 0 {
+		render.Status(r, b.Status)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// VersionConflictResponse
+// --------------------------------------------------------------------------
+
+// VersionConflictResponse returns the problem of a missing or outdated version
+type VersionConflictResponse struct {
+	*VersionConflict
+}
+
+// Render the specific response
+func (b VersionConflictResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, b.Status)
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// ChangeListResponse
+// --------------------------------------------------------------------------
+
+// ChangeListResponse returns entries of the change log
+type ChangeListResponse struct {
+	*ChangeList
+}
+
+// Render the specific response
+func (b ChangeListResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// BookmarkStatisticsResponse
+// --------------------------------------------------------------------------
+
+// BookmarkStatisticsResponse returns the statistics of the bookmarks
+type BookmarkStatisticsResponse struct {
+	*BookmarkStatistics
+}
+
+// Render the specific response
+func (b BookmarkStatisticsResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// DuplicateListResponse
+// --------------------------------------------------------------------------
+
+// DuplicateListResponse returns the groups of duplicate bookmarks
+type DuplicateListResponse struct {
+	*DuplicateList
+}
+
+// Render the specific response
+func (b DuplicateListResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// ArchiveVersionResponse
+// --------------------------------------------------------------------------
+
+// ArchiveVersionResponse returns an archived version of a bookmark
+type ArchiveVersionResponse struct {
+	*ArchiveVersion
+	Status int `json:"-"` // ignore this
+}
+
+// Render the specific response
+func (b ArchiveVersionResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	if b.Status == 0 {
+		render.Status(r, http.StatusOK)
+	} else {
+		render.Status(r, b.Status)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// ArchiveVersionListResponse
+// --------------------------------------------------------------------------
+
+// ArchiveVersionListResponse returns the archived versions of a bookmark
+type ArchiveVersionListResponse struct {
+	*ArchiveVersionList
+}
+
+// Render the specific response
+func (b ArchiveVersionListResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// PagePreviewResponse
+// --------------------------------------------------------------------------
+
+// PagePreviewResponse returns the metadata of a page
+type PagePreviewResponse struct {
+	*PagePreview
+}
+
+// Render the specific response
+func (b PagePreviewResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// URLSuggestionListResponse
+// --------------------------------------------------------------------------
+
+// URLSuggestionListResponse returns a list of URLSuggestion entries
+type URLSuggestionListResponse struct {
+	*URLSuggestionList
+}
+
+// Render the specific response
+func (b URLSuggestionListResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// URLHistoryResponse
+// --------------------------------------------------------------------------
+
+// URLHistoryResponse returns the URL history of a bookmark
+type URLHistoryResponse struct {
+	*URLHistory
+}
+
+// Render the specific response
+func (b URLHistoryResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// UserSettingsResponse
+// --------------------------------------------------------------------------
+
+// UserSettingsResponse returns the settings of the user
+type UserSettingsResponse struct {
+	*UserSettings
+}
+
+// Render the specific response
+func (b UserSettingsResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// WebhookResponse
+// --------------------------------------------------------------------------
+
+// WebhookResponse returns a webhook
+type WebhookResponse struct {
+	*Webhook
+	Status int `json:"-"` // ignore this
+}
+
+// Render the specific response
+func (b WebhookResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	if b.Status != 0 {
+		render.Status(r, b.Status)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// WebhookListResponse
+// --------------------------------------------------------------------------
+
+// WebhookListResponse returns the webhooks of the user
+type WebhookListResponse struct {
+	*WebhookList
+}
+
+// Render the specific response
+func (b WebhookListResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// WebhookDeliveryListResponse
+// --------------------------------------------------------------------------
+
+// WebhookDeliveryListResponse returns the delivery log of a webhook
+type WebhookDeliveryListResponse struct {
+	*WebhookDeliveryList
+}
+
+// Render the specific response
+func (b WebhookDeliveryListResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
 // --------------------------------------------------------------------------
 // BookmarksPathsResponse
 // --------------------------------------------------------------------------
@@ -0,0 +1,103 @@
+package api
+
+import (
+	er "errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/handler"
+)
+
+// errPreconditionRequired is used for changes without the version of the bookmark
+var errPreconditionRequired = er.New("the version of the bookmark is missing, use the If-Match header or the version")
+
+// precondition holds the versions of the bookmark, on which a change is based. The wildcard '*' of the If-Match
+// header accepts every version
+type precondition struct {
+	missing  bool
+	any      bool
+	versions []int
+}
+
+// readPrecondition takes the versions of the If-Match header, without the header the version of the payload
+// is used. Weak entity-tags never match, as the If-Match header uses the strong comparison
+func readPrecondition(r *http.Request, version int) (precondition, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if version > 0 {
+			return precondition{versions: []int{version}}, nil
+		}
+		return precondition{missing: true}, nil
+	}
+
+	var p precondition
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		switch {
+		case tag == "*":
+			p.any = true
+		case strings.HasPrefix(tag, "W/"):
+			continue
+		default:
+			v, err := strconv.Atoi(strings.Trim(tag, `"`))
+			if err != nil || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+				return precondition{}, fmt.Errorf("invalid entity-tag '%s'", tag)
+			}
+			p.versions = append(p.versions, v)
+		}
+	}
+	return p, nil
+}
+
+// check compares the precondition with the stored version of the bookmark
+func (p precondition) check(version int) error {
+	if p.missing {
+		return errPreconditionRequired
+	}
+	if p.any {
+		return nil
+	}
+	for _, v := range p.versions {
+		if v == version {
+			return nil
+		}
+	}
+	return store.ErrStaleVersion
+}
+
+// bookmarkETag uses the version as a strong ETag of the bookmark
+func bookmarkETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// isPreconditionError checks if the change was rejected because of a missing or an outdated version
+func isPreconditionError(err error) bool {
+	return er.Is(err, errPreconditionRequired) || er.Is(err, store.ErrStaleVersion)
+}
+
+// renderPreconditionError responds to a change without a version or with an outdated version. For an outdated
+// version the current state of the bookmark is returned
+func (b *BookmarksAPI) renderPreconditionError(err error, id, username string, w http.ResponseWriter, r *http.Request) error {
+	problem := &VersionConflict{
+		Type:     "about:blank",
+		Status:   http.StatusPreconditionRequired,
+		Detail:   err.Error(),
+		Instance: r.URL.Path,
+	}
+	if er.Is(err, store.ErrStaleVersion) {
+		problem.Status = http.StatusPreconditionFailed
+		// the bookmark could have been deleted in the meantime
+		if current, err := b.Repository.GetBookmarkById(id, username); err == nil {
+			problem.Current = entityToModel(current)
+			w.Header().Set("ETag", bookmarkETag(current.Version))
+		}
+	}
+	problem.Title = http.StatusText(problem.Status)
+
+	handler.LogFunction("api.renderPreconditionError").Warnf("the change of bookmark '%s' is rejected: %v", id, err)
+	return render.Render(w, r, VersionConflictResponse{VersionConflict: problem})
+}
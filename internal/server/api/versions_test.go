@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookmarkVersions(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Get("/{id}", bookmarkAPI.Secure(bookmarkAPI.GetBookmarkByID))
+	r.Put("/", bookmarkAPI.Secure(bookmarkAPI.Update))
+	r.Delete("/{id}", bookmarkAPI.Secure(bookmarkAPI.Delete))
+
+	bm, _ := repo.Create(store.Bookmark{DisplayName: "Node", Path: "/", Type: store.Node, URL: "http://url", UserName: userName, Favicon: "favicon.ico"})
+
+	send := func(method, url, payload, ifMatch string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(payload))
+		req.Header.Add("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Add("If-Match", ifMatch)
+		}
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	update := func(name string, version int) string {
+		return fmt.Sprintf(`{"id": "%s", "path": "/", "displayName": "%s", "type": "Node", "url": "http://url", "favicon": "favicon.ico", "version": %d}`, bm.ID, name, version)
+	}
+
+	rec := send("GET", "/"+bm.ID, "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+
+	// a change needs the version
+	rec = send("PUT", "/", update("Renamed", 0), "")
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+	rec = send("PUT", "/", update("Renamed", 0), "W/\"1\"")
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	rec = send("PUT", "/", update("Renamed", 0), "1")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = send("PUT", "/", update("Renamed", 1), "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	// the outdated version is rejected, the current state is returned
+	rec = send("PUT", "/", update("Other", 0), `"1"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	var conflict VersionConflict
+	if err := json.Unmarshal(rec.Body.Bytes(), &conflict); err != nil {
+		t.Fatalf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, http.StatusPreconditionFailed, conflict.Status)
+	assert.Equal(t, "Renamed", conflict.Current.DisplayName)
+	assert.Equal(t, 2, conflict.Current.Version)
+	stored, _ := repo.GetBookmarkById(bm.ID, userName)
+	assert.Equal(t, "Renamed", stored.DisplayName)
+
+	// one of the listed versions has to match
+	rec = send("PUT", "/", update("Other", 0), `"1", "2"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = send("DELETE", "/"+bm.ID, "", "")
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+	rec = send("DELETE", "/"+bm.ID+"?version=x", "", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = send("DELETE", "/"+bm.ID+"?version=2", "", "")
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	rec = send("DELETE", "/"+bm.ID+"?version=3", "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	_, err := repo.GetBookmarkById(bm.ID, userName)
+	assert.Error(t, err)
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/bihe/bookmarks/internal/visits"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+// the number of visits returned for a bookmark if no limit is given
+const defaultVisitHistory = 50
+
+// swagger:operation GET /api/v1/bookmarks/recentlyvisited/{num} bookmarks GetRecentlyVisited
+//
+// get recently visited bookmarks
+//
+// return the bookmarks ordered by the time of the last visit
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: num
+//   in: path
+// responses:
+//   '200':
+//     description: BookmarkList
+//     schema:
+//       "$ref": "#/definitions/BookmarkList"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetRecentlyVisited(user security.User, w http.ResponseWriter, r *http.Request) error {
+	num, _ := strconv.Atoi(chi.URLParam(r, "num"))
+	if num < 1 {
+		num = 100
+	}
+
+	handler.LogFunction("api.GetRecentlyVisited").Debugf("get the recently visited bookmarks for user: '%s'", user.Username)
+
+	bms, err := b.Repository.GetRecentlyVisited(user.Username, num)
+	if err != nil {
+		handler.LogFunction("api.GetRecentlyVisited").Errorf("cannot get recently visited bookmarks: '%v'", err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the recently visited bookmarks: %v", err), Request: r}
+	}
+	bookmarks := entityListToModel(bms)
+	count := len(bookmarks)
+	return render.Render(w, r, BookmarkListResponse{BookmarkList: &BookmarkList{
+		Success: true,
+		Count:   count,
+		Message: fmt.Sprintf("Found %d items.", count),
+		Value:   bookmarks,
+	}})
+}
+
+// swagger:operation GET /api/v1/bookmarks/frecent/{num} bookmarks GetFrecent
+//
+// get bookmarks by frecency
+//
+// return the bookmarks ranked by the frequency and the recency of the visits. every visit counts,
+// recent visits count more than old ones
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: num
+//   in: path
+// responses:
+//   '200':
+//     description: BookmarkList
+//     schema:
+//       "$ref": "#/definitions/BookmarkList"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetFrecent(user security.User, w http.ResponseWriter, r *http.Request) error {
+	num, _ := strconv.Atoi(chi.URLParam(r, "num"))
+	if num < 1 {
+		num = 100
+	}
+
+	handler.LogFunction("api.GetFrecent").Debugf("get the frecent bookmarks for user: '%s'", user.Username)
+
+	rankings, err := visits.Frecent(b.Repository, user.Username, time.Now().UTC(), num)
+	if err != nil {
+		handler.LogFunction("api.GetFrecent").Errorf("cannot rank the bookmarks: '%v'", err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the frecent bookmarks: %v", err), Request: r}
+	}
+	ids := make([]string, 0, len(rankings))
+	for _, rank := range rankings {
+		ids = append(ids, rank.BookmarkID)
+	}
+	bms, err := b.Repository.GetBookmarksByIds(ids, user.Username)
+	if err != nil {
+		handler.LogFunction("api.GetFrecent").Errorf("cannot get the bookmarks: '%v'", err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the frecent bookmarks: %v", err), Request: r}
+	}
+	byID := make(map[string]store.Bookmark)
+	for _, bm := range bms {
+		byID[bm.ID] = bm
+	}
+
+	bookmarks := make([]Bookmark, 0, len(rankings))
+	for _, rank := range rankings {
+		bm, ok := byID[rank.BookmarkID]
+		if !ok {
+			continue
+		}
+		model := entityToModel(bm)
+		model.Frecency = math.Round(rank.Score*100) / 100
+		bookmarks = append(bookmarks, *model)
+	}
+	count := len(bookmarks)
+	return render.Render(w, r, BookmarkListResponse{BookmarkList: &BookmarkList{
+		Success: true,
+		Count:   count,
+		Message: fmt.Sprintf("Found %d items.", count),
+		Value:   bookmarks,
+	}})
+}
+
+// swagger:operation GET /api/v1/bookmarks/{id}/visits bookmarks GetVisits
+//
+// get the visits of a bookmark
+//
+// return the most recent visits of the bookmark, old visits are removed after the retention period
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+// - name: limit
+//   in: query
+// responses:
+//   '200':
+//     description: VisitList
+//     schema:
+//       "$ref": "#/definitions/VisitList"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetVisits(user security.User, w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+
+	if id == "" {
+		return errors.BadRequestError{Err: fmt.Errorf("missing id parameter"), Request: r}
+	}
+
+	limit := defaultVisitHistory
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return errors.BadRequestError{Err: fmt.Errorf("invalid limit '%s'", l), Request: r}
+		}
+		limit = n
+	}
+
+	if _, err := b.Repository.GetBookmarkById(id, user.Username); err != nil {
+		handler.LogFunction("api.GetVisits").Warnf("could not find bookmark by id '%s': %v", id, err)
+		return errors.NotFoundError{Err: fmt.Errorf("could not find bookmark with ID '%s'", id), Request: r}
+	}
+
+	entries, err := b.Repository.GetVisits(id, limit)
+	if err != nil {
+		handler.LogFunction("api.GetVisits").Errorf("cannot get the visits of bookmark '%s': %v", id, err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the visits: %v", err), Request: r}
+	}
+
+	history := make([]Visit, 0, len(entries))
+	for _, v := range entries {
+		history = append(history, Visit{Visited: v.Visited})
+	}
+
+	return render.Render(w, r, VisitListResponse{
+		VisitList: &VisitList{
+			Success: true,
+			Count:   len(history),
+			Message: fmt.Sprintf("Found %d visits", len(history)),
+			Value:   history,
+		},
+	})
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVisits(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Get("/fetch/{id}", bookmarkAPI.Secure(bookmarkAPI.FetchAndForward))
+	r.Get("/recentlyvisited/{num}", bookmarkAPI.Secure(bookmarkAPI.GetRecentlyVisited))
+	r.Get("/frecent/{num}", bookmarkAPI.Secure(bookmarkAPI.GetFrecent))
+	r.Get("/{id}/visits", bookmarkAPI.Secure(bookmarkAPI.GetVisits))
+
+	// visited often, but a long time ago
+	old, _ := repo.Create(store.Bookmark{DisplayName: "Old", Path: "/", Type: store.Node, URL: "http://old", UserName: userName, Favicon: "favicon.ico", AccessCount: 500})
+	for i := 0; i < 5; i++ {
+		repo.AddVisit(store.Visit{BookmarkID: old.ID, UserName: userName, Visited: time.Now().UTC().Add(-2 * 365 * 24 * time.Hour)})
+	}
+	today, _ := repo.Create(store.Bookmark{DisplayName: "Today", Path: "/", Type: store.Node, URL: "http://today", UserName: userName, Favicon: "favicon.ico"})
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/fetch/"+today.ID, nil)
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusFound, rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/"+today.ID+"/visits", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var visits VisitList
+	if err := json.Unmarshal(rec.Body.Bytes(), &visits); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 2, visits.Count)
+
+	for _, tc := range []struct {
+		url    string
+		status int
+	}{
+		{"/" + today.ID + "/visits?limit=0", http.StatusBadRequest},
+		{"/unknown/visits", http.StatusNotFound},
+	} {
+		rec = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", tc.url, nil)
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, tc.status, rec.Code, tc.url)
+	}
+
+	var list BookmarkList
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/recentlyvisited/10", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 2, list.Count)
+	assert.Equal(t, today.ID, list.Value[0].ID)
+	assert.Equal(t, 2, list.Value[0].AccessCount)
+	assert.NotNil(t, list.Value[0].LastAccessed)
+
+	// the visits of today outrank the old visits
+	list = BookmarkList{}
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/frecent/10", nil)
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Errorf("could not unmarshal body: %v", err)
+	}
+	assert.Equal(t, 1, list.Count)
+	assert.Equal(t, today.ID, list.Value[0].ID)
+	assert.InDelta(t, 200, list.Value[0].Frecency, 1)
+}
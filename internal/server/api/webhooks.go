@@ -0,0 +1,403 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bihe/bookmarks/internal/events"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"golang.binggl.net/commons/errors"
+	"golang.binggl.net/commons/handler"
+	"golang.binggl.net/commons/security"
+)
+
+const (
+	// the number of webhooks a user can register
+	maxWebhooks = 10
+	// the number of deliveries returned for a webhook if no limit is given
+	defaultDeliveryLog = 50
+	// the number of random bytes of a generated secret
+	webhookSecretSize = 32
+	maxWebhookURL     = 512
+	maxWebhookSecret  = 255
+)
+
+// the event types a webhook can subscribe to
+var webhookEvents = []events.Type{events.Created, events.Updated, events.Moved, events.Deleted, events.FaviconUpdated}
+
+// swagger:operation GET /api/v1/webhooks webhooks GetWebhooks
+//
+// get the webhooks of the user
+//
+// returns the registered webhooks, the secrets of the webhooks are not returned
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: WebhookList
+//     schema:
+//       "$ref": "#/definitions/WebhookList"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetWebhooks(user security.User, w http.ResponseWriter, r *http.Request) error {
+	entries, err := b.Repository.GetWebhooks(user.Username)
+	if err != nil {
+		handler.LogFunction("api.GetWebhooks").Errorf("cannot get the webhooks of user '%s': %v", user.Username, err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the webhooks: %v", err), Request: r}
+	}
+
+	webhooks := make([]Webhook, 0, len(entries))
+	for _, e := range entries {
+		webhooks = append(webhooks, webhookToModel(e))
+	}
+	return render.Render(w, r, WebhookListResponse{
+		WebhookList: &WebhookList{
+			Success: true,
+			Count:   len(webhooks),
+			Message: fmt.Sprintf("Found %d webhooks", len(webhooks)),
+			Value:   webhooks,
+		},
+	})
+}
+
+// swagger:operation POST /api/v1/webhooks webhooks CreateWebhook
+//
+// register a webhook
+//
+// the events of the bookmarks are posted as WebhookPayload to the URL of the webhook, without event types all
+// events are sent. the payload is signed with the secret as HMAC-SHA256 in the header X-Bookmarks-Signature
+// (sha256=<hex>). a secret is generated if none is supplied, the secret is only returned by this operation.
+// a new webhook is active
+//
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// responses:
+//   '201':
+//     description: Webhook
+//     schema:
+//       "$ref": "#/definitions/Webhook"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) CreateWebhook(user security.User, w http.ResponseWriter, r *http.Request) error {
+	payload := &WebhookRequest{}
+	if err := render.Bind(r, payload); err != nil {
+		handler.LogFunction("api.CreateWebhook").Warnf("cannot bind payload: '%v'", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid request data supplied"), Request: r}
+	}
+	subscribed, err := validateWebhook(payload.Webhook)
+	if err != nil {
+		return errors.BadRequestError{Err: err, Request: r}
+	}
+	secret := payload.Secret
+	if secret == "" {
+		if secret, err = webhookSecret(); err != nil {
+			handler.LogFunction("api.CreateWebhook").Errorf("cannot generate the secret of the webhook: %v", err)
+			return errors.ServerError{Err: fmt.Errorf("could not create the webhook: %v", err), Request: r}
+		}
+	}
+
+	existing, err := b.Repository.GetWebhooks(user.Username)
+	if err != nil {
+		handler.LogFunction("api.CreateWebhook").Errorf("cannot get the webhooks of user '%s': %v", user.Username, err)
+		return errors.ServerError{Err: fmt.Errorf("could not create the webhook: %v", err), Request: r}
+	}
+	if len(existing) >= maxWebhooks {
+		return errors.BadRequestError{Err: fmt.Errorf("only %d webhooks can be registered", maxWebhooks), Request: r}
+	}
+
+	webhook, err := b.Repository.SaveWebhook(store.Webhook{
+		UserName: user.Username,
+		URL:      payload.URL,
+		Secret:   secret,
+		Events:   subscribed,
+		Active:   true,
+	})
+	if err != nil {
+		handler.LogFunction("api.CreateWebhook").Errorf("cannot save the webhook of user '%s': %v", user.Username, err)
+		return errors.ServerError{Err: fmt.Errorf("could not create the webhook: %v", err), Request: r}
+	}
+	handler.LogFunction("api.CreateWebhook").Infof("created webhook '%s' of user '%s' for '%s'", webhook.ID, user.Username, webhook.URL)
+
+	created := webhookToModel(webhook)
+	created.Secret = webhook.Secret
+	return render.Render(w, r, WebhookResponse{
+		Webhook: &created,
+		Status:  http.StatusCreated,
+	})
+}
+
+// swagger:operation PUT /api/v1/webhooks/{id} webhooks UpdateWebhook
+//
+// change a webhook
+//
+// the URL, the event types and the state of the webhook are replaced by the supplied values. the secret is only
+// changed if a new secret is supplied. pending deliveries of a disabled webhook are not sent
+//
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+// responses:
+//   '200':
+//     description: Webhook
+//     schema:
+//       "$ref": "#/definitions/Webhook"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) UpdateWebhook(user security.User, w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+
+	if id == "" {
+		return errors.BadRequestError{Err: fmt.Errorf("missing id parameter"), Request: r}
+	}
+
+	payload := &WebhookRequest{}
+	if err := render.Bind(r, payload); err != nil {
+		handler.LogFunction("api.UpdateWebhook").Warnf("cannot bind payload: '%v'", err)
+		return errors.BadRequestError{Err: fmt.Errorf("invalid request data supplied"), Request: r}
+	}
+	subscribed, err := validateWebhook(payload.Webhook)
+	if err != nil {
+		return errors.BadRequestError{Err: err, Request: r}
+	}
+
+	webhook, err := b.Repository.GetWebhookByID(id, user.Username)
+	if err != nil {
+		handler.LogFunction("api.UpdateWebhook").Warnf("could not find webhook by id '%s': %v", id, err)
+		return errors.NotFoundError{Err: fmt.Errorf("could not find webhook with ID '%s'", id), Request: r}
+	}
+	webhook.URL = payload.URL
+	webhook.Events = subscribed
+	webhook.Active = payload.Active
+	if payload.Secret != "" {
+		webhook.Secret = payload.Secret
+	}
+
+	if webhook, err = b.Repository.SaveWebhook(webhook); err != nil {
+		handler.LogFunction("api.UpdateWebhook").Errorf("cannot save the webhook '%s': %v", id, err)
+		return errors.ServerError{Err: fmt.Errorf("could not update the webhook: %v", err), Request: r}
+	}
+	updated := webhookToModel(webhook)
+	return render.Render(w, r, WebhookResponse{
+		Webhook: &updated,
+	})
+}
+
+// swagger:operation DELETE /api/v1/webhooks/{id} webhooks DeleteWebhook
+//
+// delete a webhook
+//
+// the webhook and its delivery log are removed, pending deliveries are not sent
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+// responses:
+//   '200':
+//     description: Result
+//     schema:
+//       "$ref": "#/definitions/Result"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) DeleteWebhook(user security.User, w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+
+	if id == "" {
+		return errors.BadRequestError{Err: fmt.Errorf("missing id parameter"), Request: r}
+	}
+
+	if _, err := b.Repository.GetWebhookByID(id, user.Username); err != nil {
+		handler.LogFunction("api.DeleteWebhook").Warnf("could not find webhook by id '%s': %v", id, err)
+		return errors.NotFoundError{Err: fmt.Errorf("could not find webhook with ID '%s'", id), Request: r}
+	}
+	if err := b.Repository.InUnitOfWork(func(repo store.Repository) error {
+		return repo.DeleteWebhook(id, user.Username)
+	}); err != nil {
+		handler.LogFunction("api.DeleteWebhook").Errorf("cannot delete the webhook '%s': %v", id, err)
+		return errors.ServerError{Err: fmt.Errorf("could not delete the webhook: %v", err), Request: r}
+	}
+
+	return render.Render(w, r, ResultResponse{
+		Result: &Result{
+			Success: true,
+			Message: fmt.Sprintf("Webhook with ID '%s' was deleted", id),
+			Value:   id,
+		},
+	})
+}
+
+// swagger:operation GET /api/v1/webhooks/{id}/deliveries webhooks GetWebhookDeliveries
+//
+// get the delivery log of a webhook
+//
+// returns the deliveries of the webhook with the outcome of the last attempt, the most recent delivery first
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+// - name: limit
+//   in: query
+// responses:
+//   '200':
+//     description: WebhookDeliveryList
+//     schema:
+//       "$ref": "#/definitions/WebhookDeliveryList"
+//   '400':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '404':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '401':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+//   '403':
+//     description: ProblemDetail
+//     schema:
+//       "$ref": "#/definitions/ProblemDetail"
+func (b *BookmarksAPI) GetWebhookDeliveries(user security.User, w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+
+	if id == "" {
+		return errors.BadRequestError{Err: fmt.Errorf("missing id parameter"), Request: r}
+	}
+
+	limit := defaultDeliveryLog
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxPageSize {
+			return errors.BadRequestError{Err: fmt.Errorf("invalid limit '%s', the limit has to be between 1 and %d", l, maxPageSize), Request: r}
+		}
+		limit = n
+	}
+
+	if _, err := b.Repository.GetWebhookByID(id, user.Username); err != nil {
+		handler.LogFunction("api.GetWebhookDeliveries").Warnf("could not find webhook by id '%s': %v", id, err)
+		return errors.NotFoundError{Err: fmt.Errorf("could not find webhook with ID '%s'", id), Request: r}
+	}
+
+	entries, err := b.Repository.GetDeliveries(id, limit)
+	if err != nil {
+		handler.LogFunction("api.GetWebhookDeliveries").Errorf("cannot get the deliveries of webhook '%s': %v", id, err)
+		return errors.ServerError{Err: fmt.Errorf("could not get the deliveries: %v", err), Request: r}
+	}
+
+	deliveries := make([]WebhookDelivery, 0, len(entries))
+	for _, e := range entries {
+		deliveries = append(deliveries, webhookDeliveryToModel(e))
+	}
+	return render.Render(w, r, WebhookDeliveryListResponse{
+		WebhookDeliveryList: &WebhookDeliveryList{
+			Success: true,
+			Count:   len(deliveries),
+			Message: fmt.Sprintf("Found %d deliveries", len(deliveries)),
+			Value:   deliveries,
+		},
+	})
+}
+
+// validateWebhook checks the URL, the secret and the event types of the webhook. the event types are returned
+// as the comma separated list of the store
+func validateWebhook(w *Webhook) (string, error) {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return "", fmt.Errorf("invalid URL '%s' of webhook", w.URL)
+	}
+	if len(w.URL) > maxWebhookURL {
+		return "", fmt.Errorf("the URL of the webhook exceeds %d characters", maxWebhookURL)
+	}
+	if len(w.Secret) > maxWebhookSecret {
+		return "", fmt.Errorf("the secret of the webhook exceeds %d characters", maxWebhookSecret)
+	}
+
+	var subscribed []string
+	for _, e := range w.Events {
+		known := false
+		for _, t := range webhookEvents {
+			known = known || e == string(t)
+		}
+		if !known {
+			return "", fmt.Errorf("invalid event type '%s'", e)
+		}
+		subscribed = append(subscribed, e)
+	}
+	return strings.Join(subscribed, ","), nil
+}
+
+// webhookSecret generates a random secret
+func webhookSecret() (string, error) {
+	secret := make([]byte, webhookSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhooks(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	bookmarkAPI, r, cleanup := faviconAPI(t, repo)
+	defer cleanup()
+	r.Post("/", bookmarkAPI.Secure(bookmarkAPI.Create))
+	r.Delete("/{id}", bookmarkAPI.Secure(bookmarkAPI.Delete))
+	r.Get("/webhooks", bookmarkAPI.Secure(bookmarkAPI.GetWebhooks))
+	r.Post("/webhooks", bookmarkAPI.Secure(bookmarkAPI.CreateWebhook))
+	r.Put("/webhooks/{id}", bookmarkAPI.Secure(bookmarkAPI.UpdateWebhook))
+	r.Delete("/webhooks/{id}", bookmarkAPI.Secure(bookmarkAPI.DeleteWebhook))
+	r.Get("/webhooks/{id}/deliveries", bookmarkAPI.Secure(bookmarkAPI.GetWebhookDeliveries))
+
+	send := func(method, url, body string, status int) []byte {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", "*")
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, status, rec.Code, method+" "+url)
+		return rec.Body.Bytes()
+	}
+
+	var all, deletions Webhook
+	json.Unmarshal(send("POST", "/webhooks", `{"url": "https://example.com/all"}`, http.StatusCreated), &all)
+	assert.NotEmpty(t, all.ID)
+	assert.True(t, all.Active)
+	assert.Equal(t, []string{}, all.Events)
+	assert.Equal(t, 64, len(all.Secret))
+	json.Unmarshal(send("POST", "/webhooks", `{"url": "https://example.com/deleted", "secret": "s3cret", "events": ["deleted"]}`, http.StatusCreated), &deletions)
+	assert.Equal(t, "s3cret", deletions.Secret)
+	assert.Equal(t, []string{"deleted"}, deletions.Events)
+
+	for _, body := range []string{
+		`{"url": "ftp://example.com"}`,
+		`{"url": "https://"}`,
+		`{"url": "https://example.com", "events": ["created", "renamed"]}`,
+		`{"url": "https://example.com/` + strings.Repeat("a", maxWebhookURL) + `"}`,
+	} {
+		send("POST", "/webhooks", body, http.StatusBadRequest)
+	}
+
+	// the secrets are not returned
+	var list WebhookList
+	json.Unmarshal(send("GET", "/webhooks", "", http.StatusOK), &list)
+	if assert.Equal(t, 2, list.Count) {
+		assert.Equal(t, "", list.Value[0].Secret)
+		assert.Equal(t, "", list.Value[1].Secret)
+	}
+
+	// the changes of the bookmarks are added to the outbox of the subscribed webhooks
+	var created Result
+	json.Unmarshal(send("POST", "/", `{"path": "/", "displayName": "Work", "type": "Folder"}`, http.StatusCreated), &created)
+	send("DELETE", "/"+created.Value, "", http.StatusOK)
+
+	var log WebhookDeliveryList
+	json.Unmarshal(send("GET", "/webhooks/"+all.ID+"/deliveries", "", http.StatusOK), &log)
+	if assert.Equal(t, 2, log.Count) {
+		assert.Equal(t, "deleted", log.Value[0].EventType)
+		assert.Equal(t, "created", log.Value[1].EventType)
+		assert.Equal(t, string(store.DeliveryPending), log.Value[1].Status)
+		assert.NotNil(t, log.Value[1].NextAttempt)
+	}
+	json.Unmarshal(send("GET", "/webhooks/"+all.ID+"/deliveries?limit=1", "", http.StatusOK), &log)
+	assert.Equal(t, 1, log.Count)
+	json.Unmarshal(send("GET", "/webhooks/"+deletions.ID+"/deliveries", "", http.StatusOK), &log)
+	assert.Equal(t, 1, log.Count)
+
+	deliveries, _ := repo.GetDeliveries(all.ID, 10)
+	var payload WebhookPayload
+	if err := json.Unmarshal([]byte(deliveries[1].Payload), &payload); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	assert.Equal(t, "created", payload.Type)
+	assert.NotEmpty(t, payload.ID)
+	assert.Equal(t, created.Value, payload.Data.ID)
+	assert.Equal(t, "Work", payload.Data.Bookmark.DisplayName)
+	// the webhooks receive the same payload for an event
+	other, _ := repo.GetDeliveries(deletions.ID, 10)
+	assert.Contains(t, other[0].Payload, `"type":"deleted"`)
+	assert.NotEqual(t, deliveries[0].Payload, deliveries[1].Payload)
+	assert.Equal(t, deliveries[0].Payload, other[0].Payload)
+
+	// a disabled webhook receives no events, the secret is kept
+	var updated Webhook
+	json.Unmarshal(send("PUT", "/webhooks/"+all.ID, `{"url": "https://example.com/changed", "active": false}`, http.StatusOK), &updated)
+	assert.Equal(t, "https://example.com/changed", updated.URL)
+	assert.False(t, updated.Active)
+	assert.Equal(t, "", updated.Secret)
+	assert.NotNil(t, updated.Modified)
+	hook, _ := repo.GetWebhookByID(all.ID, userName)
+	assert.Equal(t, all.Secret, hook.Secret)
+
+	send("POST", "/", `{"path": "/", "displayName": "Other", "type": "Folder"}`, http.StatusCreated)
+	json.Unmarshal(send("GET", "/webhooks/"+all.ID+"/deliveries", "", http.StatusOK), &log)
+	assert.Equal(t, 2, log.Count)
+
+	send("PUT", "/webhooks/"+all.ID, `{"url": "mailto:user@example.com"}`, http.StatusBadRequest)
+	send("PUT", "/webhooks/unknown", `{"url": "https://example.com"}`, http.StatusNotFound)
+	send("GET", "/webhooks/unknown/deliveries", "", http.StatusNotFound)
+	send("GET", "/webhooks/"+all.ID+"/deliveries?limit=0", "", http.StatusBadRequest)
+	send("GET", "/webhooks/"+all.ID+"/deliveries?limit=1001", "", http.StatusBadRequest)
+
+	send("DELETE", "/webhooks/"+all.ID, "", http.StatusOK)
+	send("DELETE", "/webhooks/"+all.ID, "", http.StatusNotFound)
+	json.Unmarshal(send("GET", "/webhooks", "", http.StatusOK), &list)
+	assert.Equal(t, 1, list.Count)
+	deliveries, _ = repo.GetDeliveries(all.ID, 10)
+	assert.Equal(t, 0, len(deliveries))
+}
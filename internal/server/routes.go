@@ -48,22 +48,61 @@ func (s *Server) routes() {
 		r.Use(security.NewJwtMiddleware(s.jwtOpts, s.cookieSettings).JwtContext)
 
 		r.Get("/appinfo", s.appInfoAPI.Secure(s.appInfoAPI.HandleAppInfo))
+		r.Get("/favicons/{hash}", s.bookmarkAPI.Secure(s.bookmarkAPI.GetFaviconByHash))
 
 		// group API methods together
 		r.Route("/api/v1/bookmarks", func(r chi.Router) {
 			r.Post("/", s.bookmarkAPI.Secure(s.bookmarkAPI.Create))
 			r.Put("/", s.bookmarkAPI.Secure(s.bookmarkAPI.Update))
+			r.Patch("/{id}", s.bookmarkAPI.Secure(s.bookmarkAPI.Patch))
 			r.Put("/sortorder", s.bookmarkAPI.Secure(s.bookmarkAPI.UpdateSortOrder))
+			r.Post("/batch", s.bookmarkAPI.Secure(s.bookmarkAPI.ApplyBatch))
 			r.Delete("/{id}", s.bookmarkAPI.Secure(s.bookmarkAPI.Delete))
 			r.Get("/{id}", s.bookmarkAPI.Secure(s.bookmarkAPI.GetBookmarkByID))
 			r.Get("/bypath", s.bookmarkAPI.Secure(s.bookmarkAPI.GetBookmarksByPath))
 			r.Get("/allpaths", s.bookmarkAPI.Secure(s.bookmarkAPI.GetAllPaths))
+			r.Get("/tree", s.bookmarkAPI.Secure(s.bookmarkAPI.GetBookmarkTree))
 			r.Get("/folder", s.bookmarkAPI.Secure(s.bookmarkAPI.GetBookmarksFolderByPath))
 			r.Get("/byname", s.bookmarkAPI.Secure(s.bookmarkAPI.GetBookmarksByName))
 			r.Get("/mostvisited/{num}", s.bookmarkAPI.Secure(s.bookmarkAPI.GetMostVisited))
+			r.Get("/recentlyvisited/{num}", s.bookmarkAPI.Secure(s.bookmarkAPI.GetRecentlyVisited))
+			r.Get("/frecent/{num}", s.bookmarkAPI.Secure(s.bookmarkAPI.GetFrecent))
+			r.Get("/stats", s.bookmarkAPI.Secure(s.bookmarkAPI.GetStatistics))
+			r.Get("/readlater", s.bookmarkAPI.Secure(s.bookmarkAPI.GetReadLater))
+			r.Post("/readlater/actions", s.bookmarkAPI.Secure(s.bookmarkAPI.ReadLaterActions))
+			r.Get("/changes", s.bookmarkAPI.Secure(s.bookmarkAPI.GetUserChanges))
+			r.Post("/revert", s.bookmarkAPI.Secure(s.bookmarkAPI.RevertChanges))
 			r.Get("/fetch/{id}", s.bookmarkAPI.Secure(s.bookmarkAPI.FetchAndForward))
 			r.Get("/favicon/{id}", s.bookmarkAPI.Secure(s.bookmarkAPI.GetFavicon))
+			r.Post("/{id}/favicon", s.bookmarkAPI.Secure(s.bookmarkAPI.UploadFavicon))
+			r.Delete("/{id}/favicon", s.bookmarkAPI.Secure(s.bookmarkAPI.DeleteFavicon))
+			r.Post("/{id}/favicon/refresh", s.bookmarkAPI.Secure(s.bookmarkAPI.RefreshFavicon))
+			r.Post("/favicons", s.bookmarkAPI.Secure(s.bookmarkAPI.GetFaviconURLs))
+			r.Post("/preview", s.bookmarkAPI.Secure(s.bookmarkAPI.PreviewPage))
+			r.Get("/health", s.bookmarkAPI.Secure(s.bookmarkAPI.GetBookmarksHealth))
+			r.Post("/health/actions", s.bookmarkAPI.Secure(s.bookmarkAPI.HealthActions))
+			r.Get("/{id}/health", s.bookmarkAPI.Secure(s.bookmarkAPI.GetBookmarkHealthHistory))
+			r.Get("/suggestions", s.bookmarkAPI.Secure(s.bookmarkAPI.GetURLSuggestions))
+			r.Post("/suggestions/apply", s.bookmarkAPI.Secure(s.bookmarkAPI.ApplyURLSuggestions))
+			r.Get("/{id}/urlhistory", s.bookmarkAPI.Secure(s.bookmarkAPI.GetURLHistory))
+			r.Get("/{id}/visits", s.bookmarkAPI.Secure(s.bookmarkAPI.GetVisits))
+			r.Get("/{id}/changes", s.bookmarkAPI.Secure(s.bookmarkAPI.GetBookmarkChanges))
+			r.Post("/{id}/archive", s.bookmarkAPI.Secure(s.bookmarkAPI.CreateArchive))
+			r.Get("/{id}/archive", s.bookmarkAPI.Secure(s.bookmarkAPI.GetArchives))
+			r.Get("/{id}/archive/{version}", s.bookmarkAPI.Secure(s.bookmarkAPI.GetArchive))
+			r.Get("/duplicates", s.bookmarkAPI.Secure(s.bookmarkAPI.GetDuplicates))
+			r.Post("/duplicates/merge", s.bookmarkAPI.Secure(s.bookmarkAPI.MergeDuplicates))
 		})
+		r.Get("/api/v1/settings", s.bookmarkAPI.Secure(s.bookmarkAPI.GetUserSettings))
+		r.Put("/api/v1/settings", s.bookmarkAPI.Secure(s.bookmarkAPI.UpdateUserSettings))
+		r.Get("/api/v1/sync", s.bookmarkAPI.Secure(s.bookmarkAPI.GetSyncChanges))
+		r.Post("/api/v1/sync", s.bookmarkAPI.Secure(s.bookmarkAPI.ApplySyncChanges))
+		r.Get("/api/v1/events", s.bookmarkAPI.Secure(s.bookmarkAPI.StreamEvents))
+		r.Get("/api/v1/webhooks", s.bookmarkAPI.Secure(s.bookmarkAPI.GetWebhooks))
+		r.Post("/api/v1/webhooks", s.bookmarkAPI.Secure(s.bookmarkAPI.CreateWebhook))
+		r.Put("/api/v1/webhooks/{id}", s.bookmarkAPI.Secure(s.bookmarkAPI.UpdateWebhook))
+		r.Delete("/api/v1/webhooks/{id}", s.bookmarkAPI.Secure(s.bookmarkAPI.DeleteWebhook))
+		r.Get("/api/v1/webhooks/{id}/deliveries", s.bookmarkAPI.Secure(s.bookmarkAPI.GetWebhookDeliveries))
 
 		// swagger
 		handler.ServeStaticDir(r, "/swagger", http.Dir(filepath.Join(s.basePath, "./assets/swagger")))
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/jinzhu/gorm"
 	"golang.binggl.net/commons/cookies"
@@ -15,9 +16,19 @@ import (
 	"golang.binggl.net/commons/security"
 
 	"github.com/bihe/bookmarks/internal"
+	"github.com/bihe/bookmarks/internal/archive"
 	"github.com/bihe/bookmarks/internal/config"
+	"github.com/bihe/bookmarks/internal/events"
+	"github.com/bihe/bookmarks/internal/favicon"
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/bihe/bookmarks/internal/jobs"
+	"github.com/bihe/bookmarks/internal/linkhealth"
+	"github.com/bihe/bookmarks/internal/readlater"
+	"github.com/bihe/bookmarks/internal/search"
 	"github.com/bihe/bookmarks/internal/server/api"
 	"github.com/bihe/bookmarks/internal/store"
+	"github.com/bihe/bookmarks/internal/visits"
+	"github.com/bihe/bookmarks/internal/webhooks"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 
@@ -36,6 +47,7 @@ type Server struct {
 	errorHandler   *handler.TemplateHandler
 	appInfoAPI     *handler.AppInfoHandler
 	bookmarkAPI    *api.BookmarksAPI
+	scheduler      *jobs.Scheduler
 }
 
 // Create instantiates a new Server instance
@@ -56,8 +68,36 @@ func Create(basePath string, config config.AppConfig, version internal.VersionIn
 	if err != nil {
 		panic(fmt.Sprintf("cannot create database connection: %v", err))
 	}
+	if err := store.Migrate(con); err != nil {
+		panic(fmt.Sprintf("cannot migrate the database schema: %v", err))
+	}
 	repository := store.Create(con)
 
+	// setup the client for outbound requests
+	// ------------------------------------------------------------------
+	fetcher, err := createFetcher(config.Fetcher)
+	if err != nil {
+		panic(fmt.Sprintf("cannot create the client for outbound requests: %v", err))
+	}
+	linkChecker, err := createLinkChecker(config.LinkCheck, fetcher, repository)
+	if err != nil {
+		panic(fmt.Sprintf("cannot create the link checker: %v", err))
+	}
+
+	archiver := createArchiver(config.Archive, basePath, fetcher, repository)
+	indexer := &search.Indexer{
+		Fetcher:    fetcher,
+		Repository: repository,
+	}
+	visitRetention, err := parseDuration(config.Visits.Retention)
+	if err != nil {
+		panic(fmt.Sprintf("invalid retention of the visits: %v", err))
+	}
+	webhookRetention, err := parseDuration(config.Webhooks.Retention)
+	if err != nil {
+		panic(fmt.Sprintf("invalid retention of the webhook deliveries: %v", err))
+	}
+
 	// setup handlers for API
 	// ------------------------------------------------------------------
 	cookieSettings := cookies.Settings{
@@ -90,11 +130,55 @@ func Create(basePath string, config config.AppConfig, version internal.VersionIn
 		TemplateDir:    templatePath,
 	}
 	bookmarkAPI := &api.BookmarksAPI{
-		Handler:        baseHandler,
-		Repository:     repository,
-		BasePath:       basePath,
-		FaviconPath:    config.FaviconPath,
-		DefaultFavicon: config.DefaultFavicon,
+		Handler:         baseHandler,
+		Repository:      repository,
+		BasePath:        basePath,
+		FaviconPath:     config.FaviconPath,
+		DefaultFavicon:  config.DefaultFavicon,
+		Fetcher:         fetcher,
+		LinkChecker:     linkChecker,
+		Archiver:        archiver,
+		Indexer:         indexer,
+		ArchiveOnCreate: config.Archive.OnCreate,
+		Events:          events.NewBus(config.Events.BufferSize),
+	}
+
+	// setup background jobs
+	// ------------------------------------------------------------------
+	scheduler := jobs.NewScheduler()
+	if err := scheduleJob(scheduler, &favicon.GarbageCollector{
+		Store:      favicon.NewStore(filepath.Join(basePath, config.FaviconPath)),
+		Repository: repository,
+	}, config.Jobs.FaviconGC); err != nil {
+		panic(fmt.Sprintf("cannot setup the background jobs: %v", err))
+	}
+	if err := scheduleJob(scheduler, linkChecker, config.Jobs.LinkHealth); err != nil {
+		panic(fmt.Sprintf("cannot setup the background jobs: %v", err))
+	}
+	if err := scheduleJob(scheduler, &archive.GarbageCollector{
+		Store:      archiver.Store,
+		Repository: repository,
+	}, config.Jobs.ArchiveGC); err != nil {
+		panic(fmt.Sprintf("cannot setup the background jobs: %v", err))
+	}
+	if err := scheduleJob(scheduler, indexer, config.Jobs.ContentIndex); err != nil {
+		panic(fmt.Sprintf("cannot setup the background jobs: %v", err))
+	}
+	if err := scheduleJob(scheduler, &visits.Retention{
+		Repository: repository,
+		Keep:       visitRetention,
+	}, config.Jobs.VisitRetention); err != nil {
+		panic(fmt.Sprintf("cannot setup the background jobs: %v", err))
+	}
+	if err := scheduleJob(scheduler, &readlater.Expiry{Repository: repository}, config.Jobs.ReadLaterExpiry); err != nil {
+		panic(fmt.Sprintf("cannot setup the background jobs: %v", err))
+	}
+	if err := scheduleJob(scheduler, webhooks.New(fetcher, repository, webhooks.Options{
+		MaxAttempts: config.Webhooks.MaxAttempts,
+		BatchSize:   config.Webhooks.BatchSize,
+		Retention:   webhookRetention,
+	}), config.Jobs.Webhooks); err != nil {
+		panic(fmt.Sprintf("cannot setup the background jobs: %v", err))
 	}
 
 	// server combines setting and handlers to form the backend
@@ -124,6 +208,7 @@ func Create(basePath string, config config.AppConfig, version internal.VersionIn
 		appInfoAPI:     appInfo,
 		errorHandler:   errHandler,
 		bookmarkAPI:    bookmarkAPI,
+		scheduler:      scheduler,
 	}
 	srv.routes()
 	return &srv
@@ -134,6 +219,96 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.router.ServeHTTP(w, r)
 }
 
+// StartJobs starts the execution of the background jobs
+func (s *Server) StartJobs() {
+	s.scheduler.Start()
+}
+
+// StopJobs stops the background jobs and waits for running jobs to finish
+func (s *Server) StopJobs() {
+	s.scheduler.Stop()
+}
+
+// scheduleJob adds the job with the configured interval, an empty interval disables the job
+func scheduleJob(scheduler *jobs.Scheduler, job jobs.Job, interval string) error {
+	d, err := parseDuration(interval)
+	if err != nil {
+		return fmt.Errorf("invalid interval for job '%s': %v", job.Name(), err)
+	}
+	scheduler.Add(job, d)
+	return nil
+}
+
+// createLinkChecker maps the configuration to the options of the link checker
+func createLinkChecker(settings config.LinkCheckSettings, fetcher fetch.Fetcher, repository store.Repository) (*linkhealth.Checker, error) {
+	recheckAfter, err := parseDuration(settings.RecheckAfter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recheckAfter: %v", err)
+	}
+	hostDelay, err := parseDuration(settings.HostDelay)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hostDelay: %v", err)
+	}
+	retention, err := parseDuration(settings.HistoryRetention)
+	if err != nil {
+		return nil, fmt.Errorf("invalid historyRetention: %v", err)
+	}
+	return linkhealth.New(fetcher, repository, linkhealth.Options{
+		RecheckAfter:     recheckAfter,
+		HostDelay:        hostDelay,
+		BatchSize:        settings.BatchSize,
+		Workers:          settings.Workers,
+		HistoryRetention: retention,
+	}), nil
+}
+
+// createArchiver maps the configuration to the archiver of the bookmark pages
+func createArchiver(settings config.ArchiveSettings, basePath string, fetcher fetch.Fetcher, repository store.Repository) *archive.Archiver {
+	archivePath := settings.Path
+	if archivePath == "" {
+		archivePath = archive.DefaultPath
+	}
+	return &archive.Archiver{
+		Fetcher:     fetcher,
+		Repository:  repository,
+		Store:       archive.NewStore(filepath.Join(basePath, archivePath)),
+		MaxVersions: settings.MaxVersions,
+		Limits: archive.Limits{
+			MaxResources: settings.MaxResources,
+			MaxSize:      settings.MaxSize,
+		},
+	}
+}
+
+// parseDuration parses the configured duration, an empty value results in 0
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s': %v", value, err)
+	}
+	return d, nil
+}
+
+// createFetcher maps the configuration to the options of the outbound client
+func createFetcher(settings config.FetcherSettings) (*fetch.Client, error) {
+	timeout, err := parseDuration(settings.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout: %v", err)
+	}
+	return fetch.New(fetch.Options{
+		Timeout:              timeout,
+		MaxBodySize:          settings.MaxBodySize,
+		UserAgent:            settings.UserAgent,
+		MaxRedirects:         settings.MaxRedirects,
+		Proxy:                settings.Proxy,
+		AllowPrivateNetworks: settings.AllowPrivateNetworks,
+		DenyNetworks:         settings.DenyNetworks,
+	})
+}
+
 // use the go-chi logger middleware and redirect request logging to a file
 func (s *Server) setupRequestLogging() {
 
@@ -0,0 +1,63 @@
+package smartfolder
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/store"
+)
+
+// MaxResults is the maximum number of bookmarks of a smart folder
+const MaxResults = 500
+
+// Evaluate returns the bookmarks matching the query of the smart folder
+func Evaluate(repo store.Repository, folder store.Bookmark, now time.Time) ([]store.Bookmark, error) {
+	if folder.Type != store.SmartFolder {
+		return nil, fmt.Errorf("'%s' is not a smart folder", folder.DisplayName)
+	}
+	filter, err := Parse(folder.Query, now)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query of smart folder '%s': %v", folder.DisplayName, err)
+	}
+
+	// the domains are only roughly filtered by the repository, the limit is applied to the checked bookmarks
+	limit := MaxResults
+	if len(filter.Domains) > 0 {
+		limit = 0
+	}
+	bookmarks, err := repo.FindBookmarks(filter, folder.UserName, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not find the bookmarks of smart folder '%s': %v", folder.DisplayName, err)
+	}
+	if len(filter.Domains) == 0 {
+		return bookmarks, nil
+	}
+
+	var matches []store.Bookmark
+	for _, bm := range bookmarks {
+		if matchDomain(bm.URL, filter.Domains) {
+			matches = append(matches, bm)
+			if len(matches) == MaxResults {
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+// matchDomain checks if the host of the URL is one of the domains or a sub-domain of them
+func matchDomain(raw string, domains []string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package smartfolder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// filterRepository returns the bookmarks and keeps the last filter
+type filterRepository struct {
+	store.Repository
+	bookmarks []store.Bookmark
+	filter    store.BookmarkFilter
+	limit     int
+}
+
+func (r *filterRepository) FindBookmarks(filter store.BookmarkFilter, username string, limit int) ([]store.Bookmark, error) {
+	r.filter = filter
+	r.limit = limit
+	return r.bookmarks, nil
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Now().UTC()
+	repo := &filterRepository{bookmarks: []store.Bookmark{
+		{DisplayName: "Repository", URL: "https://github.com/golang/go"},
+		{DisplayName: "Gist", URL: "https://gist.GitHub.com/x"},
+		{DisplayName: "Fake", URL: "https://github.com.example.org/"},
+		{DisplayName: "Path", URL: "https://example.org/github.com"},
+	}}
+
+	_, err := Evaluate(repo, store.Bookmark{Type: store.Folder, Query: "go"}, now)
+	assert.Error(t, err)
+	_, err = Evaluate(repo, store.Bookmark{Type: store.SmartFolder, Query: "tag:urgent"}, now)
+	assert.Error(t, err)
+
+	bms, err := Evaluate(repo, store.Bookmark{Type: store.SmartFolder, Query: "domain:github.com", UserName: "user"}, now)
+	if err != nil {
+		t.Fatalf("could not evaluate smart folder: %v", err)
+	}
+	var names []string
+	for _, bm := range bms {
+		names = append(names, bm.DisplayName)
+	}
+	assert.Equal(t, []string{"Repository", "Gist"}, names)
+	assert.Equal(t, 0, repo.limit)
+
+	bms, _ = Evaluate(repo, store.Bookmark{Type: store.SmartFolder, Query: "name:go"}, now)
+	assert.Equal(t, 4, len(bms))
+	assert.Equal(t, MaxResults, repo.limit)
+	assert.Equal(t, []string{"go"}, repo.filter.Names)
+}
@@ -0,0 +1,151 @@
+// Package smartfolder evaluates the saved searches of smart folders
+package smartfolder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bihe/bookmarks/internal/store"
+)
+
+// the units of the time spans of the query, e.g. 30d or 1y
+var units = map[byte]time.Duration{
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+	'm': 30 * 24 * time.Hour,
+	'y': 365 * 24 * time.Hour,
+}
+
+// Parse converts the query of a smart folder to a filter. The query is a list of criteria, separated by
+// whitespace, which all have to match. Values with whitespace are quoted, e.g. name:"release notes".
+//
+//	text            the name contains the text, the same as name:text
+//	name:text       the name contains the text
+//	url:text        the URL contains the text
+//	domain:host     the URL has the host or a sub-domain of it, several domains match any of them
+//	path:/folder    the bookmark is in the folder or a sub-folder
+//	visited:never   the bookmark was never visited
+//	unvisited:1y    the bookmark was not visited in the time span
+//	newer:30d       the bookmark was created in the time span
+//	older:30d       the bookmark was created before the time span
+//
+// Time spans are numbers with the unit d (days), w (weeks), m (30 days) or y (365 days), relative to now
+func Parse(query string, now time.Time) (store.BookmarkFilter, error) {
+	var filter store.BookmarkFilter
+
+	tokens, err := tokenize(query)
+	if err != nil {
+		return filter, err
+	}
+	if len(tokens) == 0 {
+		return filter, fmt.Errorf("the query is empty")
+	}
+
+	for _, token := range tokens {
+		key, value := "name", token
+		if i := strings.Index(token, ":"); i > 0 && isKey(token[:i]) {
+			key, value = strings.ToLower(token[:i]), token[i+1:]
+		}
+		if value == "" {
+			return filter, fmt.Errorf("missing value of '%s'", key)
+		}
+
+		switch key {
+		case "name":
+			filter.Names = append(filter.Names, value)
+		case "url":
+			filter.URLs = append(filter.URLs, value)
+		case "domain":
+			filter.Domains = append(filter.Domains, strings.ToLower(strings.TrimPrefix(value, ".")))
+		case "path":
+			if !strings.HasPrefix(value, "/") {
+				return filter, fmt.Errorf("the path '%s' has to start with '/'", value)
+			}
+			filter.Path = value
+		case "visited":
+			if value != "never" {
+				return filter, fmt.Errorf("invalid value '%s' of visited, only 'never' is supported", value)
+			}
+			filter.NeverVisited = true
+		case "unvisited":
+			d, err := parseSpan(value)
+			if err != nil {
+				return filter, err
+			}
+			filter.NotVisitedSince = now.Add(-d)
+		case "newer":
+			d, err := parseSpan(value)
+			if err != nil {
+				return filter, err
+			}
+			filter.CreatedAfter = now.Add(-d)
+		case "older":
+			d, err := parseSpan(value)
+			if err != nil {
+				return filter, err
+			}
+			filter.CreatedBefore = now.Add(-d)
+		case "tag":
+			return filter, fmt.Errorf("bookmarks have no tags, '%s' cannot be searched", token)
+		}
+	}
+	return filter, nil
+}
+
+// isKey checks if the text before a colon is one of the keys of the query. other texts, e.g. of
+// 'http://' or 'Go: tips', are part of a name
+func isKey(s string) bool {
+	switch strings.ToLower(s) {
+	case "name", "url", "domain", "path", "visited", "unvisited", "newer", "older", "tag":
+		return true
+	}
+	return false
+}
+
+// parseSpan converts a time span like 30d to a duration
+func parseSpan(value string) (time.Duration, error) {
+	if len(value) < 2 {
+		return 0, fmt.Errorf("invalid time span '%s'", value)
+	}
+	unit, ok := units[value[len(value)-1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid unit of time span '%s', use d, w, m or y", value)
+	}
+	n, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid time span '%s'", value)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// tokenize splits the query by whitespace, quoted parts are kept together without the quotes
+func tokenize(query string) ([]string, error) {
+	var (
+		tokens  []string
+		current strings.Builder
+		quoted  bool
+	)
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("missing closing quote in query '%s'", query)
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
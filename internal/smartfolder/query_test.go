@@ -0,0 +1,54 @@
+package smartfolder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	filter, err := Parse(`golang name:"release notes" url:/blog Domain:GitHub.com domain:.gitlab.com path:/Work/Go`, now)
+	if err != nil {
+		t.Fatalf("could not parse query: %v", err)
+	}
+	assert.Equal(t, store.BookmarkFilter{
+		Names:   []string{"golang", "release notes"},
+		URLs:    []string{"/blog"},
+		Domains: []string{"github.com", "gitlab.com"},
+		Path:    "/Work/Go",
+	}, filter)
+
+	filter, err = Parse("visited:never unvisited:1y newer:2w older:30d", now)
+	if err != nil {
+		t.Fatalf("could not parse query: %v", err)
+	}
+	assert.True(t, filter.NeverVisited)
+	assert.Equal(t, now.AddDate(0, 0, -365), filter.NotVisitedSince)
+	assert.Equal(t, now.AddDate(0, 0, -14), filter.CreatedAfter)
+	assert.Equal(t, now.AddDate(0, 0, -30), filter.CreatedBefore)
+
+	// texts with a colon which are no criteria are part of the name
+	filter, _ = Parse("Go: http://example", now)
+	assert.Equal(t, []string{"Go:", "http://example"}, filter.Names)
+
+	for _, query := range []string{
+		"",
+		"   ",
+		`name:"open`,
+		"url:",
+		"path:Work",
+		"visited:yesterday",
+		"unvisited:10",
+		"unvisited:0d",
+		"newer:5h",
+		"older:xd",
+		"tag:urgent",
+	} {
+		_, err := Parse(query, now)
+		assert.Error(t, err, query)
+	}
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// CreateArchive adds a new version to the archives of the bookmark. The version number is assigned
+// consecutively, the created archive is returned
+func (r *dbRepository) CreateArchive(archive Archive) (Archive, error) {
+	if archive.BookmarkID == "" {
+		return Archive{}, fmt.Errorf("no bookmark supplied for the archive")
+	}
+	if archive.FileName == "" {
+		return Archive{}, fmt.Errorf("no file supplied for the archive")
+	}
+	if archive.Created.IsZero() {
+		archive.Created = time.Now().UTC()
+	}
+
+	var versions []int
+	h := r.con().Model(&Archive{}).Where("bookmark_id = ?", archive.BookmarkID).Pluck("COALESCE(MAX(version), 0)", &versions)
+	if h.Error != nil {
+		return Archive{}, fmt.Errorf("cannot get archive version of bookmark '%s': %v", archive.BookmarkID, h.Error)
+	}
+	archive.ID = 0
+	archive.Version = 1
+	if len(versions) > 0 {
+		archive.Version = versions[0] + 1
+	}
+
+	if h := r.con().Create(&archive); h.Error != nil {
+		return Archive{}, fmt.Errorf("cannot create archive of bookmark '%s': %v", archive.BookmarkID, h.Error)
+	}
+	return archive, nil
+}
+
+// GetArchives returns the archived versions of the bookmark, the most recent version first
+func (r *dbRepository) GetArchives(bookmarkID string) ([]Archive, error) {
+	var archives []Archive
+	h := r.con().Where("bookmark_id = ?", bookmarkID).Order("version desc").Find(&archives)
+	return archives, h.Error
+}
+
+// GetArchive returns the given version of the archives of the bookmark. A version less than 1 returns
+// the most recent archive
+func (r *dbRepository) GetArchive(bookmarkID string, version int) (Archive, error) {
+	var archive Archive
+	q := r.con().Where("bookmark_id = ?", bookmarkID)
+	if version > 0 {
+		q = q.Where("version = ?", version)
+	}
+	if h := q.Order("version desc").First(&archive); h.Error != nil {
+		return Archive{}, fmt.Errorf("cannot get archive version %d of bookmark '%s': %v", version, bookmarkID, h.Error)
+	}
+	return archive, nil
+}
+
+// DeleteArchive removes the given archive version
+func (r *dbRepository) DeleteArchive(id uint) error {
+	if h := r.con().Where("id = ?", id).Delete(Archive{}); h.Error != nil {
+		return fmt.Errorf("cannot delete archive '%d': %v", id, h.Error)
+	}
+	return nil
+}
+
+// GetArchiveReferences returns the number of archives using the given file, regardless of the user
+func (r *dbRepository) GetArchiveReferences(fileName string) (int, error) {
+	var count int
+	h := r.con().Model(&Archive{}).Where("file_name = ?", fileName).Count(&count)
+	return count, h.Error
+}
+
+// GetAllArchiveFiles returns the distinct files referenced by the archives of all users
+func (r *dbRepository) GetAllArchiveFiles() ([]string, error) {
+	var files []string
+	h := r.con().Model(&Archive{}).Pluck("DISTINCT(file_name)", &files)
+	return files, h.Error
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchives(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	bm, err := repo.Create(Bookmark{
+		DisplayName: "Node",
+		Path:        "/",
+		Type:        Node,
+		URL:         "http://url",
+		UserName:    "username",
+	})
+	if err != nil {
+		t.Fatalf("Could not create bookmarks: %v", err)
+	}
+
+	_, err = repo.CreateArchive(Archive{})
+	assert.Error(t, err)
+	_, err = repo.CreateArchive(Archive{BookmarkID: bm.ID})
+	assert.Error(t, err)
+
+	_, err = repo.GetArchive(bm.ID, 0)
+	assert.Error(t, err)
+
+	first, err := repo.CreateArchive(Archive{BookmarkID: bm.ID, UserName: "username", URL: bm.URL, FileName: "a.html", Size: 10})
+	if err != nil {
+		t.Fatalf("cannot create archive: %v", err)
+	}
+	assert.Equal(t, 1, first.Version)
+	assert.False(t, first.Created.IsZero())
+	second, err := repo.CreateArchive(Archive{BookmarkID: bm.ID, UserName: "username", URL: bm.URL, FileName: "b.html", Size: 20})
+	if err != nil {
+		t.Fatalf("cannot create archive: %v", err)
+	}
+	assert.Equal(t, 2, second.Version)
+
+	archives, err := repo.GetArchives(bm.ID)
+	if err != nil {
+		t.Fatalf("cannot get archives: %v", err)
+	}
+	assert.Equal(t, 2, len(archives))
+	assert.Equal(t, 2, archives[0].Version)
+
+	a, err := repo.GetArchive(bm.ID, 1)
+	if err != nil {
+		t.Fatalf("cannot get archive: %v", err)
+	}
+	assert.Equal(t, "a.html", a.FileName)
+	a, _ = repo.GetArchive(bm.ID, 0)
+	assert.Equal(t, "b.html", a.FileName)
+	_, err = repo.GetArchive(bm.ID, 3)
+	assert.Error(t, err)
+
+	count, _ := repo.GetArchiveReferences("a.html")
+	assert.Equal(t, 1, count)
+	files, _ := repo.GetAllArchiveFiles()
+	assert.Equal(t, 2, len(files))
+
+	// the next version follows the most recent remaining archive
+	assert.NoError(t, repo.DeleteArchive(second.ID))
+	third, _ := repo.CreateArchive(Archive{BookmarkID: bm.ID, UserName: "username", URL: bm.URL, FileName: "a.html", Size: 10})
+	assert.Equal(t, 2, third.Version)
+	count, _ = repo.GetArchiveReferences("a.html")
+	assert.Equal(t, 2, count)
+
+	// the archives are removed with the bookmark
+	assert.NoError(t, repo.Delete(bm))
+	archives, _ = repo.GetArchives(bm.ID)
+	assert.Equal(t, 0, len(archives))
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/bihe/bookmarks/internal"
+)
+
+// NewChange creates the change-log entry of a bookmark. Before is nil for created bookmarks, after is nil for
+// deleted bookmarks
+func NewChange(op ChangeOperation, requestID string, before, after *Bookmark) (ChangeLog, error) {
+	entry := ChangeLog{Operation: op, RequestID: requestID}
+	var err error
+	if before != nil {
+		if entry.Before, err = marshalBookmark(*before); err != nil {
+			return ChangeLog{}, err
+		}
+		entry.EntityID, entry.UserName = before.ID, before.UserName
+	}
+	if after != nil {
+		if entry.After, err = marshalBookmark(*after); err != nil {
+			return ChangeLog{}, err
+		}
+		entry.EntityID, entry.UserName = after.ID, after.UserName
+	}
+	if entry.EntityID == "" {
+		return ChangeLog{}, fmt.Errorf("no bookmark supplied for the change log")
+	}
+	return entry, nil
+}
+
+// LogChange adds the change of the bookmark to the change log of the repository, the repository of a unit of
+// work has to be used to log the change together with the change of the bookmark
+func LogChange(repo Repository, requestID string, op ChangeOperation, before, after *Bookmark) error {
+	entry, err := NewChange(op, requestID, before, after)
+	if err != nil {
+		return err
+	}
+	if err := repo.AddChange(entry); err != nil {
+		internal.LogFunction("store.LogChange").Errorf("could not log the change of bookmark '%s': %v", entry.EntityID, err)
+		return err
+	}
+	return nil
+}
+
+func marshalBookmark(bm Bookmark) (string, error) {
+	payload, err := json.Marshal(bm)
+	if err != nil {
+		return "", fmt.Errorf("cannot serialize bookmark '%s': %v", bm.ID, err)
+	}
+	return string(payload), nil
+}
+
+// AddChange appends the entry to the change log
+func (r *dbRepository) AddChange(entry ChangeLog) error {
+	if entry.EntityID == "" || entry.UserName == "" {
+		return fmt.Errorf("no entity or user supplied for the change log")
+	}
+	if entry.Changed.IsZero() {
+		entry.Changed = time.Now().UTC()
+	}
+	entry.ID = 0
+	if h := r.con().Create(&entry); h.Error != nil {
+		return fmt.Errorf("cannot save the change of '%s': %v", entry.EntityID, h.Error)
+	}
+	return nil
+}
+
+// GetChanges returns the changes of the entity made by the user, the most recent change first
+func (r *dbRepository) GetChanges(entityID, username string, limit int) ([]ChangeLog, error) {
+	var changes []ChangeLog
+	h := r.con().Where("entity_id = ? AND user_name = ?", entityID, username).Order("id desc").Limit(limit).Find(&changes)
+	return changes, h.Error
+}
+
+// GetUserChanges returns the changes made by the user, the most recent change first
+func (r *dbRepository) GetUserChanges(username string, limit int) ([]ChangeLog, error) {
+	var changes []ChangeLog
+	h := r.con().Where("user_name = ?", username).Order("id desc").Limit(limit).Find(&changes)
+	return changes, h.Error
+}
+
+// GetChangesSince returns the changes made by the user after the given time, the most recent change first
+func (r *dbRepository) GetChangesSince(username string, since time.Time) ([]ChangeLog, error) {
+	var changes []ChangeLog
+	h := r.con().Where("user_name = ? AND changed > ?", username, since).Order("id desc").Find(&changes)
+	return changes, h.Error
+}
+
+// GetRequestChanges returns the changes of the user made by the request, the most recent change first
+func (r *dbRepository) GetRequestChanges(username, requestID string) ([]ChangeLog, error) {
+	var changes []ChangeLog
+	if requestID == "" {
+		return changes, fmt.Errorf("no request supplied")
+	}
+	h := r.con().Where("user_name = ? AND request_id = ?", username, requestID).Order("id desc").Find(&changes)
+	return changes, h.Error
+}
+
+// Restore creates a deleted bookmark again, the ID and the time of the creation of the bookmark are kept
+func (r *dbRepository) Restore(item Bookmark) (Bookmark, error) {
+	if item.ID == "" {
+		return Bookmark{}, fmt.Errorf("no ID supplied to restore the bookmark")
+	}
+	created := item.Created
+	// clients which kept the version of the deleted bookmark cannot change the restored bookmark
+	item.Version++
+	bm, err := r.Create(item)
+	if err != nil {
+		return Bookmark{}, err
+	}
+	if !created.IsZero() {
+		if h := r.con().Model(&bm).UpdateColumn("created", created); h.Error != nil {
+			return Bookmark{}, fmt.Errorf("cannot restore the creation time of bookmark '%s': %v", bm.ID, h.Error)
+		}
+		bm.Created = created
+	}
+	return bm, nil
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeLog(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	_, err := NewChange(ChangeCreate, "", nil, nil)
+	assert.Error(t, err)
+	assert.Error(t, repo.AddChange(ChangeLog{}))
+
+	bm, err := repo.Create(Bookmark{DisplayName: "A", Path: "/", Type: Node, URL: "http://a", UserName: "username"})
+	if err != nil {
+		t.Fatalf("Could not create bookmark: %v", err)
+	}
+	created, err := NewChange(ChangeCreate, "req-1", nil, &bm)
+	if err != nil {
+		t.Fatalf("cannot create the change: %v", err)
+	}
+	assert.Equal(t, bm.ID, created.EntityID)
+	assert.Equal(t, "username", created.UserName)
+	assert.Equal(t, "", created.Before)
+
+	updated := bm
+	updated.DisplayName = "B"
+	changed, _ := NewChange(ChangeUpdate, "req-2", &bm, &updated)
+	deleted, _ := NewChange(ChangeDelete, "req-3", &updated, nil)
+	other, _ := NewChange(ChangeCreate, "req-4", nil, &Bookmark{ID: "other", UserName: "other"})
+	for _, c := range []ChangeLog{created, changed, deleted, other} {
+		if err := repo.AddChange(c); err != nil {
+			t.Fatalf("cannot add the change: %v", err)
+		}
+	}
+
+	changes, err := repo.GetChanges(bm.ID, "username", 10)
+	if err != nil {
+		t.Fatalf("cannot get the changes: %v", err)
+	}
+	assert.Equal(t, 3, len(changes))
+	assert.Equal(t, ChangeDelete, changes[0].Operation)
+	assert.Equal(t, "req-3", changes[0].RequestID)
+	assert.Equal(t, "", changes[0].After)
+	assert.Equal(t, ChangeCreate, changes[2].Operation)
+
+	var before Bookmark
+	if err := json.Unmarshal([]byte(changes[1].Before), &before); err != nil {
+		t.Fatalf("cannot read the state before the change: %v", err)
+	}
+	assert.Equal(t, "A", before.DisplayName)
+	assert.Equal(t, bm.URL, before.URL)
+
+	changes, _ = repo.GetChanges(bm.ID, "other", 10)
+	assert.Equal(t, 0, len(changes))
+
+	changes, _ = repo.GetUserChanges("username", 2)
+	assert.Equal(t, 2, len(changes))
+	assert.Equal(t, "req-3", changes[0].RequestID)
+
+	// the log is kept after the bookmark was deleted
+	assert.NoError(t, repo.Delete(bm))
+	changes, _ = repo.GetChanges(bm.ID, "username", 10)
+	assert.Equal(t, 3, len(changes))
+}
+
+func TestRequestChangesAndRestore(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	folder, _ := repo.Create(Bookmark{DisplayName: "Work", Path: "/", Type: Folder, UserName: "username"})
+	bm, err := repo.Create(Bookmark{DisplayName: "A", Path: "/Work", Type: Node, URL: "http://a", UserName: "username", AccessCount: 3})
+	if err != nil {
+		t.Fatalf("Could not create bookmark: %v", err)
+	}
+
+	old, _ := NewChange(ChangeCreate, "req-1", nil, &folder)
+	old.Changed = time.Now().UTC().Add(-48 * time.Hour)
+	first, _ := NewChange(ChangeCreate, "req-2", nil, &bm)
+	second, _ := NewChange(ChangeDelete, "req-2", &bm, nil)
+	for _, c := range []ChangeLog{old, first, second} {
+		if err := repo.AddChange(c); err != nil {
+			t.Fatalf("cannot add the change: %v", err)
+		}
+	}
+
+	changes, err := repo.GetRequestChanges("username", "req-2")
+	if err != nil {
+		t.Fatalf("cannot get the changes: %v", err)
+	}
+	assert.Equal(t, 2, len(changes))
+	assert.Equal(t, ChangeDelete, changes[0].Operation)
+	_, err = repo.GetRequestChanges("username", "")
+	assert.Error(t, err)
+
+	changes, _ = repo.GetChangesSince("username", time.Now().UTC().Add(-24*time.Hour))
+	assert.Equal(t, 2, len(changes))
+
+	_, err = repo.Restore(Bookmark{DisplayName: "B", Path: "/", UserName: "username"})
+	assert.Error(t, err)
+	_, err = repo.Restore(bm)
+	assert.Error(t, err, "the bookmark still exists")
+
+	created := bm.Created.Add(-time.Hour)
+	bm.Created = created
+	assert.NoError(t, repo.Delete(bm))
+	restored, err := repo.Restore(bm)
+	if err != nil {
+		t.Fatalf("cannot restore the bookmark: %v", err)
+	}
+	assert.Equal(t, bm.ID, restored.ID)
+	assert.Equal(t, 3, restored.AccessCount)
+	stored, _ := repo.GetBookmarkById(bm.ID, "username")
+	assert.True(t, created.Equal(stored.Created))
+	folder, _ = repo.GetFolderByPath("/Work", "username")
+	assert.Equal(t, 1, folder.ChildCount)
+}
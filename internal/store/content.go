@@ -0,0 +1,97 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// the number of search terms inserted with a single statement
+const termBatchSize = 200
+
+// SaveContent replaces the page content and the search terms of the bookmark
+func (r *dbRepository) SaveContent(content PageContent, terms map[string]int) error {
+	if content.BookmarkID == "" {
+		return fmt.Errorf("no bookmark supplied for the page content")
+	}
+	if content.Indexed.IsZero() {
+		content.Indexed = time.Now().UTC()
+	}
+	if h := r.con().Save(&content); h.Error != nil {
+		return fmt.Errorf("cannot save page content of bookmark '%s': %v", content.BookmarkID, h.Error)
+	}
+	if h := r.con().Where("bookmark_id = ?", content.BookmarkID).Delete(SearchTerm{}); h.Error != nil {
+		return fmt.Errorf("cannot delete search terms of bookmark '%s': %v", content.BookmarkID, h.Error)
+	}
+
+	// insert the terms in a stable order with multi-row statements
+	sorted := make([]string, 0, len(terms))
+	for t := range terms {
+		sorted = append(sorted, t)
+	}
+	sort.Strings(sorted)
+	for len(sorted) > 0 {
+		n := termBatchSize
+		if len(sorted) < n {
+			n = len(sorted)
+		}
+		values := make([]string, 0, n)
+		args := make([]interface{}, 0, n*4)
+		for _, t := range sorted[:n] {
+			values = append(values, "(?, ?, ?, ?)")
+			args = append(args, t, content.BookmarkID, content.UserName, terms[t])
+		}
+		sql := "INSERT INTO SEARCH_TERMS (term, bookmark_id, user_name, frequency) VALUES " + strings.Join(values, ", ")
+		if h := r.con().Exec(sql, args...); h.Error != nil {
+			return fmt.Errorf("cannot save search terms of bookmark '%s': %v", content.BookmarkID, h.Error)
+		}
+		sorted = sorted[n:]
+	}
+	return nil
+}
+
+// DeleteContent removes the page content and the search terms of the bookmark
+func (r *dbRepository) DeleteContent(bookmarkID string) error {
+	if h := r.con().Where("bookmark_id = ?", bookmarkID).Delete(SearchTerm{}); h.Error != nil {
+		return fmt.Errorf("cannot delete search terms of bookmark '%s': %v", bookmarkID, h.Error)
+	}
+	if h := r.con().Where("bookmark_id = ?", bookmarkID).Delete(PageContent{}); h.Error != nil {
+		return fmt.Errorf("cannot delete page content of bookmark '%s': %v", bookmarkID, h.Error)
+	}
+	return nil
+}
+
+// GetPageContents returns the page contents of the given bookmarks
+func (r *dbRepository) GetPageContents(bookmarkIDs []string) ([]PageContent, error) {
+	var contents []PageContent
+	if len(bookmarkIDs) == 0 {
+		return contents, nil
+	}
+	h := r.con().Where("bookmark_id IN (?)", bookmarkIDs).Find(&contents)
+	return contents, h.Error
+}
+
+// SearchContent returns the bookmarks of the user whose page content contains all of the terms.
+// the matches are ordered by the frequency of the terms
+func (r *dbRepository) SearchContent(terms []string, username string, limit int) ([]ContentMatch, error) {
+	var matches []ContentMatch
+	if len(terms) == 0 {
+		return matches, nil
+	}
+	h := r.con().Raw(`SELECT bookmark_id, SUM(frequency) AS score FROM SEARCH_TERMS
+        WHERE user_name = ? AND term IN (?)
+        GROUP BY bookmark_id HAVING COUNT(*) = ?
+        ORDER BY score DESC, bookmark_id LIMIT ?`, username, terms, len(terms), limit).Scan(&matches)
+	return matches, h.Error
+}
+
+// GetBookmarksToIndex returns the nodes of all users which were never indexed or whose URL changed
+// since the last indexing
+func (r *dbRepository) GetBookmarksToIndex(limit int) ([]Bookmark, error) {
+	var bookmarks []Bookmark
+	h := r.con().Raw(`SELECT b.* FROM BOOKMARKS b LEFT JOIN PAGE_CONTENT c ON c.bookmark_id = b.id
+        WHERE b.type = ? AND b.url <> '' AND (c.bookmark_id IS NULL OR c.url <> b.url)
+        ORDER BY b.created LIMIT ?`, Node, limit).Scan(&bookmarks)
+	return bookmarks, h.Error
+}
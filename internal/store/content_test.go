@@ -0,0 +1,99 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentSearch(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	var ids []string
+	for i, url := range []string{"http://a", "http://b", "http://c"} {
+		bm, err := repo.Create(Bookmark{
+			DisplayName: fmt.Sprintf("Node%d", i),
+			Path:        "/",
+			Type:        Node,
+			URL:         url,
+			UserName:    "username",
+		})
+		if err != nil {
+			t.Fatalf("Could not create bookmarks: %v", err)
+		}
+		ids = append(ids, bm.ID)
+	}
+
+	toIndex, err := repo.GetBookmarksToIndex(10)
+	if err != nil {
+		t.Fatalf("cannot get bookmarks to index: %v", err)
+	}
+	assert.Equal(t, 3, len(toIndex))
+
+	assert.Error(t, repo.SaveContent(PageContent{}, nil))
+	assert.NoError(t, repo.SaveContent(PageContent{BookmarkID: ids[0], UserName: "username", URL: "http://a", Content: "go is fun, go go"},
+		map[string]int{"go": 3, "is": 1, "fun": 1}))
+	assert.NoError(t, repo.SaveContent(PageContent{BookmarkID: ids[1], UserName: "username", URL: "http://b", Content: "go rocks"},
+		map[string]int{"go": 1, "rocks": 1}))
+	// pages which cannot be indexed are stored without content
+	assert.NoError(t, repo.SaveContent(PageContent{BookmarkID: ids[2], UserName: "username", URL: "http://c"}, nil))
+
+	// more terms than a single statement supports
+	terms := make(map[string]int)
+	for i := 0; i < termBatchSize+10; i++ {
+		terms[fmt.Sprintf("term%d", i)] = 1
+	}
+	other, _ := repo.Create(Bookmark{DisplayName: "Other", Path: "/", Type: Node, URL: "http://d", UserName: "other"})
+	assert.NoError(t, repo.SaveContent(PageContent{BookmarkID: other.ID, UserName: "other", URL: "http://d", Content: "go"}, terms))
+
+	toIndex, _ = repo.GetBookmarksToIndex(10)
+	assert.Equal(t, 0, len(toIndex))
+
+	matches, err := repo.SearchContent([]string{"go"}, "username", 10)
+	if err != nil {
+		t.Fatalf("cannot search content: %v", err)
+	}
+	assert.Equal(t, []ContentMatch{{BookmarkID: ids[0], Score: 3}, {BookmarkID: ids[1], Score: 1}}, matches)
+
+	// all terms must match
+	matches, _ = repo.SearchContent([]string{"go", "rocks"}, "username", 10)
+	assert.Equal(t, []ContentMatch{{BookmarkID: ids[1], Score: 2}}, matches)
+	matches, _ = repo.SearchContent([]string{"go", "missing"}, "username", 10)
+	assert.Equal(t, 0, len(matches))
+	matches, _ = repo.SearchContent(nil, "username", 10)
+	assert.Equal(t, 0, len(matches))
+	matches, _ = repo.SearchContent([]string{"term205"}, "other", 10)
+	assert.Equal(t, 1, len(matches))
+	matches, _ = repo.SearchContent([]string{"go"}, "username", 1)
+	assert.Equal(t, 1, len(matches))
+
+	contents, err := repo.GetPageContents([]string{ids[0], ids[1]})
+	if err != nil {
+		t.Fatalf("cannot get page contents: %v", err)
+	}
+	assert.Equal(t, 2, len(contents))
+
+	// the content is replaced
+	assert.NoError(t, repo.SaveContent(PageContent{BookmarkID: ids[1], UserName: "username", URL: "http://b", Content: "rust"},
+		map[string]int{"rust": 1}))
+	matches, _ = repo.SearchContent([]string{"go"}, "username", 10)
+	assert.Equal(t, 1, len(matches))
+
+	// a changed URL requires a new indexing
+	bm, _ := repo.GetBookmarkById(ids[0], "username")
+	bm.URL = "http://changed"
+	repo.Update(bm)
+	toIndex, _ = repo.GetBookmarksToIndex(10)
+	assert.Equal(t, 1, len(toIndex))
+
+	assert.NoError(t, repo.DeleteContent(ids[0]))
+	matches, _ = repo.SearchContent([]string{"go"}, "username", 10)
+	assert.Equal(t, 0, len(matches))
+
+	// the index is removed with the bookmark
+	assert.NoError(t, repo.Delete(other))
+	matches, _ = repo.SearchContent([]string{"go"}, "other", 10)
+	assert.Equal(t, 0, len(matches))
+}
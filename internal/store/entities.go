@@ -2,6 +2,7 @@ package store
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -10,22 +11,34 @@ type NodeType int
 const (
 	Node NodeType = iota
 	Folder
+	// SmartFolder is a saved search, the bookmarks of the folder are found by the query
+	SmartFolder
 )
 
 // Bookmark maps the database table to a struct
 type Bookmark struct {
-	ID          string     `gorm:"primary_key;TYPE:varchar(255);COLUMN:id"`
-	Path        string     `gorm:"TYPE:varchar(255);COLUMN:path;NOT NULL;INDEX:IX_PATH;INDEX:IX_PATH_USER"`
-	DisplayName string     `gorm:"TYPE:varchar(128);COLUMN:display_name;NOT NULL"`
-	URL         string     `gorm:"TYPE:varchar(512);COLUMN:url;NOT NULL;INDEX:IX_SORT_ORDER"`
-	SortOrder   int        `gorm:"COLUMN:sort_order;DEFAULT:0;NOT NULL"`
-	Type        NodeType   `gorm:"COLUMN:type;DEFAULT:0;NOT NULL"`
-	UserName    string     `gorm:"TYPE:varchar(128);COLUMN:user_name;NOT NULL;INDEX:IX_USER;INDEX:IX_PATH_USER"`
-	Created     time.Time  `gorm:"COLUMN:created;NOT NULL"`
-	Modified    *time.Time `gorm:"COLUMN:modified"`
-	ChildCount  int        `gorm:"COLUMN:child_count;DEFAULT:0;NOT NULL"`
-	AccessCount int        `gorm:"COLUMN:access_count;DEFAULT:0;NOT NULL"`
-	Favicon     string     `gorm:"TYPE:varchar(128);COLUMN:favicon;NOT NULL"`
+	ID           string       `gorm:"primary_key;TYPE:varchar(255);COLUMN:id"`
+	Path         string       `gorm:"TYPE:varchar(255);COLUMN:path;NOT NULL;INDEX:IX_PATH;INDEX:IX_PATH_USER"`
+	DisplayName  string       `gorm:"TYPE:varchar(128);COLUMN:display_name;NOT NULL"`
+	URL          string       `gorm:"TYPE:varchar(512);COLUMN:url;NOT NULL;INDEX:IX_SORT_ORDER"`
+	SortOrder    int          `gorm:"COLUMN:sort_order;DEFAULT:0;NOT NULL"`
+	Type         NodeType     `gorm:"COLUMN:type;DEFAULT:0;NOT NULL"`
+	UserName     string       `gorm:"TYPE:varchar(128);COLUMN:user_name;NOT NULL;INDEX:IX_USER;INDEX:IX_PATH_USER"`
+	Created      time.Time    `gorm:"COLUMN:created;NOT NULL"`
+	Modified     *time.Time   `gorm:"COLUMN:modified"`
+	ChildCount   int          `gorm:"COLUMN:child_count;DEFAULT:0;NOT NULL"`
+	AccessCount  int          `gorm:"COLUMN:access_count;DEFAULT:0;NOT NULL"`
+	LastAccessed *time.Time   `gorm:"COLUMN:last_accessed;INDEX:IX_LAST_ACCESSED"`
+	Favicon      string       `gorm:"TYPE:varchar(128);COLUMN:favicon;NOT NULL"`
+	Query        string       `gorm:"TYPE:varchar(512);COLUMN:query;DEFAULT:'';NOT NULL"`
+	ReadLater    bool         `gorm:"COLUMN:read_later;DEFAULT:false;NOT NULL;INDEX:IX_READ_LATER"`
+	ReadAt       *time.Time   `gorm:"COLUMN:read_at"`
+	Metadata     PageMetadata `gorm:"embedded"`
+	// Version is incremented with every modification, it is used to detect changes based on an outdated state
+	Version int `gorm:"COLUMN:version;DEFAULT:1;NOT NULL"`
+	// Sequence is the change sequence of the user at the last modification, CreatedSequence at the creation
+	Sequence        int64 `gorm:"COLUMN:sync_sequence;DEFAULT:0;NOT NULL;INDEX:IX_SYNC_SEQUENCE"`
+	CreatedSequence int64 `gorm:"COLUMN:sync_created;DEFAULT:0;NOT NULL"`
 }
 
 func (b Bookmark) String() string {
@@ -37,8 +50,346 @@ func (Bookmark) TableName() string {
 	return "BOOKMARKS"
 }
 
+// PageMetadata is the descriptive information of the page of a bookmark
+type PageMetadata struct {
+	Title        string `gorm:"TYPE:varchar(255);COLUMN:title;DEFAULT:'';NOT NULL"`
+	Description  string `gorm:"TYPE:varchar(1024);COLUMN:description;DEFAULT:'';NOT NULL"`
+	CanonicalURL string `gorm:"TYPE:varchar(512);COLUMN:canonical_url;DEFAULT:'';NOT NULL"`
+	ImageURL     string `gorm:"TYPE:varchar(512);COLUMN:image_url;DEFAULT:'';NOT NULL"`
+	Language     string `gorm:"TYPE:varchar(32);COLUMN:language;DEFAULT:'';NOT NULL"`
+	SiteName     string `gorm:"TYPE:varchar(128);COLUMN:site_name;DEFAULT:'';NOT NULL"`
+}
+
+// IsEmpty checks if no metadata is available
+func (m PageMetadata) IsEmpty() bool {
+	return m == PageMetadata{}
+}
+
+// truncate fits the values into the columns. text is cut, URLs which do not fit are removed
+func (m PageMetadata) truncate() PageMetadata {
+	return PageMetadata{
+		Title:        truncateText(m.Title, 255),
+		Description:  truncateText(m.Description, 1024),
+		CanonicalURL: dropLongText(m.CanonicalURL, 512),
+		ImageURL:     dropLongText(m.ImageURL, 512),
+		Language:     dropLongText(m.Language, 32),
+		SiteName:     truncateText(m.SiteName, 128),
+	}
+}
+
+// truncateText cuts the text to the given number of characters
+func truncateText(s string, n int) string {
+	r := []rune(s)
+	if len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+func dropLongText(s string, n int) string {
+	if len([]rune(s)) > n {
+		return ""
+	}
+	return s
+}
+
 // NodeCount displays the number of child-elements for a given path (1 level)
 type NodeCount struct {
 	Path  string
 	Count int
 }
+
+// LinkStatus is the result of checking the URL of a bookmark
+type LinkStatus string
+
+const (
+	// LinkOK is used for URLs which are available
+	LinkOK LinkStatus = "ok"
+	// LinkRedirected is used for available URLs which redirect to a different location
+	LinkRedirected LinkStatus = "redirected"
+	// LinkBroken is used for URLs which are gone, e.g. 404 or unknown domains
+	LinkBroken LinkStatus = "broken"
+	// LinkError is used for temporary or unclear failures, e.g. timeouts or server errors
+	LinkError LinkStatus = "error"
+)
+
+// LinkHealth holds the latest result of the link check of a bookmark
+type LinkHealth struct {
+	BookmarkID  string     `gorm:"primary_key;TYPE:varchar(255);COLUMN:bookmark_id"`
+	UserName    string     `gorm:"TYPE:varchar(128);COLUMN:user_name;NOT NULL;INDEX:IX_HEALTH_USER"`
+	Status      LinkStatus `gorm:"TYPE:varchar(32);COLUMN:status;NOT NULL;INDEX:IX_HEALTH_STATUS"`
+	StatusCode  int        `gorm:"COLUMN:status_code;DEFAULT:0;NOT NULL"`
+	FinalURL    string     `gorm:"TYPE:varchar(512);COLUMN:final_url;NOT NULL"`
+	Latency     int64      `gorm:"COLUMN:latency;DEFAULT:0;NOT NULL"`
+	Error       string     `gorm:"TYPE:varchar(512);COLUMN:error;NOT NULL"`
+	Failures    int        `gorm:"COLUMN:failures;DEFAULT:0;NOT NULL"`
+	LastChecked time.Time  `gorm:"COLUMN:last_checked;NOT NULL;INDEX:IX_HEALTH_CHECKED"`
+}
+
+// TableName specifies the name of the Table used
+func (LinkHealth) TableName() string {
+	return "LINK_HEALTH"
+}
+
+// LinkCheck is an entry of the history of link checks for a bookmark
+type LinkCheck struct {
+	ID         uint       `gorm:"primary_key;AUTO_INCREMENT;COLUMN:id"`
+	BookmarkID string     `gorm:"TYPE:varchar(255);COLUMN:bookmark_id;NOT NULL;INDEX:IX_CHECK_BOOKMARK"`
+	Status     LinkStatus `gorm:"TYPE:varchar(32);COLUMN:status;NOT NULL"`
+	StatusCode int        `gorm:"COLUMN:status_code;DEFAULT:0;NOT NULL"`
+	FinalURL   string     `gorm:"TYPE:varchar(512);COLUMN:final_url;NOT NULL"`
+	Latency    int64      `gorm:"COLUMN:latency;DEFAULT:0;NOT NULL"`
+	Error      string     `gorm:"TYPE:varchar(512);COLUMN:error;NOT NULL"`
+	Checked    time.Time  `gorm:"COLUMN:checked;NOT NULL;INDEX:IX_CHECK_CHECKED"`
+}
+
+// TableName specifies the name of the Table used
+func (LinkCheck) TableName() string {
+	return "LINK_CHECKS"
+}
+
+// URLSuggestion proposes a new URL for a bookmark, because the URL permanently redirects to a different location
+type URLSuggestion struct {
+	BookmarkID   string    `gorm:"primary_key;TYPE:varchar(255);COLUMN:bookmark_id"`
+	UserName     string    `gorm:"TYPE:varchar(128);COLUMN:user_name;NOT NULL;INDEX:IX_SUGGESTION_USER"`
+	URL          string    `gorm:"TYPE:varchar(512);COLUMN:url;NOT NULL"`
+	SuggestedURL string    `gorm:"TYPE:varchar(512);COLUMN:suggested_url;NOT NULL"`
+	StatusCode   int       `gorm:"COLUMN:status_code;DEFAULT:0;NOT NULL"`
+	Detected     time.Time `gorm:"COLUMN:detected;NOT NULL"`
+}
+
+// TableName specifies the name of the Table used
+func (URLSuggestion) TableName() string {
+	return "URL_SUGGESTIONS"
+}
+
+// URLChangeReason describes why the URL of a bookmark was changed
+type URLChangeReason string
+
+const (
+	// URLChangeManual is used for URLs changed by the user
+	URLChangeManual URLChangeReason = "manual"
+	// URLChangeRedirect is used for applied suggestions of permanent redirects
+	URLChangeRedirect URLChangeReason = "redirect"
+	// URLChangeAutoRedirect is used for permanent redirects which were applied automatically
+	URLChangeAutoRedirect URLChangeReason = "auto-redirect"
+)
+
+// URLHistory keeps the previous URL of a bookmark
+type URLHistory struct {
+	ID         uint            `gorm:"primary_key;AUTO_INCREMENT;COLUMN:id"`
+	BookmarkID string          `gorm:"TYPE:varchar(255);COLUMN:bookmark_id;NOT NULL;INDEX:IX_URL_HISTORY_BOOKMARK"`
+	UserName   string          `gorm:"TYPE:varchar(128);COLUMN:user_name;NOT NULL"`
+	OldURL     string          `gorm:"TYPE:varchar(512);COLUMN:old_url;NOT NULL"`
+	NewURL     string          `gorm:"TYPE:varchar(512);COLUMN:new_url;NOT NULL"`
+	Reason     URLChangeReason `gorm:"TYPE:varchar(32);COLUMN:reason;NOT NULL"`
+	Changed    time.Time       `gorm:"COLUMN:changed;NOT NULL"`
+}
+
+// TableName specifies the name of the Table used
+func (URLHistory) TableName() string {
+	return "URL_HISTORY"
+}
+
+// UserSettings holds the preferences of a user. Read-later bookmarks are marked as read when they are
+// opened, if MarkReadOnOpen is set. Read bookmarks are moved to the folder ReadLaterExpiryPath after
+// ReadLaterExpiryDays, if both values are set
+type UserSettings struct {
+	UserName            string     `gorm:"primary_key;TYPE:varchar(128);COLUMN:user_name"`
+	AutoApplyRedirects  bool       `gorm:"COLUMN:auto_apply_redirects;NOT NULL"`
+	MarkReadOnOpen      bool       `gorm:"COLUMN:mark_read_on_open;DEFAULT:false;NOT NULL"`
+	ReadLaterExpiryDays int        `gorm:"COLUMN:read_later_expiry_days;DEFAULT:0;NOT NULL"`
+	ReadLaterExpiryPath string     `gorm:"TYPE:varchar(255);COLUMN:read_later_expiry_path;DEFAULT:'';NOT NULL"`
+	Modified            *time.Time `gorm:"COLUMN:modified"`
+}
+
+// TableName specifies the name of the Table used
+func (UserSettings) TableName() string {
+	return "USER_SETTINGS"
+}
+
+// Archive is a stored snapshot of the page of a bookmark. The versions of a bookmark are numbered
+// consecutively, the file is named by the hash of the content and may be shared between bookmarks
+type Archive struct {
+	ID         uint      `gorm:"primary_key;AUTO_INCREMENT;COLUMN:id"`
+	BookmarkID string    `gorm:"TYPE:varchar(255);COLUMN:bookmark_id;NOT NULL;UNIQUE_INDEX:UX_ARCHIVES_VERSION"`
+	Version    int       `gorm:"COLUMN:version;NOT NULL;UNIQUE_INDEX:UX_ARCHIVES_VERSION"`
+	UserName   string    `gorm:"TYPE:varchar(128);COLUMN:user_name;NOT NULL"`
+	URL        string    `gorm:"TYPE:varchar(512);COLUMN:url;NOT NULL"`
+	FileName   string    `gorm:"TYPE:varchar(128);COLUMN:file_name;NOT NULL;INDEX:IX_ARCHIVES_FILE"`
+	Size       int64     `gorm:"COLUMN:size;NOT NULL"`
+	Created    time.Time `gorm:"COLUMN:created;NOT NULL"`
+}
+
+// TableName specifies the name of the Table used
+func (Archive) TableName() string {
+	return "ARCHIVES"
+}
+
+// PageContent is the readable text of the page of a bookmark. An empty content is stored if the
+// page could not be indexed, the page is indexed again if the URL of the bookmark changes
+type PageContent struct {
+	BookmarkID string    `gorm:"primary_key;TYPE:varchar(255);COLUMN:bookmark_id"`
+	UserName   string    `gorm:"TYPE:varchar(128);COLUMN:user_name;NOT NULL"`
+	URL        string    `gorm:"TYPE:varchar(512);COLUMN:url;NOT NULL"`
+	Content    string    `gorm:"TYPE:text;COLUMN:content;NOT NULL"`
+	Indexed    time.Time `gorm:"COLUMN:indexed;NOT NULL"`
+}
+
+// TableName specifies the name of the Table used
+func (PageContent) TableName() string {
+	return "PAGE_CONTENT"
+}
+
+// SearchTerm is an entry of the inverted index of the page contents
+type SearchTerm struct {
+	Term       string `gorm:"primary_key;TYPE:varchar(64);COLUMN:term;INDEX:IX_SEARCH_TERMS_USER_TERM"`
+	BookmarkID string `gorm:"primary_key;TYPE:varchar(255);COLUMN:bookmark_id;INDEX:IX_SEARCH_TERMS_BOOKMARK"`
+	UserName   string `gorm:"TYPE:varchar(128);COLUMN:user_name;NOT NULL;INDEX:IX_SEARCH_TERMS_USER_TERM"`
+	Frequency  int    `gorm:"COLUMN:frequency;NOT NULL"`
+}
+
+// TableName specifies the name of the Table used
+func (SearchTerm) TableName() string {
+	return "SEARCH_TERMS"
+}
+
+// Visit records an access of a bookmark
+type Visit struct {
+	ID         uint      `gorm:"primary_key;AUTO_INCREMENT;COLUMN:id"`
+	BookmarkID string    `gorm:"TYPE:varchar(255);COLUMN:bookmark_id;NOT NULL;INDEX:IX_VISITS_BOOKMARK"`
+	UserName   string    `gorm:"TYPE:varchar(128);COLUMN:user_name;NOT NULL;INDEX:IX_VISITS_USER"`
+	Visited    time.Time `gorm:"COLUMN:visited;NOT NULL;INDEX:IX_VISITS_VISITED"`
+}
+
+// TableName specifies the name of the Table used
+func (Visit) TableName() string {
+	return "VISITS"
+}
+
+// ContentMatch is a bookmark found by the full-text search, the score is the frequency of the terms
+type ContentMatch struct {
+	BookmarkID string
+	Score      int
+}
+
+// ChangeOperation is the kind of modification of a bookmark
+type ChangeOperation string
+
+const (
+	// ChangeCreate is used for created bookmarks
+	ChangeCreate ChangeOperation = "create"
+	// ChangeUpdate is used for modified bookmarks
+	ChangeUpdate ChangeOperation = "update"
+	// ChangeDelete is used for deleted bookmarks
+	ChangeDelete ChangeOperation = "delete"
+)
+
+// ChangeLog is an entry of the append-only log of the modifications of bookmarks. Before and After hold the
+// JSON representation of the bookmark, the RequestID is the ID of the request which caused the change
+type ChangeLog struct {
+	ID        uint            `gorm:"primary_key;AUTO_INCREMENT;COLUMN:id"`
+	EntityID  string          `gorm:"TYPE:varchar(255);COLUMN:entity_id;NOT NULL;INDEX:IX_CHANGE_LOG_ENTITY"`
+	UserName  string          `gorm:"TYPE:varchar(128);COLUMN:user_name;NOT NULL;INDEX:IX_CHANGE_LOG_USER"`
+	Operation ChangeOperation `gorm:"TYPE:varchar(32);COLUMN:operation;NOT NULL"`
+	Before    string          `gorm:"TYPE:text;COLUMN:before_state;NOT NULL"`
+	After     string          `gorm:"TYPE:text;COLUMN:after_state;NOT NULL"`
+	RequestID string          `gorm:"TYPE:varchar(128);COLUMN:request_id;NOT NULL;INDEX:IX_CHANGE_LOG_REQUEST"`
+	Changed   time.Time       `gorm:"COLUMN:changed;NOT NULL"`
+}
+
+// TableName specifies the name of the Table used
+func (ChangeLog) TableName() string {
+	return "CHANGE_LOG"
+}
+
+// SyncSequence is the monotonically increasing change sequence of a user, every modification of a bookmark
+// increments the sequence
+type SyncSequence struct {
+	UserName string `gorm:"primary_key;TYPE:varchar(128);COLUMN:user_name"`
+	Value    int64  `gorm:"COLUMN:current_value;DEFAULT:0;NOT NULL"`
+}
+
+// TableName specifies the name of the Table used
+func (SyncSequence) TableName() string {
+	return "SYNC_SEQUENCES"
+}
+
+// Tombstone records the deletion of a bookmark, the sequence is the change sequence of the user at the deletion
+type Tombstone struct {
+	ID         uint      `gorm:"primary_key;AUTO_INCREMENT;COLUMN:id"`
+	BookmarkID string    `gorm:"TYPE:varchar(255);COLUMN:bookmark_id;NOT NULL;INDEX:IX_TOMBSTONES_BOOKMARK"`
+	UserName   string    `gorm:"TYPE:varchar(128);COLUMN:user_name;NOT NULL;INDEX:IX_TOMBSTONES_USER"`
+	Sequence   int64     `gorm:"COLUMN:sync_sequence;NOT NULL;INDEX:IX_TOMBSTONES_SEQUENCE"`
+	Deleted    time.Time `gorm:"COLUMN:deleted;NOT NULL"`
+}
+
+// TableName specifies the name of the Table used
+func (Tombstone) TableName() string {
+	return "TOMBSTONES"
+}
+
+// Webhook is the subscription of a user to the events of the bookmarks. Events is the comma separated list of
+// the subscribed event types, all events are sent for an empty list. The payloads are signed with the secret
+type Webhook struct {
+	ID       string     `gorm:"primary_key;TYPE:varchar(255);COLUMN:id"`
+	UserName string     `gorm:"TYPE:varchar(128);COLUMN:user_name;NOT NULL;INDEX:IX_WEBHOOKS_USER"`
+	URL      string     `gorm:"TYPE:varchar(512);COLUMN:url;NOT NULL"`
+	Secret   string     `gorm:"TYPE:varchar(255);COLUMN:secret;NOT NULL"`
+	Events   string     `gorm:"TYPE:varchar(255);COLUMN:events;NOT NULL"`
+	Active   bool       `gorm:"COLUMN:active;NOT NULL"`
+	Created  time.Time  `gorm:"COLUMN:created;NOT NULL"`
+	Modified *time.Time `gorm:"COLUMN:modified"`
+}
+
+// TableName specifies the name of the Table used
+func (Webhook) TableName() string {
+	return "WEBHOOKS"
+}
+
+// Subscribes checks if the webhook receives the events of the given type
+func (w Webhook) Subscribes(eventType string) bool {
+	if w.Events == "" {
+		return true
+	}
+	for _, e := range strings.Split(w.Events, ",") {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryStatus is the state of the delivery of a webhook payload
+type DeliveryStatus string
+
+const (
+	// DeliveryPending is a delivery which is not yet sent or is sent again
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliveryDelivered is a delivery which was accepted by the receiver
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryFailed is a delivery which is not sent again
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// WebhookDelivery is an entry of the outbox of the webhooks. The pending deliveries are sent at the time of
+// the next attempt, the outcome of the last attempt is kept as the delivery log
+type WebhookDelivery struct {
+	ID          uint           `gorm:"primary_key;AUTO_INCREMENT;COLUMN:id"`
+	WebhookID   string         `gorm:"TYPE:varchar(255);COLUMN:webhook_id;NOT NULL;INDEX:IX_WEBHOOK_DELIVERIES_WEBHOOK"`
+	UserName    string         `gorm:"TYPE:varchar(128);COLUMN:user_name;NOT NULL"`
+	EventType   string         `gorm:"TYPE:varchar(32);COLUMN:event_type;NOT NULL"`
+	Payload     string         `gorm:"TYPE:text;COLUMN:payload;NOT NULL"`
+	Status      DeliveryStatus `gorm:"TYPE:varchar(32);COLUMN:status;NOT NULL;INDEX:IX_WEBHOOK_DELIVERIES_STATUS"`
+	Attempts    int            `gorm:"COLUMN:attempts;NOT NULL"`
+	NextAttempt time.Time      `gorm:"COLUMN:next_attempt;NOT NULL;INDEX:IX_WEBHOOK_DELIVERIES_NEXT"`
+	LastAttempt *time.Time     `gorm:"COLUMN:last_attempt"`
+	StatusCode  int            `gorm:"COLUMN:status_code;NOT NULL"`
+	Error       string         `gorm:"TYPE:varchar(1024);COLUMN:error;NOT NULL"`
+	Created     time.Time      `gorm:"COLUMN:created;NOT NULL"`
+}
+
+// TableName specifies the name of the Table used
+func (WebhookDelivery) TableName() string {
+	return "WEBHOOK_DELIVERIES"
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"strings"
+	"time"
+)
+
+// BookmarkFilter defines the criteria of a search for bookmarks, all given criteria have to match
+type BookmarkFilter struct {
+	// Names are the texts contained in the name of the bookmark
+	Names []string
+	// URLs are the texts contained in the URL of the bookmark
+	URLs []string
+	// Domains are the texts of which at least one is contained in the URL of the bookmark. the
+	// filter cannot check if the text is the host of the URL, the result has to be checked
+	Domains []string
+	// Path is the folder of the bookmark including the sub-folders
+	Path string
+	// NotVisitedSince matches bookmarks never visited or not visited since the given time. for bookmarks
+	// visited before the visits were recorded the time of the creation is used
+	NotVisitedSince time.Time
+	// NeverVisited matches bookmarks which were never visited
+	NeverVisited bool
+	// CreatedAfter matches bookmarks created after the given time
+	CreatedAfter time.Time
+	// CreatedBefore matches bookmarks created before the given time
+	CreatedBefore time.Time
+}
+
+// FindBookmarks returns the nodes of the user which match the filter. A limit of 0 returns all nodes
+func (r *dbRepository) FindBookmarks(filter BookmarkFilter, username string, limit int) ([]Bookmark, error) {
+	q := r.con().Where("user_name = ? AND type = ?", username, Node)
+	for _, name := range filter.Names {
+		q = q.Where("lower(display_name) LIKE ?", "%"+strings.ToLower(name)+"%")
+	}
+	for _, url := range filter.URLs {
+		q = q.Where("lower(url) LIKE ?", "%"+strings.ToLower(url)+"%")
+	}
+	if len(filter.Domains) > 0 {
+		var (
+			conditions []string
+			args       []interface{}
+		)
+		for _, domain := range filter.Domains {
+			conditions = append(conditions, "lower(url) LIKE ?")
+			args = append(args, "%"+strings.ToLower(domain)+"%")
+		}
+		q = q.Where(strings.Join(conditions, " OR "), args...)
+	}
+	if filter.Path != "" && filter.Path != "/" {
+		path := strings.TrimSuffix(filter.Path, "/")
+		q = q.Where("(path = ? OR path LIKE ?)", path, path+"/%")
+	}
+	if !filter.NotVisitedSince.IsZero() {
+		q = q.Where("(access_count = 0 OR COALESCE(last_accessed, created) < ?)", filter.NotVisitedSince)
+	}
+	if filter.NeverVisited {
+		q = q.Where("access_count = 0")
+	}
+	if !filter.CreatedAfter.IsZero() {
+		q = q.Where("created > ?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		q = q.Where("created < ?", filter.CreatedBefore)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+
+	var bookmarks []Bookmark
+	h := q.Order("display_name").Find(&bookmarks)
+	return bookmarks, h.Error
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindBookmarks(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	for _, bm := range []Bookmark{
+		{DisplayName: "Work", Path: "/", Type: Folder},
+		{DisplayName: "Docs", Path: "/Work", Type: Folder},
+		{DisplayName: "Smart", Path: "/", Type: SmartFolder, Query: "domain:github.com"},
+		{DisplayName: "Go Repository", Path: "/Work", Type: Node, URL: "https://github.com/golang/go", AccessCount: 2},
+		{DisplayName: "Go Docs", Path: "/Work/Docs", Type: Node, URL: "https://golang.org/doc"},
+		{DisplayName: "News", Path: "/", Type: Node, URL: "https://news.example.com"},
+		{DisplayName: "Workshop", Path: "/", Type: Node, URL: "https://workshop.example.com"},
+	} {
+		bm.UserName = "username"
+		if _, err := repo.Create(bm); err != nil {
+			t.Fatalf("Could not create bookmarks: %v", err)
+		}
+	}
+	repo.Create(Bookmark{DisplayName: "Go Foreign", Path: "/", Type: Node, URL: "https://github.com/other", UserName: "other"})
+
+	old := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	db.Exec("UPDATE BOOKMARKS SET created = ? WHERE display_name = ?", old, "News")
+	db.Exec("UPDATE BOOKMARKS SET last_accessed = ? WHERE display_name = ?", time.Now().UTC(), "Go Repository")
+
+	names := func(filter BookmarkFilter, limit int) []string {
+		bms, err := repo.FindBookmarks(filter, "username", limit)
+		if err != nil {
+			t.Fatalf("cannot find bookmarks: %v", err)
+		}
+		var result []string
+		for _, bm := range bms {
+			result = append(result, bm.DisplayName)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"Go Docs", "Go Repository", "News", "Workshop"}, names(BookmarkFilter{}, 0))
+	assert.Equal(t, []string{"Go Docs", "Go Repository"}, names(BookmarkFilter{}, 2))
+	assert.Equal(t, []string{"Go Docs", "Go Repository"}, names(BookmarkFilter{Names: []string{"go"}}, 0))
+	assert.Equal(t, []string{"Go Docs"}, names(BookmarkFilter{Names: []string{"go", "DOCS"}}, 0))
+	assert.Equal(t, []string{"Go Docs"}, names(BookmarkFilter{URLs: []string{"golang.org"}}, 0))
+	assert.Equal(t, []string{"Go Docs", "Go Repository"}, names(BookmarkFilter{Domains: []string{"github.com", "golang.org"}}, 0))
+	// the path includes the sub-folders but not folders with the same prefix
+	assert.Equal(t, []string{"Go Docs", "Go Repository"}, names(BookmarkFilter{Path: "/Work"}, 0))
+	assert.Equal(t, []string{"Go Docs"}, names(BookmarkFilter{Path: "/Work/Docs/"}, 0))
+	assert.Equal(t, []string{"Go Docs", "News", "Workshop"}, names(BookmarkFilter{NeverVisited: true}, 0))
+	assert.Equal(t, []string{"Go Docs", "News", "Workshop"}, names(BookmarkFilter{NotVisitedSince: time.Now().UTC().Add(-time.Hour)}, 0))
+	assert.Equal(t, []string{"News"}, names(BookmarkFilter{CreatedBefore: old.Add(time.Hour)}, 0))
+	assert.Equal(t, []string{"Go Docs", "Go Repository", "Workshop"}, names(BookmarkFilter{CreatedAfter: old.Add(time.Hour)}, 0))
+
+	smart, err := repo.GetSmartFolderByPath("/Smart", "username")
+	if err != nil {
+		t.Fatalf("cannot get smart folder: %v", err)
+	}
+	assert.Equal(t, "domain:github.com", smart.Query)
+	_, err = repo.GetFolderByPath("/Smart", "username")
+	assert.Error(t, err)
+	_, err = repo.GetSmartFolderByPath("/Work", "username")
+	assert.Error(t, err)
+
+	// a smart folder is not a path, no children can be created
+	_, err = repo.Create(Bookmark{DisplayName: "Child", Path: "/Smart", Type: Node, URL: "http://child", UserName: "username"})
+	assert.Error(t, err)
+
+	smart.Query = "domain:gitlab.com"
+	smart, err = repo.Update(smart)
+	if err != nil {
+		t.Fatalf("cannot update smart folder: %v", err)
+	}
+	assert.Equal(t, "domain:gitlab.com", smart.Query)
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// SaveLinkCheck adds the check to the history and updates the current link health of the bookmark
+func (r *dbRepository) SaveLinkCheck(check LinkCheck, username string) (LinkHealth, error) {
+	if check.BookmarkID == "" {
+		return LinkHealth{}, fmt.Errorf("no bookmark supplied for the link check")
+	}
+	if check.Checked.IsZero() {
+		check.Checked = time.Now().UTC()
+	}
+	check.ID = 0
+
+	if h := r.con().Create(&check); h.Error != nil {
+		return LinkHealth{}, fmt.Errorf("cannot save link check of bookmark '%s': %v", check.BookmarkID, h.Error)
+	}
+
+	var health LinkHealth
+	h := r.con().Where(&LinkHealth{BookmarkID: check.BookmarkID}).First(&health)
+	if h.Error != nil && !h.RecordNotFound() {
+		return LinkHealth{}, fmt.Errorf("cannot get link health of bookmark '%s': %v", check.BookmarkID, h.Error)
+	}
+
+	// consecutive failures are counted, a successful check resets the counter
+	failures := 0
+	if check.Status == LinkBroken || check.Status == LinkError {
+		failures = health.Failures + 1
+	}
+
+	health = LinkHealth{
+		BookmarkID:  check.BookmarkID,
+		UserName:    username,
+		Status:      check.Status,
+		StatusCode:  check.StatusCode,
+		FinalURL:    check.FinalURL,
+		Latency:     check.Latency,
+		Error:       check.Error,
+		Failures:    failures,
+		LastChecked: check.Checked,
+	}
+	if h := r.con().Save(&health); h.Error != nil {
+		return LinkHealth{}, fmt.Errorf("cannot save link health of bookmark '%s': %v", check.BookmarkID, h.Error)
+	}
+	return health, nil
+}
+
+// GetLinkHealth returns the link health of the bookmarks of the user, optionally filtered by the status
+func (r *dbRepository) GetLinkHealth(username string, status LinkStatus) ([]LinkHealth, error) {
+	var health []LinkHealth
+	q := r.con().Where("user_name = ?", username)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	h := q.Order("last_checked desc").Find(&health)
+	return health, h.Error
+}
+
+// GetLinkChecks returns the most recent checks of the given bookmark
+func (r *dbRepository) GetLinkChecks(bookmarkID string, limit int) ([]LinkCheck, error) {
+	var checks []LinkCheck
+	h := r.con().Where("bookmark_id = ?", bookmarkID).Order("checked desc").Limit(limit).Find(&checks)
+	return checks, h.Error
+}
+
+// GetBookmarksToCheck returns the nodes of all users which were never checked or not since the given time.
+// the bookmarks are ordered by the time of the last check, never checked bookmarks are returned first
+func (r *dbRepository) GetBookmarksToCheck(checkedBefore time.Time, limit int) ([]Bookmark, error) {
+	var bookmarks []Bookmark
+	h := r.con().Raw(`SELECT b.* FROM BOOKMARKS b LEFT JOIN LINK_HEALTH h ON h.bookmark_id = b.id
+        WHERE b.type = ? AND b.url <> '' AND (h.bookmark_id IS NULL OR h.last_checked < ?)
+        ORDER BY h.last_checked LIMIT ?`, Node, checkedBefore, limit).Scan(&bookmarks)
+	return bookmarks, h.Error
+}
+
+// DeleteLinkChecksBefore removes the history of link checks older than the given time
+func (r *dbRepository) DeleteLinkChecksBefore(t time.Time) error {
+	h := r.con().Where("checked < ?", t).Delete(LinkCheck{})
+	return h.Error
+}
+
+// deleteDependents removes the link health, the URL suggestions, the histories, the archives, the indexed
+// content and the visits of the bookmarks matched by the condition. archived files are removed by the garbage collection
+func (r *dbRepository) deleteDependents(where string, args ...interface{}) error {
+	if h := r.con().Where(where, args...).Delete(LinkCheck{}); h.Error != nil {
+		return fmt.Errorf("cannot delete link checks: %v", h.Error)
+	}
+	if h := r.con().Where(where, args...).Delete(LinkHealth{}); h.Error != nil {
+		return fmt.Errorf("cannot delete link health: %v", h.Error)
+	}
+	if h := r.con().Where(where, args...).Delete(URLSuggestion{}); h.Error != nil {
+		return fmt.Errorf("cannot delete URL suggestions: %v", h.Error)
+	}
+	if h := r.con().Where(where, args...).Delete(URLHistory{}); h.Error != nil {
+		return fmt.Errorf("cannot delete URL history: %v", h.Error)
+	}
+	if h := r.con().Where(where, args...).Delete(Archive{}); h.Error != nil {
+		return fmt.Errorf("cannot delete archives: %v", h.Error)
+	}
+	if h := r.con().Where(where, args...).Delete(SearchTerm{}); h.Error != nil {
+		return fmt.Errorf("cannot delete search terms: %v", h.Error)
+	}
+	if h := r.con().Where(where, args...).Delete(PageContent{}); h.Error != nil {
+		return fmt.Errorf("cannot delete page content: %v", h.Error)
+	}
+	if h := r.con().Where(where, args...).Delete(Visit{}); h.Error != nil {
+		return fmt.Errorf("cannot delete visits: %v", h.Error)
+	}
+	return nil
+}
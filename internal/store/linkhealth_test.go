@@ -0,0 +1,115 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveLinkCheck(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	bm, err := repo.Create(Bookmark{
+		DisplayName: "Node",
+		Path:        "/",
+		Type:        Node,
+		URL:         "http://url",
+		UserName:    "username",
+	})
+	if err != nil {
+		t.Fatalf("Could not create bookmarks: %v", err)
+	}
+
+	_, err = repo.SaveLinkCheck(LinkCheck{}, "username")
+	assert.Error(t, err)
+
+	health, err := repo.SaveLinkCheck(LinkCheck{BookmarkID: bm.ID, Status: LinkBroken, StatusCode: 404, FinalURL: "http://url"}, "username")
+	if err != nil {
+		t.Fatalf("cannot save link check: %v", err)
+	}
+	assert.Equal(t, 1, health.Failures)
+	assert.False(t, health.LastChecked.IsZero())
+
+	health, err = repo.SaveLinkCheck(LinkCheck{BookmarkID: bm.ID, Status: LinkError, Error: "timeout"}, "username")
+	if err != nil {
+		t.Fatalf("cannot save link check: %v", err)
+	}
+	assert.Equal(t, 2, health.Failures)
+	assert.Equal(t, LinkError, health.Status)
+
+	list, err := repo.GetLinkHealth("username", LinkError)
+	if err != nil {
+		t.Fatalf("cannot get link health: %v", err)
+	}
+	assert.Equal(t, 1, len(list))
+	assert.Equal(t, "timeout", list[0].Error)
+
+	list, _ = repo.GetLinkHealth("username", LinkBroken)
+	assert.Equal(t, 0, len(list))
+	list, _ = repo.GetLinkHealth("other", "")
+	assert.Equal(t, 0, len(list))
+
+	// a successful check resets the failures
+	health, _ = repo.SaveLinkCheck(LinkCheck{BookmarkID: bm.ID, Status: LinkOK, StatusCode: 200}, "username")
+	assert.Equal(t, 0, health.Failures)
+
+	checks, err := repo.GetLinkChecks(bm.ID, 2)
+	if err != nil {
+		t.Fatalf("cannot get link checks: %v", err)
+	}
+	assert.Equal(t, 2, len(checks))
+
+	// the history is removed with the bookmark
+	assert.NoError(t, repo.Delete(bm))
+	checks, _ = repo.GetLinkChecks(bm.ID, 10)
+	assert.Equal(t, 0, len(checks))
+	list, _ = repo.GetLinkHealth("username", "")
+	assert.Equal(t, 0, len(list))
+}
+
+func TestGetBookmarksToCheck(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	var nodes []Bookmark
+	for _, user := range []string{"user1", "user2", "user2"} {
+		bm, err := repo.Create(Bookmark{
+			DisplayName: "Node",
+			Path:        "/",
+			Type:        Node,
+			URL:         "http://url",
+			UserName:    user,
+		})
+		if err != nil {
+			t.Fatalf("Could not create bookmarks: %v", err)
+		}
+		nodes = append(nodes, bm)
+	}
+	if _, err := repo.Create(Bookmark{DisplayName: "Folder", Path: "/", Type: Folder, UserName: "user1"}); err != nil {
+		t.Fatalf("Could not create bookmarks: %v", err)
+	}
+
+	now := time.Now().UTC()
+	repo.SaveLinkCheck(LinkCheck{BookmarkID: nodes[0].ID, Status: LinkOK, Checked: now.Add(-48 * time.Hour)}, "user1")
+	repo.SaveLinkCheck(LinkCheck{BookmarkID: nodes[1].ID, Status: LinkOK, Checked: now}, "user2")
+
+	// the folder and the recently checked bookmark are skipped, unchecked bookmarks first
+	bookmarks, err := repo.GetBookmarksToCheck(now.Add(-24*time.Hour), 10)
+	if err != nil {
+		t.Fatalf("cannot get bookmarks to check: %v", err)
+	}
+	assert.Equal(t, 2, len(bookmarks))
+	assert.Equal(t, nodes[2].ID, bookmarks[0].ID)
+	assert.Equal(t, nodes[0].ID, bookmarks[1].ID)
+
+	bookmarks, _ = repo.GetBookmarksToCheck(now.Add(-24*time.Hour), 1)
+	assert.Equal(t, 1, len(bookmarks))
+
+	assert.NoError(t, repo.DeleteLinkChecksBefore(now.Add(-time.Hour)))
+	checks, _ := repo.GetLinkChecks(nodes[0].ID, 10)
+	assert.Equal(t, 0, len(checks))
+	checks, _ = repo.GetLinkChecks(nodes[1].ID, 10)
+	assert.Equal(t, 1, len(checks))
+}
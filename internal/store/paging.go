@@ -0,0 +1,358 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// SortKey selects the order of a list of bookmarks
+type SortKey string
+
+const (
+	// SortOrder is the order of the folders, defined by the sort-order and the name
+	SortOrder SortKey = "sortOrder"
+	// SortName orders the bookmarks by the name
+	SortName SortKey = "name"
+	// SortCreated orders the bookmarks by the creation time
+	SortCreated SortKey = "created"
+	// SortModified orders the bookmarks by the time of the last change, unchanged bookmarks by the creation time
+	SortModified SortKey = "modified"
+	// SortAccessCount orders the bookmarks by the number of visits
+	SortAccessCount SortKey = "accessCount"
+	// SortHost orders the bookmarks by the host of the URL
+	SortHost SortKey = "host"
+)
+
+// ErrInvalidCursor is returned for a cursor, which does not belong to the sort order of the list
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListOptions select the order and a page of a list of bookmarks. Without a limit all bookmarks are returned,
+// After is the position of the last bookmark of the previous page
+type ListOptions struct {
+	Sort  SortKey
+	Desc  bool
+	Limit int
+	After *Cursor
+}
+
+// BookmarkPage is a page of a sorted list of bookmarks. Total is the number of bookmarks of all pages, Next is
+// the position of the last bookmark of the page, if more bookmarks follow
+type BookmarkPage struct {
+	Bookmarks []Bookmark
+	Total     int
+	Next      *Cursor
+}
+
+// Cursor is the position of a bookmark in a sorted list, the values of the sort columns of the bookmark
+type Cursor struct {
+	Sort   SortKey
+	Desc   bool
+	Values []interface{}
+}
+
+type cursorToken struct {
+	Sort   SortKey       `json:"s"`
+	Desc   bool          `json:"d,omitempty"`
+	Values []interface{} `json:"v"`
+}
+
+// String encodes the cursor as opaque token
+func (c Cursor) String() string {
+	payload, _ := json.Marshal(cursorToken{Sort: c.Sort, Desc: c.Desc, Values: c.Values})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// ParseCursor decodes the token of a cursor. The cursor has to belong to the given sort order
+func ParseCursor(token string, key SortKey, desc bool) (*Cursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var t cursorToken
+	d := json.NewDecoder(bytes.NewReader(payload))
+	d.UseNumber()
+	if err := d.Decode(&t); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	opts := ListOptions{Sort: key, Desc: desc}
+	columns := sortColumns(opts, "")
+	if opts.key() != normalizeSort(t.Sort) || t.Desc != desc || len(t.Values) != len(columns) {
+		return nil, ErrInvalidCursor
+	}
+
+	c := &Cursor{Sort: t.Sort, Desc: t.Desc, Values: make([]interface{}, len(columns))}
+	for i, col := range columns {
+		if c.Values[i], err = col.parse(t.Values[i]); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return c, nil
+}
+
+func normalizeSort(key SortKey) SortKey {
+	if key == "" {
+		return SortOrder
+	}
+	return key
+}
+
+func (o ListOptions) key() SortKey {
+	return normalizeSort(o.Sort)
+}
+
+// valid checks the sort key of the options
+func (o ListOptions) valid() error {
+	switch o.key() {
+	case SortOrder, SortName, SortCreated, SortModified, SortAccessCount, SortHost:
+		return nil
+	}
+	return fmt.Errorf("invalid sort key '%s'", o.Sort)
+}
+
+// the types of the values of the sort columns
+const (
+	stringColumn = iota
+	intColumn
+	timeColumn
+)
+
+// sortColumn is a column of the order of a list. the bookmark ID is the last column, it makes the order unique
+type sortColumn struct {
+	expr  string
+	desc  bool
+	kind  int
+	value func(bm Bookmark) interface{}
+}
+
+func (c sortColumn) parse(v interface{}) (interface{}, error) {
+	switch c.kind {
+	case intColumn:
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		i, err := n.Int64()
+		return int(i), err
+	case timeColumn:
+		s, ok := v.(string)
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	default:
+		s, ok := v.(string)
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		return s, nil
+	}
+}
+
+// compare returns -1, 0 or 1 if the value of the bookmark is before, equal or after the given value in the
+// direction of the column
+func (c sortColumn) compare(a, b interface{}) int {
+	var result int
+	switch c.kind {
+	case intColumn:
+		x, y := a.(int), b.(int)
+		if x < y {
+			result = -1
+		} else if x > y {
+			result = 1
+		}
+	case timeColumn:
+		x, y := a.(time.Time), b.(time.Time)
+		if x.Before(y) {
+			result = -1
+		} else if x.After(y) {
+			result = 1
+		}
+	default:
+		result = strings.Compare(a.(string), b.(string))
+	}
+	if c.desc {
+		return -result
+	}
+	return result
+}
+
+// sortColumns returns the columns of the order of the options. The name and the ID are used for bookmarks with
+// the same value of the sort key. hostExpr is the SQL expression for the host of the URL
+func sortColumns(opts ListOptions, hostExpr string) []sortColumn {
+	name := sortColumn{expr: "display_name", kind: stringColumn, value: func(bm Bookmark) interface{} { return bm.DisplayName }}
+	id := sortColumn{expr: "id", kind: stringColumn, value: func(bm Bookmark) interface{} { return bm.ID }}
+
+	var columns []sortColumn
+	switch opts.key() {
+	case SortOrder:
+		columns = []sortColumn{
+			{expr: "sort_order", desc: opts.Desc, kind: intColumn, value: func(bm Bookmark) interface{} { return bm.SortOrder }},
+			{expr: name.expr, desc: opts.Desc, kind: name.kind, value: name.value},
+		}
+	case SortName:
+		columns = []sortColumn{{expr: name.expr, desc: opts.Desc, kind: name.kind, value: name.value}}
+	case SortCreated:
+		columns = []sortColumn{
+			{expr: "created", desc: opts.Desc, kind: timeColumn, value: func(bm Bookmark) interface{} { return bm.Created }},
+			name,
+		}
+	case SortModified:
+		columns = []sortColumn{
+			{expr: "COALESCE(modified, created)", desc: opts.Desc, kind: timeColumn, value: func(bm Bookmark) interface{} {
+				if bm.Modified != nil {
+					return *bm.Modified
+				}
+				return bm.Created
+			}},
+			name,
+		}
+	case SortAccessCount:
+		columns = []sortColumn{
+			{expr: "access_count", desc: opts.Desc, kind: intColumn, value: func(bm Bookmark) interface{} { return bm.AccessCount }},
+			name,
+		}
+	case SortHost:
+		columns = []sortColumn{
+			{expr: hostExpr, desc: opts.Desc, kind: stringColumn, value: func(bm Bookmark) interface{} { return urlHost(bm.URL) }},
+			name,
+		}
+	}
+	return append(columns, id)
+}
+
+// urlHost returns the host of the URL like the SQL expression of the host
+func urlHost(url string) string {
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+	}
+	if i := strings.Index(url, "/"); i >= 0 {
+		url = url[:i]
+	}
+	return strings.ToLower(url)
+}
+
+// hostExpr returns the SQL expression for the host of the URL, the string functions differ between the databases
+func (r *dbRepository) hostExpr() string {
+	if r.con().Dialect().GetName() == "mysql" {
+		return "LOWER(SUBSTRING_INDEX(SUBSTRING_INDEX(url, '://', -1), '/', 1))"
+	}
+	rest := "(CASE WHEN instr(url, '://') > 0 THEN substr(url, instr(url, '://') + 3) ELSE url END)"
+	return "lower(CASE WHEN instr(" + rest + ", '/') > 0 THEN substr(" + rest + ", 1, instr(" + rest + ", '/') - 1) ELSE " + rest + " END)"
+}
+
+// list returns the page of the query for the options. the total is counted without the cursor and the limit
+func (r *dbRepository) list(q *gorm.DB, opts ListOptions) (BookmarkPage, error) {
+	if err := opts.valid(); err != nil {
+		return BookmarkPage{}, err
+	}
+	var total int
+	if h := q.Model(&Bookmark{}).Count(&total); h.Error != nil {
+		return BookmarkPage{}, fmt.Errorf("cannot count the bookmarks: %v", h.Error)
+	}
+
+	columns := sortColumns(opts, r.hostExpr())
+	if opts.After != nil {
+		if len(opts.After.Values) != len(columns) {
+			return BookmarkPage{}, ErrInvalidCursor
+		}
+		// the bookmarks after the cursor: the first different column decides the position
+		var (
+			conditions []string
+			args       []interface{}
+		)
+		for i, c := range columns {
+			var parts []string
+			for _, prev := range columns[:i] {
+				parts = append(parts, prev.expr+" = ?")
+			}
+			op := " > ?"
+			if c.desc {
+				op = " < ?"
+			}
+			parts = append(parts, c.expr+op)
+			conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+			args = append(args, opts.After.Values[:i+1]...)
+		}
+		q = q.Where(strings.Join(conditions, " OR "), args...)
+	}
+	for _, c := range columns {
+		if c.desc {
+			q = q.Order(c.expr + " DESC")
+		} else {
+			q = q.Order(c.expr)
+		}
+	}
+	if opts.Limit > 0 {
+		// one more bookmark shows if a next page follows
+		q = q.Limit(opts.Limit + 1)
+	}
+
+	var bookmarks []Bookmark
+	if h := q.Find(&bookmarks); h.Error != nil {
+		return BookmarkPage{}, h.Error
+	}
+	return newPage(bookmarks, total, opts, columns), nil
+}
+
+func newPage(bookmarks []Bookmark, total int, opts ListOptions, columns []sortColumn) BookmarkPage {
+	page := BookmarkPage{Bookmarks: bookmarks, Total: total}
+	if opts.Limit > 0 && len(bookmarks) > opts.Limit {
+		page.Bookmarks = bookmarks[:opts.Limit]
+		last := page.Bookmarks[opts.Limit-1]
+		page.Next = &Cursor{Sort: opts.key(), Desc: opts.Desc, Values: make([]interface{}, len(columns))}
+		for i, c := range columns {
+			page.Next.Values[i] = c.value(last)
+		}
+	}
+	return page
+}
+
+// Paginate sorts the bookmarks and returns the page of the options, like the query methods of the repository
+func Paginate(bookmarks []Bookmark, opts ListOptions) (BookmarkPage, error) {
+	if err := opts.valid(); err != nil {
+		return BookmarkPage{}, err
+	}
+	columns := sortColumns(opts, "")
+	compare := func(a Bookmark, values []interface{}) int {
+		for i, c := range columns {
+			if result := c.compare(c.value(a), values[i]); result != 0 {
+				return result
+			}
+		}
+		return 0
+	}
+	valuesOf := func(bm Bookmark) []interface{} {
+		values := make([]interface{}, len(columns))
+		for i, c := range columns {
+			values[i] = c.value(bm)
+		}
+		return values
+	}
+
+	sorted := make([]Bookmark, len(bookmarks))
+	copy(sorted, bookmarks)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compare(sorted[i], valuesOf(sorted[j])) < 0
+	})
+	if opts.After != nil {
+		if len(opts.After.Values) != len(columns) {
+			return BookmarkPage{}, ErrInvalidCursor
+		}
+		i := sort.Search(len(sorted), func(i int) bool {
+			return compare(sorted[i], opts.After.Values) > 0
+		})
+		sorted = sorted[i:]
+	}
+	if opts.Limit > 0 && len(sorted) > opts.Limit+1 {
+		sorted = sorted[:opts.Limit+1]
+	}
+	return newPage(sorted, len(bookmarks), opts, columns), nil
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func pagingBookmarks(t *testing.T, repo Repository, db *gorm.DB) {
+	created := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i, bm := range []Bookmark{
+		{DisplayName: "Delta", URL: "https://b.example.com/x", SortOrder: 1, AccessCount: 3},
+		{DisplayName: "alpha", URL: "http://A.example.com", SortOrder: 2, AccessCount: 1},
+		{DisplayName: "Charlie", URL: "https://c.example.com/y/z", SortOrder: 0, AccessCount: 3},
+		{DisplayName: "Bravo", URL: "b.example.com", SortOrder: 1, AccessCount: 0},
+		{DisplayName: "Echo", URL: "https://a.example.com/", SortOrder: 0, AccessCount: 5},
+	} {
+		bm.Path = "/"
+		bm.Type = Node
+		bm.UserName = "username"
+		if _, err := repo.Create(bm); err != nil {
+			t.Fatalf("could not create bookmark: %v", err)
+		}
+		db.Exec("UPDATE BOOKMARKS SET created = ? WHERE display_name = ?", created.Add(time.Duration(i)*time.Hour), bm.DisplayName)
+	}
+}
+
+func names(bookmarks []Bookmark) []string {
+	var n []string
+	for _, bm := range bookmarks {
+		n = append(n, bm.DisplayName)
+	}
+	return n
+}
+
+func TestGetBookmarksByPathPages(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+	pagingBookmarks(t, repo, db)
+	db.Exec("UPDATE BOOKMARKS SET modified = ? WHERE display_name = ?", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), "alpha")
+
+	for _, tc := range []struct {
+		opts     ListOptions
+		expected []string
+	}{
+		{ListOptions{}, []string{"Charlie", "Echo", "Bravo", "Delta", "alpha"}},
+		{ListOptions{Desc: true}, []string{"alpha", "Delta", "Bravo", "Echo", "Charlie"}},
+		{ListOptions{Sort: SortName}, []string{"Bravo", "Charlie", "Delta", "Echo", "alpha"}},
+		{ListOptions{Sort: SortCreated, Desc: true}, []string{"Echo", "Bravo", "Charlie", "alpha", "Delta"}},
+		{ListOptions{Sort: SortModified, Desc: true}, []string{"alpha", "Echo", "Bravo", "Charlie", "Delta"}},
+		{ListOptions{Sort: SortAccessCount, Desc: true}, []string{"Echo", "Charlie", "Delta", "alpha", "Bravo"}},
+		{ListOptions{Sort: SortHost}, []string{"Echo", "alpha", "Bravo", "Delta", "Charlie"}},
+	} {
+		// all bookmarks at once
+		page, err := repo.GetBookmarksByPath("/", "username", tc.opts)
+		if err != nil {
+			t.Fatalf("could not get bookmarks: %v", err)
+		}
+		assert.Equal(t, tc.expected, names(page.Bookmarks), "sort %s", tc.opts.Sort)
+		assert.Equal(t, 5, page.Total)
+		assert.Nil(t, page.Next)
+
+		// the same order page by page, the cursor is passed as token
+		var paged []string
+		opts := tc.opts
+		opts.Limit = 2
+		for i := 0; i < 3; i++ {
+			page, err := repo.GetBookmarksByPath("/", "username", opts)
+			if err != nil {
+				t.Fatalf("could not get bookmarks: %v", err)
+			}
+			assert.Equal(t, 5, page.Total)
+			paged = append(paged, names(page.Bookmarks)...)
+			if page.Next == nil {
+				break
+			}
+			if opts.After, err = ParseCursor(page.Next.String(), opts.Sort, opts.Desc); err != nil {
+				t.Fatalf("could not parse the cursor: %v", err)
+			}
+		}
+		assert.Equal(t, tc.expected, paged, "pages of sort %s", tc.opts.Sort)
+
+		// the bookmarks sorted in memory have the same order
+		all, _ := repo.GetBookmarksByPath("/", "username", ListOptions{})
+		page, err = Paginate(all.Bookmarks, tc.opts)
+		if err != nil {
+			t.Fatalf("could not paginate bookmarks: %v", err)
+		}
+		assert.Equal(t, tc.expected, names(page.Bookmarks), "paginate sort %s", tc.opts.Sort)
+	}
+
+	_, err := repo.GetBookmarksByPath("/", "username", ListOptions{Sort: "unknown"})
+	assert.Error(t, err)
+}
+
+func TestPaginate(t *testing.T) {
+	var bookmarks []Bookmark
+	for _, n := range []string{"e", "b", "d", "a", "c"} {
+		bookmarks = append(bookmarks, Bookmark{ID: n, DisplayName: n})
+	}
+	opts := ListOptions{Sort: SortName, Limit: 2}
+	page, err := Paginate(bookmarks, opts)
+	if err != nil {
+		t.Fatalf("could not paginate bookmarks: %v", err)
+	}
+	assert.Equal(t, []string{"a", "b"}, names(page.Bookmarks))
+	assert.Equal(t, 5, page.Total)
+	assert.NotNil(t, page.Next)
+
+	opts.After = page.Next
+	page, _ = Paginate(bookmarks, opts)
+	assert.Equal(t, []string{"c", "d"}, names(page.Bookmarks))
+
+	opts.After = page.Next
+	page, _ = Paginate(bookmarks, opts)
+	assert.Equal(t, []string{"e"}, names(page.Bookmarks))
+	assert.Nil(t, page.Next)
+
+	// the order of the original list is not changed
+	assert.Equal(t, "e", bookmarks[0].DisplayName)
+}
+
+func TestParseCursor(t *testing.T) {
+	c := Cursor{Sort: SortAccessCount, Desc: true, Values: []interface{}{3, "Delta", "id"}}
+
+	parsed, err := ParseCursor(c.String(), SortAccessCount, true)
+	if err != nil {
+		t.Fatalf("could not parse cursor: %v", err)
+	}
+	assert.Equal(t, c, *parsed)
+
+	// the cursor belongs to another order
+	_, err = ParseCursor(c.String(), SortAccessCount, false)
+	assert.Equal(t, ErrInvalidCursor, err)
+	_, err = ParseCursor(c.String(), SortName, true)
+	assert.Equal(t, ErrInvalidCursor, err)
+
+	_, err = ParseCursor("not a cursor", SortAccessCount, true)
+	assert.Equal(t, ErrInvalidCursor, err)
+	invalid := Cursor{Sort: SortAccessCount, Desc: true, Values: []interface{}{"3", "Delta", "id"}}
+	_, err = ParseCursor(invalid.String(), SortAccessCount, true)
+	assert.Equal(t, ErrInvalidCursor, err)
+
+	// the default order is the sortorder
+	c = Cursor{Sort: SortOrder, Values: []interface{}{1, "Delta", "id"}}
+	_, err = ParseCursor(c.String(), "", false)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// GetReadLater returns the read-later bookmarks of the user, the oldest first
+func (r *dbRepository) GetReadLater(username string, unreadOnly bool) ([]Bookmark, error) {
+	var bookmarks []Bookmark
+	q := r.con().Where("user_name = ? AND type = ? AND read_later = ?", username, Node, true)
+	if unreadOnly {
+		q = q.Where("read_at IS NULL")
+	}
+	h := q.Order("created").Order("display_name").Find(&bookmarks)
+	return bookmarks, h.Error
+}
+
+// SetRead marks the bookmark as read or unread
+func (r *dbRepository) SetRead(id, username string, read bool) (Bookmark, error) {
+	var bm Bookmark
+	if h := r.con().Where(&Bookmark{ID: id, UserName: username}).First(&bm); h.Error != nil {
+		return Bookmark{}, fmt.Errorf("cannot get bookmark by id '%s': %v", id, h.Error)
+	}
+
+	var readAt *time.Time
+	if read {
+		now := time.Now().UTC()
+		readAt = &now
+	}
+	seq, err := r.nextSequence(username)
+	if err != nil {
+		return Bookmark{}, err
+	}
+	if h := r.con().Model(&bm).UpdateColumns(map[string]interface{}{"read_at": readAt, "sync_sequence": seq, "version": gorm.Expr("version + 1")}); h.Error != nil {
+		return Bookmark{}, fmt.Errorf("cannot change the read state of bookmark '%s': %v", id, h.Error)
+	}
+	bm.ReadAt = readAt
+	bm.Sequence = seq
+	bm.Version++
+	return bm, nil
+}
+
+// GetReadLaterExpirySettings returns the settings of the users which expire read bookmarks
+func (r *dbRepository) GetReadLaterExpirySettings() ([]UserSettings, error) {
+	var settings []UserSettings
+	h := r.con().Where("read_later_expiry_days > 0 AND read_later_expiry_path <> ''").Find(&settings)
+	return settings, h.Error
+}
+
+// ExpiredBookmark is a bookmark moved by the expiry, the states before and after the move are used for the
+// change log
+type ExpiredBookmark struct {
+	Before Bookmark
+	After  Bookmark
+}
+
+// ExpireReadLater moves the bookmarks of the user, which were read before the given time, to the path and
+// removes them from the read-later bookmarks. The moved bookmarks are returned
+func (r *dbRepository) ExpireReadLater(username, path string, readBefore time.Time) ([]ExpiredBookmark, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no path supplied for the expired bookmarks")
+	}
+	if path != "/" {
+		if _, err := r.folderByPath(path, username, Folder); err != nil {
+			return nil, fmt.Errorf("the path '%s' for the expired bookmarks is not available: %v", path, err)
+		}
+	}
+
+	var expired []Bookmark
+	if h := r.con().Where("user_name = ? AND type = ? AND read_later = ? AND read_at IS NOT NULL AND read_at < ?",
+		username, Node, true, readBefore).Find(&expired); h.Error != nil {
+		return nil, fmt.Errorf("cannot get the expired bookmarks: %v", h.Error)
+	}
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	seq, err := r.nextSequence(username)
+	if err != nil {
+		return nil, err
+	}
+	paths := map[string]bool{path: true}
+	now := time.Now().UTC()
+	moved := make([]ExpiredBookmark, 0, len(expired))
+	for _, bm := range expired {
+		paths[bm.Path] = true
+		before := bm
+		if h := r.con().Model(&bm).Updates(map[string]interface{}{"read_later": false, "path": path, "modified": now, "sync_sequence": seq, "version": gorm.Expr("version + 1")}); h.Error != nil {
+			return nil, fmt.Errorf("cannot move the expired bookmark '%s': %v", bm.ID, h.Error)
+		}
+		after := before
+		after.ReadLater = false
+		after.Path = path
+		after.Modified = &now
+		after.Sequence = seq
+		after.Version++
+		moved = append(moved, ExpiredBookmark{Before: before, After: after})
+	}
+
+	// the child-count of the folders changed, the root path has no folder entry
+	for p := range paths {
+		if p == "/" {
+			continue
+		}
+		folder, err := r.folderByPath(p, username, Folder)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get the folder of path '%s': %v", p, err)
+		}
+		var count int
+		if h := r.con().Model(&Bookmark{}).Where("user_name = ? AND path = ?", username, p).Count(&count); h.Error != nil {
+			return nil, fmt.Errorf("cannot count the child-elements of path '%s': %v", p, h.Error)
+		}
+		if err := r.updateChildCount(&folder, count); err != nil {
+			return nil, err
+		}
+	}
+	return moved, nil
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadLater(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	var ids []string
+	for _, bm := range []Bookmark{
+		{DisplayName: "Inbox", Path: "/", Type: Folder},
+		{DisplayName: "Read", Path: "/", Type: Folder},
+		{DisplayName: "First", Path: "/Inbox", Type: Node, URL: "http://first", ReadLater: true},
+		{DisplayName: "Second", Path: "/Inbox", Type: Node, URL: "http://second", ReadLater: true},
+		{DisplayName: "Third", Path: "/", Type: Node, URL: "http://third", ReadLater: true},
+		{DisplayName: "Permanent", Path: "/Inbox", Type: Node, URL: "http://permanent"},
+	} {
+		bm.UserName = "username"
+		created, err := repo.Create(bm)
+		if err != nil {
+			t.Fatalf("Could not create bookmarks: %v", err)
+		}
+		ids = append(ids, created.ID)
+	}
+	db.Exec("UPDATE BOOKMARKS SET created = ? WHERE display_name = ?", time.Now().UTC().Add(-time.Hour), "Second")
+
+	names := func(bms []Bookmark) []string {
+		var result []string
+		for _, bm := range bms {
+			result = append(result, bm.DisplayName)
+		}
+		return result
+	}
+
+	unread, err := repo.GetReadLater("username", true)
+	if err != nil {
+		t.Fatalf("cannot get read-later bookmarks: %v", err)
+	}
+	assert.Equal(t, []string{"Second", "First", "Third"}, names(unread))
+
+	bm, err := repo.SetRead(ids[2], "username", true)
+	if err != nil {
+		t.Fatalf("cannot mark bookmark as read: %v", err)
+	}
+	assert.NotNil(t, bm.ReadAt)
+	repo.SetRead(ids[3], "username", true)
+	_, err = repo.SetRead("unknown", "username", true)
+	assert.Error(t, err)
+
+	unread, _ = repo.GetReadLater("username", true)
+	assert.Equal(t, []string{"Third"}, names(unread))
+	all, _ := repo.GetReadLater("username", false)
+	assert.Equal(t, []string{"Second", "First", "Third"}, names(all))
+
+	bm, _ = repo.SetRead(ids[3], "username", false)
+	assert.Nil(t, bm.ReadAt)
+	bm, _ = repo.GetBookmarkById(ids[3], "username")
+	assert.Nil(t, bm.ReadAt)
+
+	// an update keeps the read state
+	bm, _ = repo.GetBookmarkById(ids[2], "username")
+	bm.DisplayName = "First!"
+	bm.ReadAt = nil
+	bm, _ = repo.Update(bm)
+	assert.NotNil(t, bm.ReadAt)
+
+	settings, _ := repo.GetReadLaterExpirySettings()
+	assert.Equal(t, 0, len(settings))
+	repo.SaveUserSettings(UserSettings{UserName: "username", ReadLaterExpiryDays: 7, ReadLaterExpiryPath: "/Read"})
+	repo.SaveUserSettings(UserSettings{UserName: "other", ReadLaterExpiryDays: 7})
+	settings, _ = repo.GetReadLaterExpirySettings()
+	if assert.Equal(t, 1, len(settings)) {
+		assert.Equal(t, "/Read", settings[0].ReadLaterExpiryPath)
+	}
+
+	_, err = repo.ExpireReadLater("username", "/Unknown", time.Now().UTC())
+	assert.Error(t, err)
+	expired, err := repo.ExpireReadLater("username", "/Read", time.Now().UTC().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(expired))
+
+	expired, err = repo.ExpireReadLater("username", "/Read", time.Now().UTC().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("cannot expire read bookmarks: %v", err)
+	}
+	if assert.Equal(t, 1, len(expired)) {
+		assert.Equal(t, ids[2], expired[0].Before.ID)
+		assert.True(t, expired[0].Before.ReadLater)
+		assert.Equal(t, "/Read", expired[0].After.Path)
+		assert.False(t, expired[0].After.ReadLater)
+		assert.Equal(t, expired[0].Before.Version+1, expired[0].After.Version)
+	}
+
+	bm, _ = repo.GetBookmarkById(ids[2], "username")
+	assert.Equal(t, "/Read", bm.Path)
+	assert.False(t, bm.ReadLater)
+	assert.Equal(t, expired[0].After.Version, bm.Version)
+	inbox, _ := repo.GetFolderByPath("/Inbox", "username")
+	assert.Equal(t, 2, inbox.ChildCount)
+	read, _ := repo.GetFolderByPath("/Read", "username")
+	assert.Equal(t, 1, read.ChildCount)
+	all, _ = repo.GetReadLater("username", false)
+	assert.Equal(t, []string{"Second", "Third"}, names(all))
+}
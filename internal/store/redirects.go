@@ -0,0 +1,131 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// SaveURLSuggestion creates or replaces the suggested URL of a bookmark
+func (r *dbRepository) SaveURLSuggestion(suggestion URLSuggestion) error {
+	if suggestion.BookmarkID == "" {
+		return fmt.Errorf("no bookmark supplied for the URL suggestion")
+	}
+	if suggestion.Detected.IsZero() {
+		suggestion.Detected = time.Now().UTC()
+	}
+	if h := r.con().Save(&suggestion); h.Error != nil {
+		return fmt.Errorf("cannot save URL suggestion of bookmark '%s': %v", suggestion.BookmarkID, h.Error)
+	}
+	return nil
+}
+
+// DeleteURLSuggestion removes the suggested URL of the bookmark
+func (r *dbRepository) DeleteURLSuggestion(bookmarkID string) error {
+	if h := r.con().Where("bookmark_id = ?", bookmarkID).Delete(URLSuggestion{}); h.Error != nil {
+		return fmt.Errorf("cannot delete URL suggestion of bookmark '%s': %v", bookmarkID, h.Error)
+	}
+	return nil
+}
+
+// GetURLSuggestions returns the suggested URLs for the bookmarks of the user
+func (r *dbRepository) GetURLSuggestions(username string) ([]URLSuggestion, error) {
+	var suggestions []URLSuggestion
+	h := r.con().Where("user_name = ?", username).Order("detected desc").Find(&suggestions)
+	return suggestions, h.Error
+}
+
+// UpdateURL changes the URL of the bookmark. The previous URL is kept in the history and a
+// suggestion for the bookmark is removed
+func (r *dbRepository) UpdateURL(id, username, url string, reason URLChangeReason) (Bookmark, error) {
+	var bm Bookmark
+	if h := r.con().Where(&Bookmark{ID: id, UserName: username}).First(&bm); h.Error != nil {
+		return Bookmark{}, fmt.Errorf("cannot get bookmark by id '%s': %v", id, h.Error)
+	}
+	if bm.Type != Node {
+		return Bookmark{}, fmt.Errorf("the URL of folder '%s' cannot be changed", id)
+	}
+	if url == "" {
+		return Bookmark{}, fmt.Errorf("the URL is empty")
+	}
+
+	seq, err := r.nextSequence(username)
+	if err != nil {
+		return Bookmark{}, err
+	}
+
+	oldURL := bm.URL
+	now := time.Now().UTC()
+	if h := r.con().Model(&bm).Updates(map[string]interface{}{"url": url, "modified": now, "sync_sequence": seq, "version": gorm.Expr("version + 1")}); h.Error != nil {
+		return Bookmark{}, fmt.Errorf("cannot update URL of bookmark '%s': %v", id, h.Error)
+	}
+	bm.URL = url
+	bm.Modified = &now
+	bm.Sequence = seq
+	bm.Version++
+	if oldURL != url {
+		if err := r.AddURLHistory(URLHistory{
+			BookmarkID: id,
+			UserName:   username,
+			OldURL:     oldURL,
+			NewURL:     url,
+			Reason:     reason,
+			Changed:    now,
+		}); err != nil {
+			return Bookmark{}, err
+		}
+	}
+	if err := r.DeleteURLSuggestion(id); err != nil {
+		return Bookmark{}, err
+	}
+	return bm, nil
+}
+
+// AddURLHistory records the change of a bookmark URL
+func (r *dbRepository) AddURLHistory(entry URLHistory) error {
+	if entry.BookmarkID == "" {
+		return fmt.Errorf("no bookmark supplied for the URL history")
+	}
+	if entry.Changed.IsZero() {
+		entry.Changed = time.Now().UTC()
+	}
+	entry.ID = 0
+	if h := r.con().Create(&entry); h.Error != nil {
+		return fmt.Errorf("cannot save URL history of bookmark '%s': %v", entry.BookmarkID, h.Error)
+	}
+	return nil
+}
+
+// GetURLHistory returns the URL changes of the bookmark, the most recent change first
+func (r *dbRepository) GetURLHistory(bookmarkID string) ([]URLHistory, error) {
+	var history []URLHistory
+	h := r.con().Where("bookmark_id = ?", bookmarkID).Order("changed desc, id desc").Find(&history)
+	return history, h.Error
+}
+
+// GetUserSettings returns the settings of the user, the defaults are returned if the user has no settings
+func (r *dbRepository) GetUserSettings(username string) (UserSettings, error) {
+	var settings UserSettings
+	h := r.con().Where("user_name = ?", username).First(&settings)
+	if h.RecordNotFound() {
+		return UserSettings{UserName: username}, nil
+	}
+	if h.Error != nil {
+		return UserSettings{}, fmt.Errorf("cannot get settings of user '%s': %v", username, h.Error)
+	}
+	return settings, nil
+}
+
+// SaveUserSettings creates or replaces the settings of the user
+func (r *dbRepository) SaveUserSettings(settings UserSettings) (UserSettings, error) {
+	if settings.UserName == "" {
+		return UserSettings{}, fmt.Errorf("no user supplied for the settings")
+	}
+	now := time.Now().UTC()
+	settings.Modified = &now
+	if h := r.con().Save(&settings); h.Error != nil {
+		return UserSettings{}, fmt.Errorf("cannot save settings of user '%s': %v", settings.UserName, h.Error)
+	}
+	return settings, nil
+}
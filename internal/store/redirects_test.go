@@ -0,0 +1,112 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURLSuggestions(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	bm, err := repo.Create(Bookmark{
+		DisplayName: "Node",
+		Path:        "/",
+		Type:        Node,
+		URL:         "http://old",
+		UserName:    "username",
+	})
+	if err != nil {
+		t.Fatalf("Could not create bookmarks: %v", err)
+	}
+
+	assert.Error(t, repo.SaveURLSuggestion(URLSuggestion{}))
+	assert.NoError(t, repo.SaveURLSuggestion(URLSuggestion{BookmarkID: bm.ID, UserName: "username", URL: bm.URL, SuggestedURL: "http://moved", StatusCode: 301}))
+	// the suggestion is replaced
+	assert.NoError(t, repo.SaveURLSuggestion(URLSuggestion{BookmarkID: bm.ID, UserName: "username", URL: bm.URL, SuggestedURL: "http://new", StatusCode: 308}))
+
+	suggestions, err := repo.GetURLSuggestions("username")
+	if err != nil {
+		t.Fatalf("cannot get URL suggestions: %v", err)
+	}
+	assert.Equal(t, 1, len(suggestions))
+	assert.Equal(t, "http://new", suggestions[0].SuggestedURL)
+	assert.False(t, suggestions[0].Detected.IsZero())
+	suggestions, _ = repo.GetURLSuggestions("other")
+	assert.Equal(t, 0, len(suggestions))
+
+	_, err = repo.UpdateURL(bm.ID, "other", "http://new", URLChangeRedirect)
+	assert.Error(t, err)
+	_, err = repo.UpdateURL(bm.ID, "username", "", URLChangeRedirect)
+	assert.Error(t, err)
+
+	updated, err := repo.UpdateURL(bm.ID, "username", "http://new", URLChangeRedirect)
+	if err != nil {
+		t.Fatalf("cannot update URL: %v", err)
+	}
+	assert.Equal(t, "http://new", updated.URL)
+	assert.NotNil(t, updated.Modified)
+
+	stored, _ := repo.GetBookmarkById(bm.ID, "username")
+	assert.Equal(t, "http://new", stored.URL)
+
+	// the suggestion is applied
+	suggestions, _ = repo.GetURLSuggestions("username")
+	assert.Equal(t, 0, len(suggestions))
+
+	history, err := repo.GetURLHistory(bm.ID)
+	if err != nil {
+		t.Fatalf("cannot get URL history: %v", err)
+	}
+	assert.Equal(t, 1, len(history))
+	assert.Equal(t, "http://old", history[0].OldURL)
+	assert.Equal(t, "http://new", history[0].NewURL)
+	assert.Equal(t, URLChangeRedirect, history[0].Reason)
+
+	assert.Error(t, repo.AddURLHistory(URLHistory{}))
+	assert.NoError(t, repo.AddURLHistory(URLHistory{BookmarkID: bm.ID, UserName: "username", OldURL: "http://new", NewURL: "http://manual", Reason: URLChangeManual}))
+	history, _ = repo.GetURLHistory(bm.ID)
+	assert.Equal(t, 2, len(history))
+	assert.Equal(t, URLChangeManual, history[0].Reason)
+
+	// suggestions and history are removed with the bookmark
+	assert.NoError(t, repo.SaveURLSuggestion(URLSuggestion{BookmarkID: bm.ID, UserName: "username", URL: bm.URL, SuggestedURL: "http://other"}))
+	assert.NoError(t, repo.Delete(bm))
+	suggestions, _ = repo.GetURLSuggestions("username")
+	assert.Equal(t, 0, len(suggestions))
+	history, _ = repo.GetURLHistory(bm.ID)
+	assert.Equal(t, 0, len(history))
+}
+
+func TestUserSettings(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	settings, err := repo.GetUserSettings("username")
+	if err != nil {
+		t.Fatalf("cannot get settings: %v", err)
+	}
+	assert.Equal(t, "username", settings.UserName)
+	assert.False(t, settings.AutoApplyRedirects)
+
+	_, err = repo.SaveUserSettings(UserSettings{})
+	assert.Error(t, err)
+
+	settings, err = repo.SaveUserSettings(UserSettings{UserName: "username", AutoApplyRedirects: true})
+	if err != nil {
+		t.Fatalf("cannot save settings: %v", err)
+	}
+	assert.NotNil(t, settings.Modified)
+
+	settings, _ = repo.GetUserSettings("username")
+	assert.True(t, settings.AutoApplyRedirects)
+
+	_, err = repo.SaveUserSettings(UserSettings{UserName: "username", AutoApplyRedirects: false})
+	assert.NoError(t, err)
+	settings, _ = repo.GetUserSettings("username")
+	assert.False(t, settings.AutoApplyRedirects)
+
+	settings, _ = repo.GetUserSettings("other")
+	assert.False(t, settings.AutoApplyRedirects)
+}
@@ -5,6 +5,7 @@ package store
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -21,19 +22,96 @@ type Repository interface {
 	Update(item Bookmark) (Bookmark, error)
 	Delete(item Bookmark) error
 	DeletePath(path, username string) error
+	UpdateChildCountOfPath(path, username string) error
+	AddAccessCount(id, username string, count int) error
 
 	GetAllBookmarks(username string) ([]Bookmark, error)
-	GetBookmarksByPath(path, username string) ([]Bookmark, error)
+	GetBookmarksByPath(path, username string, opts ListOptions) (BookmarkPage, error)
 	GetBookmarksByPathStart(path, username string) ([]Bookmark, error)
-	GetBookmarksByName(name, username string) ([]Bookmark, error)
-	GetMostRecentBookmarks(username string, limit int) ([]Bookmark, error)
+	GetBookmarkTree(path, username string) ([]Bookmark, error)
+	GetBookmarksByName(name, username string, opts ListOptions) (BookmarkPage, error)
+	GetMostRecentBookmarks(username string, opts ListOptions) (BookmarkPage, error)
 	GetPathChildCount(path, username string) ([]NodeCount, error)
 	GetAllPaths(username string) ([]string, error)
 
 	GetBookmarkById(id, username string) (Bookmark, error)
+	GetBookmarksByIds(ids []string, username string) ([]Bookmark, error)
+	IDExists(id string) (bool, error)
 	GetFolderByPath(path, username string) (Bookmark, error)
+	GetSmartFolderByPath(path, username string) (Bookmark, error)
+	FindBookmarks(filter BookmarkFilter, username string, limit int) ([]Bookmark, error)
+
+	GetFaviconReferences(favicon string) (int, error)
+	GetAllFavicons() ([]string, error)
+
+	SaveLinkCheck(check LinkCheck, username string) (LinkHealth, error)
+	GetLinkHealth(username string, status LinkStatus) ([]LinkHealth, error)
+	GetLinkChecks(bookmarkID string, limit int) ([]LinkCheck, error)
+	GetBookmarksToCheck(checkedBefore time.Time, limit int) ([]Bookmark, error)
+	DeleteLinkChecksBefore(t time.Time) error
+
+	SaveURLSuggestion(suggestion URLSuggestion) error
+	DeleteURLSuggestion(bookmarkID string) error
+	GetURLSuggestions(username string) ([]URLSuggestion, error)
+	UpdateURL(id, username, url string, reason URLChangeReason) (Bookmark, error)
+	AddURLHistory(entry URLHistory) error
+	GetURLHistory(bookmarkID string) ([]URLHistory, error)
+
+	GetUserSettings(username string) (UserSettings, error)
+	SaveUserSettings(settings UserSettings) (UserSettings, error)
+
+	CreateArchive(archive Archive) (Archive, error)
+	GetArchives(bookmarkID string) ([]Archive, error)
+	GetArchive(bookmarkID string, version int) (Archive, error)
+	DeleteArchive(id uint) error
+	GetArchiveReferences(fileName string) (int, error)
+	GetAllArchiveFiles() ([]string, error)
+
+	SaveContent(content PageContent, terms map[string]int) error
+	DeleteContent(bookmarkID string) error
+	GetPageContents(bookmarkIDs []string) ([]PageContent, error)
+	SearchContent(terms []string, username string, limit int) ([]ContentMatch, error)
+	GetBookmarksToIndex(limit int) ([]Bookmark, error)
+
+	AddVisit(visit Visit) error
+	GetVisits(bookmarkID string, limit int) ([]Visit, error)
+	MoveVisits(fromID, toID, username string) error
+	GetRecentlyVisited(username string, limit int) ([]Bookmark, error)
+	GetUserVisits(username string, since time.Time) ([]Visit, error)
+	DeleteVisitsBefore(t time.Time) error
+
+	GetStatistics(username string, opts StatisticsOptions) (Statistics, error)
+
+	GetReadLater(username string, unreadOnly bool) ([]Bookmark, error)
+	SetRead(id, username string, read bool) (Bookmark, error)
+	GetReadLaterExpirySettings() ([]UserSettings, error)
+	ExpireReadLater(username, path string, readBefore time.Time) ([]ExpiredBookmark, error)
+
+	AddChange(entry ChangeLog) error
+	GetChanges(entityID, username string, limit int) ([]ChangeLog, error)
+	GetUserChanges(username string, limit int) ([]ChangeLog, error)
+	GetChangesSince(username string, since time.Time) ([]ChangeLog, error)
+	GetRequestChanges(username, requestID string) ([]ChangeLog, error)
+	Restore(item Bookmark) (Bookmark, error)
+
+	GetSequence(username string) (int64, error)
+	GetChangedBookmarks(username string, since int64) ([]Bookmark, error)
+	GetTombstones(username string, since int64) ([]Tombstone, error)
+
+	GetWebhooks(username string) ([]Webhook, error)
+	GetWebhookByID(id, username string) (Webhook, error)
+	SaveWebhook(webhook Webhook) (Webhook, error)
+	DeleteWebhook(id, username string) error
+	AddDelivery(delivery WebhookDelivery) (WebhookDelivery, error)
+	UpdateDelivery(delivery WebhookDelivery) error
+	GetDueDeliveries(now time.Time, limit int) ([]WebhookDelivery, error)
+	GetDeliveries(webhookID string, limit int) ([]WebhookDelivery, error)
+	DeleteDeliveriesBefore(t time.Time) error
 }
 
+// ErrStaleVersion is returned for a modification of a bookmark, which is based on an outdated version
+var ErrStaleVersion = errors.New("the bookmark was changed in the meantime")
+
 // Create a new repository
 func Create(db *gorm.DB) Repository {
 	return &dbRepository{
@@ -42,6 +120,11 @@ func Create(db *gorm.DB) Repository {
 	}
 }
 
+// Migrate creates the tables of the store and adds missing columns and indexes
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Bookmark{}, &LinkHealth{}, &LinkCheck{}, &URLSuggestion{}, &URLHistory{}, &UserSettings{}, &Archive{}, &PageContent{}, &SearchTerm{}, &Visit{}, &ChangeLog{}, &SyncSequence{}, &Tombstone{}, &Webhook{}, &WebhookDelivery{}).Error
+}
+
 // --------------------------------------------------------------------------
 // Implementation
 // --------------------------------------------------------------------------
@@ -75,14 +158,12 @@ func (r *dbRepository) GetAllBookmarks(username string) ([]Bookmark, error) {
 	return bookmarks, h.Error
 }
 
-// GetBookmarksByPath return the bookmark elements which have the given path
-func (r *dbRepository) GetBookmarksByPath(path, username string) ([]Bookmark, error) {
-	var bookmarks []Bookmark
-	h := r.con().Order("sort_order").Order("display_name").Where(&Bookmark{
+// GetBookmarksByPath return the page of the bookmark elements which have the given path
+func (r *dbRepository) GetBookmarksByPath(path, username string, opts ListOptions) (BookmarkPage, error) {
+	return r.list(r.con().Where(&Bookmark{
 		UserName: username,
 		Path:     path,
-	}).Find(&bookmarks)
-	return bookmarks, h.Error
+	}), opts)
 }
 
 // GetBookmarksByPathStart return the bookmark elements which path starts with
@@ -96,22 +177,32 @@ func (r *dbRepository) GetBookmarksByPathStart(path, username string) ([]Bookmar
 	return bookmarks, h.Error
 }
 
-// GetBookmarksByName searches for bookmarks by the given name
-func (r *dbRepository) GetBookmarksByName(name, username string) ([]Bookmark, error) {
+// GetBookmarkTree returns the bookmark elements of the path and of all sub-folders, ordered by the path, the
+// sort-order and the name
+func (r *dbRepository) GetBookmarkTree(path, username string) ([]Bookmark, error) {
 	var bookmarks []Bookmark
-	h := r.con().Order("sort_order").Order("display_name").
-		Where("user_name = ? AND lower(display_name) LIKE ?", username, "%"+strings.ToLower(name)+"%").Find(&bookmarks)
+	q := r.con().Where("user_name = ?", username)
+	if path != "/" {
+		q = q.Where("path = ? OR path LIKE ?", path, path+"/%")
+	}
+	h := q.Order("path").Order("sort_order").Order("display_name").Find(&bookmarks)
 	return bookmarks, h.Error
 }
 
-// GetMostRecentBookmarks returns bookmarks which where recently visited
-func (r *dbRepository) GetMostRecentBookmarks(username string, limit int) ([]Bookmark, error) {
-	var bookmarks []Bookmark
-	h := r.con().
-		Limit(limit).
-		Order("access_count DESC").Order("display_name").
-		Where("user_name = ? AND type = ? AND access_count > 0", username, Node).Find(&bookmarks)
-	return bookmarks, h.Error
+// GetBookmarksByName searches for bookmarks by the given name
+func (r *dbRepository) GetBookmarksByName(name, username string, opts ListOptions) (BookmarkPage, error) {
+	return r.list(r.con().
+		Where("user_name = ? AND lower(display_name) LIKE ?", username, "%"+strings.ToLower(name)+"%"), opts)
+}
+
+// GetMostRecentBookmarks returns bookmarks which where recently visited, without a sort key the most visited
+// bookmarks are returned first
+func (r *dbRepository) GetMostRecentBookmarks(username string, opts ListOptions) (BookmarkPage, error) {
+	if opts.Sort == "" {
+		opts.Sort, opts.Desc = SortAccessCount, true
+	}
+	return r.list(r.con().
+		Where("user_name = ? AND type = ? AND access_count > 0", username, Node), opts)
 }
 
 // GetBookmarkById returns the bookmark specified by the given id - for the user
@@ -121,8 +212,34 @@ func (r *dbRepository) GetBookmarkById(id, username string) (Bookmark, error) {
 	return bookmark, h.Error
 }
 
+// GetBookmarksByIds returns the bookmarks specified by the given ids - for the user
+func (r *dbRepository) GetBookmarksByIds(ids []string, username string) ([]Bookmark, error) {
+	var bookmarks []Bookmark
+	if len(ids) == 0 {
+		return bookmarks, nil
+	}
+	h := r.con().Where("user_name = ? AND id IN (?)", username, ids).Find(&bookmarks)
+	return bookmarks, h.Error
+}
+
+// IDExists checks if a bookmark with the given id exists, regardless of the user
+func (r *dbRepository) IDExists(id string) (bool, error) {
+	var count int
+	h := r.con().Model(&Bookmark{}).Where("id = ?", id).Count(&count)
+	return count > 0, h.Error
+}
+
 // GetFolderByPath returns the bookmark folder elements specified by path
 func (r *dbRepository) GetFolderByPath(path, username string) (Bookmark, error) {
+	return r.folderByPath(path, username, Folder)
+}
+
+// GetSmartFolderByPath returns the smart folder specified by path
+func (r *dbRepository) GetSmartFolderByPath(path, username string) (Bookmark, error) {
+	return r.folderByPath(path, username, SmartFolder)
+}
+
+func (r *dbRepository) folderByPath(path, username string, t NodeType) (Bookmark, error) {
 	var bookmark Bookmark
 
 	// ROOT path is virtual
@@ -139,7 +256,7 @@ func (r *dbRepository) GetFolderByPath(path, username string) (Bookmark, error)
 		UserName:    username,
 		Path:        parent,
 		DisplayName: folderName,
-		Type:        Folder,
+		Type:        t,
 	}).First(&bookmark)
 
 	return bookmark, h.Error
@@ -172,6 +289,20 @@ func (r *dbRepository) GetAllPaths(username string) ([]string, error) {
 	return r.availablePaths(username)
 }
 
+// GetFaviconReferences returns the number of bookmarks using the given favicon, regardless of the user
+func (r *dbRepository) GetFaviconReferences(favicon string) (int, error) {
+	var count int
+	h := r.con().Model(&Bookmark{}).Where("favicon = ?", favicon).Count(&count)
+	return count, h.Error
+}
+
+// GetAllFavicons returns the distinct favicons referenced by the bookmarks of all users
+func (r *dbRepository) GetAllFavicons() ([]string, error) {
+	var favicons []string
+	h := r.con().Model(&Bookmark{}).Where("favicon <> ''").Pluck("DISTINCT(favicon)", &favicons)
+	return favicons, h.Error
+}
+
 // modify data
 // --------------------------------------------------------------------------
 
@@ -215,9 +346,24 @@ func (r *dbRepository) Create(item Bookmark) (Bookmark, error) {
 		}
 	}
 
+	seq, err := r.nextSequence(item.UserName)
+	if err != nil {
+		return Bookmark{}, err
+	}
+	item.Sequence = seq
+	item.CreatedSequence = seq
+	if item.Version == 0 {
+		item.Version = 1
+	}
+
+	item.Metadata = item.Metadata.truncate()
 	if h := r.con().Create(&item); h.Error != nil {
 		return Bookmark{}, h.Error
 	}
+	// a restored bookmark is no longer deleted
+	if h := r.con().Where("bookmark_id = ?", item.ID).Delete(Tombstone{}); h.Error != nil {
+		return Bookmark{}, fmt.Errorf("cannot remove the deletion of bookmark '%s': %v", item.ID, h.Error)
+	}
 
 	// this entry (either node or folder) was created with a given path. increment the number of child-elements
 	// for this given path, and update the "parent" directory entry.
@@ -275,15 +421,42 @@ func (r *dbRepository) Update(item Bookmark) (Bookmark, error) {
 		}
 	}
 
+	// the counters are not part of the update, they are written by UpdateChildCount and AddVisit
+	item.AccessCount = bm.AccessCount
+	item.ChildCount = bm.ChildCount
+	item.Metadata = item.Metadata.truncate()
+	if !visibleChange(bm, item) {
+		return bm, nil
+	}
+
+	// the version is checked and incremented in one statement, a concurrent modification of the version
+	// which was read before is detected
+	h = r.con().Model(&Bookmark{}).Where("id = ? AND user_name = ? AND version = ?", bm.ID, bm.UserName, bm.Version).
+		UpdateColumn("version", bm.Version+1)
+	if h.Error != nil {
+		return Bookmark{}, fmt.Errorf("cannot update the version of bookmark '%s': %v", item.ID, h.Error)
+	}
+	if h.RowsAffected == 0 {
+		return Bookmark{}, ErrStaleVersion
+	}
+
+	seq, err := r.nextSequence(item.UserName)
+	if err != nil {
+		return Bookmark{}, err
+	}
+
 	now := time.Now().UTC()
 	bm.Modified = &now
+	bm.Sequence = seq
+	bm.Version++
 	bm.DisplayName = item.DisplayName
 	bm.Path = item.Path
 	bm.SortOrder = item.SortOrder
 	bm.URL = item.URL
 	bm.Favicon = item.Favicon
-	bm.AccessCount = item.AccessCount
-	bm.ChildCount = item.ChildCount
+	bm.Query = item.Query
+	bm.ReadLater = item.ReadLater
+	bm.Metadata = item.Metadata
 
 	h = r.con().Save(&bm)
 	if h.Error != nil {
@@ -292,6 +465,50 @@ func (r *dbRepository) Update(item Bookmark) (Bookmark, error) {
 	return bm, nil
 }
 
+// visibleChange checks if the update modifies one of the fields which are maintained by the user
+func visibleChange(current, item Bookmark) bool {
+	return current.DisplayName != item.DisplayName ||
+		current.Path != item.Path ||
+		current.SortOrder != item.SortOrder ||
+		current.URL != item.URL ||
+		current.Favicon != item.Favicon ||
+		current.Query != item.Query ||
+		current.ReadLater != item.ReadLater ||
+		current.Metadata != item.Metadata
+}
+
+// UpdateChildCountOfPath counts the child-elements of the folder with the given path and stores the count. The
+// count does not change the version of the folder, the root path has no folder
+func (r *dbRepository) UpdateChildCountOfPath(path, username string) error {
+	if path == "/" {
+		return nil
+	}
+	folder, err := r.GetFolderByPath(path, username)
+	if err != nil {
+		return fmt.Errorf("could not get folder of given path '%s'", path)
+	}
+	nodes, err := r.GetPathChildCount(path, username)
+	if err != nil {
+		return fmt.Errorf("could not get child-count of given path '%s'", path)
+	}
+
+	var count int
+	if len(nodes) > 0 {
+		// the count was queried for the specific path
+		count = nodes[0].Count
+	}
+	return r.updateChildCount(&folder, count)
+}
+
+// AddAccessCount adds the given number of accesses to the bookmark, the version is not changed
+func (r *dbRepository) AddAccessCount(id, username string, count int) error {
+	if h := r.con().Model(&Bookmark{}).Where("id = ? AND user_name = ?", id, username).
+		UpdateColumn("access_count", gorm.Expr("access_count + ?", count)); h.Error != nil {
+		return fmt.Errorf("cannot update the access-count of bookmark '%s': %v", id, h.Error)
+	}
+	return nil
+}
+
 // Delete removes the bookmark identified by id
 func (r *dbRepository) Delete(item Bookmark) error {
 	var (
@@ -317,11 +534,21 @@ func (r *dbRepository) Delete(item Bookmark) error {
 		}
 	}
 
-	h = r.con().Delete(&bm)
+	h = r.con().Where("version = ?", bm.Version).Delete(&bm)
 	if h.Error != nil {
 		return fmt.Errorf("cannot delete bookmark by id '%s': %v", item.ID, h.Error)
 	}
-	return nil
+	if h.RowsAffected == 0 {
+		return ErrStaleVersion
+	}
+	if err := r.deleteDependents("bookmark_id = ?", bm.ID); err != nil {
+		return err
+	}
+	seq, err := r.nextSequence(bm.UserName)
+	if err != nil {
+		return err
+	}
+	return r.addTombstones(bm.UserName, seq, bm.ID)
 }
 
 // DeletePath removes all bookmarks having the same path
@@ -334,6 +561,15 @@ func (r *dbRepository) DeletePath(path, username string) error {
 		return fmt.Errorf("cannot delete the root path")
 	}
 
+	var ids []string
+	if h := r.con().Model(&Bookmark{}).Where("user_name = ? AND path LIKE ?", username, path+"%").Pluck("id", &ids); h.Error != nil {
+		return fmt.Errorf("cannot get the bookmarks of path '%s': %v", path, h.Error)
+	}
+
+	if err := r.deleteDependents("bookmark_id IN (SELECT id FROM BOOKMARKS WHERE user_name = ? AND path LIKE ?)", username, path+"%"); err != nil {
+		return err
+	}
+
 	h := r.con().Where("user_name = ? AND path LIKE ?", username, path+"%").Delete(Bookmark{})
 	if h.Error != nil {
 		return fmt.Errorf("no bookmarks available for path '%s': %v", path, h.Error)
@@ -348,31 +584,21 @@ func (r *dbRepository) DeletePath(path, username string) error {
 		return fmt.Errorf("cannot delete folder '%s': %v", path, h.Error)
 	}
 
+	seq, err := r.nextSequence(username)
+	if err != nil {
+		return err
+	}
+	if err := r.addTombstones(username, seq, append(ids, folder.ID)...); err != nil {
+		return err
+	}
+
 	// entries with path /pa/th were deleted - also the folder /pa - th needs to be deleted
 	parentPath, _, ok := pathAndFolder(path)
 	if !ok {
 		return fmt.Errorf("could not get parent-path/folder of given path '%s'", path)
 	}
 
-	if parentPath != "/" {
-		parentFolder, err := r.GetFolderByPath(parentPath, username)
-		if err != nil {
-			return fmt.Errorf("could not get folder of given path '%s'", parentPath)
-		}
-		nodes, err := r.GetPathChildCount(parentPath, username)
-		if err != nil {
-			return fmt.Errorf("could not get child-count of given path '%s'", parentPath)
-		}
-
-		var count int
-		if len(nodes) > 0 {
-			count = nodes[0].Count
-		}
-
-		return r.updateChildCount(&parentFolder, count)
-	}
-
-	return nil
+	return r.UpdateChildCountOfPath(parentPath, username)
 }
 
 // --------------------------------------------------------------------------
@@ -389,9 +615,10 @@ func (r *dbRepository) con() *gorm.DB {
 	return r.transient
 }
 
+// updateChildCount writes the counter only, the version and the time of the modification are kept
 func (r *dbRepository) updateChildCount(folder *Bookmark, count int) error {
-	if h := r.con().Model(folder).Update(
-		map[string]interface{}{"child_count": count, "modified": time.Now().UTC()}); h.Error != nil {
+	if h := r.con().Model(&Bookmark{}).Where("id = ? AND user_name = ?", folder.ID, folder.UserName).
+		UpdateColumn("child_count", count); h.Error != nil {
 		return fmt.Errorf("cannot update item '%+v': %v", *folder, h.Error)
 	}
 	return nil
@@ -460,6 +687,18 @@ func (r *dbRepository) calcChildCount(path, username string, fn func(i int) int)
 	return r.updateChildCount(&bm, count)
 }
 
+// FolderPath is the path of the child-elements of the folder with the given path and name
+func FolderPath(path, name string) string {
+	folderPath := path
+	if !strings.HasSuffix(path, "/") {
+		folderPath = path + "/"
+	}
+	if strings.HasPrefix(folderPath, "//") {
+		folderPath = strings.ReplaceAll(folderPath, "//", "/")
+	}
+	return folderPath + name
+}
+
 func pathAndFolder(fullPath string) (path string, folder string, valid bool) {
 	i := strings.LastIndex(fullPath, "/")
 	if i == -1 {
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -42,7 +43,7 @@ func repository(t *testing.T) (Repository, *gorm.DB) {
 		t.Fatalf("cannot create database connection: %v", err)
 	}
 	// Migrate the schema
-	DB.AutoMigrate(&Bookmark{})
+	Migrate(DB)
 
 	DB.LogMode(true)
 	return Create(DB), DB
@@ -300,6 +301,45 @@ func TestUpdateBookmark(t *testing.T) {
 	}
 }
 
+func TestBookmarkMetadata(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	bm, err := repo.Create(Bookmark{
+		DisplayName: "displayName",
+		Path:        "/",
+		Type:        Node,
+		URL:         "http://url",
+		UserName:    "username",
+		Metadata: PageMetadata{
+			Title:       "Title",
+			Description: strings.Repeat("ä", 2000),
+			ImageURL:    "http://url/" + strings.Repeat("a", 600),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Could not create bookmarks: %v", err)
+	}
+	assert.False(t, bm.Metadata.IsEmpty())
+
+	bm, err = repo.GetBookmarkById(bm.ID, "username")
+	if err != nil {
+		t.Fatalf("Could not get bookmark: %v", err)
+	}
+	assert.Equal(t, "Title", bm.Metadata.Title)
+	// values are fitted into the columns
+	assert.Equal(t, 1024, len([]rune(bm.Metadata.Description)))
+	assert.Equal(t, "", bm.Metadata.ImageURL)
+
+	bm.Metadata.Language = "en"
+	if _, err := repo.Update(bm); err != nil {
+		t.Fatalf("Could not update bookmark: %v", err)
+	}
+	bm, _ = repo.GetBookmarkById(bm.ID, "username")
+	assert.Equal(t, "en", bm.Metadata.Language)
+	assert.Equal(t, "Title", bm.Metadata.Title)
+}
+
 func TestDeleteBookmark(t *testing.T) {
 	repo, db := repository(t)
 	defer db.Close()
@@ -636,7 +676,8 @@ func TestGetBookmarksByPath(t *testing.T) {
 		t.Errorf(errStr, err)
 	}
 
-	bookmarks, err := repo.GetBookmarksByPath("/", userName)
+	page, err := repo.GetBookmarksByPath("/", userName, ListOptions{})
+	bookmarks := page.Bookmarks
 	if err != nil {
 		t.Errorf(errStr, err)
 	}
@@ -644,7 +685,8 @@ func TestGetBookmarksByPath(t *testing.T) {
 	assert.Equal(t, 1, len(bookmarks))
 	assert.Equal(t, "Folder", bookmarks[0].DisplayName)
 
-	bookmarks, err = repo.GetBookmarksByPath("/Folder", userName)
+	page, err = repo.GetBookmarksByPath("/Folder", userName, ListOptions{})
+	bookmarks = page.Bookmarks
 	if err != nil {
 		t.Errorf(errStr, err)
 	}
@@ -697,19 +739,22 @@ func TestGetBookmarksByName(t *testing.T) {
 		t.Errorf(errStr, err)
 	}
 
-	bookmarks, err := repo.GetBookmarksByName("node", userName)
+	page, err := repo.GetBookmarksByName("node", userName, ListOptions{})
+	bookmarks := page.Bookmarks
 	if err != nil {
 		t.Errorf(errStr1, err)
 	}
 	assert.Equal(t, 2, len(bookmarks))
 
-	bookmarks, err = repo.GetBookmarksByName("Folder", userName)
+	page, err = repo.GetBookmarksByName("Folder", userName, ListOptions{})
+	bookmarks = page.Bookmarks
 	if err != nil {
 		t.Errorf(errStr1, err)
 	}
 	assert.Equal(t, 1, len(bookmarks))
 
-	bookmarks, err = repo.GetBookmarksByName("o", userName)
+	page, err = repo.GetBookmarksByName("o", userName, ListOptions{})
+	bookmarks = page.Bookmarks
 	if err != nil {
 		t.Errorf(errStr1, err)
 	}
@@ -760,7 +805,8 @@ func TestGetMostRecentBookmarks(t *testing.T) {
 	}
 	assert.Equal(t, 3, len(all))
 
-	recent, err := repo.GetMostRecentBookmarks(userName, 10)
+	page, err := repo.GetMostRecentBookmarks(userName, ListOptions{Limit: 10})
+	recent := page.Bookmarks
 	if err != nil {
 		t.Errorf("could not get bookmarks: %v", err)
 	}
@@ -768,7 +814,8 @@ func TestGetMostRecentBookmarks(t *testing.T) {
 	assert.Equal(t, "Node1", recent[0].DisplayName)
 	assert.Equal(t, "Node", recent[1].DisplayName)
 
-	recent, err = repo.GetMostRecentBookmarks(userName, 1)
+	page, err = repo.GetMostRecentBookmarks(userName, ListOptions{Limit: 1})
+	recent = page.Bookmarks
 	if err != nil {
 		t.Errorf("could not get bookmarks: %v", err)
 	}
@@ -863,3 +910,188 @@ func TestGetFolderByPath(t *testing.T) {
 		t.Errorf("expected error for path ''")
 	}
 }
+
+func TestFaviconReferences(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	for i, user := range []string{"user1", "user2"} {
+		if _, err := repo.Create(Bookmark{
+			DisplayName: fmt.Sprintf("Node%d", i),
+			Path:        "/",
+			Type:        Node,
+			URL:         "http://url",
+			UserName:    user,
+			Favicon:     "shared.png",
+		}); err != nil {
+			t.Errorf("Could not create bookmarks: %v", err)
+		}
+	}
+	if _, err := repo.Create(Bookmark{
+		DisplayName: "Other",
+		Path:        "/",
+		Type:        Node,
+		URL:         "http://url",
+		UserName:    "user1",
+		Favicon:     "other.png",
+	}); err != nil {
+		t.Errorf("Could not create bookmarks: %v", err)
+	}
+	if _, err := repo.Create(Bookmark{
+		DisplayName: "Folder",
+		Path:        "/",
+		Type:        Folder,
+		UserName:    "user1",
+	}); err != nil {
+		t.Errorf("Could not create bookmarks: %v", err)
+	}
+
+	// the references are counted across all users
+	count, err := repo.GetFaviconReferences("shared.png")
+	if err != nil {
+		t.Errorf("cannot get favicon references: %v", err)
+	}
+	assert.Equal(t, 2, count)
+
+	count, err = repo.GetFaviconReferences("unknown.png")
+	if err != nil {
+		t.Errorf("cannot get favicon references: %v", err)
+	}
+	assert.Equal(t, 0, count)
+
+	favicons, err := repo.GetAllFavicons()
+	if err != nil {
+		t.Errorf("cannot get all favicons: %v", err)
+	}
+	assert.ElementsMatch(t, []string{"shared.png", "other.png"}, favicons)
+}
+
+func TestGetBookmarksByIds(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	var ids []string
+	for i, user := range []string{"user1", "user1", "user2"} {
+		bm, err := repo.Create(Bookmark{
+			DisplayName: fmt.Sprintf("Node%d", i),
+			Path:        "/",
+			Type:        Node,
+			URL:         "http://url",
+			UserName:    user,
+		})
+		if err != nil {
+			t.Errorf("Could not create bookmarks: %v", err)
+		}
+		ids = append(ids, bm.ID)
+	}
+
+	// only the bookmarks of the user are returned
+	bookmarks, err := repo.GetBookmarksByIds(append(ids, "unknown"), "user1")
+	if err != nil {
+		t.Errorf("cannot get bookmarks by ids: %v", err)
+	}
+	assert.Equal(t, 2, len(bookmarks))
+
+	bookmarks, err = repo.GetBookmarksByIds(nil, "user1")
+	if err != nil {
+		t.Errorf("cannot get bookmarks by ids: %v", err)
+	}
+	assert.Equal(t, 0, len(bookmarks))
+
+	// the existence of an id is checked across all users
+	exists, err := repo.IDExists(ids[2])
+	if err != nil {
+		t.Errorf("cannot check the id: %v", err)
+	}
+	assert.True(t, exists)
+	exists, err = repo.IDExists("unknown")
+	if err != nil {
+		t.Errorf("cannot check the id: %v", err)
+	}
+	assert.False(t, exists)
+}
+
+func TestBookmarkVersion(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	folder, err := repo.Create(Bookmark{DisplayName: "Folder", Path: "/", Type: Folder, UserName: "username"})
+	if err != nil {
+		t.Fatalf("Could not create bookmark: %v", err)
+	}
+	assert.Equal(t, 1, folder.Version)
+
+	node, err := repo.Create(Bookmark{DisplayName: "Node", Path: "/Folder", Type: Node, URL: "http://a", UserName: "username"})
+	if err != nil {
+		t.Fatalf("Could not create bookmark: %v", err)
+	}
+	assert.Equal(t, 1, node.Version)
+
+	// the child-count and the access-count are counters, they do not change the version
+	folder, _ = repo.GetBookmarkById(folder.ID, "username")
+	assert.Equal(t, 1, folder.Version)
+	assert.Equal(t, 1, folder.ChildCount)
+	assert.Nil(t, folder.Modified)
+	assert.NoError(t, repo.AddVisit(Visit{BookmarkID: node.ID, UserName: "username"}))
+	assert.NoError(t, repo.AddAccessCount(node.ID, "username", 2))
+	counted, _ := repo.GetBookmarkById(node.ID, "username")
+	assert.Equal(t, 1, counted.Version)
+	assert.Equal(t, 3, counted.AccessCount)
+	assert.NotNil(t, counted.LastAccessed)
+	unchanged, _ := repo.Update(counted)
+	assert.Equal(t, 1, unchanged.Version)
+
+	node.DisplayName = "Renamed"
+	node, err = repo.Update(node)
+	if err != nil {
+		t.Fatalf("Could not update bookmark: %v", err)
+	}
+	assert.Equal(t, 2, node.Version)
+	stored, _ := repo.GetBookmarkById(node.ID, "username")
+	assert.Equal(t, 2, stored.Version)
+
+	read, _ := repo.SetRead(node.ID, "username", true)
+	assert.Equal(t, 3, read.Version)
+	moved, _ := repo.UpdateURL(node.ID, "username", "http://b", URLChangeManual)
+	assert.Equal(t, 4, moved.Version)
+	stored, _ = repo.GetBookmarkById(node.ID, "username")
+	assert.Equal(t, 4, stored.Version)
+}
+
+func TestGetBookmarkTree(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	for _, bm := range []Bookmark{
+		{DisplayName: "Folder", Path: "/", Type: Folder},
+		{DisplayName: "Folders", Path: "/", Type: Folder},
+		{DisplayName: "B", Path: "/Folder", Type: Node, URL: "http://b", SortOrder: 1},
+		{DisplayName: "A", Path: "/Folder", Type: Node, URL: "http://a", SortOrder: 2},
+		{DisplayName: "Sub", Path: "/Folder", Type: Folder},
+		{DisplayName: "C", Path: "/Folder/Sub", Type: Node, URL: "http://c"},
+		{DisplayName: "D", Path: "/Folders", Type: Node, URL: "http://d"},
+	} {
+		bm.UserName = "username"
+		if _, err := repo.Create(bm); err != nil {
+			t.Fatalf("Could not create bookmark: %v", err)
+		}
+	}
+	repo.Create(Bookmark{DisplayName: "Other", Path: "/", Type: Node, URL: "http://other", UserName: "other"})
+
+	names := func(path string) []string {
+		bookmarks, err := repo.GetBookmarkTree(path, "username")
+		if err != nil {
+			t.Fatalf("cannot get the tree: %v", err)
+		}
+		var result []string
+		for _, bm := range bookmarks {
+			result = append(result, bm.Path+":"+bm.DisplayName)
+		}
+		return result
+	}
+
+	// the folder '/Folders' starts with the same path, but is not part of the tree
+	assert.Equal(t, []string{"/Folder:Sub", "/Folder:B", "/Folder:A", "/Folder/Sub:C"}, names("/Folder"))
+	assert.Equal(t, 7, len(names("/")))
+	assert.Equal(t, 0, len(names("/Missing")))
+}
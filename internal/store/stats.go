@@ -0,0 +1,173 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// StatisticsOptions restricts the lists of the statistics
+type StatisticsOptions struct {
+	// Limit is the maximum number of entries of the lists
+	Limit int
+	// StaleBefore is the time of the last visit, bookmarks visited earlier are stale
+	StaleBefore time.Time
+}
+
+// Statistics summarizes the bookmarks and the visits of a user
+type Statistics struct {
+	Nodes             int
+	Folders           int
+	DeepestPaths      []PathDepth
+	BiggestFolders    []FolderSize
+	TopDomains        []DomainCount
+	NeverVisitedCount int
+	NeverVisited      []Bookmark
+	StaleCount        int
+	Stale             []Bookmark
+	Growth            []MonthCount
+	Heatmap           []VisitSlot
+}
+
+// PathDepth is a path with the number of its levels
+type PathDepth struct {
+	Path  string
+	Depth int
+}
+
+// FolderSize is the full path of a folder with the number of its child-elements
+type FolderSize struct {
+	Path  string
+	Count int
+}
+
+// DomainCount is the number of bookmarks of a host
+type DomainCount struct {
+	Domain string
+	Count  int
+}
+
+// MonthCount is the number of bookmarks created in a month, formatted as YYYY-MM
+type MonthCount struct {
+	Month string
+	Count int
+}
+
+// VisitSlot is the number of visits of an hour of a weekday (0 = Sunday) in UTC
+type VisitSlot struct {
+	Weekday int
+	Hour    int
+	Count   int
+}
+
+// the host of the URL, the part between the scheme and the first slash
+const domainExpr = `LOWER(CASE WHEN INSTR(u.rest, '/') > 0 THEN SUBSTR(u.rest, 1, INSTR(u.rest, '/') - 1) ELSE u.rest END)`
+
+// GetStatistics calculates the statistics of the bookmarks of the user. all values are aggregated by the database
+func (r *dbRepository) GetStatistics(username string, opts StatisticsOptions) (Statistics, error) {
+	var stats Statistics
+	if opts.Limit <= 0 {
+		return stats, fmt.Errorf("the limit of the statistics must be positive")
+	}
+
+	var types []struct {
+		Type  NodeType
+		Count int
+	}
+	if h := r.con().Raw(`SELECT type, COUNT(*) AS count FROM BOOKMARKS WHERE user_name = ? GROUP BY type`, username).
+		Scan(&types); h.Error != nil {
+		return stats, fmt.Errorf("cannot count the bookmarks: %v", h.Error)
+	}
+	for _, t := range types {
+		switch t.Type {
+		case Node:
+			stats.Nodes = t.Count
+		case Folder:
+			stats.Folders = t.Count
+		}
+	}
+
+	query := `SELECT h.path AS path, CASE h.path WHEN '/' THEN 0 ELSE LENGTH(h.path) - LENGTH(REPLACE(h.path, '/', '')) END AS depth
+        FROM (` + nativeHierarchyQuery + `) h ORDER BY depth DESC, h.path LIMIT ?`
+	if h := r.con().Raw(query, Folder, username, opts.Limit).Scan(&stats.DeepestPaths); h.Error != nil {
+		return stats, fmt.Errorf("cannot get the deepest paths: %v", h.Error)
+	}
+
+	var folders []Bookmark
+	if h := r.con().Where("user_name = ? AND type = ? AND child_count > 0", username, Folder).
+		Order("child_count DESC").Order("display_name").Limit(opts.Limit).Find(&folders); h.Error != nil {
+		return stats, fmt.Errorf("cannot get the biggest folders: %v", h.Error)
+	}
+	for _, f := range folders {
+		path := f.Path + "/" + f.DisplayName
+		if f.Path == "/" {
+			path = "/" + f.DisplayName
+		}
+		stats.BiggestFolders = append(stats.BiggestFolders, FolderSize{Path: path, Count: f.ChildCount})
+	}
+
+	query = `SELECT ` + domainExpr + ` AS domain, COUNT(*) AS count FROM (
+            SELECT CASE WHEN INSTR(url, '://') > 0 THEN SUBSTR(url, INSTR(url, '://') + 3) ELSE url END AS rest
+            FROM BOOKMARKS WHERE user_name = ? AND type = ? AND url <> ''
+        ) u GROUP BY ` + domainExpr + ` ORDER BY count DESC, domain LIMIT ?`
+	if h := r.con().Raw(query, username, Node, opts.Limit).Scan(&stats.TopDomains); h.Error != nil {
+		return stats, fmt.Errorf("cannot get the top domains: %v", h.Error)
+	}
+
+	neverVisited := r.con().Model(&Bookmark{}).Where("user_name = ? AND type = ? AND access_count = 0", username, Node)
+	if h := neverVisited.Count(&stats.NeverVisitedCount); h.Error != nil {
+		return stats, fmt.Errorf("cannot count the unvisited bookmarks: %v", h.Error)
+	}
+	if h := neverVisited.Order("created").Limit(opts.Limit).Find(&stats.NeverVisited); h.Error != nil {
+		return stats, fmt.Errorf("cannot get the unvisited bookmarks: %v", h.Error)
+	}
+
+	// bookmarks visited before the visits were recorded have no time of the last access, the time of the
+	// creation is used for them
+	stale := r.con().Model(&Bookmark{}).Where("user_name = ? AND type = ? AND access_count > 0 AND COALESCE(last_accessed, created) < ?",
+		username, Node, opts.StaleBefore)
+	if h := stale.Count(&stats.StaleCount); h.Error != nil {
+		return stats, fmt.Errorf("cannot count the stale bookmarks: %v", h.Error)
+	}
+	if h := stale.Order("COALESCE(last_accessed, created)").Limit(opts.Limit).Find(&stats.Stale); h.Error != nil {
+		return stats, fmt.Errorf("cannot get the stale bookmarks: %v", h.Error)
+	}
+
+	month := r.dateExpr("month", "created")
+	query = `SELECT ` + month + ` AS month, COUNT(*) AS count FROM BOOKMARKS
+        WHERE user_name = ? AND type = ? GROUP BY ` + month + ` ORDER BY month`
+	if h := r.con().Raw(query, username, Node).Scan(&stats.Growth); h.Error != nil {
+		return stats, fmt.Errorf("cannot get the growth of the bookmarks: %v", h.Error)
+	}
+
+	weekday, hour := r.dateExpr("weekday", "visited"), r.dateExpr("hour", "visited")
+	query = `SELECT ` + weekday + ` AS weekday, ` + hour + ` AS hour, COUNT(*) AS count FROM VISITS
+        WHERE user_name = ? GROUP BY ` + weekday + `, ` + hour
+	if h := r.con().Raw(query, username).Scan(&stats.Heatmap); h.Error != nil {
+		return stats, fmt.Errorf("cannot get the visit heatmap: %v", h.Error)
+	}
+
+	return stats, nil
+}
+
+// dateExpr returns the SQL expression for a part of the date column, the date functions differ between the databases.
+// the parts are the month as YYYY-MM, the weekday starting with 0 for Sunday and the hour
+func (r *dbRepository) dateExpr(part, column string) string {
+	if r.con().Dialect().GetName() == "mysql" {
+		switch part {
+		case "month":
+			return "DATE_FORMAT(" + column + ", '%Y-%m')"
+		case "weekday":
+			return "(DAYOFWEEK(" + column + ") - 1)"
+		default:
+			return "HOUR(" + column + ")"
+		}
+	}
+	switch part {
+	case "month":
+		return "strftime('%Y-%m', " + column + ")"
+	case "weekday":
+		return "CAST(strftime('%w', " + column + ") AS INTEGER)"
+	default:
+		return "CAST(strftime('%H', " + column + ") AS INTEGER)"
+	}
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatistics(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	for _, bm := range []Bookmark{
+		{DisplayName: "A", Path: "/", Type: Folder},
+		{DisplayName: "B", Path: "/A", Type: Folder},
+		{DisplayName: "C", Path: "/A/B", Type: Folder},
+		{DisplayName: "Node1", Path: "/", Type: Node, URL: "https://www.Example.com/page?q=1"},
+		{DisplayName: "Node2", Path: "/A", Type: Node, URL: "http://example.org/"},
+		{DisplayName: "Node3", Path: "/A", Type: Node, URL: "https://www.example.com"},
+		{DisplayName: "Node4", Path: "/A/B", Type: Node, URL: "https://other.net/path/to", AccessCount: 3},
+	} {
+		bm.UserName = "username"
+		if _, err := repo.Create(bm); err != nil {
+			t.Fatalf("Could not create bookmarks: %v", err)
+		}
+	}
+	if _, err := repo.Create(Bookmark{DisplayName: "Foreign", Path: "/", Type: Node, URL: "http://foreign", UserName: "other"}); err != nil {
+		t.Fatalf("Could not create bookmarks: %v", err)
+	}
+
+	// move the creation of a bookmark to a previous month
+	created := time.Date(2020, 1, 15, 10, 0, 0, 0, time.UTC)
+	db.Exec("UPDATE BOOKMARKS SET created = ? WHERE display_name = ?", created, "Node1")
+	db.Exec("UPDATE BOOKMARKS SET created = ? WHERE display_name = ?", created.AddDate(0, 1, 0), "Node4")
+
+	nodes, _ := repo.GetBookmarksByName("Node", "username", ListOptions{})
+	byName := make(map[string]Bookmark)
+	for _, n := range nodes.Bookmarks {
+		byName[n.DisplayName] = n
+	}
+	// Sunday, 14:30 UTC
+	visited := time.Date(2020, 5, 3, 14, 30, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		if err := repo.AddVisit(Visit{BookmarkID: byName["Node2"].ID, UserName: "username", Visited: visited}); err != nil {
+			t.Fatalf("cannot add visit: %v", err)
+		}
+	}
+	// Monday, 09:00 UTC
+	repo.AddVisit(Visit{BookmarkID: byName["Node3"].ID, UserName: "username", Visited: time.Date(2020, 5, 4, 9, 0, 0, 0, time.UTC)})
+	db.Exec("UPDATE BOOKMARKS SET access_count = 2 WHERE display_name = ?", "Node2")
+	db.Exec("UPDATE BOOKMARKS SET access_count = 1, last_accessed = ? WHERE display_name = ?", time.Now().UTC(), "Node3")
+
+	_, err := repo.GetStatistics("username", StatisticsOptions{})
+	assert.Error(t, err)
+
+	stats, err := repo.GetStatistics("username", StatisticsOptions{Limit: 2, StaleBefore: time.Now().UTC().Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("cannot get statistics: %v", err)
+	}
+	assert.Equal(t, 4, stats.Nodes)
+	assert.Equal(t, 3, stats.Folders)
+
+	assert.Equal(t, []PathDepth{{Path: "/A/B/C", Depth: 3}, {Path: "/A/B", Depth: 2}}, stats.DeepestPaths)
+	assert.Equal(t, []FolderSize{{Path: "/A", Count: 3}, {Path: "/A/B", Count: 2}}, stats.BiggestFolders)
+	assert.Equal(t, []DomainCount{{Domain: "www.example.com", Count: 2}, {Domain: "example.org", Count: 1}}, stats.TopDomains)
+
+	assert.Equal(t, 1, stats.NeverVisitedCount)
+	if assert.Equal(t, 1, len(stats.NeverVisited)) {
+		assert.Equal(t, "Node1", stats.NeverVisited[0].DisplayName)
+	}
+	// Node4 was visited before the visits were recorded, Node2 two times in May 2020
+	assert.Equal(t, 2, stats.StaleCount)
+	if assert.Equal(t, 2, len(stats.Stale)) {
+		assert.Equal(t, "Node4", stats.Stale[0].DisplayName)
+		assert.Equal(t, "Node2", stats.Stale[1].DisplayName)
+	}
+
+	month := time.Now().UTC().Format("2006-01")
+	assert.Equal(t, []MonthCount{{Month: "2020-01", Count: 1}, {Month: "2020-02", Count: 1}, {Month: month, Count: 2}}, stats.Growth)
+	assert.ElementsMatch(t, []VisitSlot{{Weekday: 0, Hour: 14, Count: 2}, {Weekday: 1, Hour: 9, Count: 1}}, stats.Heatmap)
+
+	stats, _ = repo.GetStatistics("unknown", StatisticsOptions{Limit: 5})
+	assert.Equal(t, 0, stats.Nodes)
+	assert.Equal(t, []PathDepth{{Path: "/", Depth: 0}}, stats.DeepestPaths)
+	assert.Equal(t, 0, len(stats.TopDomains))
+}
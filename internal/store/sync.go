@@ -0,0 +1,60 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// GetSequence returns the current change sequence of the user, zero if the user has no changes
+func (r *dbRepository) GetSequence(username string) (int64, error) {
+	var seq SyncSequence
+	h := r.con().Where("user_name = ?", username).First(&seq)
+	if h.RecordNotFound() {
+		return 0, nil
+	}
+	return seq.Value, h.Error
+}
+
+// GetChangedBookmarks returns the bookmarks of the user, which were created or modified after the given
+// sequence, ordered by the sequence
+func (r *dbRepository) GetChangedBookmarks(username string, since int64) ([]Bookmark, error) {
+	var bookmarks []Bookmark
+	h := r.con().Where("user_name = ? AND sync_sequence > ?", username, since).Order("sync_sequence").Order("id").Find(&bookmarks)
+	return bookmarks, h.Error
+}
+
+// GetTombstones returns the deletions of bookmarks of the user after the given sequence, ordered by the sequence
+func (r *dbRepository) GetTombstones(username string, since int64) ([]Tombstone, error) {
+	var tombstones []Tombstone
+	h := r.con().Where("user_name = ? AND sync_sequence > ?", username, since).Order("sync_sequence").Order("id").Find(&tombstones)
+	return tombstones, h.Error
+}
+
+// nextSequence increments the change sequence of the user and returns the new value. within a transaction the
+// row of the sequence is locked until the commit, concurrent changes of the user get ascending values
+func (r *dbRepository) nextSequence(username string) (int64, error) {
+	h := r.con().Model(&SyncSequence{}).Where("user_name = ?", username).UpdateColumn("current_value", gorm.Expr("current_value + 1"))
+	if h.Error != nil {
+		return 0, fmt.Errorf("cannot increment the change sequence of user '%s': %v", username, h.Error)
+	}
+	if h.RowsAffected == 0 {
+		if h := r.con().Create(&SyncSequence{UserName: username, Value: 1}); h.Error != nil {
+			return 0, fmt.Errorf("cannot create the change sequence of user '%s': %v", username, h.Error)
+		}
+		return 1, nil
+	}
+	return r.GetSequence(username)
+}
+
+// addTombstones records the deletion of the bookmarks
+func (r *dbRepository) addTombstones(username string, seq int64, ids ...string) error {
+	now := time.Now().UTC()
+	for _, id := range ids {
+		if h := r.con().Create(&Tombstone{BookmarkID: id, UserName: username, Sequence: seq, Deleted: now}); h.Error != nil {
+			return fmt.Errorf("cannot record the deletion of bookmark '%s': %v", id, h.Error)
+		}
+	}
+	return nil
+}
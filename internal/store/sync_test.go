@@ -0,0 +1,105 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncSequence(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	seq, err := repo.GetSequence("username")
+	if err != nil {
+		t.Fatalf("cannot get the sequence: %v", err)
+	}
+	assert.Equal(t, int64(0), seq)
+
+	folder, err := repo.Create(Bookmark{DisplayName: "Folder", Path: "/", Type: Folder, UserName: "username"})
+	if err != nil {
+		t.Fatalf("Could not create bookmark: %v", err)
+	}
+	assert.Equal(t, int64(1), folder.Sequence)
+	assert.Equal(t, int64(1), folder.CreatedSequence)
+
+	// the child-count of the folder is a counter and not a change of the folder
+	node, err := repo.Create(Bookmark{DisplayName: "Node", Path: "/Folder", Type: Node, URL: "http://a", UserName: "username"})
+	if err != nil {
+		t.Fatalf("Could not create bookmark: %v", err)
+	}
+	assert.Equal(t, int64(2), node.Sequence)
+	seq, _ = repo.GetSequence("username")
+	assert.Equal(t, int64(2), seq)
+
+	other, _ := repo.Create(Bookmark{DisplayName: "Other", Path: "/", Type: Node, URL: "http://b", UserName: "other"})
+	assert.Equal(t, int64(1), other.Sequence)
+
+	changed, err := repo.GetChangedBookmarks("username", 1)
+	if err != nil {
+		t.Fatalf("cannot get the changed bookmarks: %v", err)
+	}
+	assert.Equal(t, 1, len(changed))
+	assert.Equal(t, node.ID, changed[0].ID)
+
+	node.DisplayName = "Renamed"
+	updated, err := repo.Update(node)
+	if err != nil {
+		t.Fatalf("Could not update bookmark: %v", err)
+	}
+	assert.Equal(t, int64(3), updated.Sequence)
+	assert.Equal(t, int64(2), updated.CreatedSequence)
+
+	// an update without a change keeps the sequence
+	unchanged, _ := repo.Update(updated)
+	assert.Equal(t, int64(3), unchanged.Sequence)
+
+	read, _ := repo.SetRead(node.ID, "username", true)
+	assert.Equal(t, int64(4), read.Sequence)
+	moved, _ := repo.UpdateURL(node.ID, "username", "http://c", URLChangeManual)
+	assert.Equal(t, int64(5), moved.Sequence)
+}
+
+func TestSyncTombstones(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	folder, _ := repo.Create(Bookmark{DisplayName: "Folder", Path: "/", Type: Folder, UserName: "username"})
+	node, _ := repo.Create(Bookmark{DisplayName: "Node", Path: "/Folder", Type: Node, URL: "http://a", UserName: "username"})
+	single, _ := repo.Create(Bookmark{DisplayName: "Single", Path: "/", Type: Node, URL: "http://b", UserName: "username"})
+	seq, _ := repo.GetSequence("username")
+
+	if err := repo.Delete(single); err != nil {
+		t.Fatalf("Could not delete bookmark: %v", err)
+	}
+	if err := repo.DeletePath("/Folder", "username"); err != nil {
+		t.Fatalf("Could not delete path: %v", err)
+	}
+
+	tombstones, err := repo.GetTombstones("username", seq)
+	if err != nil {
+		t.Fatalf("cannot get the tombstones: %v", err)
+	}
+	assert.Equal(t, 3, len(tombstones))
+	assert.Equal(t, single.ID, tombstones[0].BookmarkID)
+	assert.Equal(t, seq+1, tombstones[0].Sequence)
+	assert.Equal(t, []string{node.ID, folder.ID}, []string{tombstones[1].BookmarkID, tombstones[2].BookmarkID})
+	assert.Equal(t, seq+2, tombstones[1].Sequence)
+	assert.False(t, tombstones[0].Deleted.After(time.Now().UTC()))
+
+	tombstones, _ = repo.GetTombstones("username", seq+1)
+	assert.Equal(t, 2, len(tombstones))
+	tombstones, _ = repo.GetTombstones("other", 0)
+	assert.Equal(t, 0, len(tombstones))
+
+	// the restored bookmark is no longer deleted
+	if _, err := repo.Restore(single); err != nil {
+		t.Fatalf("Could not restore bookmark: %v", err)
+	}
+	tombstones, _ = repo.GetTombstones("username", seq)
+	assert.Equal(t, 2, len(tombstones))
+	changed, _ := repo.GetChangedBookmarks("username", seq+2)
+	assert.Equal(t, 1, len(changed))
+	assert.Equal(t, seq+3, changed[0].CreatedSequence)
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// AddVisit records the visit of a bookmark and updates the access-count and the time of the last access of the bookmark
+func (r *dbRepository) AddVisit(visit Visit) error {
+	if visit.BookmarkID == "" {
+		return fmt.Errorf("no bookmark supplied for the visit")
+	}
+	if visit.Visited.IsZero() {
+		visit.Visited = time.Now().UTC()
+	}
+	visit.ID = 0
+
+	if h := r.con().Create(&visit); h.Error != nil {
+		return fmt.Errorf("cannot save visit of bookmark '%s': %v", visit.BookmarkID, h.Error)
+	}
+	if h := r.con().Model(&Bookmark{}).Where("id = ? AND user_name = ?", visit.BookmarkID, visit.UserName).
+		UpdateColumns(map[string]interface{}{"access_count": gorm.Expr("access_count + 1"), "last_accessed": visit.Visited}); h.Error != nil {
+		return fmt.Errorf("cannot update last access of bookmark '%s': %v", visit.BookmarkID, h.Error)
+	}
+	return nil
+}
+
+// GetVisits returns the most recent visits of the given bookmark
+func (r *dbRepository) GetVisits(bookmarkID string, limit int) ([]Visit, error) {
+	var visits []Visit
+	h := r.con().Where("bookmark_id = ?", bookmarkID).Order("visited desc").Limit(limit).Find(&visits)
+	return visits, h.Error
+}
+
+// MoveVisits assigns the visits of a bookmark of the user to another bookmark
+func (r *dbRepository) MoveVisits(fromID, toID, username string) error {
+	h := r.con().Model(&Visit{}).Where("bookmark_id = ? AND user_name = ?", fromID, username).
+		UpdateColumn("bookmark_id", toID)
+	return h.Error
+}
+
+// GetRecentlyVisited returns the bookmarks of the user ordered by the time of the last access
+func (r *dbRepository) GetRecentlyVisited(username string, limit int) ([]Bookmark, error) {
+	var bookmarks []Bookmark
+	h := r.con().
+		Limit(limit).
+		Order("last_accessed DESC").Order("display_name").
+		Where("user_name = ? AND type = ? AND last_accessed IS NOT NULL", username, Node).Find(&bookmarks)
+	return bookmarks, h.Error
+}
+
+// GetUserVisits returns the visits of all bookmarks of the user since the given time
+func (r *dbRepository) GetUserVisits(username string, since time.Time) ([]Visit, error) {
+	var visits []Visit
+	h := r.con().Where("user_name = ? AND visited >= ?", username, since).Order("visited desc").Find(&visits)
+	return visits, h.Error
+}
+
+// DeleteVisitsBefore removes the visits older than the given time
+func (r *dbRepository) DeleteVisitsBefore(t time.Time) error {
+	h := r.con().Where("visited < ?", t).Delete(Visit{})
+	return h.Error
+}
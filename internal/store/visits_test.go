@@ -0,0 +1,100 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVisits(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	var bookmarks []Bookmark
+	for _, name := range []string{"A", "B", "C"} {
+		bm, err := repo.Create(Bookmark{
+			DisplayName: name,
+			Path:        "/",
+			Type:        Node,
+			URL:         "http://" + name,
+			UserName:    "username",
+		})
+		if err != nil {
+			t.Fatalf("Could not create bookmarks: %v", err)
+		}
+		bookmarks = append(bookmarks, bm)
+	}
+
+	assert.Error(t, repo.AddVisit(Visit{}))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, v := range []Visit{
+		{BookmarkID: bookmarks[0].ID, UserName: "username", Visited: now.Add(-72 * time.Hour)},
+		{BookmarkID: bookmarks[0].ID, UserName: "username", Visited: now.Add(-2 * time.Hour)},
+		{BookmarkID: bookmarks[1].ID, UserName: "username", Visited: now.Add(-time.Hour)},
+	} {
+		if err := repo.AddVisit(v); err != nil {
+			t.Fatalf("cannot add visit: %v", err)
+		}
+	}
+
+	bm, _ := repo.GetBookmarkById(bookmarks[1].ID, "username")
+	if assert.NotNil(t, bm.LastAccessed) {
+		assert.True(t, now.Add(-time.Hour).Equal(*bm.LastAccessed))
+	}
+	bm, _ = repo.GetBookmarkById(bookmarks[2].ID, "username")
+	assert.Nil(t, bm.LastAccessed)
+
+	// an update of the bookmark keeps the last access
+	bm, _ = repo.GetBookmarkById(bookmarks[1].ID, "username")
+	bm.DisplayName = "B2"
+	bm.LastAccessed = nil
+	bm, err := repo.Update(bm)
+	if err != nil {
+		t.Fatalf("cannot update bookmark: %v", err)
+	}
+	assert.NotNil(t, bm.LastAccessed)
+
+	recent, err := repo.GetRecentlyVisited("username", 10)
+	if err != nil {
+		t.Fatalf("cannot get recently visited bookmarks: %v", err)
+	}
+	assert.Equal(t, 2, len(recent))
+	assert.Equal(t, bookmarks[1].ID, recent[0].ID)
+	assert.Equal(t, bookmarks[0].ID, recent[1].ID)
+	recent, _ = repo.GetRecentlyVisited("other", 10)
+	assert.Equal(t, 0, len(recent))
+
+	visits, err := repo.GetVisits(bookmarks[0].ID, 1)
+	if err != nil {
+		t.Fatalf("cannot get visits: %v", err)
+	}
+	assert.Equal(t, 1, len(visits))
+	assert.True(t, now.Add(-2*time.Hour).Equal(visits[0].Visited))
+
+	visits, err = repo.GetUserVisits("username", now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("cannot get visits of user: %v", err)
+	}
+	assert.Equal(t, 2, len(visits))
+
+	assert.NoError(t, repo.DeleteVisitsBefore(now.Add(-24*time.Hour)))
+	visits, _ = repo.GetVisits(bookmarks[0].ID, 10)
+	assert.Equal(t, 1, len(visits))
+
+	// the visits are moved to another bookmark of the user
+	assert.NoError(t, repo.MoveVisits(bookmarks[1].ID, bookmarks[2].ID, "other"))
+	visits, _ = repo.GetVisits(bookmarks[1].ID, 10)
+	assert.Equal(t, 1, len(visits))
+	assert.NoError(t, repo.MoveVisits(bookmarks[1].ID, bookmarks[2].ID, "username"))
+	visits, _ = repo.GetVisits(bookmarks[1].ID, 10)
+	assert.Equal(t, 0, len(visits))
+	visits, _ = repo.GetVisits(bookmarks[2].ID, 10)
+	assert.Equal(t, 1, len(visits))
+
+	// the visits are removed with the bookmark
+	assert.NoError(t, repo.Delete(bookmarks[0]))
+	visits, _ = repo.GetUserVisits("username", now.Add(-24*time.Hour))
+	assert.Equal(t, 1, len(visits))
+}
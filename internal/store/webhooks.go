@@ -0,0 +1,106 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GetWebhooks returns the webhooks of the user
+func (r *dbRepository) GetWebhooks(username string) ([]Webhook, error) {
+	var webhooks []Webhook
+	h := r.con().Where("user_name = ?", username).Order("created").Find(&webhooks)
+	return webhooks, h.Error
+}
+
+// GetWebhookByID returns the webhook of the user
+func (r *dbRepository) GetWebhookByID(id, username string) (Webhook, error) {
+	var webhook Webhook
+	h := r.con().Where("id = ? AND user_name = ?", id, username).First(&webhook)
+	return webhook, h.Error
+}
+
+// SaveWebhook creates a webhook without an ID or updates the existing webhook
+func (r *dbRepository) SaveWebhook(webhook Webhook) (Webhook, error) {
+	if webhook.UserName == "" {
+		return Webhook{}, fmt.Errorf("no user supplied for the webhook")
+	}
+	now := time.Now().UTC()
+	if webhook.ID == "" {
+		webhook.ID = uuid.New().String()
+		webhook.Created = now
+		webhook.Modified = nil
+		if h := r.con().Create(&webhook); h.Error != nil {
+			return Webhook{}, fmt.Errorf("cannot create webhook of user '%s': %v", webhook.UserName, h.Error)
+		}
+		return webhook, nil
+	}
+	webhook.Modified = &now
+	if h := r.con().Save(&webhook); h.Error != nil {
+		return Webhook{}, fmt.Errorf("cannot save webhook '%s': %v", webhook.ID, h.Error)
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook removes the webhook of the user and its deliveries
+func (r *dbRepository) DeleteWebhook(id, username string) error {
+	h := r.con().Where("id = ? AND user_name = ?", id, username).Delete(Webhook{})
+	if h.Error != nil {
+		return fmt.Errorf("cannot delete webhook '%s': %v", id, h.Error)
+	}
+	if h.RowsAffected == 0 {
+		return fmt.Errorf("the webhook '%s' does not exist", id)
+	}
+	if h := r.con().Where("webhook_id = ?", id).Delete(WebhookDelivery{}); h.Error != nil {
+		return fmt.Errorf("cannot delete the deliveries of webhook '%s': %v", id, h.Error)
+	}
+	return nil
+}
+
+// AddDelivery adds a pending delivery to the outbox, without a time of the next attempt it is sent right away
+func (r *dbRepository) AddDelivery(delivery WebhookDelivery) (WebhookDelivery, error) {
+	if delivery.WebhookID == "" {
+		return WebhookDelivery{}, fmt.Errorf("no webhook supplied for the delivery")
+	}
+	now := time.Now().UTC()
+	delivery.ID = 0
+	delivery.Status = DeliveryPending
+	delivery.Created = now
+	if delivery.NextAttempt.IsZero() {
+		delivery.NextAttempt = now
+	}
+	if h := r.con().Create(&delivery); h.Error != nil {
+		return WebhookDelivery{}, fmt.Errorf("cannot add delivery of webhook '%s': %v", delivery.WebhookID, h.Error)
+	}
+	return delivery, nil
+}
+
+// UpdateDelivery saves the outcome of an attempt of the delivery
+func (r *dbRepository) UpdateDelivery(delivery WebhookDelivery) error {
+	if h := r.con().Save(&delivery); h.Error != nil {
+		return fmt.Errorf("cannot save delivery %d: %v", delivery.ID, h.Error)
+	}
+	return nil
+}
+
+// GetDueDeliveries returns the pending deliveries of all users with a next attempt up to the given time
+func (r *dbRepository) GetDueDeliveries(now time.Time, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	h := r.con().Where("status = ? AND next_attempt <= ?", DeliveryPending, now).
+		Order("next_attempt").Order("id").Limit(limit).Find(&deliveries)
+	return deliveries, h.Error
+}
+
+// GetDeliveries returns the most recent deliveries of the webhook
+func (r *dbRepository) GetDeliveries(webhookID string, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	h := r.con().Where("webhook_id = ?", webhookID).Order("id desc").Limit(limit).Find(&deliveries)
+	return deliveries, h.Error
+}
+
+// DeleteDeliveriesBefore removes the completed deliveries created before the given time, pending deliveries are kept
+func (r *dbRepository) DeleteDeliveriesBefore(t time.Time) error {
+	h := r.con().Where("status <> ? AND created < ?", DeliveryPending, t).Delete(WebhookDelivery{})
+	return h.Error
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhooks(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	_, err := repo.SaveWebhook(Webhook{URL: "https://example.com/hook"})
+	assert.Error(t, err)
+
+	hook, err := repo.SaveWebhook(Webhook{UserName: "username", URL: "https://example.com/hook", Secret: "secret", Events: "created,deleted", Active: true})
+	if err != nil {
+		t.Fatalf("cannot create webhook: %v", err)
+	}
+	assert.NotEmpty(t, hook.ID)
+	assert.False(t, hook.Created.IsZero())
+	assert.Nil(t, hook.Modified)
+	assert.True(t, hook.Subscribes("created"))
+	assert.False(t, hook.Subscribes("moved"))
+	assert.True(t, Webhook{}.Subscribes("moved"))
+
+	if _, err := repo.SaveWebhook(Webhook{UserName: "other", URL: "https://example.com/other"}); err != nil {
+		t.Fatalf("cannot create webhook: %v", err)
+	}
+
+	hooks, err := repo.GetWebhooks("username")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(hooks))
+	assert.Equal(t, "secret", hooks[0].Secret)
+
+	_, err = repo.GetWebhookByID(hook.ID, "other")
+	assert.Error(t, err)
+
+	hook.Active = false
+	hook.Events = ""
+	hook, err = repo.SaveWebhook(hook)
+	assert.NoError(t, err)
+	assert.NotNil(t, hook.Modified)
+	hook, err = repo.GetWebhookByID(hook.ID, "username")
+	assert.NoError(t, err)
+	assert.False(t, hook.Active)
+	assert.Equal(t, "", hook.Events)
+
+	_, err = repo.AddDelivery(WebhookDelivery{})
+	assert.Error(t, err)
+	d, err := repo.AddDelivery(WebhookDelivery{WebhookID: hook.ID, UserName: "username", EventType: "created", Payload: "{}"})
+	assert.NoError(t, err)
+
+	assert.Error(t, repo.DeleteWebhook(hook.ID, "other"))
+	assert.NoError(t, repo.DeleteWebhook(hook.ID, "username"))
+	_, err = repo.GetWebhookByID(hook.ID, "username")
+	assert.Error(t, err)
+	deliveries, _ := repo.GetDeliveries(d.WebhookID, 10)
+	assert.Equal(t, 0, len(deliveries))
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	repo, db := repository(t)
+	defer db.Close()
+
+	now := time.Now().UTC()
+	for _, d := range []WebhookDelivery{
+		{WebhookID: "A", EventType: "created", NextAttempt: now.Add(time.Hour)},
+		{WebhookID: "A", EventType: "updated"},
+		{WebhookID: "B", EventType: "deleted", NextAttempt: now.Add(-time.Hour)},
+	} {
+		d.UserName = "username"
+		d.Payload = "{}"
+		if _, err := repo.AddDelivery(d); err != nil {
+			t.Fatalf("cannot add delivery: %v", err)
+		}
+	}
+
+	due, err := repo.GetDueDeliveries(now.Add(time.Second), 10)
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(due)) {
+		assert.Equal(t, "deleted", due[0].EventType)
+		assert.Equal(t, "updated", due[1].EventType)
+		assert.Equal(t, DeliveryPending, due[0].Status)
+	}
+	due, _ = repo.GetDueDeliveries(now.Add(time.Second), 1)
+	assert.Equal(t, 1, len(due))
+
+	attempted := now.Add(time.Second)
+	delivered := due[0]
+	delivered.Status = DeliveryDelivered
+	delivered.Attempts = 1
+	delivered.LastAttempt = &attempted
+	delivered.StatusCode = 204
+	assert.NoError(t, repo.UpdateDelivery(delivered))
+
+	due, _ = repo.GetDueDeliveries(now.Add(2*time.Hour), 10)
+	assert.Equal(t, 2, len(due))
+
+	deliveries, err := repo.GetDeliveries("B", 10)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(deliveries)) {
+		assert.Equal(t, DeliveryDelivered, deliveries[0].Status)
+		assert.Equal(t, 204, deliveries[0].StatusCode)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+	}
+	deliveries, _ = repo.GetDeliveries("A", 10)
+	if assert.Equal(t, 2, len(deliveries)) {
+		assert.Equal(t, "updated", deliveries[0].EventType)
+	}
+
+	// pending deliveries are kept
+	assert.NoError(t, repo.DeleteDeliveriesBefore(now.Add(time.Minute)))
+	deliveries, _ = repo.GetDeliveries("B", 10)
+	assert.Equal(t, 0, len(deliveries))
+	deliveries, _ = repo.GetDeliveries("A", 10)
+	assert.Equal(t, 2, len(deliveries))
+}
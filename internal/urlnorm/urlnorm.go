@@ -0,0 +1,77 @@
+// Package urlnorm normalizes URLs to detect bookmarks which refer to the same page
+package urlnorm
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// the ports which are removed for the given schemes
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// isTracking identifies query parameters which only track the origin of a visit
+func isTracking(key string) bool {
+	k := strings.ToLower(key)
+	return strings.HasPrefix(k, "utm_") || k == "fbclid"
+}
+
+// Normalize returns the canonical form of the URL. The scheme and host are lowercased, default ports,
+// trailing slashes, fragments and tracking parameters are removed and the query is sorted
+func Normalize(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("invalid URL '%s': %v", raw, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("the URL '%s' is not absolute", raw)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		// IPv6 addresses are enclosed in brackets
+		host = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+	}
+	u.Host = host
+	u.Fragment = ""
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+
+	query := u.Query()
+	for key := range query {
+		if isTracking(key) {
+			delete(query, key)
+		}
+	}
+	for _, values := range query {
+		sort.Strings(values)
+	}
+	// Encode sorts the parameters by key
+	u.RawQuery = query.Encode()
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
+
+// Equal checks if both URLs have the same normal form
+func Equal(a, b string) bool {
+	na, err := Normalize(a)
+	if err != nil {
+		return false
+	}
+	nb, err := Normalize(b)
+	if err != nil {
+		return false
+	}
+	return na == nb
+}
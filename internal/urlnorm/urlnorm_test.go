@@ -0,0 +1,44 @@
+package urlnorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		url      string
+		expected string
+	}{
+		{"http://Example.COM/", "http://example.com"},
+		{"HTTPS://example.com:443/path/", "https://example.com/path"},
+		{"http://example.com:80/path", "http://example.com/path"},
+		{"http://example.com:8080/path", "http://example.com:8080/path"},
+		{"https://example.com:80/", "https://example.com:80"},
+		{"http://example.com/path#section", "http://example.com/path"},
+		{"http://example.com/?b=2&a=1&a=0", "http://example.com?a=0&a=1&b=2"},
+		{"http://example.com/?utm_source=mail&UTM_Campaign=x&fbclid=abc&id=1", "http://example.com?id=1"},
+		{"http://example.com/?utm_source=mail", "http://example.com"},
+		{"http://[::1]:80/", "http://[::1]"},
+		{"http://[::1]:8080/", "http://[::1]:8080"},
+		{"  http://example.com/Path  ", "http://example.com/Path"},
+		{"http://example.com/a%2Fb/", "http://example.com/a%2Fb"},
+	} {
+		n, err := Normalize(tc.url)
+		if err != nil {
+			t.Errorf("could not normalize '%s': %v", tc.url, err)
+			continue
+		}
+		assert.Equal(t, tc.expected, n, tc.url)
+	}
+
+	for _, invalid := range []string{"", "/relative", "example.com", "http://%zz"} {
+		_, err := Normalize(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	assert.True(t, Equal("http://Example.com/page/?utm_medium=x#top", "http://example.com:80/page"))
+	assert.False(t, Equal("http://example.com/a", "http://example.com/b"))
+	assert.False(t, Equal("", ""))
+}
@@ -0,0 +1,74 @@
+// Package visits ranks bookmarks by the history of their visits and removes old visits
+package visits
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/store"
+)
+
+const (
+	// HalfLife is the age at which a visit counts half as much as a visit right now
+	HalfLife = 30 * 24 * time.Hour
+	// VisitWeight is the score of a visit right now
+	VisitWeight = 100.0
+	// horizon limits the visits used for the ranking, older visits contribute less than 0.1%
+	horizon = 10 * HalfLife
+)
+
+// Ranking is the frecency score of a bookmark
+type Ranking struct {
+	BookmarkID string
+	Score      float64
+	LastVisit  time.Time
+}
+
+// Weight returns the contribution of a visit at the given time to the frecency score. The weight
+// decays exponentially with the age of the visit
+func Weight(visited, now time.Time) float64 {
+	age := now.Sub(visited)
+	if age < 0 {
+		age = 0
+	}
+	return VisitWeight * math.Pow(0.5, float64(age)/float64(HalfLife))
+}
+
+// Frecent returns the bookmarks of the user with the highest frecency. The frecency is the sum of
+// the weights of all visits, frequently visited bookmarks rank high as long as the visits are recent
+func Frecent(repo store.Repository, username string, now time.Time, limit int) ([]Ranking, error) {
+	visits, err := repo.GetUserVisits(username, now.Add(-horizon))
+	if err != nil {
+		return nil, fmt.Errorf("could not get the visits of user '%s': %v", username, err)
+	}
+
+	rankings := make(map[string]*Ranking)
+	for _, v := range visits {
+		r, ok := rankings[v.BookmarkID]
+		if !ok {
+			r = &Ranking{BookmarkID: v.BookmarkID}
+			rankings[v.BookmarkID] = r
+		}
+		r.Score += Weight(v.Visited, now)
+		if v.Visited.After(r.LastVisit) {
+			r.LastVisit = v.Visited
+		}
+	}
+
+	result := make([]Ranking, 0, len(rankings))
+	for _, r := range rankings {
+		result = append(result, *r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].LastVisit.After(result[j].LastVisit)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
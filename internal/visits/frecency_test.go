@@ -0,0 +1,90 @@
+package visits
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// visitRepository keeps the visits in memory
+type visitRepository struct {
+	store.Repository
+	visits []store.Visit
+}
+
+func (r *visitRepository) GetUserVisits(username string, since time.Time) ([]store.Visit, error) {
+	var visits []store.Visit
+	for _, v := range r.visits {
+		if v.UserName == username && !v.Visited.Before(since) {
+			visits = append(visits, v)
+		}
+	}
+	return visits, nil
+}
+
+func (r *visitRepository) DeleteVisitsBefore(t time.Time) error {
+	var visits []store.Visit
+	for _, v := range r.visits {
+		if !v.Visited.Before(t) {
+			visits = append(visits, v)
+		}
+	}
+	r.visits = visits
+	return nil
+}
+
+func visitsAt(id, username string, times ...time.Time) []store.Visit {
+	var visits []store.Visit
+	for _, t := range times {
+		visits = append(visits, store.Visit{BookmarkID: id, UserName: username, Visited: t})
+	}
+	return visits
+}
+
+func TestWeight(t *testing.T) {
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, VisitWeight, Weight(now, now))
+	assert.Equal(t, VisitWeight, Weight(now.Add(time.Hour), now))
+	assert.InDelta(t, VisitWeight/2, Weight(now.Add(-HalfLife), now), 0.0001)
+	assert.InDelta(t, VisitWeight/4, Weight(now.Add(-2*HalfLife), now), 0.0001)
+}
+
+func TestFrecent(t *testing.T) {
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	twoYears := now.Add(-2 * 365 * 24 * time.Hour)
+
+	var old []time.Time
+	for i := 0; i < 500; i++ {
+		old = append(old, twoYears.Add(time.Duration(i)*time.Minute))
+	}
+	var visits []store.Visit
+	visits = append(visits, visitsAt("old", "user", old...)...)
+	visits = append(visits, visitsAt("today", "user", now.Add(-time.Hour))...)
+	visits = append(visits, visitsAt("frequent", "user", now.Add(-time.Hour), now.Add(-2*time.Hour), now.Add(-48*time.Hour))...)
+	visits = append(visits, visitsAt("lastmonth", "user", now.Add(-HalfLife))...)
+	visits = append(visits, visitsAt("foreign", "other", now)...)
+	repo := &visitRepository{visits: visits}
+
+	rankings, err := Frecent(repo, "user", now, 0)
+	if err != nil {
+		t.Fatalf("could not rank the bookmarks: %v", err)
+	}
+	var ids []string
+	for _, r := range rankings {
+		ids = append(ids, r.BookmarkID)
+	}
+	// visits older than the horizon are ignored
+	assert.Equal(t, []string{"frequent", "today", "lastmonth"}, ids)
+	assert.Equal(t, now.Add(-time.Hour), rankings[0].LastVisit)
+	assert.True(t, rankings[0].Score > 2*rankings[1].Score)
+
+	rankings, _ = Frecent(repo, "user", now, 1)
+	assert.Equal(t, 1, len(rankings))
+	assert.Equal(t, "frequent", rankings[0].BookmarkID)
+
+	rankings, _ = Frecent(repo, "unknown", now, 10)
+	assert.Equal(t, 0, len(rankings))
+}
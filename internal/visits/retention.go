@@ -0,0 +1,31 @@
+package visits
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/store"
+)
+
+// Retention is a job which removes the visits older than the retention period
+type Retention struct {
+	Repository store.Repository
+	// Keep is the retention period of the visits, visits are kept forever if not set
+	Keep time.Duration
+}
+
+// Name of the job
+func (r *Retention) Name() string {
+	return "visit-retention"
+}
+
+// Run removes the expired visits
+func (r *Retention) Run() error {
+	if r.Keep <= 0 {
+		return nil
+	}
+	if err := r.Repository.DeleteVisitsBefore(time.Now().UTC().Add(-r.Keep)); err != nil {
+		return fmt.Errorf("could not remove the expired visits: %v", err)
+	}
+	return nil
+}
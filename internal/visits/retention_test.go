@@ -0,0 +1,25 @@
+package visits
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetention(t *testing.T) {
+	now := time.Now().UTC()
+	repo := &visitRepository{visits: visitsAt("id", "user", now.Add(-48*time.Hour), now.Add(-time.Hour))}
+
+	job := &Retention{Repository: repo}
+	assert.Equal(t, "visit-retention", job.Name())
+
+	// without a retention period the visits are kept
+	assert.NoError(t, job.Run())
+	assert.Equal(t, 2, len(repo.visits))
+
+	job.Keep = 24 * time.Hour
+	assert.NoError(t, job.Run())
+	assert.Equal(t, []store.Visit{{BookmarkID: "id", UserName: "user", Visited: now.Add(-time.Hour)}}, repo.visits)
+}
@@ -0,0 +1,181 @@
+// Package webhooks delivers the events of bookmarks to the webhooks of the users. The deliveries are taken from
+// the outbox of the store and are sent again with an increasing delay, until the receiver accepts them
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bihe/bookmarks/internal"
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/bihe/bookmarks/internal/store"
+)
+
+const (
+	// SignatureHeader holds the HMAC-SHA256 of the payload with the secret of the webhook: sha256=<hex>
+	SignatureHeader = "X-Bookmarks-Signature"
+	// EventHeader holds the type of the event
+	EventHeader = "X-Bookmarks-Event"
+	// DeliveryHeader holds the ID of the delivery, it is the same for every attempt
+	DeliveryHeader = "X-Bookmarks-Delivery"
+
+	// DefaultMaxAttempts is the number of attempts before a delivery fails
+	DefaultMaxAttempts = 8
+	// DefaultBatchSize limits the number of deliveries sent in one run
+	DefaultBatchSize = 100
+	// DefaultRetention defines how long the completed deliveries are kept in the delivery log
+	DefaultRetention = 30 * 24 * time.Hour
+
+	// the delay after the first failed attempt, it is doubled with every further attempt
+	initialBackoff = 30 * time.Second
+	maxBackoff     = 6 * time.Hour
+	// the maximum length of the error kept for a delivery
+	maxErrorLength = 1024
+)
+
+// Poster sends the payload to the URL of a webhook
+type Poster interface {
+	Post(url string, header http.Header, body []byte) (*fetch.Response, error)
+}
+
+// Options configures the Dispatcher
+type Options struct {
+	MaxAttempts int
+	BatchSize   int
+	Retention   time.Duration
+}
+
+// Dispatcher is a job which sends the due deliveries of the outbox
+type Dispatcher struct {
+	poster     Poster
+	repository store.Repository
+	opts       Options
+}
+
+// New creates a Dispatcher, default values are used for options which are not set
+func New(poster Poster, repository store.Repository, opts Options) *Dispatcher {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	return &Dispatcher{
+		poster:     poster,
+		repository: repository,
+		opts:       opts,
+	}
+}
+
+// Sign returns the value of the SignatureHeader for the payload
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns the delay before the next attempt after the given number of failed attempts
+func Backoff(attempts int) time.Duration {
+	delay := initialBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+// Name of the job
+func (d *Dispatcher) Name() string {
+	return "webhook-delivery"
+}
+
+// Run sends the due deliveries and removes the expired entries of the delivery log. A failed delivery is
+// scheduled again and does not fail the run
+func (d *Dispatcher) Run() error {
+	now := time.Now().UTC()
+	deliveries, err := d.repository.GetDueDeliveries(now, d.opts.BatchSize)
+	if err != nil {
+		return fmt.Errorf("could not get the due deliveries: %v", err)
+	}
+
+	// the webhooks are looked up once per run, the delivery only belongs to the webhook of the same user
+	webhooks := make(map[string]*store.Webhook)
+	var failed int
+	for _, delivery := range deliveries {
+		key := delivery.UserName + "/" + delivery.WebhookID
+		webhook, ok := webhooks[key]
+		if !ok {
+			if w, err := d.repository.GetWebhookByID(delivery.WebhookID, delivery.UserName); err == nil {
+				webhook = &w
+			}
+			webhooks[key] = webhook
+		}
+		delivery = d.deliver(webhook, delivery, now)
+		if err := d.repository.UpdateDelivery(delivery); err != nil {
+			internal.LogFunction("webhooks.Run").Errorf("could not save the delivery %d: %v", delivery.ID, err)
+			failed++
+		}
+	}
+
+	if err := d.repository.DeleteDeliveriesBefore(now.Add(-d.opts.Retention)); err != nil {
+		return fmt.Errorf("could not remove the expired deliveries: %v", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("could not save %d deliveries", failed)
+	}
+	return nil
+}
+
+// deliver posts the payload to the webhook and returns the delivery with the outcome of the attempt
+func (d *Dispatcher) deliver(webhook *store.Webhook, delivery store.WebhookDelivery, now time.Time) store.WebhookDelivery {
+	if webhook == nil || !webhook.Active {
+		delivery.Status = store.DeliveryFailed
+		delivery.Error = "the webhook is not available or disabled"
+		return delivery
+	}
+
+	payload := []byte(delivery.Payload)
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(SignatureHeader, Sign(webhook.Secret, payload))
+	header.Set(EventHeader, delivery.EventType)
+	header.Set(DeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+
+	delivery.Attempts++
+	delivery.LastAttempt = &now
+	delivery.StatusCode = 0
+	delivery.Error = ""
+	resp, err := d.poster.Post(webhook.URL, header, payload)
+	switch {
+	case err != nil:
+		delivery.Error = err.Error()
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		delivery.StatusCode = resp.StatusCode
+		delivery.Error = fmt.Sprintf("the webhook responded with status %d", resp.StatusCode)
+	default:
+		delivery.StatusCode = resp.StatusCode
+		delivery.Status = store.DeliveryDelivered
+		return delivery
+	}
+
+	if len(delivery.Error) > maxErrorLength {
+		delivery.Error = delivery.Error[:maxErrorLength]
+	}
+	if delivery.Attempts >= d.opts.MaxAttempts {
+		delivery.Status = store.DeliveryFailed
+		internal.LogFunction("webhooks.deliver").Warnf("the delivery %d to webhook '%s' failed after %d attempts: %s", delivery.ID, webhook.ID, delivery.Attempts, delivery.Error)
+		return delivery
+	}
+	delivery.NextAttempt = now.Add(Backoff(delivery.Attempts))
+	return delivery
+}
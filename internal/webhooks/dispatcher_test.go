@@ -0,0 +1,151 @@
+package webhooks
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bihe/bookmarks/internal/fetch"
+	"github.com/bihe/bookmarks/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// outboxRepository holds the webhooks and records the updated deliveries
+type outboxRepository struct {
+	store.Repository
+	webhooks   []store.Webhook
+	due        []store.WebhookDelivery
+	updated    map[uint]store.WebhookDelivery
+	retainedAt time.Time
+}
+
+func (r *outboxRepository) GetDueDeliveries(now time.Time, limit int) ([]store.WebhookDelivery, error) {
+	if len(r.due) > limit {
+		return r.due[:limit], nil
+	}
+	return r.due, nil
+}
+
+func (r *outboxRepository) GetWebhookByID(id, username string) (store.Webhook, error) {
+	for _, w := range r.webhooks {
+		if w.ID == id && w.UserName == username {
+			return w, nil
+		}
+	}
+	return store.Webhook{}, fmt.Errorf("record not found")
+}
+
+func (r *outboxRepository) UpdateDelivery(delivery store.WebhookDelivery) error {
+	r.updated[delivery.ID] = delivery
+	return nil
+}
+
+func (r *outboxRepository) DeleteDeliveriesBefore(t time.Time) error {
+	r.retainedAt = t
+	return nil
+}
+
+type post struct {
+	url    string
+	header http.Header
+	body   string
+}
+
+// recordingPoster answers the posts with the status of the URL
+type recordingPoster struct {
+	status map[string]int
+	posts  []post
+}
+
+func (p *recordingPoster) Post(url string, header http.Header, body []byte) (*fetch.Response, error) {
+	p.posts = append(p.posts, post{url, header, string(body)})
+	status, ok := p.status[url]
+	if !ok {
+		return nil, fmt.Errorf("could not connect to '%s'", url)
+	}
+	return &fetch.Response{URL: url, StatusCode: status}, nil
+}
+
+func TestSign(t *testing.T) {
+	// the signature of the HMAC-SHA256 test vector of RFC 4231 (test case 2)
+	assert.Equal(t, "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		Sign("Jefe", []byte("what do ya want for nothing?")))
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, time.Minute, Backoff(2))
+	assert.Equal(t, 4*time.Minute, Backoff(4))
+	assert.Equal(t, 6*time.Hour, Backoff(20))
+}
+
+func TestDispatcher(t *testing.T) {
+	repo := &outboxRepository{
+		webhooks: []store.Webhook{
+			{ID: "ok", UserName: "user", URL: "https://example.com/ok", Secret: "secret", Active: true},
+			{ID: "error", UserName: "user", URL: "https://example.com/error", Secret: "secret", Active: true},
+			{ID: "down", UserName: "user", URL: "https://down.example.com", Active: true},
+			{ID: "disabled", UserName: "user", URL: "https://example.com/ok", Active: false},
+		},
+		due: []store.WebhookDelivery{
+			{ID: 1, WebhookID: "ok", UserName: "user", EventType: "created", Payload: `{"id":"1"}`},
+			{ID: 2, WebhookID: "error", UserName: "user", EventType: "deleted", Payload: `{}`, Attempts: 2},
+			{ID: 3, WebhookID: "down", UserName: "user", EventType: "updated", Payload: `{}`, Attempts: 7},
+			{ID: 4, WebhookID: "disabled", UserName: "user", EventType: "updated", Payload: `{}`},
+			{ID: 5, WebhookID: "ok", UserName: "other", EventType: "updated", Payload: `{}`},
+		},
+		updated: make(map[uint]store.WebhookDelivery),
+	}
+	for i := range repo.due {
+		repo.due[i].Status = store.DeliveryPending
+	}
+	poster := &recordingPoster{status: map[string]int{
+		"https://example.com/ok":    http.StatusNoContent,
+		"https://example.com/error": http.StatusInternalServerError,
+	}}
+	job := New(poster, repo, Options{})
+	assert.Equal(t, "webhook-delivery", job.Name())
+
+	assert.NoError(t, job.Run())
+	assert.WithinDuration(t, time.Now().UTC().Add(-DefaultRetention), repo.retainedAt, time.Minute)
+
+	if assert.Equal(t, 3, len(poster.posts)) {
+		p := poster.posts[0]
+		assert.Equal(t, "https://example.com/ok", p.url)
+		assert.Equal(t, `{"id":"1"}`, p.body)
+		assert.Equal(t, Sign("secret", []byte(`{"id":"1"}`)), p.header.Get(SignatureHeader))
+		assert.Equal(t, "created", p.header.Get(EventHeader))
+		assert.Equal(t, "1", p.header.Get(DeliveryHeader))
+		assert.Equal(t, "application/json", p.header.Get("Content-Type"))
+	}
+
+	delivered := repo.updated[1]
+	assert.Equal(t, store.DeliveryDelivered, delivered.Status)
+	assert.Equal(t, http.StatusNoContent, delivered.StatusCode)
+	assert.Equal(t, 1, delivered.Attempts)
+	assert.NotNil(t, delivered.LastAttempt)
+
+	retried := repo.updated[2]
+	assert.Equal(t, store.DeliveryPending, retried.Status)
+	assert.Equal(t, 3, retried.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, retried.StatusCode)
+	assert.Contains(t, retried.Error, "500")
+	assert.WithinDuration(t, time.Now().UTC().Add(Backoff(3)), retried.NextAttempt, time.Minute)
+
+	// the last attempt fails the delivery
+	failed := repo.updated[3]
+	assert.Equal(t, store.DeliveryFailed, failed.Status)
+	assert.Equal(t, 8, failed.Attempts)
+	assert.Contains(t, failed.Error, "could not connect")
+
+	// the deliveries of disabled or unknown webhooks are not sent
+	assert.Equal(t, store.DeliveryFailed, repo.updated[4].Status)
+	assert.Equal(t, 0, repo.updated[4].Attempts)
+	assert.Equal(t, store.DeliveryFailed, repo.updated[5].Status)
+
+	// the batch size limits the deliveries of a run
+	poster.posts = nil
+	assert.NoError(t, New(poster, repo, Options{BatchSize: 1}).Run())
+	assert.Equal(t, 1, len(poster.posts))
+}